@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -19,10 +20,54 @@ const (
 	rowBuildLogPath = "sd-artifacts/builds.log"
 )
 
+const (
+	// TextFormat writes "step: message", which is the default
+	TextFormat = "text"
+	// JSONFormat writes a normalized JSON object per line for tooling
+	JSONFormat = "json"
+	// TimestampedFormat writes the text format prefixed with the time of the line
+	TimestampedFormat = "timestamped"
+)
+
+// Formats is the supported formats of the build log
+var Formats = []string{TextFormat, JSONFormat, TimestampedFormat}
+
+// Options decides how the build log is written
+type Options struct {
+	// Format is one of Formats. The empty format means TextFormat.
+	Format string
+	// Elapsed prefixes the elapsed time since the first line instead of the wall-clock time in TimestampedFormat
+	Elapsed bool
+	// JobName is added to the lines in JSONFormat when it is not empty
+	JobName string
+}
+
+// ValidateFormat returns an error when the format is not supported
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported log format `%s`, must be one of %v", format, Formats)
+}
+
+// Error is returned when the build log cannot be read
+type Error struct {
+	Err error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
 // Logger outputs logs
 type Logger interface {
 	Run()
 	Stop()
+	// Steps returns the steps seen in the log so far
+	Steps() []Step
 }
 
 type log struct {
@@ -32,6 +77,10 @@ type log struct {
 	cancel         context.CancelFunc
 	done           chan<- struct{}
 	currentLineNum int
+	options        Options
+	startTime      time.Time
+	mutex          sync.Mutex
+	tracker        stepTracker
 }
 
 type logLine struct {
@@ -41,21 +90,30 @@ type logLine struct {
 	StepName string `json:"s"`
 }
 
+type jsonLine struct {
+	Time    time.Time `json:"time"`
+	Line    int       `json:"line"`
+	Job     string    `json:"job,omitempty"`
+	Step    string    `json:"step"`
+	Message string    `json:"message"`
+}
+
 type parseError struct{}
 
 func (e *parseError) Error() string { return "Parse Error" }
 
 // New creates new Logger interface.
-func New(filepath string, writer io.Writer, done chan<- struct{}) (Logger, error) {
+func New(filepath string, writer io.Writer, done chan<- struct{}, options Options) (Logger, error) {
 	log := log{
-		writer: writer,
-		done:   done,
+		writer:  writer,
+		done:    done,
+		options: options,
 	}
 
 	var err error
 	log.file, err = os.OpenFile(filepath, os.O_RDONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
-		return &log, fmt.Errorf("failed to open raw build log file: %w", err)
+		return &log, &Error{Err: fmt.Errorf("failed to open raw build log file: %w", err)}
 	}
 
 	log.ctx, log.cancel = context.WithCancel(context.Background())
@@ -67,6 +125,13 @@ func (l *log) Stop() {
 	l.cancel()
 }
 
+func (l *log) Steps() []Step {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return append([]Step{}, l.tracker.steps...)
+}
+
 func (l *log) Run() {
 	reader := bufio.NewReader(l.file)
 	buildDone := false
@@ -125,22 +190,75 @@ func (l *log) output(reader *bufio.Reader) (bool, error) {
 		return false, fmt.Errorf("failed to read logfile: %w", err)
 	}
 
-	parsedLog, err := parse(line)
+	ll, err := parse(line)
 	if err != nil {
 		logrus.Warnf("\x1b[33mParsed error. If you want to check see %s:%d \x1b[0m", rowBuildLogPath, l.currentLineNum)
 		return false, &parseError{}
 	}
 
-	fmt.Fprintf(l.writer, "%s\r\n", parsedLog)
+	l.mutex.Lock()
+	l.tracker.track(ll)
+	l.mutex.Unlock()
+
+	formatted, err := l.format(ll)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprint(l.writer, formatted)
 	return false, nil
 }
 
-func parse(rawLog []byte) (string, error) {
+// format returns the line written in the format of the options
+func (l *log) format(ll *logLine) (string, error) {
+	t := time.UnixMilli(ll.Time)
+
+	switch l.options.Format {
+	case JSONFormat:
+		j, err := json.Marshal(jsonLine{
+			Time:    t.UTC(),
+			Line:    ll.Line,
+			Job:     l.options.JobName,
+			Step:    ll.StepName,
+			Message: ll.Message,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to format log: %w", err)
+		}
+		return string(j) + "\n", nil
+	case TimestampedFormat:
+		if l.startTime.IsZero() {
+			l.startTime = t
+		}
+		return fmt.Sprintf("[%s] %s: %s\r\n", l.timestamp(t), ll.StepName, ll.Message), nil
+	default:
+		return fmt.Sprintf("%s: %s\r\n", ll.StepName, ll.Message), nil
+	}
+}
+
+func (l *log) timestamp(t time.Time) string {
+	if !l.options.Elapsed {
+		return t.Format("15:04:05.000")
+	}
+
+	elapsed := t.Sub(l.startTime)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		int(elapsed.Hours()),
+		int(elapsed.Minutes())%60,
+		int(elapsed.Seconds())%60,
+		elapsed.Milliseconds()%1000)
+}
+
+func parse(rawLog []byte) (*logLine, error) {
 	ll := &logLine{}
 	err := json.Unmarshal(rawLog, ll)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse raw log: %w", err)
+		return nil, fmt.Errorf("failed to parse raw log: %w", err)
 	}
 
-	return fmt.Sprintf("%s: %s", ll.StepName, ll.Message), nil
+	return ll, nil
 }
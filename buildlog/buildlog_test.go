@@ -81,6 +81,7 @@ func TestRun(t *testing.T) {
 		case <-done:
 			expected := "main: test 1\r\nmain: test 2\r\n"
 			assert.Equal(t, expected, writer.String())
+			assert.Equal(t, []Step{{Name: "main", StartTime: time.UnixMilli(1581662022394), EndTime: time.UnixMilli(1581662022395)}}, l.Steps())
 		case <-timeout:
 			assert.Fail(t, "timeout stop buildlog")
 		}
@@ -217,7 +218,7 @@ func TestNew(t *testing.T) {
 		writer := bytes.NewBuffer(nil)
 
 		loggerDone := make(chan struct{})
-		logger, err := New(tmpFile.Name(), writer, loggerDone)
+		logger, err := New(tmpFile.Name(), writer, loggerDone, Options{Format: JSONFormat})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -234,13 +235,14 @@ func TestNew(t *testing.T) {
 
 		assert.Equal(t, tmpFile.Name(), file.Name())
 		assert.Equal(t, writer, log.writer)
+		assert.Equal(t, Options{Format: JSONFormat}, log.options)
 	})
 
 	t.Run("failure", func(t *testing.T) {
 		writer := bytes.NewBuffer(nil)
 
 		loggerDone := make(chan struct{})
-		logger, err := New("/", writer, loggerDone)
+		logger, err := New("/", writer, loggerDone, Options{})
 		if err == nil {
 			t.Fatal("failure err is nil")
 		}
@@ -256,3 +258,72 @@ func TestNew(t *testing.T) {
 		assert.Equal(t, 0, strings.Index(msg, "failed to open raw build log file: "), fmt.Sprintf("expected error is `failed to open raw build log file: ...`, actual: `%v`", msg))
 	})
 }
+
+func TestFormat(t *testing.T) {
+	lines := []*logLine{
+		{Time: 1581662022394, Message: "test 1", Line: 0, StepName: "main"},
+		{Time: 1581662085400, Message: "test 2", Line: 1, StepName: "main"},
+	}
+	wall := func(ms int64) string {
+		return time.UnixMilli(ms).Format("15:04:05.000")
+	}
+
+	testCases := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{
+			name:    "default format",
+			options: Options{},
+			want:    "main: test 1\r\nmain: test 2\r\n",
+		},
+		{
+			name:    "text format",
+			options: Options{Format: TextFormat},
+			want:    "main: test 1\r\nmain: test 2\r\n",
+		},
+		{
+			name:    "json format",
+			options: Options{Format: JSONFormat, JobName: "test"},
+			want: `{"time":"2020-02-14T06:33:42.394Z","line":0,"job":"test","step":"main","message":"test 1"}` + "\n" +
+				`{"time":"2020-02-14T06:34:45.4Z","line":1,"job":"test","step":"main","message":"test 2"}` + "\n",
+		},
+		{
+			name:    "json format without job name",
+			options: Options{Format: JSONFormat},
+			want: `{"time":"2020-02-14T06:33:42.394Z","line":0,"step":"main","message":"test 1"}` + "\n" +
+				`{"time":"2020-02-14T06:34:45.4Z","line":1,"step":"main","message":"test 2"}` + "\n",
+		},
+		{
+			name:    "timestamped format",
+			options: Options{Format: TimestampedFormat},
+			want:    fmt.Sprintf("[%s] main: test 1\r\n[%s] main: test 2\r\n", wall(1581662022394), wall(1581662085400)),
+		},
+		{
+			name:    "timestamped format with elapsed time",
+			options: Options{Format: TimestampedFormat, Elapsed: true},
+			want:    "[00:00:00.000] main: test 1\r\n[00:01:03.006] main: test 2\r\n",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			l := log{options: tt.options}
+			got := ""
+			for _, ll := range lines {
+				formatted, err := l.format(ll)
+				assert.Nil(t, err)
+				got += formatted
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, f := range Formats {
+		assert.Nil(t, ValidateFormat(f))
+	}
+	assert.EqualError(t, ValidateFormat("xml"), "unsupported log format `xml`, must be one of [text json timestamped]")
+}
@@ -0,0 +1,68 @@
+package buildlog
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+type prefixWriter struct {
+	writer io.Writer
+	prefix []byte
+	mutex  *sync.Mutex
+	buf    []byte
+}
+
+// NewPrefixWriter returns a writer which writes each line to w with the prefix.
+// Writers sharing the same mutex never interleave their lines, so that the logs of builds running in parallel can be multiplexed.
+// Close writes the rest of the output which does not end with a newline as a line.
+func NewPrefixWriter(w io.Writer, prefix string, mutex *sync.Mutex) io.WriteCloser {
+	return &prefixWriter{
+		writer: w,
+		prefix: []byte(prefix),
+		mutex:  mutex,
+	}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := make([]byte, 0, len(p.prefix)+i+1)
+		line = append(line, p.prefix...)
+		line = append(line, p.buf[:i+1]...)
+		p.buf = p.buf[i+1:]
+
+		p.mutex.Lock()
+		_, err := p.writer.Write(line)
+		p.mutex.Unlock()
+		if err != nil {
+			return len(b), err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (p *prefixWriter) Close() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	line := make([]byte, 0, len(p.prefix)+len(p.buf)+1)
+	line = append(line, p.prefix...)
+	line = append(line, p.buf...)
+	line = append(line, '\n')
+	p.buf = nil
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, err := p.writer.Write(line)
+
+	return err
+}
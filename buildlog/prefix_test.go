@@ -0,0 +1,66 @@
+package buildlog
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		inputs     []string
+		want       string
+		wantClosed string
+	}{
+		{"a line", []string{"main: test\r\n"}, "[lint] main: test\r\n", "[lint] main: test\r\n"},
+		{"lines in a write", []string{"main: test 1\nmain: test 2\n"}, "[lint] main: test 1\n[lint] main: test 2\n", "[lint] main: test 1\n[lint] main: test 2\n"},
+		{"a line in writes", []string{"main: ", "test\n"}, "[lint] main: test\n", "[lint] main: test\n"},
+		{"an incomplete line", []string{"main: test"}, "", "[lint] main: test\n"},
+		{"an incomplete last line", []string{"main: test 1\nmain: test 2"}, "[lint] main: test 1\n", "[lint] main: test 1\n[lint] main: test 2\n"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			w := NewPrefixWriter(buf, "[lint] ", &sync.Mutex{})
+			for _, input := range tt.inputs {
+				n, err := w.Write([]byte(input))
+				assert.Nil(t, err)
+				assert.Equal(t, len(input), n)
+			}
+			assert.Equal(t, tt.want, buf.String())
+
+			assert.Nil(t, w.Close())
+			assert.Equal(t, tt.wantClosed, buf.String())
+		})
+	}
+
+	t.Run("writers sharing a mutex", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		mutex := &sync.Mutex{}
+		lint := NewPrefixWriter(buf, "[lint] ", mutex)
+		test := NewPrefixWriter(buf, "[test] ", mutex)
+
+		var wg sync.WaitGroup
+		for _, w := range []io.Writer{lint, test} {
+			wg.Add(1)
+			go func(w io.Writer) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					_, _ = w.Write([]byte("main: test\n"))
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+		assert.Len(t, lines, 200)
+		for _, line := range lines {
+			assert.Regexp(t, `^\[(lint|test)\] main: test$`, string(line))
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package buildlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Filter selects the lines of the build log to replay
+type Filter struct {
+	// Steps is the glob patterns of the step names. All steps are selected when it is empty.
+	Steps []string
+	// Grep selects the lines whose message matches it when it is not nil
+	Grep *regexp.Regexp
+	// Tail selects only the last lines of the log when it is positive
+	Tail int
+}
+
+// Validate returns an error when the filter is invalid
+func (f Filter) Validate() error {
+	for _, s := range f.Steps {
+		if _, err := path.Match(s, ""); err != nil {
+			return fmt.Errorf("invalid step name `%s`: %v", s, err)
+		}
+	}
+
+	if f.Tail < 0 {
+		return fmt.Errorf("invalid tail `%d`, must be 0 or more", f.Tail)
+	}
+
+	return nil
+}
+
+func (f Filter) match(ll *logLine) bool {
+	if f.Grep != nil && !f.Grep.MatchString(ll.Message) {
+		return false
+	}
+
+	if len(f.Steps) == 0 {
+		return true
+	}
+	for _, s := range f.Steps {
+		if ok, _ := path.Match(s, ll.StepName); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Replay writes the raw build log read from r to w in the format of the options, as a running build does.
+// When follow is true, Replay keeps writing the lines appended to r until ctx is done.
+func Replay(ctx context.Context, r io.Reader, w io.Writer, options Options, filter Filter, follow bool) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	l := &log{writer: w, options: options}
+	reader := bufio.NewReader(r)
+	// The lines are held until the end of the current log is read to write only the last ones
+	tailing := filter.Tail > 0
+	tail := make([]*logLine, 0, filter.Tail)
+	pending := []byte{}
+
+	write := func(ll *logLine) error {
+		formatted, err := l.format(ll)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, formatted)
+		return nil
+	}
+
+	for {
+		b, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read logfile: %w", err)
+		}
+		eof := err == io.EOF
+		pending = append(pending, b...)
+
+		// A line without the newline may still be written by the build
+		if (!eof || !follow) && len(bytes.TrimSpace(pending)) > 0 {
+			l.currentLineNum++
+			ll, err := parse(bytes.TrimSpace(pending))
+			pending = []byte{}
+			if err != nil {
+				logrus.Warnf("\x1b[33mParsed error at line %d of the raw build log\x1b[0m", l.currentLineNum)
+				continue
+			}
+
+			// The elapsed time is relative to the start of the build even if the first lines are filtered out
+			if l.startTime.IsZero() {
+				l.startTime = time.UnixMilli(ll.Time)
+			}
+
+			if !filter.match(ll) {
+				continue
+			}
+
+			if tailing {
+				if len(tail) == filter.Tail {
+					tail = tail[1:]
+				}
+				tail = append(tail, ll)
+				continue
+			}
+
+			if err := write(ll); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !eof {
+			continue
+		}
+
+		if tailing {
+			tailing = false
+			for _, ll := range tail {
+				if err := write(ll); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(readInterval):
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package buildlog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var replayInputs = []string{
+	`{"t": 1581662022394, "m": "install", "n": 0, "s": "sd-setup-init"}` + "\n",
+	`{"t": 1581662022500, "m": "npm install", "n": 1, "s": "install"}` + "\n",
+	`{"t": 1581662023394, "m": "npm test", "n": 2, "s": "test"}` + "\n",
+	`{"t": 1581662024394, "m": "1 passing", "n": 3, "s": "test"}` + "\n",
+	`{"t": 1581662025394, "m": "2 passing", "n": 4, "s": "test"}` + "\n",
+}
+
+func TestReplay(t *testing.T) {
+	testCases := []struct {
+		name      string
+		inputs    []string
+		options   Options
+		filter    Filter
+		want      string
+		wantError string
+	}{
+		{
+			name:   "success",
+			inputs: replayInputs,
+			want:   "sd-setup-init: install\r\ninstall: npm install\r\ntest: npm test\r\ntest: 1 passing\r\ntest: 2 passing\r\n",
+		},
+		{
+			name:   "success with steps",
+			inputs: replayInputs,
+			filter: Filter{Steps: []string{"sd-*", "install"}},
+			want:   "sd-setup-init: install\r\ninstall: npm install\r\n",
+		},
+		{
+			name:   "success with grep",
+			inputs: replayInputs,
+			filter: Filter{Grep: regexp.MustCompile("install$")},
+			want:   "sd-setup-init: install\r\ninstall: npm install\r\n",
+		},
+		{
+			name:   "success with tail",
+			inputs: replayInputs,
+			filter: Filter{Tail: 2},
+			want:   "test: 1 passing\r\ntest: 2 passing\r\n",
+		},
+		{
+			name:   "success with tail more than lines",
+			inputs: replayInputs,
+			filter: Filter{Steps: []string{"install"}, Tail: 2},
+			want:   "install: npm install\r\n",
+		},
+		{
+			name:    "success with elapsed time from the start of the build",
+			inputs:  replayInputs,
+			options: Options{Format: TimestampedFormat, Elapsed: true},
+			filter:  Filter{Steps: []string{"test"}, Tail: 1},
+			want:    "[00:00:03.000] test: 2 passing\r\n",
+		},
+		{
+			name:    "success with json format",
+			inputs:  replayInputs[:1],
+			options: Options{Format: JSONFormat, JobName: "main"},
+			want:    `{"time":"2020-02-14T06:33:42.394Z","line":0,"job":"main","step":"sd-setup-init","message":"install"}` + "\n",
+		},
+		{
+			name:   "success with broken lines and the last line without newline",
+			inputs: []string{"broken\n", "\n", strings.TrimSuffix(replayInputs[1], "\n")},
+			want:   "install: npm install\r\n",
+		},
+		{
+			name:      "failure by invalid step",
+			inputs:    replayInputs,
+			filter:    Filter{Steps: []string{"["}},
+			wantError: "invalid step name `[`: syntax error in pattern",
+		},
+		{
+			name:      "failure by negative tail",
+			inputs:    replayInputs,
+			filter:    Filter{Tail: -1},
+			wantError: "invalid tail `-1`, must be 0 or more",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			err := Replay(context.Background(), strings.NewReader(strings.Join(tt.inputs, "")), buf, tt.options, tt.filter, false)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestReplayFollow(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "builds.log")
+	if err := os.WriteFile(logPath, []byte(strings.Join(replayInputs[:3], "")), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	buf := &syncBuffer{}
+	finished := make(chan error)
+	go func() {
+		finished <- Replay(ctx, file, buf, Options{}, Filter{Steps: []string{"test"}, Tail: 1}, true)
+	}()
+
+	assert.Eventually(t, func() bool { return buf.String() == "test: npm test\r\n" }, 5*time.Second, 10*time.Millisecond)
+
+	// The line is written in two parts as a running build may do
+	last := replayInputs[3]
+	chErr := make(chan error, 1)
+	write(t, logPath, []string{last[:10]}, chErr)
+	time.Sleep(10 * readInterval)
+	write(t, logPath, []string{last[10:]}, chErr)
+
+	assert.Eventually(t, func() bool { return buf.String() == "test: npm test\r\ntest: 1 passing\r\n" }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-finished:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timeout stop replay")
+	}
+	assert.Empty(t, chErr)
+}
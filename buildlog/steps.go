@@ -0,0 +1,124 @@
+package buildlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// StepsFile is the file name of the step results written in the artifacts directory
+	StepsFile = "steps.json"
+
+	// StepSuccess is the status of a step which finished successfully
+	StepSuccess = "SUCCESS"
+	// StepFailure is the status of the step which the build failed in
+	StepFailure = "FAILURE"
+)
+
+// Step is a step seen in the build log. EndTime is the start of the next step, or the last line of the step.
+type Step struct {
+	Name      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// StepResult is the result of a step of the build
+type StepResult struct {
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	// Duration is in seconds
+	Duration float64 `json:"duration"`
+	Status   string  `json:"status"`
+	// ExitCode is nil when the exit code of the failed step is unknown
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+// stepTracker records the step transitions from the "s" field of the log lines
+type stepTracker struct {
+	steps []Step
+}
+
+func (s *stepTracker) track(ll *logLine) {
+	t := time.UnixMilli(ll.Time)
+
+	if n := len(s.steps); n > 0 && s.steps[n-1].Name == ll.StepName {
+		if t.After(s.steps[n-1].EndTime) {
+			s.steps[n-1].EndTime = t
+		}
+		return
+	}
+
+	if n := len(s.steps); n > 0 && t.After(s.steps[n-1].EndTime) {
+		s.steps[n-1].EndTime = t
+	}
+
+	s.steps = append(s.steps, Step{Name: ll.StepName, StartTime: t, EndTime: t})
+}
+
+// Summarize returns the results of the steps.
+// The last step ends at endTime, and it is failed with the exit code when buildErr is not nil.
+// The exit code is ignored when it is negative.
+func Summarize(steps []Step, endTime time.Time, buildErr error, exitCode int) []StepResult {
+	results := make([]StepResult, 0, len(steps))
+	for i, step := range steps {
+		result := StepResult{
+			Name:      step.Name,
+			StartTime: step.StartTime,
+			EndTime:   step.EndTime,
+			Status:    StepSuccess,
+		}
+
+		last := i == len(steps)-1
+		if last && endTime.After(result.EndTime) {
+			result.EndTime = endTime
+		}
+
+		code := 0
+		if last && buildErr != nil {
+			result.Status = StepFailure
+			code = exitCode
+		}
+		if code >= 0 {
+			result.ExitCode = &code
+		}
+
+		result.Duration = result.EndTime.Sub(result.StartTime).Round(time.Millisecond).Seconds()
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// PrintSteps prints the table of the step results
+func PrintSteps(w io.Writer, results []StepResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTART\tDURATION\tSTATUS\tEXIT CODE")
+	for _, r := range results {
+		code := "-"
+		if r.ExitCode != nil {
+			code = fmt.Sprint(*r.ExitCode)
+		}
+		duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Second)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.StartTime.Format("15:04:05"), duration, r.Status, code)
+	}
+	tw.Flush()
+}
+
+// WriteSteps writes the step results as JSON to the file
+func WriteSteps(filePath string, results []StepResult) error {
+	stepsJSON, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write step results: %w", err)
+	}
+
+	if err := os.WriteFile(filePath, stepsJSON, 0666); err != nil {
+		return fmt.Errorf("failed to write step results: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package buildlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepTracker(t *testing.T) {
+	tracker := stepTracker{}
+	for _, ll := range []*logLine{
+		{Time: 1581662022000, StepName: "sd-setup-launcher"},
+		{Time: 1581662023000, StepName: "sd-setup-launcher"},
+		{Time: 1581662025000, StepName: "install"},
+		{Time: 1581662030000, StepName: "install"},
+		{Time: 1581662031000, StepName: "test"},
+	} {
+		tracker.track(ll)
+	}
+
+	assert.Equal(t, []Step{
+		{Name: "sd-setup-launcher", StartTime: time.UnixMilli(1581662022000), EndTime: time.UnixMilli(1581662025000)},
+		{Name: "install", StartTime: time.UnixMilli(1581662025000), EndTime: time.UnixMilli(1581662031000)},
+		{Name: "test", StartTime: time.UnixMilli(1581662031000), EndTime: time.UnixMilli(1581662031000)},
+	}, tracker.steps)
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.UnixMilli(1581662022000)
+	steps := []Step{
+		{Name: "install", StartTime: start, EndTime: start.Add(3 * time.Second)},
+		{Name: "test", StartTime: start.Add(3 * time.Second), EndTime: start.Add(4 * time.Second)},
+	}
+	zero, two := 0, 2
+
+	testCases := []struct {
+		name     string
+		buildErr error
+		exitCode int
+		want     []StepResult
+	}{
+		{
+			name: "success",
+			want: []StepResult{
+				{Name: "install", StartTime: start, EndTime: start.Add(3 * time.Second), Duration: 3, Status: StepSuccess, ExitCode: &zero},
+				{Name: "test", StartTime: start.Add(3 * time.Second), EndTime: start.Add(10 * time.Second), Duration: 7, Status: StepSuccess, ExitCode: &zero},
+			},
+		},
+		{
+			name:     "failure with exit code",
+			buildErr: errors.New("exit status 2"),
+			exitCode: 2,
+			want: []StepResult{
+				{Name: "install", StartTime: start, EndTime: start.Add(3 * time.Second), Duration: 3, Status: StepSuccess, ExitCode: &zero},
+				{Name: "test", StartTime: start.Add(3 * time.Second), EndTime: start.Add(10 * time.Second), Duration: 7, Status: StepFailure, ExitCode: &two},
+			},
+		},
+		{
+			name:     "failure with unknown exit code",
+			buildErr: errors.New("failed"),
+			exitCode: -1,
+			want: []StepResult{
+				{Name: "install", StartTime: start, EndTime: start.Add(3 * time.Second), Duration: 3, Status: StepSuccess, ExitCode: &zero},
+				{Name: "test", StartTime: start.Add(3 * time.Second), EndTime: start.Add(10 * time.Second), Duration: 7, Status: StepFailure},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summarize(steps, start.Add(10*time.Second), tt.buildErr, tt.exitCode)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPrintSteps(t *testing.T) {
+	start := time.UnixMilli(1581662022000)
+	zero := 0
+	results := []StepResult{
+		{Name: "install", StartTime: start, Duration: 3.4, Status: StepSuccess, ExitCode: &zero},
+		{Name: "test", StartTime: start.Add(3 * time.Second), Duration: 65, Status: StepFailure},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	PrintSteps(buf, results)
+
+	want := "STEP      START      DURATION   STATUS    EXIT CODE\n" +
+		"install   " + start.Format("15:04:05") + "   3s         SUCCESS   0\n" +
+		"test      " + start.Add(3*time.Second).Format("15:04:05") + "   1m5s       FAILURE   -\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestWriteSteps(t *testing.T) {
+	start := time.UnixMilli(1581662022000).UTC()
+	one := 1
+	filePath := filepath.Join(t.TempDir(), StepsFile)
+
+	err := WriteSteps(filePath, []StepResult{
+		{Name: "test", StartTime: start, EndTime: start.Add(time.Second), Duration: 1, Status: StepFailure, ExitCode: &one},
+	})
+	assert.Nil(t, err)
+
+	got, err := os.ReadFile(filePath)
+	assert.Nil(t, err)
+	want := `[
+  {
+    "name": "test",
+    "startTime": "2020-02-14T06:33:42Z",
+    "endTime": "2020-02-14T06:33:43Z",
+    "duration": 1,
+    "status": "FAILURE",
+    "exitCode": 1
+  }
+]`
+	assert.Equal(t, want, string(got))
+
+	err = WriteSteps(filepath.Join(t.TempDir(), "notExist", StepsFile), nil)
+	assert.NotNil(t, err)
+}
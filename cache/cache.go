@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PipelineScope is the scope of the cache shared by all jobs of the pipeline
+	PipelineScope = "pipeline"
+	// EventScope is the scope of the cache shared by the jobs of a single sd-local run
+	EventScope = "event"
+	// JobScope is the scope of the cache kept for each job
+	JobScope = "job"
+
+	dataDir  = "data"
+	pathFile = "path"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// Store manages the cached directories under the base directory.
+// The directories are laid out as <pipeline>/pipeline/<entry>, <pipeline>/event/<event>/<entry> and <pipeline>/job/<job>/<entry>.
+type Store struct {
+	baseDir string
+}
+
+// Entry is a cached directory
+type Entry struct {
+	Pipeline string
+	Scope    string
+	// Name is the job or event name, empty for the pipeline scope
+	Name string
+	// Path is the path in the build container
+	Path string
+	// Dir is the directory on the host
+	Dir  string
+	Size int64
+}
+
+// New returns the store of the cache in the base directory
+func New(baseDir string) *Store {
+	return &Store{baseDir: baseDir}
+}
+
+func shortHash(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+func sanitize(name string) string {
+	return invalidNameChars.ReplaceAllString(name, "-")
+}
+
+// PipelineKey returns the name identifying the pipeline of the source directory in the store
+func PipelineKey(srcPath string) string {
+	return sanitize(filepath.Base(srcPath)) + "-" + shortHash(srcPath)
+}
+
+func entryName(containerPath string) string {
+	return sanitize(path.Base(containerPath)) + "-" + shortHash(containerPath)
+}
+
+func (s *Store) scopeDir(pipeline, scope, name string) string {
+	if scope == PipelineScope {
+		return filepath.Join(s.baseDir, pipeline, scope)
+	}
+	return filepath.Join(s.baseDir, pipeline, scope, name)
+}
+
+// prepare creates the directory of the entry and records its path in the build container
+func prepare(dir, containerPath string) (string, error) {
+	data := filepath.Join(dir, dataDir)
+	if err := os.MkdirAll(data, 0700); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	// The cache directory created by the older version is open to everyone
+	if err := os.Chmod(data, 0700); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, pathFile), []byte(containerPath), 0600); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	return data, nil
+}
+
+// Volumes creates the cache directories of the job and returns the volumes to mount them as "<host dir>:<container path>".
+// containerPath resolves the paths declared in screwdriver.yaml in the build container.
+// When the same path is declared in several scopes, the job scope wins over the event scope and the event scope over the pipeline scope.
+func (s *Store) Volumes(pipeline, event, job string, c *screwdriver.Cache, containerPath func(string) (string, error)) ([]string, error) {
+	if c.Empty() {
+		return nil, nil
+	}
+
+	scopes := []struct {
+		scope string
+		name  string
+		paths []string
+	}{
+		{scope: JobScope, name: job, paths: c.Job},
+		{scope: EventScope, name: event, paths: c.Event},
+		{scope: PipelineScope, paths: c.Pipeline},
+	}
+
+	volumes := make([]string, 0)
+	mounted := make(map[string]string)
+	for _, sc := range scopes {
+		for _, p := range sc.paths {
+			cp, err := containerPath(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cache path: %v", err)
+			}
+
+			if scope, ok := mounted[cp]; ok {
+				logrus.Warnf("%s cache `%s` is ignored, it is already cached in the %s scope", sc.scope, p, scope)
+				continue
+			}
+
+			data, err := prepare(filepath.Join(s.scopeDir(pipeline, sc.scope, sc.name), entryName(cp)), cp)
+			if err != nil {
+				return nil, err
+			}
+
+			mounted[cp] = sc.scope
+			volumes = append(volumes, data+":"+cp)
+		}
+	}
+
+	return volumes, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+func readEntries(entryDirs []string, pipeline, scope, name string) ([]Entry, error) {
+	entries := make([]Entry, 0, len(entryDirs))
+	for _, dir := range entryDirs {
+		containerPath, err := os.ReadFile(filepath.Join(dir, pathFile))
+		if err != nil {
+			// Not a cache entry
+			continue
+		}
+
+		data := filepath.Join(dir, dataDir)
+		size, err := dirSize(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache: %v", err)
+		}
+
+		entries = append(entries, Entry{
+			Pipeline: pipeline,
+			Scope:    scope,
+			Name:     name,
+			Path:     strings.TrimSpace(string(containerPath)),
+			Dir:      data,
+			Size:     size,
+		})
+	}
+
+	return entries, nil
+}
+
+func subDirs(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache: %v", err)
+	}
+
+	dirs := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, f.Name()))
+		}
+	}
+
+	return dirs, nil
+}
+
+// List returns the cached directories sorted by pipeline, scope, name and path
+func (s *Store) List() ([]Entry, error) {
+	pipelines, err := subDirs(s.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Entry, 0)
+	for _, p := range pipelines {
+		pipeline := filepath.Base(p)
+
+		dirs, err := subDirs(filepath.Join(p, PipelineScope))
+		if err != nil {
+			return nil, err
+		}
+		entries, err := readEntries(dirs, pipeline, PipelineScope, "")
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, entries...)
+
+		for _, scope := range []string{EventScope, JobScope} {
+			names, err := subDirs(filepath.Join(p, scope))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, n := range names {
+				dirs, err := subDirs(n)
+				if err != nil {
+					return nil, err
+				}
+				entries, err := readEntries(dirs, pipeline, scope, filepath.Base(n))
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, entries...)
+			}
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Pipeline != b.Pipeline {
+			return a.Pipeline < b.Pipeline
+		}
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Path < b.Path
+	})
+
+	return list, nil
+}
+
+// Clear removes the cache of the pipeline, or all the cache when the pipeline is empty
+func (s *Store) Clear(pipeline string) error {
+	dir := s.baseDir
+	if pipeline != "" {
+		dir = filepath.Join(s.baseDir, pipeline)
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("cache of pipeline `%s` does not exist", pipeline)
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove cache: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveEvent removes the event scope cache, which lives only during the event
+func (s *Store) RemoveEvent(pipeline, event string) error {
+	if err := os.RemoveAll(s.scopeDir(pipeline, EventScope, event)); err != nil {
+		return fmt.Errorf("failed to remove cache: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/stretchr/testify/assert"
+)
+
+func containerPath(p string) (string, error) {
+	return strings.Replace(p, "~", "/root", 1), nil
+}
+
+func TestPipelineKey(t *testing.T) {
+	key := PipelineKey("/home/user/my repo")
+	assert.True(t, strings.HasPrefix(key, "my-repo-"))
+	assert.Len(t, key, len("my-repo-")+8)
+	assert.NotEqual(t, key, PipelineKey("/home/other/my repo"))
+}
+
+func TestVolumes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cache     *screwdriver.Cache
+		want      []string
+		wantError string
+	}{
+		{
+			name:  "no cache",
+			cache: nil,
+			want:  nil,
+		},
+		{
+			name: "all scopes",
+			cache: &screwdriver.Cache{
+				Pipeline: []string{"/sd/workspace/node_modules"},
+				Event:    []string{"/tmp/event"},
+				Job:      []string{"~/.npm"},
+			},
+			want: []string{
+				"pipe/job/test/.npm-*/data:/root/.npm",
+				"pipe/event/ev/event-*/data:/tmp/event",
+				"pipe/pipeline/node_modules-*/data:/sd/workspace/node_modules",
+			},
+		},
+		{
+			name: "same path in several scopes",
+			cache: &screwdriver.Cache{
+				Pipeline: []string{"/cache"},
+				Job:      []string{"/cache"},
+			},
+			want: []string{
+				"pipe/job/test/cache-*/data:/cache",
+			},
+		},
+		{
+			name: "invalid path",
+			cache: &screwdriver.Cache{
+				Job: []string{"relative"},
+			},
+			wantError: "invalid cache path: invalid argument",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			s := New(baseDir)
+
+			got, err := s.Volumes("pipe", "ev", "test", tt.cache, func(p string) (string, error) {
+				if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "~") {
+					return "", os.ErrInvalid
+				}
+				return containerPath(p)
+			})
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, got, len(tt.want))
+
+			for i, w := range tt.want {
+				pattern, container, _ := strings.Cut(w, ":")
+				host, gotContainer, _ := strings.Cut(got[i], ":")
+				assert.Equal(t, container, gotContainer)
+
+				matched, err := filepath.Match(filepath.Join(baseDir, pattern), host)
+				assert.Nil(t, err)
+				assert.True(t, matched, host)
+
+				info, err := os.Stat(host)
+				assert.Nil(t, err)
+				assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+			}
+		})
+	}
+
+	t.Run("success to restrict the cache directory open to everyone", func(t *testing.T) {
+		baseDir := t.TempDir()
+		s := New(baseDir)
+		c := &screwdriver.Cache{Pipeline: []string{"/cache"}}
+		containerPath := func(p string) (string, error) { return p, nil }
+
+		got, err := s.Volumes("pipeline", "event", "main", c, containerPath)
+		assert.Nil(t, err)
+		host, _, _ := strings.Cut(got[0], ":")
+		assert.Nil(t, os.Chmod(host, 0777))
+
+		_, err = s.Volumes("pipeline", "event", "main", c, containerPath)
+		assert.Nil(t, err)
+		info, err := os.Stat(host)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+		info, err = os.Stat(filepath.Join(filepath.Dir(host), pathFile))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+}
+
+func TestListAndClear(t *testing.T) {
+	baseDir := t.TempDir()
+	s := New(baseDir)
+
+	_, err := s.Volumes("a", "ev", "test", &screwdriver.Cache{Job: []string{"/cache"}}, containerPath)
+	assert.Nil(t, err)
+	volumes, err := s.Volumes("b", "ev", "test", &screwdriver.Cache{Pipeline: []string{"/cache"}, Event: []string{"/event"}}, containerPath)
+	assert.Nil(t, err)
+
+	host, _, _ := strings.Cut(volumes[1], ":")
+	err = os.WriteFile(filepath.Join(host, "file"), []byte("12345"), 0666)
+	assert.Nil(t, err)
+
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+	assert.Equal(t, Entry{Pipeline: "a", Scope: JobScope, Name: "test", Path: "/cache", Dir: list[0].Dir}, list[0])
+	assert.Equal(t, Entry{Pipeline: "b", Scope: EventScope, Name: "ev", Path: "/event", Dir: list[1].Dir}, list[1])
+	assert.Equal(t, Entry{Pipeline: "b", Scope: PipelineScope, Path: "/cache", Dir: host, Size: 5}, list[2])
+
+	err = s.RemoveEvent("b", "ev")
+	assert.Nil(t, err)
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	err = s.Clear("a")
+	assert.Nil(t, err)
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	err = s.Clear("a")
+	assert.EqualError(t, err, "cache of pipeline `a` does not exist")
+
+	err = s.Clear("")
+	assert.Nil(t, err)
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 0)
+}
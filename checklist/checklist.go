@@ -0,0 +1,76 @@
+package checklist
+
+import (
+	"fmt"
+	"io"
+)
+
+// Status is the result of a check
+type Status string
+
+const (
+	// OK means the check passed
+	OK Status = "OK"
+	// Warn means the check found something which may cause problems
+	Warn Status = "WARN"
+	// Fail means the check failed
+	Fail Status = "FAIL"
+	// Skip means the check was not run, e.g. since the one it depends on failed
+	Skip Status = "SKIP"
+)
+
+// Item is the result of a check, with the suggested fix when it did not pass
+type Item struct {
+	Status Status
+	Name   string
+	Detail string
+	Fix    string
+}
+
+// List is the results of the checks in the order they were run
+type List struct {
+	Items []Item
+}
+
+// OK adds the check which passed
+func (l *List) OK(name, detail string) {
+	l.Items = append(l.Items, Item{Status: OK, Name: name, Detail: detail})
+}
+
+// Warn adds the check which found something which may cause problems
+func (l *List) Warn(name, detail, fix string) {
+	l.Items = append(l.Items, Item{Status: Warn, Name: name, Detail: detail, Fix: fix})
+}
+
+// Fail adds the check which failed
+func (l *List) Fail(name, detail, fix string) {
+	l.Items = append(l.Items, Item{Status: Fail, Name: name, Detail: detail, Fix: fix})
+}
+
+// Skip adds the check which was not run
+func (l *List) Skip(name, detail string) {
+	l.Items = append(l.Items, Item{Status: Skip, Name: name, Detail: detail})
+}
+
+// Failed returns the number of the checks which failed
+func (l *List) Failed() int {
+	n := 0
+	for _, item := range l.Items {
+		if item.Status == Fail {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Print writes the checks, one per line followed by the suggested fix
+func (l *List) Print(w io.Writer) {
+	for _, item := range l.Items {
+		status := fmt.Sprintf("[%s]", item.Status)
+		fmt.Fprintf(w, "%-6s %s: %s\n", status, item.Name, item.Detail)
+		if item.Fix != "" {
+			fmt.Fprintf(w, "%-6s -> %s\n", "", item.Fix)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package checklist
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	l := &List{}
+	l.OK("api-url", "https://api.screwdriver.cd responds")
+	l.Fail("token", "failed to get JWT: StatusCode 401", "Set a new API token by `sd-local config set token`")
+	l.Warn("disk", "1GB free in /var/lib/docker", "Remove the unused images")
+	l.Skip("JWT", "no JWT")
+
+	assert.Equal(t, 1, l.Failed())
+
+	buf := bytes.NewBuffer(nil)
+	l.Print(buf)
+	assert.Equal(t, `[OK]   api-url: https://api.screwdriver.cd responds
+[FAIL] token: failed to get JWT: StatusCode 401
+       -> Set a new API token by `+"`sd-local config set token`"+`
+[WARN] disk: 1GB free in /var/lib/docker
+       -> Remove the unused images
+[SKIP] JWT: no JWT
+`, buf.String())
+}
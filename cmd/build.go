@@ -5,37 +5,51 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	"github.com/mitchellh/go-homedir"
 	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/cache"
 	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/history"
 	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/project"
+	"github.com/screwdriver-cd/sd-local/redact"
 	"github.com/screwdriver-cd/sd-local/scm"
 	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/screwdriver-cd/sd-local/state"
+	"github.com/screwdriver-cd/sd-local/token"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
 	configNew       = config.New
 	apiNew          = screwdriver.New
+	offlineAPINew   = screwdriver.NewOffline
 	buildLogNew     = buildlog.New
 	launchNew       = launch.New
+	cacheNew        = cache.New
+	secretNew       = secret.New
+	redactor        = redact.New()
 	artifactsDir    = launch.ArtifactsDir
 	sdUtilsDir      = launch.SdUtilsDir
 	memory          = ""
 	scmNew          = scm.New
 	osMkdirAll      = os.MkdirAll
+	osChown         = os.Chown
 	useSudo         = false
 	usePrivileged   = false
 	interactiveMode = false
-	loggerDone      chan struct{}
 )
 
 func mergeEnvFromFile(optionEnv *screwdriver.EnvVars, envFilePath string) error {
@@ -61,326 +75,905 @@ func generateUserAgent(uuid string) string {
 	return ua
 }
 
-func newBuildCmd() *cobra.Command {
-	var srcURL string
-	var flagEnv map[string]string
-	var envFilePath string
-	var optionMeta string
-	var metaFilePath string
-	var socketPath string
-	var localVolumes []string
-	var buildUser string
-	var noImagePull bool
+// buildFlags holds the flags of the commands which run builds
+type buildFlags struct {
+	srcURL           string
+	flagEnv          map[string]string
+	envFilePath      string
+	optionMeta       string
+	metaFilePath     string
+	socketPath       string
+	localVolumes     []string
+	buildUser        string
+	noImagePull      bool
+	containerRuntime string
+	offline          bool
+	redactPatterns   []string
+	logFormat        string
+	logTime          string
+	logOutput        string
+	apiTimeout       string
+	apiRetries       string
+	apiRetryBackoff  string
+	apiProxy         string
+	caCert           string
+	clientCert       string
+	clientKey        string
+	showOptions      bool
+	trustProject     bool
+	// The flags are merged with the project file in the source directory
+	flagSet *pflag.FlagSet
+	project *project.File
+	sources map[string]string
+}
 
-	buildCmd := &cobra.Command{
-		Use:   "build [job name]",
-		Short: "Run screwdriver build.",
-		Long:  `Run screwdriver build of the specified job name.`,
-		Args: func(cmd *cobra.Command, args []string) error {
-			err := cobra.ExactArgs(1)(cmd, args)
+// buildSetup holds what is prepared before launching builds
+type buildSetup struct {
+	entry       *config.Entry
+	api         screwdriver.API
+	srcPath     string
+	sdYAMLPath  string
+	optionEnv   screwdriver.EnvVars
+	meta        launch.Meta
+	cache       *cache.Store
+	pipeline    string
+	event       string
+	secrets     *secret.Store
+	configName  string
+	history     *history.Store
+	jwtSource   *token.Source
+	tokenServer tokenServer
+}
 
-			if err != nil {
-				return err
-			}
+const (
+	wallLogTime    = "wall"
+	elapsedLogTime = "elapsed"
+)
 
-			if optionMeta != "" && metaFilePath != "" {
-				return errors.New("can't pass the both options `meta` and `meta-file`, please specify only one of them")
-			}
+func (f *buildFlags) validate() error {
+	if f.optionMeta != "" && f.metaFilePath != "" {
+		return errors.New("can't pass the both options `meta` and `meta-file`, please specify only one of them")
+	}
 
-			return nil
-		},
-		RunE: func(cmd *cobra.Command, args []string) error {
-			var err error
-			var optionEnv screwdriver.EnvVars
-			cmd.SilenceUsage = true
+	if err := buildlog.ValidateFormat(f.logFormat); err != nil {
+		return err
+	}
 
-			if envFilePath != "" {
-				err = mergeEnvFromFile(&optionEnv, envFilePath)
-				if err != nil {
-					return err
-				}
-			}
-			optionEnv.AppendAll(flagEnv)
+	if f.logTime != wallLogTime && f.logTime != elapsedLogTime {
+		return fmt.Errorf("unsupported log time `%s`, must be one of [%s %s]", f.logTime, wallLogTime, elapsedLogTime)
+	}
 
-			metaJSON := []byte("{}")
-			if optionMeta != "" {
-				metaJSON = []byte(optionMeta)
-			} else if metaFilePath != "" {
-				absMetaFilePath, err := filepath.Abs(metaFilePath)
+	return validateInteractive(f.containerRuntime)
+}
 
-				if err != nil {
-					return err
-				}
+// validateInteractive rejects the interactive mode with the runtime which does not support it,
+// before the images are pulled and the volumes are created
+func validateInteractive(runtime string) error {
+	if interactiveMode && runtime == launch.DockerAPIRuntime {
+		return fmt.Errorf("can't pass the option `interactive` with the runtime `%s`, which does not support it", runtime)
+	}
 
-				metaJSON, err = os.ReadFile(absMetaFilePath)
+	return nil
+}
 
-				if err != nil {
-					return fmt.Errorf("failed to read meta-file %s: %v", metaFilePath, err)
-				}
-			}
+// logOptions returns the options of the build log of the job
+func (f *buildFlags) logOptions(jobName string) buildlog.Options {
+	return buildlog.Options{
+		Format:  f.logFormat,
+		Elapsed: f.logTime == elapsedLogTime,
+		JobName: jobName,
+	}
+}
+
+// httpOptions returns the options of the requests to the API. The flags take precedence over the config entry.
+func (f *buildFlags) httpOptions(entry *config.Entry) (screwdriver.HTTPOptions, error) {
+	value := func(flag, conf string) string {
+		if flag != "" {
+			return flag
+		}
+		return conf
+	}
 
-			var meta launch.Meta
+	return screwdriver.HTTPSettings{
+		Timeout:      value(f.apiTimeout, entry.APITimeout),
+		Retries:      value(f.apiRetries, entry.APIRetries),
+		RetryBackoff: value(f.apiRetryBackoff, entry.APIRetryBackoff),
+		Proxy:        value(f.apiProxy, entry.APIProxy),
+		CACert:       value(f.caCert, entry.CACert),
+		ClientCert:   value(f.clientCert, entry.ClientCert),
+		ClientKey:    value(f.clientKey, entry.ClientKey),
+	}.Options()
+}
 
-			err = json.Unmarshal(metaJSON, &meta)
+// openLogOutput returns the writer of the build log, which writes to the log output file as well when it is specified.
+// The returned function closes the file.
+func (f *buildFlags) openLogOutput(stdout io.Writer) (io.Writer, func(), error) {
+	if f.logOutput == "" {
+		return stdout, func() {}, nil
+	}
 
-			if err != nil {
-				return fmt.Errorf("failed to parse meta %s, meta must be formated with JSON: %v", string(metaJSON), err)
-			}
+	file, err := os.Create(f.logOutput)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log output file: %v", err)
+	}
 
-			cwd, err := os.Getwd()
-			if err != nil {
-				return err
-			}
+	return io.MultiWriter(stdout, file), func() {
+		if err := file.Close(); err != nil {
+			logrus.Warn(fmt.Errorf("failed to close log output file: %v", err))
+		}
+	}, nil
+}
 
-			configBaseDir, err := homedir.Dir()
-			if err != nil {
-				return err
-			}
+func (f *buildFlags) readMeta() (launch.Meta, error) {
+	var err error
 
-			sdlocalDir := filepath.Join(configBaseDir, ".sdlocal")
-			srcPath := cwd
+	metaJSON := []byte("{}")
+	if f.optionMeta != "" {
+		metaJSON = []byte(f.optionMeta)
+	} else if f.metaFilePath != "" {
+		absMetaFilePath, err := filepath.Abs(f.metaFilePath)
 
-			if srcURL != "" {
-				logrus.Infof("Pulling the source code from %s...", srcURL)
+		if err != nil {
+			return nil, err
+		}
 
-				scm, err := scmNew(sdlocalDir, srcURL, useSudo)
-				if err != nil {
-					return err
-				}
-				s, ok := scm.(Cleaner)
-				if ok {
-					cleaners = append(cleaners, s)
-				}
+		metaJSON, err = os.ReadFile(absMetaFilePath)
 
-				err = scm.Pull()
-				if err != nil {
-					return err
-				}
-				srcPath = scm.LocalPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read meta-file %s: %v", f.metaFilePath, err)
+		}
+	}
+
+	var meta launch.Meta
+
+	err = json.Unmarshal(metaJSON, &meta)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse meta %s, meta must be formated with JSON: %v", string(metaJSON), err)
+	}
+
+	return meta, nil
+}
+
+// prepareSource returns the path of the source code, which is pulled when the source URL is specified.
+// The flags are merged with the project file found from the source directory.
+func (f *buildFlags) prepareSource(sdlocalDir string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	srcPath := cwd
+
+	if f.srcURL != "" {
+		logrus.Infof("Pulling the source code from %s...", f.srcURL)
+
+		scm, err := scmNew(sdlocalDir, f.srcURL, useSudo)
+		if err != nil {
+			return "", err
+		}
+		s, ok := scm.(Cleaner)
+		if ok {
+			cleaners.add(s)
+		}
+		recordResources(state.Resource{Kind: state.CloneKind, Name: scm.LocalPath(), Sudo: useSudo})
+
+		err = scm.Pull()
+		if err != nil {
+			return "", err
+		}
+		srcPath = scm.LocalPath()
+	}
+
+	if err := f.applyProject(srcPath, f.srcURL != ""); err != nil {
+		return "", err
+	}
+
+	// The values from the project file are validated as well
+	if err := f.validate(); err != nil {
+		return "", err
+	}
+
+	return srcPath, nil
+}
+
+// setup reads the options, pulls the source code and initializes the API to get jobs
+func (f *buildFlags) setup() (*buildSetup, error) {
+	var err error
+	var optionEnv screwdriver.EnvVars
+
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	srcPath, err := f.prepareSource(sdlocalDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.envFilePath != "" {
+		err = mergeEnvFromFile(&optionEnv, f.envFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	optionEnv.AppendAll(f.flagEnv)
+
+	meta, err := f.readMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	configPath := filepath.Join(sdlocalDir, "config")
+	config, err := configNew(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.selectConfig(&config); err != nil {
+		return nil, err
+	}
+
+	configName := config.Current
+	entry, err := config.Entry(configName)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.containerRuntime == "" {
+		f.containerRuntime = entry.Runtime
+	}
+
+	if err := launch.ValidateRuntime(f.containerRuntime); err != nil {
+		return nil, err
+	}
+
+	// The runtime may be given by the config or the project file
+	if err := validateInteractive(f.containerRuntime); err != nil {
+		return nil, err
+	}
+
+	secrets, err := secretNew(filepath.Join(sdlocalDir, "secrets"), configName, secretPassphrase(configName))
+	if err != nil {
+		return nil, err
+	}
+
+	uuidStr := entry.UUID
+	if uuidStr == "" {
+		fmt.Println("sd-local collects UUIDs for statistical surveys.")
+		fmt.Println("You can reset it later by removing the UUID key from config.")
+		fmt.Print("Would you please cooperate with the survey? [y/N]: ")
+
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		uuidStr = "-"
+		input = strings.TrimSuffix(input, "\n")
+		if input == "y" || input == "Y" || input == "yes" || input == "Yes" {
+			uuidStr = uuid.NewString()
+		}
+		err = entry.Set("uuid", uuidStr)
+		if err != nil {
+			return nil, err
+		}
+		err = config.Save()
+		if err != nil {
+			return nil, err
+		}
+
+		if uuidStr != "-" {
+			fmt.Printf("UUID key has been added to %s\n", configPath)
+		} else {
+			fmt.Println("UUID key is not set.")
+		}
+	}
+
+	ua := generateUserAgent(uuidStr)
+	var api screwdriver.API
+	var jwtSource *token.Source
+	if f.offline {
+		api = offlineAPINew()
+	} else {
+		if err := unlockToken(entry, configName); err != nil {
+			return nil, err
+		}
+
+		httpOptions, err := f.httpOptions(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		api, err = apiNew(entry.APIURL, entry.Token, ua, httpOptions)
+		if err != nil {
+			return nil, err
+		}
+		jwtSource = newJWTSource(api, filepath.Join(sdlocalDir, "tokens"), configName, entry)
+	}
+
+	err = initJWT(api, jwtSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.registerRedaction(filepath.Join(sdlocalDir, "redact-patterns"), entry, api, optionEnv, secrets); err != nil {
+		return nil, err
+	}
+
+	s := &buildSetup{
+		entry:      entry,
+		api:        api,
+		srcPath:    srcPath,
+		sdYAMLPath: filepath.Join(srcPath, "screwdriver.yaml"),
+		optionEnv:  optionEnv,
+		meta:       meta,
+		cache:      cacheNew(filepath.Join(sdlocalDir, "cache")),
+		pipeline:   cache.PipelineKey(srcPath),
+		event:      strconv.FormatInt(time.Now().UnixNano(), 10),
+		secrets:    secrets,
+		configName: configName,
+		history:    historyNew(filepath.Join(sdlocalDir, "builds")),
+		jwtSource:  jwtSource,
+	}
+	s.startTokenServer()
+
+	return s, nil
+}
+
+// cacheVolumes returns the volumes to mount the cache declared in the job
+func (s *buildSetup) cacheVolumes(jobName string, job screwdriver.Job) ([]string, error) {
+	return s.cache.Volumes(s.pipeline, s.event, jobName, job.Cache, func(p string) (string, error) {
+		return launch.ContainerPath(p, job.Environment)
+	})
+}
+
+// removeEventCache removes the event scope cache after the builds of the run finish
+func (s *buildSetup) removeEventCache() {
+	if err := s.cache.RemoveEvent(s.pipeline, s.event); err != nil {
+		logrus.Warn(err)
+	}
+}
+
+// registerRedaction registers the values and patterns masked in the build output and logs
+func (f *buildFlags) registerRedaction(patternFile string, entry *config.Entry, api screwdriver.API, optionEnv screwdriver.EnvVars, secrets *secret.Store) error {
+	redactor.Add(api.JWT(), entry.Token)
+
+	for _, env := range optionEnv {
+		for k, v := range env {
+			if redact.SecretLike(k) {
+				redactor.Add(v)
 			}
+		}
+	}
+
+	values, err := secrets.Resolve(secrets.Names())
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		redactor.Add(v)
+	}
+
+	// The JWTs refreshed during the builds are masked as well
+	if err := redactor.AddPattern(redact.JWTPattern); err != nil {
+		return err
+	}
+
+	if err := redactor.AddPatternFile(patternFile); err != nil {
+		return err
+	}
+
+	for _, p := range f.redactPatterns {
+		if err := redactor.AddPattern(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// makeDir creates the host side directory and returns its absolute path
+func makeDir(dir string) (string, error) {
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := osMkdirAll(path, 0777); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// chownBuildUser makes the build user given by --user own the directory shared with the build container, which only its owner can access.
+// Only the numeric UID[:GID] is used, since the IDs of the user named in the image are not known on the host.
+// The directory is kept owned by the host user otherwise, which the root of the container can access as well.
+func (f *buildFlags) chownBuildUser(dir string) {
+	uidStr, gidStr, _ := strings.Cut(f.buildUser, ":")
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil || uid == os.Getuid() {
+		return
+	}
+
+	gid := -1
+	if g, err := strconv.Atoi(gidStr); err == nil {
+		gid = g
+	}
+
+	// Only root can give the directory to another user, so the build may fail to write it
+	if err := osChown(dir, uid, gid); err != nil {
+		logrus.Warnf("the build user `%s` may not be able to write %s: %v", f.buildUser, dir, err)
+	}
+}
+
+func (f *buildFlags) launchOption(s *buildSetup, job screwdriver.Job, jobName, artifactsPath, sdUtilsPath string) (launch.Option, error) {
+	cacheVolumes, err := s.cacheVolumes(jobName, job)
+	if err != nil {
+		return launch.Option{}, err
+	}
+	for _, v := range cacheVolumes {
+		dir, _, _ := strings.Cut(v, ":")
+		f.chownBuildUser(dir)
+	}
+
+	secrets, err := s.secrets.Resolve(job.Secrets)
+	if err != nil {
+		return launch.Option{}, err
+	}
+
+	// The volumes are copied not to share the cache volumes among the jobs of a pipeline
+	localVolumes := append(append([]string{}, f.localVolumes...), cacheVolumes...)
+
+	jwt, err := s.jwt()
+	if err != nil {
+		return launch.Option{}, err
+	}
+
+	tokenKey, err := s.newTokenKey()
+	if err != nil {
+		return launch.Option{}, err
+	}
+
+	return launch.Option{
+		Job:             job,
+		Entry:           *s.entry,
+		JobName:         jobName,
+		JWT:             jwt,
+		ArtifactsPath:   artifactsPath,
+		SdUtilsPath:     sdUtilsPath,
+		Memory:          memory,
+		SrcPath:         s.srcPath,
+		OptionEnv:       s.optionEnv,
+		Meta:            s.meta,
+		UseSudo:         useSudo,
+		UsePrivileged:   usePrivileged,
+		InteractiveMode: interactiveMode,
+		SocketPath:      f.socketPath,
+		FlagVerbose:     flagVerbose,
+		LocalVolumes:    localVolumes,
+		BuildUser:       f.buildUser,
+		NoImagePull:     f.noImagePull,
+		Runtime:         f.containerRuntime,
+		Secrets:         secrets,
+		TokenURL:        s.tokenURL(),
+		TokenKey:        tokenKey,
+		Labels:          session.Labels(),
+	}, nil
+}
+
+// exitCode returns the exit code of the failed build container, or -1 when it is unknown
+func exitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
+// runBuild launches the build and writes its log to the writer until it finishes.
+// The containers, volumes and networks of the build are removed when it finishes.
+func runBuild(s *buildSetup, option launch.Option, writer io.Writer, logOptions buildlog.Options) ([]buildlog.StepResult, error) {
+	defer s.revokeTokenKey(option.TokenKey)
+	forget := recordResources(launch.Resources(option)...)
+	launcher := launchNew(option)
+	if l, ok := launcher.(Cleaner); ok {
+		remove := cleaners.add(l)
+		defer func() {
+			l.Clean()
+			remove()
+			forget()
+		}()
+	}
+
+	return runLauncher(s, launcher, option, writer, logOptions)
+}
+
+// runLauncher runs the build with the launcher and writes its log to the writer until it finishes.
+// The results of the steps are written in the artifacts directory and returned even if the build fails.
+// The build is recorded in the history as well.
+func runLauncher(s *buildSetup, launcher launch.Launcher, option launch.Option, writer io.Writer, logOptions buildlog.Options) ([]buildlog.StepResult, error) {
+	redacted := redactor.Writer(writer)
+	defer redacted.Close()
+
+	loggerDone := make(chan struct{})
+	logger, err := buildLogNew(filepath.Join(option.ArtifactsPath, launch.LogFile), redacted, loggerDone, logOptions)
+	if err != nil {
+		return nil, err
+	}
+	go logger.Run()
+
+	logrus.Info("Prepare to start build...")
+	start := time.Now()
+	err = launcher.Run()
+
+	// The rest of the log is read to know the last step even if the build fails
+	logger.Stop()
+	<-loggerDone
+	end := time.Now()
+
+	steps := buildlog.Summarize(logger.Steps(), end, err, exitCode(err))
+	if len(steps) > 0 {
+		if err := buildlog.WriteSteps(filepath.Join(option.ArtifactsPath, buildlog.StepsFile), steps); err != nil {
+			logrus.Warn(err)
+		}
+	}
+
+	s.recordBuild(option, steps, err, start, end)
+
+	return steps, err
+}
+
+func newBuildCmd() *cobra.Command {
+	flags := &buildFlags{}
+	steps := launch.StepSelection{}
+	watchMode := false
+	watchIgnore := []string{}
+
+	validateWatch := func() error {
+		if watchMode && interactiveMode {
+			return errors.New("can't pass the both options `watch` and `interactive`")
+		}
+
+		if watchMode && flags.srcURL != "" {
+			return errors.New("can't pass the both options `watch` and `src-url`, the local source is watched")
+		}
+
+		return nil
+	}
+
+	buildCmd := &cobra.Command{
+		Use:   "build [job name]",
+		Short: "Run screwdriver build.",
+		Long:  `Run screwdriver build of the specified job name.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			err := cobra.ExactArgs(1)(cmd, args)
 
-			configPath := filepath.Join(sdlocalDir, "config")
-			config, err := configNew(configPath)
 			if err != nil {
 				return err
 			}
 
-			entry, err := config.Entry(config.Current)
-			if err != nil {
+			if err := validateWatch(); err != nil {
 				return err
 			}
 
-			uuidStr := entry.UUID
-			if uuidStr == "" {
-				fmt.Println("sd-local collects UUIDs for statistical surveys.")
-				fmt.Println("You can reset it later by removing the UUID key from config.")
-				fmt.Print("Would you please cooperate with the survey? [y/N]: ")
-
-				input, err := bufio.NewReader(os.Stdin).ReadString('\n')
-				if err != nil {
-					return err
-				}
-				uuidStr = "-"
-				input = strings.TrimSuffix(input, "\n")
-				if input == "y" || input == "Y" || input == "yes" || input == "Yes" {
-					uuidStr = uuid.NewString()
-				}
-				err = entry.Set("uuid", uuidStr)
-				if err != nil {
-					return err
-				}
-				err = config.Save()
-				if err != nil {
-					return err
-				}
+			return flags.validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
 
-				if uuidStr != "-" {
-					fmt.Printf("UUID key has been added to %s\n", configPath)
-				} else {
-					fmt.Println("UUID key is not set.")
-				}
+			if flags.showOptions {
+				return flags.showEffectiveOptions(cmd.OutOrStdout())
 			}
 
-			ua := generateUserAgent(uuidStr)
-			api := apiNew(entry.APIURL, entry.Token, ua)
-
-			err = api.InitJWT()
+			s, err := flags.setup()
 			if err != nil {
 				return err
 			}
+			defer s.removeEventCache()
+			defer s.stopTokenServer()
+
+			// The project file may enable the watch mode
+			if err := validateWatch(); err != nil {
+				return err
+			}
 
 			jobName := args[0]
 
-			sdYAMLPath := filepath.Join(srcPath, "screwdriver.yaml")
-			job, err := api.Job(jobName, sdYAMLPath)
+			job, err := s.api.Job(jobName, s.sdYAMLPath)
 			if err != nil {
 				return err
 			}
 
-			artifactsPath, err := filepath.Abs(artifactsDir)
+			job.Steps, err = launch.SelectSteps(job.Steps, steps)
 			if err != nil {
 				return err
 			}
 
-			err = osMkdirAll(artifactsPath, 0777)
+			artifactsPath, err := makeDir(artifactsDir)
 			if err != nil {
 				return err
 			}
 
-			sdUtilsPath, err := filepath.Abs(sdUtilsDir)
+			sdUtilsPath, err := makeDir(sdUtilsDir)
 			if err != nil {
 				return err
 			}
 
-			if err := osMkdirAll(sdUtilsPath, 0777); err != nil {
+			option, err := flags.launchOption(s, job, jobName, artifactsPath, sdUtilsPath)
+			if err != nil {
 				return err
 			}
 
-			loggerDone = make(chan struct{})
-			logger, err := buildLogNew(filepath.Join(artifactsPath, launch.LogFile), os.Stdout, loggerDone)
+			output, closeOutput, err := flags.openLogOutput(os.Stdout)
 			if err != nil {
 				return err
 			}
-			go logger.Run()
-
-			option := launch.Option{
-				Job:             job,
-				Entry:           *entry,
-				JobName:         jobName,
-				JWT:             api.JWT(),
-				ArtifactsPath:   artifactsPath,
-				SdUtilsPath:     sdUtilsPath,
-				Memory:          memory,
-				SrcPath:         srcPath,
-				OptionEnv:       optionEnv,
-				Meta:            meta,
-				UseSudo:         useSudo,
-				UsePrivileged:   usePrivileged,
-				InteractiveMode: interactiveMode,
-				SocketPath:      socketPath,
-				FlagVerbose:     flagVerbose,
-				LocalVolumes:    localVolumes,
-				BuildUser:       buildUser,
-				NoImagePull:     noImagePull,
-			}
+			defer closeOutput()
 
-			launch := launchNew(option)
-			l, ok := launch.(Cleaner)
-			if ok {
-				cleaners = append(cleaners, l)
-			}
+			if watchMode {
+				// The dind container and network are made for each build, so they can't be reused
+				if dindEnabled, _ := job.Annotations["screwdriver.cd/dockerEnabled"].(bool); dindEnabled {
+					return errors.New("watch mode does not support the job with screwdriver.cd/dockerEnabled")
+				}
 
-			logrus.Info("Prepare to start build...")
-			err = launch.Run()
-			if err != nil {
-				return err
+				w, err := watchNew(s.srcPath, append(watchIgnores(s.srcPath, artifactsPath, sdUtilsPath), watchIgnore...))
+				if err != nil {
+					return err
+				}
+				defer w.Close()
+
+				return watchBuild(s, w, option, output, cmd.OutOrStdout(), flags.logOptions(jobName))
 			}
 
-			logger.Stop()
-			<-loggerDone
+			steps, err := runBuild(s, option, output, flags.logOptions(jobName))
+			if len(steps) > 0 {
+				fmt.Fprintln(cmd.OutOrStdout())
+				buildlog.PrintSteps(cmd.OutOrStdout(), steps)
+			}
 
-			return nil
+			return err
 		},
 	}
 
+	flags.register(buildCmd)
+
+	buildCmd.Flags().BoolVarP(
+		&interactiveMode,
+		"interactive",
+		"i",
+		false,
+		"Attach the build container in interactive mode.")
+
+	buildCmd.Flags().StringArrayVar(
+		&steps.Only,
+		"step",
+		[]string{},
+		"Run only the step. Shell-style globs are allowed. Can be specified multiple times.")
+
+	buildCmd.Flags().StringVar(
+		&steps.From,
+		"from-step",
+		"",
+		"Run the steps from the step. Shell-style globs are allowed.")
+
 	buildCmd.Flags().StringVar(
+		&steps.To,
+		"to-step",
+		"",
+		"Run the steps until the step. Shell-style globs are allowed.")
+
+	buildCmd.Flags().StringArrayVar(
+		&steps.Skip,
+		"skip-step",
+		[]string{},
+		"Skip the step. Shell-style globs are allowed. Can be specified multiple times.")
+
+	buildCmd.Flags().BoolVar(
+		&watchMode,
+		"watch",
+		false,
+		"Run the build again whenever the source files change. The files in .gitignore are not watched.")
+
+	buildCmd.Flags().StringArrayVar(
+		&watchIgnore,
+		"watch-ignore",
+		[]string{},
+		"Pattern of the files not to watch in .gitignore format. Can be specified multiple times.")
+
+	return buildCmd
+}
+
+// register adds the flags shared by the commands which run builds
+func (f *buildFlags) register(cmd *cobra.Command) {
+	f.flagSet = cmd.Flags()
+
+	cmd.Flags().StringVar(
 		&artifactsDir,
 		"artifacts-dir",
 		launch.ArtifactsDir,
 		"Path to the host side directory which is mounted into $SD_ARTIFACTS_DIR.")
 
-	buildCmd.Flags().StringVar(
+	cmd.Flags().StringVar(
 		&sdUtilsDir,
 		"utils-dir",
 		launch.SdUtilsDir,
 		"Path to the host side directory that is created to mount utility files for interactive mode.")
 
-	buildCmd.Flags().StringVarP(
+	cmd.Flags().StringVarP(
 		&memory,
 		"memory",
 		"m",
 		"",
 		"Memory limit for build container, which take a positive integer, followed by a suffix of b, k, m, g.")
 
-	buildCmd.Flags().StringVar(
-		&srcURL,
+	cmd.Flags().StringVar(
+		&f.srcURL,
 		"src-url",
 		"",
 		`Specify the source url to build.
 ex) git@github.com:<org>/<repo>.git[#<branch>]
     https://github.com/<org>/<repo>.git[#<branch>]`)
 
-	buildCmd.Flags().StringToStringVarP(
-		&flagEnv,
+	cmd.Flags().StringToStringVarP(
+		&f.flagEnv,
 		"env",
 		"e",
 		map[string]string{},
 		"Set key and value relationship which is set as environment variables of Build Container. (<key>=<value>)",
 	)
 
-	buildCmd.Flags().StringVar(
-		&envFilePath,
+	cmd.Flags().StringVar(
+		&f.envFilePath,
 		"env-file",
 		"",
 		"Path to config file of environment variables. '.env' format file can be used.")
 
-	buildCmd.Flags().StringVar(
-		&optionMeta,
+	cmd.Flags().StringVar(
+		&f.optionMeta,
 		"meta",
 		"",
 		"Metadata to pass into the build environment, which is represented with JSON format",
 	)
 
-	buildCmd.Flags().StringVar(
-		&metaFilePath,
+	cmd.Flags().StringVar(
+		&f.metaFilePath,
 		"meta-file",
 		"",
 		"Path to the meta file. meta file is represented with JSON format.")
 
-	buildCmd.Flags().BoolVar(
+	cmd.Flags().BoolVar(
 		&useSudo,
 		"sudo",
 		false,
 		"Use sudo command for container runtime.")
 
-	buildCmd.Flags().BoolVar(
+	cmd.Flags().BoolVar(
 		&usePrivileged,
 		"privileged",
 		false,
 		"Use privileged mode for container runtime.")
 
-	buildCmd.Flags().BoolVarP(
-		&interactiveMode,
-		"interactive",
-		"i",
-		false,
-		"Attach the build container in interactive mode.")
-
-	buildCmd.Flags().StringVarP(
-		&socketPath,
+	cmd.Flags().StringVarP(
+		&f.socketPath,
 		"socket",
 		"S",
 		launch.DefaultSocketPath(),
 		"Path to the socket. It will used in build container.")
 
-	buildCmd.Flags().StringSliceVar(
-		&localVolumes,
+	cmd.Flags().StringSliceVar(
+		&f.localVolumes,
 		"vol",
 		[]string{},
 		"Volumes to mount into build container.")
 
-	buildCmd.Flags().StringVarP(
-		&buildUser,
+	cmd.Flags().StringVarP(
+		&f.buildUser,
 		"user",
 		"u",
 		"",
 		"Change default build user. Default value is from container in use.")
 
-	buildCmd.Flags().BoolVar(
-		&noImagePull,
+	cmd.Flags().BoolVar(
+		&f.noImagePull,
 		"no-image-pull",
 		false,
 		"Skip container image pulls to save time.")
 
-	return buildCmd
+	cmd.Flags().StringVar(
+		&f.containerRuntime,
+		"runtime",
+		"",
+		"Container runtime to run the build with. (docker, podman or docker-api) Default value is from config, otherwise docker.")
+
+	cmd.Flags().BoolVar(
+		&f.offline,
+		"offline",
+		false,
+		"Parse screwdriver.yaml locally without the Screwdriver.cd API. Templates are not supported.")
+
+	cmd.Flags().StringArrayVar(
+		&f.redactPatterns,
+		"redact",
+		[]string{},
+		"Regular expression to mask in the build output and logs. Can be specified multiple times.")
+
+	f.registerLogFormat(cmd)
+
+	cmd.Flags().StringVar(
+		&f.apiTimeout,
+		"api-timeout",
+		"",
+		"Timeout of a request to the Screwdriver.cd API, e.g. 30s. Default value is from config, otherwise 60s.")
+
+	cmd.Flags().StringVar(
+		&f.apiRetries,
+		"api-retries",
+		"",
+		"Number of retries of a request to the Screwdriver.cd API on network errors and 429, 502, 503 or 504. Default value is from config, otherwise 3.")
+
+	cmd.Flags().StringVar(
+		&f.apiRetryBackoff,
+		"api-retry-backoff",
+		"",
+		"Wait before the first retry, which is doubled for each retry, e.g. 2s. Default value is from config, otherwise 1s.")
+
+	cmd.Flags().StringVar(
+		&f.apiProxy,
+		"api-proxy",
+		"",
+		"URL of the proxy to the Screwdriver.cd API. Default value is from config, otherwise HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used.")
+
+	cmd.Flags().StringVar(
+		&f.caCert,
+		"ca-cert",
+		"",
+		"Path to the PEM bundle of the CAs trusted in addition to the system ones. Default value is from config.")
+
+	cmd.Flags().StringVar(
+		&f.clientCert,
+		"client-cert",
+		"",
+		"Path to the PEM client certificate sent to the Screwdriver.cd API. Default value is from config.")
+
+	cmd.Flags().StringVar(
+		&f.clientKey,
+		"client-key",
+		"",
+		"Path to the PEM key of the client certificate. Default value is from config.")
+
+	cmd.Flags().StringVar(
+		&f.logOutput,
+		"log-output",
+		"",
+		"Path to the file which the formatted build log is written to in addition to stdout.")
+
+	cmd.Flags().BoolVar(
+		&f.showOptions,
+		"show-effective-options",
+		false,
+		"Print the options merged with "+project.FileName+" and where each value comes from, without running the build.")
+
+	cmd.Flags().BoolVar(
+		&f.trustProject,
+		"trust-project",
+		false,
+		"Apply the security-sensitive flags in "+project.FileName+", and its config in the source pulled by --src-url, which are ignored by default.")
+}
+
+// registerLogFormat registers the flags which decide the format of the build log
+func (f *buildFlags) registerLogFormat(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&f.logFormat,
+		"log-format",
+		buildlog.TextFormat,
+		"Format of the build log. (text, json or timestamped)")
+
+	cmd.Flags().StringVar(
+		&f.logTime,
+		"log-time",
+		wallLogTime,
+		"Time prefixed to the build log in timestamped format. (wall or elapsed)")
 }
@@ -2,18 +2,35 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/cache"
 	"github.com/screwdriver-cd/sd-local/config"
 	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/redact"
 	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/secret"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
+type mockJobAPI struct {
+	mockAPI
+	job screwdriver.Job
+}
+
+func (mock mockJobAPI) Job(jobName, filePath string) (screwdriver.Job, error) {
+	return mock.job, nil
+}
+
 func TestBuildCmd(t *testing.T) {
 	t.Run("Success build cmd", func(t *testing.T) {
 		root := newBuildCmd()
@@ -202,6 +219,358 @@ func TestBuildCmd(t *testing.T) {
 		assert.Nil(t, err)
 	})
 
+	t.Run("Success build cmd with --runtime", func(t *testing.T) {
+		root := newBuildCmd()
+
+		root.SetArgs([]string{"test", "--runtime", "podman"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		launchNew = func(option launch.Option) launch.Launcher {
+			assert.Equal(t, "podman", option.Runtime)
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.Equal(t, "", buf.String())
+		assert.Nil(t, err)
+	})
+
+	t.Run("Success build cmd with runtime in config", func(t *testing.T) {
+		defConfigNew := configNew
+		defer func() {
+			configNew = defConfigNew
+		}()
+
+		root := newBuildCmd()
+
+		root.SetArgs([]string{"test"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		configNew = func(confPath string) (config.Config, error) {
+			c, err := defConfigNew(confPath)
+			c.Entries["default"].Runtime = "podman"
+			return c, err
+		}
+		launchNew = func(option launch.Option) launch.Launcher {
+			assert.Equal(t, "podman", option.Runtime)
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.Nil(t, err)
+	})
+
+	t.Run("Failed build cmd with unsupported --runtime", func(t *testing.T) {
+		defLaunchNew := launchNew
+		defer func() {
+			launchNew = defLaunchNew
+		}()
+
+		root := newBuildCmd()
+
+		root.SetArgs([]string{"test", "--runtime", "rkt"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		launchNew = func(option launch.Option) launch.Launcher {
+			assert.Fail(t, "launch must not be created with unsupported runtime")
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.Equal(t, "unsupported container runtime `rkt`, must be one of [docker podman docker-api]", err.Error())
+	})
+
+	t.Run("Success build cmd with --offline", func(t *testing.T) {
+		defAPINew := apiNew
+		defOfflineAPINew := offlineAPINew
+		defer func() {
+			apiNew = defAPINew
+			offlineAPINew = defOfflineAPINew
+		}()
+
+		root := newBuildCmd()
+
+		root.SetArgs([]string{"test", "--offline"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		called := false
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			assert.Fail(t, "online API must not be created in offline mode")
+			return mockAPI{}, nil
+		}
+		offlineAPINew = func() screwdriver.API {
+			called = true
+			return mockAPI{}
+		}
+		launchNew = func(option launch.Option) launch.Launcher {
+			assert.Equal(t, "", option.JWT)
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.True(t, called)
+	})
+
+	t.Run("Success build cmd with cache", func(t *testing.T) {
+		defAPINew := apiNew
+		defCacheNew := cacheNew
+		defer func() {
+			apiNew = defAPINew
+			cacheNew = defCacheNew
+		}()
+
+		cacheDir := t.TempDir()
+		cacheNew = func(baseDir string) *cache.Store {
+			return cache.New(cacheDir)
+		}
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			return mockJobAPI{job: screwdriver.Job{Cache: &screwdriver.Cache{Job: []string{"$SD_SOURCE_DIR/node_modules"}}}}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--vol", "/tmp:/local"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		var volumes []string
+		launchNew = func(option launch.Option) launch.Launcher {
+			volumes = option.LocalVolumes
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.Len(t, volumes, 2)
+		assert.Equal(t, "/tmp:/local", volumes[0])
+		assert.True(t, strings.HasPrefix(volumes[1], cacheDir))
+		assert.True(t, strings.HasSuffix(volumes[1], "/data:/sd/workspace/src/screwdriver.cd/sd-local/local-build/node_modules"))
+	})
+
+	t.Run("Success build cmd with secrets", func(t *testing.T) {
+		defAPINew := apiNew
+		defSecretNew := secretNew
+		defer func() {
+			apiNew = defAPINew
+			secretNew = defSecretNew
+		}()
+
+		t.Setenv(config.PassphraseEnv, "passphrase")
+		secretDir := t.TempDir()
+		store, err := secret.New(secretDir, "default", testPassphrase)
+		assert.Nil(t, err)
+		assert.Nil(t, store.Set("GIT_KEY", "key"))
+		assert.Nil(t, store.Set("NPM_TOKEN", "npm"))
+		assert.Nil(t, store.Save())
+
+		secretNew = func(dir, configName string, passphrase secret.Passphrase) (*secret.Store, error) {
+			assert.Equal(t, "default", configName)
+			return secret.New(secretDir, configName, passphrase)
+		}
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			return mockJobAPI{job: screwdriver.Job{Secrets: []string{"GIT_KEY"}}}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		var secrets map[string]string
+		launchNew = func(option launch.Option) launch.Launcher {
+			secrets = option.Secrets
+			return mockLaunch{}
+		}
+
+		err = root.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"GIT_KEY": "key"}, secrets)
+	})
+
+	t.Run("Failed build cmd with missing secrets", func(t *testing.T) {
+		defAPINew := apiNew
+		defSecretNew := secretNew
+		defer func() {
+			apiNew = defAPINew
+			secretNew = defSecretNew
+		}()
+
+		secretDir := t.TempDir()
+		secretNew = func(dir, configName string, passphrase secret.Passphrase) (*secret.Store, error) {
+			return secret.New(secretDir, configName, passphrase)
+		}
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			return mockJobAPI{job: screwdriver.Job{Secrets: []string{"GIT_KEY"}}}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		launchNew = func(option launch.Option) launch.Launcher {
+			assert.Fail(t, "build must not be launched with missing secrets")
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.EqualError(t, err, "secrets declared in the job are not set: GIT_KEY, set them with `sd-local secret set <name>`")
+	})
+
+	t.Run("Success build cmd with --log-format and --log-output", func(t *testing.T) {
+		defBuildLogNew := buildLogNew
+		defer func() {
+			buildLogNew = defBuildLogNew
+		}()
+
+		logOutput := filepath.Join(t.TempDir(), "build.log")
+
+		var gotOptions buildlog.Options
+		buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}, options buildlog.Options) (buildlog.Logger, error) {
+			gotOptions = options
+			return mockOutputLogger{done: done, writer: writer, output: "{\"step\":\"main\"}\n"}, nil
+		}
+		launchNew = func(option launch.Option) launch.Launcher {
+			return mockLaunch{}
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--log-format", "timestamped", "--log-time", "elapsed", "--log-output", logOutput})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, buildlog.Options{Format: buildlog.TimestampedFormat, Elapsed: true, JobName: "test"}, gotOptions)
+
+		written, err := os.ReadFile(logOutput)
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"step\":\"main\"}\n", string(written))
+	})
+
+	t.Run("Failed build cmd with unsupported --log-format", func(t *testing.T) {
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--log-format", "xml"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.EqualError(t, err, "unsupported log format `xml`, must be one of [text json timestamped]")
+	})
+
+	t.Run("Failed build cmd with unsupported --log-time", func(t *testing.T) {
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--log-time", "utc"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.EqualError(t, err, "unsupported log time `utc`, must be one of [wall elapsed]")
+	})
+
+	t.Run("Failed build cmd with invalid --log-output", func(t *testing.T) {
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--log-output", filepath.Join(t.TempDir(), "notExist", "build.log")})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.Contains(t, err.Error(), "failed to open log output file: ")
+	})
+
+	t.Run("Success build cmd with step selection", func(t *testing.T) {
+		defAPINew := apiNew
+		defer func() {
+			apiNew = defAPINew
+		}()
+
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			return mockJobAPI{job: screwdriver.Job{Steps: []screwdriver.Step{
+				{Name: "install", Command: "npm install"},
+				{Name: "lint", Command: "npm run lint"},
+				{Name: "test-unit", Command: "npm run test:unit"},
+				{Name: "test-functional", Command: "npm run test:functional"},
+			}}}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--from-step", "lint", "--skip-step", "test-f*"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		var steps []screwdriver.Step
+		launchNew = func(option launch.Option) launch.Launcher {
+			steps = option.Job.Steps
+			return mockLaunch{}
+		}
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, []screwdriver.Step{
+			{Name: "lint", Command: "npm run lint"},
+			{Name: "test-unit", Command: "npm run test:unit"},
+		}, steps)
+	})
+
+	t.Run("Failed build cmd with unknown --step", func(t *testing.T) {
+		defAPINew := apiNew
+		defer func() {
+			apiNew = defAPINew
+		}()
+
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			return mockJobAPI{job: screwdriver.Job{Steps: []screwdriver.Step{{Name: "install"}, {Name: "test"}}}}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--step", "lint"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.EqualError(t, err, "step `lint` is not found in the job, must be one of [install test]")
+	})
+
+	t.Run("Failed build cmd prints the results of the steps", func(t *testing.T) {
+		defBuildLogNew := buildLogNew
+		defer func() {
+			buildLogNew = defBuildLogNew
+		}()
+
+		start := time.Now().Add(-time.Minute)
+		buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}, options buildlog.Options) (buildlog.Logger, error) {
+			return mockStepsLogger{mockLogger: mockLogger{done: done}, steps: []buildlog.Step{
+				{Name: "install", StartTime: start, EndTime: start.Add(3 * time.Second)},
+				{Name: "test", StartTime: start.Add(3 * time.Second), EndTime: start.Add(4 * time.Second)},
+			}}, nil
+		}
+		launchNew = func(option launch.Option) launch.Launcher {
+			return mockPipelineLaunch{option: option, err: fmt.Errorf("failed to run build: %w", mockExitError{code: 2})}
+		}
+
+		artifactsPath := t.TempDir()
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--artifacts-dir", artifactsPath})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.EqualError(t, err, "failed to run build: exit status 2")
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.Equal(t, "", lines[0])
+		assert.Regexp(t, `^STEP +START +DURATION +STATUS +EXIT CODE$`, lines[1])
+		assert.Regexp(t, `^install +[0-9:]+ +3s +SUCCESS +0$`, lines[2])
+		assert.Regexp(t, `^test +[0-9:]+ +5[67]s +FAILURE +2$`, lines[3])
+
+		stepsJSON, err := os.ReadFile(filepath.Join(artifactsPath, buildlog.StepsFile))
+		assert.Nil(t, err)
+		var results []buildlog.StepResult
+		assert.Nil(t, json.Unmarshal(stepsJSON, &results))
+		assert.Len(t, results, 2)
+		assert.Equal(t, buildlog.StepFailure, results[1].Status)
+		assert.Equal(t, 2, *results[1].ExitCode)
+	})
+
 	t.Run("Failed build cmd with --meta and --meta-file", func(t *testing.T) {
 		root := newBuildCmd()
 
@@ -222,6 +591,61 @@ func TestBuildCmd(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	t.Run("Failed build cmd with --interactive and the docker-api runtime", func(t *testing.T) {
+		defer func() {
+			interactiveMode = false
+		}()
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "-i", "--runtime", launch.DockerAPIRuntime})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		launchNew = func(option launch.Option) launch.Launcher {
+			t.Fatal("the build must not be launched")
+			return nil
+		}
+		defer func() {
+			launchNew = func(option launch.Option) launch.Launcher {
+				return mockLaunch{}
+			}
+		}()
+
+		err := root.Execute()
+		assert.EqualError(t, err, "can't pass the option `interactive` with the runtime `docker-api`, which does not support it")
+	})
+
+	t.Run("Failed build cmd with --interactive and the docker-api runtime of the config", func(t *testing.T) {
+		defer func() {
+			interactiveMode = false
+		}()
+		defer func(c func(string) (config.Config, error)) { configNew = c }(configNew)
+		configNew = func(confPath string) (config.Config, error) {
+			return config.Config{
+				Entries: map[string]*config.Entry{
+					"default": {Runtime: launch.DockerAPIRuntime, UUID: "eb004dc1-614c-11eb-bab9-0242ac120002"},
+				},
+				Current: "default",
+			}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "-i"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		launchNew = func(option launch.Option) launch.Launcher {
+			t.Fatal("the build must not be launched")
+			return nil
+		}
+		defer func() {
+			launchNew = func(option launch.Option) launch.Launcher {
+				return mockLaunch{}
+			}
+		}()
+
+		err := root.Execute()
+		assert.EqualError(t, err, "can't pass the option `interactive` with the runtime `docker-api`, which does not support it")
+	})
+
 	t.Run("Failed build cmd when too many args", func(t *testing.T) {
 		root := newBuildCmd()
 		root.SetArgs([]string{"test", "main"})
@@ -343,3 +767,185 @@ func captureStdout(f func()) string {
 
 	return buf.String()
 }
+
+type mockStepsLogger struct {
+	mockLogger
+	steps []buildlog.Step
+}
+
+func (mock mockStepsLogger) Steps() []buildlog.Step { return mock.steps }
+
+type mockExitError struct {
+	code int
+}
+
+func (e mockExitError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+func (e mockExitError) ExitCode() int { return e.code }
+
+type mockJWTAPI struct {
+	mockAPI
+	jwt string
+}
+
+func (mock mockJWTAPI) JWT() string { return mock.jwt }
+
+type mockOutputLogger struct {
+	done   chan<- struct{}
+	writer io.Writer
+	output string
+}
+
+func (mock mockOutputLogger) Run() {
+	io.WriteString(mock.writer, mock.output)
+	close(mock.done)
+}
+
+func (mock mockOutputLogger) Stop() {}
+
+func (mock mockOutputLogger) Steps() []buildlog.Step { return nil }
+
+// testPassphrase is the passphrase of the secrets in the tests
+func testPassphrase(bool) (string, error) {
+	return "passphrase", nil
+}
+
+func TestRedaction(t *testing.T) {
+	defRedactor := redactor
+	defBuildLogNew := buildLogNew
+	defer func() {
+		redactor = defRedactor
+		buildLogNew = defBuildLogNew
+		setup()
+	}()
+	redactor = redact.New()
+
+	store, err := secret.New(t.TempDir(), "default", testPassphrase)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Set("NPM_TOKEN", "npm-secret"))
+
+	patternFile := filepath.Join(t.TempDir(), "redact-patterns")
+	err = os.WriteFile(patternFile, []byte("xoxb-[0-9]+\n"), 0600)
+	assert.Nil(t, err)
+
+	flags := &buildFlags{redactPatterns: []string{`ghp_[a-z0-9]+`}}
+	err = flags.registerRedaction(
+		patternFile,
+		&config.Entry{Token: "user-token"},
+		mockJWTAPI{jwt: "jwt.payload.signature"},
+		screwdriver.EnvVars{{"GITHUB_TOKEN": "gh-secret"}, {"NODE_ENV": "production"}},
+		store,
+	)
+	assert.Nil(t, err)
+
+	buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}, options buildlog.Options) (buildlog.Logger, error) {
+		output := "main: jwt.payload.signature user-token\r\n" +
+			"main: gh-secret npm-secret production\r\n" +
+			"main: ghp_abc123 xoxb-123\r\n"
+		return mockOutputLogger{done: done, writer: writer, output: output}, nil
+	}
+	launchNew = func(option launch.Option) launch.Launcher {
+		return mockLaunch{}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	_, err = runBuild(&buildSetup{}, launch.Option{ArtifactsPath: t.TempDir()}, buf, buildlog.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "main: *** ***\r\nmain: *** *** production\r\nmain: *** ***\r\n", buf.String())
+
+	flags = &buildFlags{redactPatterns: []string{`(`}}
+	err = flags.registerRedaction(patternFile, &config.Entry{}, mockAPI{}, nil, store)
+	assert.EqualError(t, err, "invalid redact pattern `(`: error parsing regexp: missing closing ): `(`")
+}
+
+func TestHTTPOptions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		flags   *buildFlags
+		entry   *config.Entry
+		want    screwdriver.HTTPOptions
+		wantErr string
+	}{
+		{
+			name:  "success with defaults",
+			flags: &buildFlags{},
+			entry: &config.Entry{},
+			want:  screwdriver.DefaultHTTPOptions(),
+		},
+		{
+			name:  "success with config",
+			flags: &buildFlags{},
+			entry: &config.Entry{
+				APITimeout:      "30s",
+				APIRetries:      "0",
+				APIRetryBackoff: "500ms",
+				APIProxy:        "http://proxy.example.com:8080",
+				CACert:          "/etc/ssl/ca.pem",
+				ClientCert:      "/etc/ssl/client.crt",
+				ClientKey:       "/etc/ssl/client.key",
+			},
+			want: screwdriver.HTTPOptions{
+				Timeout:      30 * time.Second,
+				Retries:      0,
+				RetryBackoff: 500 * time.Millisecond,
+				Proxy:        "http://proxy.example.com:8080",
+				CACert:       "/etc/ssl/ca.pem",
+				ClientCert:   "/etc/ssl/client.crt",
+				ClientKey:    "/etc/ssl/client.key",
+			},
+		},
+		{
+			name: "success with flags overriding config",
+			flags: &buildFlags{
+				apiTimeout:      "2m",
+				apiRetries:      "5",
+				apiRetryBackoff: "2s",
+				apiProxy:        "http://localhost:3128",
+				caCert:          "ca.pem",
+			},
+			entry: &config.Entry{
+				APITimeout: "30s",
+				APIRetries: "0",
+				APIProxy:   "http://proxy.example.com:8080",
+				CACert:     "/etc/ssl/ca.pem",
+			},
+			want: screwdriver.HTTPOptions{
+				Timeout:      2 * time.Minute,
+				Retries:      5,
+				RetryBackoff: 2 * time.Second,
+				Proxy:        "http://localhost:3128",
+				CACert:       "ca.pem",
+			},
+		},
+		{
+			name:    "failure by invalid timeout",
+			flags:   &buildFlags{apiTimeout: "30"},
+			entry:   &config.Entry{},
+			wantErr: "invalid api-timeout `30`: time: missing unit in duration \"30\"",
+		},
+		{
+			name:    "failure by negative retries",
+			flags:   &buildFlags{},
+			entry:   &config.Entry{APIRetries: "-1"},
+			wantErr: "invalid api-retries `-1`: must be 0 or a positive number",
+		},
+		{
+			name:    "failure by invalid retry backoff",
+			flags:   &buildFlags{apiRetryBackoff: "soon"},
+			entry:   &config.Entry{},
+			wantErr: "invalid api-retry-backoff `soon`: time: invalid duration \"soon\"",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.flags.httpOptions(tt.entry)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"path/filepath"
+
+	"github.com/screwdriver-cd/sd-local/cache"
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/spf13/cobra"
+)
+
+const cacheDirName = "cache"
+
+var storeDir = func() (string, error) {
+	base, err := config.BaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, cacheDirName), nil
+}
+
+func newStore() (*cache.Store, error) {
+	dir, err := storeDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir), nil
+}
+
+// NewCacheCmd return cache command.
+func NewCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of sd-local builds.",
+		Long: `Manage the cache of sd-local builds.
+The directories declared in the cache section of screwdriver.yaml are stored under ~/.sdlocal/cache.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := cmd.Help()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cacheCmd.AddCommand(
+		newCacheLsCmd(),
+		newCacheClearCmd(),
+	)
+
+	return cacheCmd
+}
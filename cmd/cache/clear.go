@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newCacheClearCmd() *cobra.Command {
+	cacheClearCmd := &cobra.Command{
+		Use:   "clear [pipeline]",
+		Short: "Clear the cache of sd-local.",
+		Long: `Clear the cache of the pipeline shown by "sd-local cache ls".
+Without the pipeline, all the cache is cleared.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			pipeline := ""
+			if len(args) > 0 {
+				pipeline = args[0]
+			}
+
+			store, err := newStore()
+			if err != nil {
+				return err
+			}
+
+			return store.Clear(pipeline)
+		},
+	}
+
+	return cacheClearCmd
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheClearCmd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		wantOut   string
+		wantError string
+	}{
+		{
+			name: "success clearing a pipeline",
+			args: []string{"clear", "a"},
+			wantOut: "PIPELINE   SCOPE      NAME   PATH     SIZE\n" +
+				"b          pipeline   -      /cache   2.0KB\n",
+		},
+		{
+			name:    "success clearing all",
+			args:    []string{"clear"},
+			wantOut: "PIPELINE   SCOPE   NAME   PATH   SIZE\n",
+		},
+		{
+			name:      "failure by pipeline that does not exist",
+			args:      []string{"clear", "c"},
+			wantError: "cache of pipeline `c` does not exist",
+		},
+		{
+			name:      "failure by too many args",
+			args:      []string{"clear", "a", "b"},
+			wantError: "accepts at most 1 arg(s), received 2",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setupStore(t)
+
+			cmd := NewCacheCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+
+			cmd = NewCacheCmd()
+			cmd.SetArgs([]string{"ls"})
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			err = cmd.Execute()
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantOut, buf.String())
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func newCacheLsCmd() *cobra.Command {
+	cacheLsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List the cache of sd-local.",
+		Long: `List the cached directories of sd-local.
+The name is the job name of the job scope cache and the event ID of the event scope cache.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			store, err := newStore()
+			if err != nil {
+				return err
+			}
+
+			entries, err := store.List()
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+			fmt.Fprintln(tw, "PIPELINE\tSCOPE\tNAME\tPATH\tSIZE")
+			for _, e := range entries {
+				name := e.Name
+				if name == "" {
+					name = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Pipeline, e.Scope, name, e.Path, formatSize(e.Size))
+			}
+
+			return tw.Flush()
+		},
+	}
+
+	return cacheLsCmd
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/cache"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupStore creates the cache of the pipelines "a" and "b" in a temporary directory
+func setupStore(t *testing.T) {
+	dir := t.TempDir()
+
+	sdir := storeDir
+	t.Cleanup(func() {
+		storeDir = sdir
+	})
+	storeDir = func() (string, error) {
+		return dir, nil
+	}
+
+	containerPath := func(p string) (string, error) { return p, nil }
+	store := cache.New(dir)
+	if _, err := store.Volumes("a", "1", "test", &screwdriver.Cache{Job: []string{"/root/.npm"}}, containerPath); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := store.Volumes("b", "1", "test", &screwdriver.Cache{Pipeline: []string{"/cache"}}, containerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, _, _ := strings.Cut(volumes[0], ":")
+	if err := os.WriteFile(filepath.Join(host, "file"), bytes.Repeat([]byte("a"), 2048), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size int64
+		want string
+	}{
+		{size: 0, want: "0B"},
+		{size: 1023, want: "1023B"},
+		{size: 1536, want: "1.5KB"},
+		{size: 5 * 1024 * 1024, want: "5.0MB"},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.want, formatSize(tt.size))
+	}
+}
+
+func TestCacheLsCmd(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		wantOut  string
+		checkErr bool
+	}{
+		{
+			name: "success",
+			args: []string{"ls"},
+			wantOut: "PIPELINE   SCOPE      NAME   PATH         SIZE\n" +
+				"a          job        test   /root/.npm   0B\n" +
+				"b          pipeline   -      /cache       2.0KB\n",
+			checkErr: false,
+		},
+		{
+			name:     "failure by too many args",
+			args:     []string{"ls", "a"},
+			wantOut:  "",
+			checkErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setupStore(t)
+
+			cmd := NewCacheCmd()
+			cmd.SetArgs(tt.args)
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			err := cmd.Execute()
+			if tt.checkErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantOut, buf.String())
+			}
+		})
+	}
+}
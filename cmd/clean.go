@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/scm"
+	"github.com/screwdriver-cd/sd-local/state"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listResources  = launch.ListResources
+	removeResource = launch.RemoveResource
+	listClones     = scm.Clones
+	removeClone    = scm.RemoveClone
+)
+
+// stateDir returns the directory of the state files of the running sd-local processes
+func stateDir() (string, error) {
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(sdlocalDir, "state"), nil
+}
+
+// recordResources records the resources in the session before they are created,
+// and returns the function to forget them after they are removed
+func recordResources(resources ...state.Resource) func() {
+	if err := session.Add(resources...); err != nil {
+		logrus.Warn(err)
+	}
+
+	return func() {
+		if err := session.Remove(resources...); err != nil {
+			logrus.Warn(err)
+		}
+	}
+}
+
+// resourceKey identifies the resource regardless of sudo, which does not change the resource
+func resourceKey(r state.Resource) string {
+	return fmt.Sprintf("%s/%s/%s", r.Runtime, r.Kind, r.Name)
+}
+
+// cleanTargets returns the resources to remove and the sessions whose resources are removed.
+// Without all, only the resources of the sd-local processes which are not running any more are returned.
+// With all, the resources of the running processes, the ones without the labels and all the clones are returned as well.
+func cleanTargets(runtime string, sudo, all bool) ([]state.Resource, []*state.Session, error) {
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	sessions, err := state.New(dir).Sessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	running := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		running[s.ID] = s.Running()
+	}
+
+	listed, err := listResources(runtime, sudo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := []state.Resource{}
+	seen := map[string]bool{}
+	add := func(r state.Resource) {
+		if k := resourceKey(r); !seen[k] {
+			seen[k] = true
+			targets = append(targets, r)
+		}
+	}
+
+	exists := make(map[string]bool, len(listed))
+	for _, r := range listed {
+		exists[resourceKey(r.Resource)] = true
+
+		if !all {
+			// The process which created the resource without the labels is unknown
+			if r.Session == "" {
+				continue
+			}
+			alive, ok := running[r.Session]
+			if !ok {
+				alive = state.ProcessRunning(r.PID)
+			}
+			if alive {
+				continue
+			}
+		}
+		add(r.Resource)
+	}
+
+	orphaned := []*state.Session{}
+	for _, s := range sessions {
+		if running[s.ID] {
+			if !all {
+				continue
+			}
+			logrus.Warnf("The resources of the running sd-local (pid %d) are removed", s.PID)
+		}
+		orphaned = append(orphaned, s)
+
+		// The resources are recorded before they are created, so the ones which do not exist are skipped
+		for _, r := range s.Resources {
+			switch {
+			case r.Kind == state.CloneKind:
+				if _, err := os.Stat(r.Name); err == nil {
+					add(r)
+				}
+			case r.Runtime == runtime:
+				if exists[resourceKey(r)] {
+					add(r)
+				}
+			default:
+				// The resource of the other runtime cannot be checked
+				add(r)
+			}
+		}
+	}
+
+	if all {
+		clones, err := listClones(sdlocalDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range clones {
+			add(state.Resource{Kind: state.CloneKind, Name: c, Sudo: sudo})
+		}
+	}
+
+	launch.SortResources(targets)
+
+	return targets, orphaned, nil
+}
+
+// removeTargets removes the resources and returns the ones which failed to be removed
+func removeTargets(targets []state.Resource) map[string]bool {
+	failed := map[string]bool{}
+	for _, r := range targets {
+		var err error
+		if r.Kind == state.CloneKind {
+			err = removeClone(r.Name, r.Sudo)
+		} else {
+			err = removeResource(r)
+		}
+
+		if err != nil {
+			logrus.Warnf("failed to remove %s: %v", r, err)
+			failed[resourceKey(r)] = true
+		}
+	}
+
+	return failed
+}
+
+// forgetSessions removes the state files of the sessions, except for the resources which failed to be removed
+func forgetSessions(sessions []*state.Session, failed map[string]bool) {
+	for _, s := range sessions {
+		removed := []state.Resource{}
+		for _, r := range s.Resources {
+			if !failed[resourceKey(r)] {
+				removed = append(removed, r)
+			}
+		}
+
+		if err := s.Remove(removed...); err != nil {
+			logrus.Warn(err)
+		}
+	}
+}
+
+func newCleanCmd() *cobra.Command {
+	var (
+		all     bool
+		dryRun  bool
+		sudo    bool
+		runtime string
+	)
+
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove the resources left by the killed builds.",
+		Long: `Remove the volumes, the containers, the networks and the cloned source code
+which are left when sd-local is killed or crashes.
+By default, only the resources of the sd-local processes which are not running
+any more are removed.
+With --all, the resources of the running processes and the ones created by
+the older sd-local are removed as well.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			runtime, err := configRuntime(runtime)
+			if err != nil {
+				return err
+			}
+			if runtime == "" {
+				runtime = launch.DockerRuntime
+			}
+
+			targets, sessions, err := cleanTargets(runtime, sudo, all)
+			if err != nil {
+				return err
+			}
+
+			if len(targets) == 0 {
+				forgetSessions(sessions, nil)
+				fmt.Fprintln(cmd.OutOrStdout(), "No resources to remove")
+				return nil
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+			fmt.Fprintln(tw, "KIND\tNAME\tRUNTIME")
+			for _, r := range targets {
+				rt := r.Runtime
+				if rt == "" {
+					rt = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Kind, r.Name, rt)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if dryRun {
+				return nil
+			}
+
+			failed := removeTargets(targets)
+			forgetSessions(sessions, failed)
+
+			if len(failed) > 0 {
+				return fmt.Errorf("failed to remove %d of %d resources", len(failed), len(targets))
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d resources\n", len(targets))
+			return nil
+		},
+	}
+
+	cleanCmd.Flags().BoolVar(
+		&all,
+		"all",
+		false,
+		"Remove all the resources of sd-local, including the ones of the running builds.")
+
+	cleanCmd.Flags().BoolVar(
+		&dryRun,
+		"dry-run",
+		false,
+		"List the resources to remove without removing them.")
+
+	cleanCmd.Flags().BoolVar(
+		&sudo,
+		"sudo",
+		false,
+		"Use sudo command to list and remove the resources.")
+
+	cleanCmd.Flags().StringVar(
+		&runtime,
+		"runtime",
+		"",
+		"Container runtime to list the resources with. (docker, podman or docker-api) Default value is from config, otherwise docker.")
+
+	return cleanCmd
+}
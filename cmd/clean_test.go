@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// deadPID is the process ID which no process has
+const deadPID = 999999999
+
+type fakeClean struct {
+	dir     string
+	store   *state.Store
+	removed []string
+}
+
+func setupClean(t *testing.T, listed []launch.Resource) *fakeClean {
+	f := &fakeClean{dir: t.TempDir()}
+	f.store = state.New(filepath.Join(f.dir, "state"))
+	t.Setenv(config.HomeEnv, f.dir)
+
+	defList, defRemove, defClones, defRemoveClone := listResources, removeResource, listClones, removeClone
+	t.Cleanup(func() {
+		listResources, removeResource, listClones, removeClone = defList, defRemove, defClones, defRemoveClone
+	})
+
+	listResources = func(runtime string, sudo bool) ([]launch.Resource, error) {
+		return listed, nil
+	}
+	removeResource = func(r state.Resource) error {
+		f.removed = append(f.removed, r.String())
+		if r.Name == "broken" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	removeClone = func(path string, sudo bool) error {
+		f.removed = append(f.removed, "clone "+filepath.Base(path))
+		return nil
+	}
+
+	return f
+}
+
+// session saves the session which has the resources
+func (f *fakeClean) session(t *testing.T, pid int, resources ...state.Resource) *state.Session {
+	s := f.store.NewSession()
+	s.PID = pid
+	if err := s.Add(resources...); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// clone makes the directory of the cloned source code
+func (f *fakeClean) clone(t *testing.T, name string) string {
+	path := filepath.Join(f.dir, "repo", name)
+	if err := os.MkdirAll(path, 0777); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (f *fakeClean) sessionIDs(t *testing.T) []string {
+	sessions, err := f.store.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func runCleanCmd(args ...string) (string, error) {
+	cmd := newCleanCmd()
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	cmd.SetErr(bytes.NewBuffer(nil))
+	cmd.SetArgs(args)
+
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+// tableRows returns the rows of the table with the columns separated by a space
+func tableRows(out string) []string {
+	rows := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		rows = append(rows, strings.Join(strings.Fields(line), " "))
+	}
+	return rows
+}
+
+func volume(runtime, name string) state.Resource {
+	return state.Resource{Runtime: runtime, Kind: state.VolumeKind, Name: name}
+}
+
+func TestCleanCmd(t *testing.T) {
+	listed := func(r state.Resource, session string, pid int) launch.Resource {
+		return launch.Resource{Resource: r, Session: session, PID: pid}
+	}
+
+	t.Run("success to remove the stale resources", func(t *testing.T) {
+		f := setupClean(t, nil)
+		deadClone := f.clone(t, "1")
+		liveClone := f.clone(t, "2")
+		dead := f.session(t, deadPID,
+			state.Resource{Kind: state.CloneKind, Name: deadClone},
+			state.Resource{Kind: state.CloneKind, Name: filepath.Join(f.dir, "repo", "removed")},
+			volume("docker", "SD_LAUNCH_BIN-dead"),
+			volume("docker", "SD_LAUNCH_HAB-dead"),
+			volume("podman", "SD_LAUNCH_BIN-dead"),
+		)
+		live := f.session(t, os.Getpid(),
+			state.Resource{Kind: state.CloneKind, Name: liveClone},
+			volume("docker", "SD_LAUNCH_BIN-live"),
+		)
+		listResources = func(runtime string, sudo bool) ([]launch.Resource, error) {
+			assert.Equal(t, launch.DockerRuntime, runtime)
+			assert.True(t, sudo)
+			return []launch.Resource{
+				listed(volume("docker", "SD_LAUNCH_BIN-dead"), dead.ID, deadPID),
+				listed(volume("docker", "SD_LAUNCH_BIN-live"), live.ID, os.Getpid()),
+				// The session of the killed sd-local which did not save the state
+				listed(state.Resource{Runtime: "docker", Kind: state.ContainerKind, Name: "sd-local-dind"}, "unknown", deadPID),
+				// The resource created by the older sd-local
+				listed(volume("docker", "SD_LAUNCH_BIN"), "", 0),
+			}, nil
+		}
+
+		out, err := runCleanCmd("--runtime", "docker", "--sudo", "--dry-run")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"KIND NAME RUNTIME",
+			"container sd-local-dind docker",
+			"volume SD_LAUNCH_BIN-dead docker",
+			"volume SD_LAUNCH_BIN-dead podman",
+			"clone " + deadClone + " -",
+		}, tableRows(out))
+		assert.Empty(t, f.removed)
+		assert.ElementsMatch(t, []string{dead.ID, live.ID}, f.sessionIDs(t))
+
+		out, err = runCleanCmd("--runtime", "docker", "--sudo")
+		assert.Nil(t, err)
+		assert.Contains(t, out, "Removed 4 resources\n")
+		assert.Equal(t, []string{
+			"container sd-local-dind (docker)",
+			"volume SD_LAUNCH_BIN-dead (docker)",
+			"volume SD_LAUNCH_BIN-dead (podman)",
+			"clone 1",
+		}, f.removed)
+		assert.Equal(t, []string{live.ID}, f.sessionIDs(t))
+	})
+
+	t.Run("success to remove all the resources", func(t *testing.T) {
+		f := setupClean(t, []launch.Resource{
+			listed(volume("podman", "SD_LAUNCH_BIN-live"), "", 0),
+			listed(volume("podman", "SD_LAUNCH_HAB"), "", 0),
+		})
+		live := f.session(t, os.Getpid(), volume("podman", "SD_LAUNCH_BIN-live"))
+		f.clone(t, "1")
+		listClones = func(baseDir string) ([]string, error) {
+			assert.Equal(t, f.dir, baseDir)
+			return []string{filepath.Join(baseDir, "repo", "1")}, nil
+		}
+
+		out, err := runCleanCmd("--runtime", "podman", "--all")
+		assert.Nil(t, err)
+		assert.Contains(t, out, "Removed 3 resources\n")
+		assert.Equal(t, []string{
+			"volume SD_LAUNCH_HAB (podman)",
+			"volume SD_LAUNCH_BIN-live (podman)",
+			"clone 1",
+		}, f.removed)
+		assert.NotContains(t, f.sessionIDs(t), live.ID)
+	})
+
+	t.Run("success with the runtime of the config", func(t *testing.T) {
+		f := setupClean(t, nil)
+		dead := f.session(t, deadPID, volume("podman", "SD_LAUNCH_BIN"))
+
+		defer func(c func(string) (config.Config, error)) { configNew = c }(configNew)
+		configNew = func(confPath string) (config.Config, error) {
+			return config.Config{
+				Entries: map[string]*config.Entry{
+					"default": {Runtime: launch.PodmanRuntime},
+				},
+				Current: "default",
+			}, nil
+		}
+		var gotRuntime string
+		listResources = func(runtime string, sudo bool) ([]launch.Resource, error) {
+			gotRuntime = runtime
+			return nil, nil
+		}
+
+		// The state of the session whose resources do not exist is removed
+		out, err := runCleanCmd()
+		assert.Nil(t, err)
+		assert.Equal(t, "No resources to remove\n", out)
+		assert.Equal(t, launch.PodmanRuntime, gotRuntime)
+		assert.NotContains(t, f.sessionIDs(t), dead.ID)
+	})
+
+	t.Run("failure to remove the resource", func(t *testing.T) {
+		f := setupClean(t, []launch.Resource{
+			listed(volume("docker", "broken"), "", deadPID),
+			listed(volume("docker", "SD_LAUNCH_BIN"), "", deadPID),
+		})
+		dead := f.session(t, deadPID, volume("docker", "broken"), volume("docker", "SD_LAUNCH_BIN"))
+
+		_, err := runCleanCmd("--runtime", "docker")
+		assert.EqualError(t, err, "failed to remove 1 of 2 resources")
+
+		// The resource which failed to be removed is kept in the state to retry
+		sessions, err := f.store.Sessions()
+		assert.Nil(t, err)
+		assert.Len(t, sessions, 1)
+		assert.Equal(t, dead.ID, sessions[0].ID)
+		assert.Equal(t, []state.Resource{volume("docker", "broken")}, sessions[0].Resources)
+	})
+
+	t.Run("failure by listing", func(t *testing.T) {
+		setupClean(t, nil)
+		listResources = func(runtime string, sudo bool) ([]launch.Resource, error) {
+			return nil, errors.New("failed to list containers: exit status 1")
+		}
+
+		_, err := runCleanCmd("--runtime", "docker")
+		assert.EqualError(t, err, "failed to list containers: exit status 1")
+	})
+}
+
+func TestRecordResources(t *testing.T) {
+	dir := t.TempDir()
+	store := state.New(dir)
+
+	defer func(s *state.Session) { session = s }(session)
+	session = store.NewSession()
+
+	forget := recordResources(volume("docker", "SD_LAUNCH_BIN"))
+	sessions, err := store.Sessions()
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, []state.Resource{volume("docker", "SD_LAUNCH_BIN")}, sessions[0].Resources)
+
+	forget()
+	sessions, err = store.Sessions()
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+}
@@ -3,21 +3,20 @@ package config
 import (
 	"path/filepath"
 
-	"github.com/mitchellh/go-homedir"
+	"github.com/screwdriver-cd/sd-local/config"
 	"github.com/spf13/cobra"
 )
 
 const (
 	configFileName = "config"
-	configDirName  = ".sdlocal"
 )
 
 var filePath = func() (string, error) {
-	home, err := homedir.Dir()
+	base, err := config.BaseDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, configDirName, configFileName), nil
+	return filepath.Join(base, configFileName), nil
 }
 
 // NewConfigCmd return config command.
@@ -41,6 +40,13 @@ func NewConfigCmd() *cobra.Command {
 		newConfigCreateCmd(),
 		newConfigDeleteCmd(),
 		newConfigUseCmd(),
+		newConfigGetCmd(),
+		newConfigUnsetCmd(),
+		newConfigRenameCmd(),
+		newConfigCopyCmd(),
+		newConfigExportCmd(),
+		newConfigImportCmd(),
+		newConfigVerifyCmd(),
 	)
 
 	return configCmd
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newConfigCopyCmd() *cobra.Command {
+	token := ""
+
+	configCopyCmd := &cobra.Command{
+		Use:   "copy [src] [dst]",
+		Short: "Copy the config of sd-local",
+		Long: `Copy the config of sd-local to a new config.
+The new config has the token of the source config unless --token is set.
+The secrets and the cached JWT of the source config are copied as well.
+With --token -, the token is read from the terminal without echo, or from the standard input.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			entry, err := config.CopyEntry(args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("token") {
+				if token == "-" {
+					token, err = readValue(cmd, "token")
+					if err != nil {
+						return err
+					}
+				}
+
+				err = entry.Set("token", token)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = config.Save()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	configCopyCmd.Flags().StringVar(
+		&token,
+		"token",
+		"",
+		"Token of the new config. Use - to read it from the terminal or the standard input.")
+
+	return configCopyCmd
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigCopyCmd(t *testing.T) {
+	testCase := []struct {
+		name      string
+		args      []string
+		stdin     string
+		wantToken string
+		wantErr   string
+	}{
+		{
+			name:      "success",
+			args:      []string{"copy", "test", "work"},
+			wantToken: "sd-token-test",
+		},
+		{
+			name:      "success with a different token",
+			args:      []string{"copy", "test", "work", "--token", "work-token"},
+			wantToken: "work-token",
+		},
+		{
+			name:      "success with the token from stdin",
+			args:      []string{"copy", "test", "work", "--token", "-"},
+			stdin:     "stdin-token\n",
+			wantToken: "stdin-token",
+		},
+		{
+			name:    "failure by Entry that does not exist",
+			args:    []string{"copy", "doesnotexist", "work"},
+			wantErr: "config `doesnotexist` does not exist",
+		},
+		{
+			name:    "failure by Entry that already exists",
+			args:    []string{"copy", "test", "default"},
+			wantErr: "config `default` already exists",
+		},
+		{
+			name:    "failure by too many args",
+			args:    []string{"copy", "test", "work", "many"},
+			wantErr: "accepts 2 arg(s), received 3",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			cnfPath := setupTestConfig(t, "config")
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetOut(bytes.NewBuffer(nil))
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+
+			c, err := config.New(cnfPath)
+			assert.Nil(t, err)
+			assert.Equal(t, "default", c.Current)
+			assert.Equal(t, "api-test.screwdriver.com", c.Entries["work"].APIURL)
+			assert.Equal(t, "1.0.0-test", c.Entries["work"].Launcher.Version)
+			assert.Equal(t, tt.wantToken, c.Entries["work"].Token)
+			assert.Equal(t, "sd-token-test", c.Entries["test"].Token)
+		})
+	}
+}
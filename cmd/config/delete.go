@@ -8,8 +8,9 @@ func newConfigDeleteCmd() *cobra.Command {
 	configDeleteCmd := &cobra.Command{
 		Use:   "delete [name]",
 		Short: "Delete the config of sd-local",
-		Long:  `Delete the config of sd-local.`,
-		Args:  cobra.ExactArgs(1),
+		Long: `Delete the config of sd-local.
+The secrets and the cached JWT of the config are removed as well.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newConfigExportCmd() *cobra.Command {
+	noToken := false
+
+	configExportCmd := &cobra.Command{
+		Use:   "export [name...]",
+		Short: "Export the configs of sd-local",
+		Long: `Export the configs of sd-local as YAML, which can be imported with the import sub command.
+All the configs are exported when no name is specified.
+The values overridden by the environment variables are not exported.
+The encrypted tokens are exported encrypted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			return config.Export(cmd.OutOrStdout(), args, !noToken)
+		},
+	}
+
+	configExportCmd.Flags().BoolVar(
+		&noToken,
+		"no-token",
+		false,
+		"Exclude the tokens.")
+
+	return configExportCmd
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigExportCmd(t *testing.T) {
+	testCase := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr string
+	}{
+		{
+			name: "success",
+			args: []string{"export", "test"},
+			wantOut: `version: 1
+configs:
+  test:
+    api-url: api-test.screwdriver.com
+    store-url: store-test.screwdriver.com
+    token: sd-token-test
+    UUID: eb004dc1-614c-11eb-bab9-0242ac120002
+    launcher:
+      version: 1.0.0-test
+      image: screwdrivercd/launcher
+`,
+		},
+		{
+			name: "success without the token",
+			args: []string{"export", "default", "--no-token"},
+			wantOut: `version: 1
+configs:
+  default:
+    api-url: api.screwdriver.com
+    store-url: store.screwdriver.com
+    token: ""
+    UUID: '-'
+    launcher:
+      version: 1.0.0
+      image: screwdrivercd/launcher
+`,
+		},
+		{
+			name:    "failure by Entry that does not exist",
+			args:    []string{"export", "doesnotexist"},
+			wantErr: "config `doesnotexist` does not exist",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestConfig(t, "config")
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantOut, buf.String())
+		})
+	}
+
+	t.Run("success with all configs", func(t *testing.T) {
+		setupTestConfig(t, "config")
+
+		cmd := NewConfigCmd()
+		cmd.SetArgs([]string{"export"})
+		buf := bytes.NewBuffer(nil)
+		cmd.SetOut(buf)
+		assert.Nil(t, cmd.Execute())
+		assert.Contains(t, buf.String(), "  default:\n")
+		assert.Contains(t, buf.String(), "  test:\n")
+	})
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/spf13/cobra"
+)
+
+// readDecryptionPassphrase returns the passphrase of SDLOCAL_PASSPHRASE, or the one entered in the terminal
+func readDecryptionPassphrase(cmd *cobra.Command) (string, error) {
+	if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	f, ok := terminal(cmd)
+	if !ok {
+		return "", fmt.Errorf("%s must be set to decrypt the token without a terminal", config.PassphraseEnv)
+	}
+
+	passphrase, err := readSecret(cmd, f, "Passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the passphrase: %v", err)
+	}
+
+	return passphrase, nil
+}
+
+func newConfigGetCmd() *cobra.Command {
+	configGetCmd := &cobra.Command{
+		Use:   "get [key]",
+		Short: "Get the value of the config of sd-local",
+		Long: `Get the value of the key in the current config of sd-local.
+The keys are the same as the set sub command.
+The value overridden by the environment variable is printed.
+The encrypted token is decrypted with the passphrase from the terminal or SDLOCAL_PASSPHRASE.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			key := args[0]
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			entry, err := config.Entry(config.Current)
+			if err != nil {
+				return err
+			}
+
+			if key == "token" && entry.TokenLocked() {
+				passphrase, err := readDecryptionPassphrase(cmd)
+				if err != nil {
+					return err
+				}
+
+				if err := entry.DecryptToken(passphrase); err != nil {
+					return err
+				}
+			}
+
+			value, err := entry.Get(key)
+			if err != nil {
+				if isInvalidKeyError(err) {
+					_ = cmd.Help()
+				}
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	}
+
+	return configGetCmd
+}
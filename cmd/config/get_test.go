@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestConfig copies the config in testdata to a temporary directory and makes the commands use it, returning its path
+func setupTestConfig(t *testing.T, name string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnfPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(cnfPath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defFilePath := filePath
+	t.Cleanup(func() {
+		filePath = defFilePath
+	})
+	filePath = func() (string, error) {
+		return cnfPath, nil
+	}
+
+	return cnfPath
+}
+
+func TestConfigGetCmd(t *testing.T) {
+	testCase := []struct {
+		name      string
+		args      []string
+		encryptBy string
+		env       map[string]string
+		wantOut   string
+		wantErr   string
+	}{
+		{
+			name:    "success",
+			args:    []string{"get", "api-url"},
+			wantOut: "api.screwdriver.com\n",
+		},
+		{
+			name:    "success with the launcher",
+			args:    []string{"get", "launcher-version"},
+			wantOut: "1.0.0\n",
+		},
+		{
+			name:    "success with the environment variable",
+			args:    []string{"get", "token"},
+			env:     map[string]string{"SDLOCAL_CONFIG": "test", "SDLOCAL_TOKEN": "env-token"},
+			wantOut: "env-token\n",
+		},
+		{
+			name:      "success with the encrypted token",
+			args:      []string{"get", "token"},
+			encryptBy: "passphrase",
+			env:       map[string]string{config.PassphraseEnv: "passphrase"},
+			wantOut:   "encrypted-sd-token\n",
+		},
+		{
+			name:      "failure by the encrypted token with wrong passphrase",
+			args:      []string{"get", "token"},
+			encryptBy: "passphrase",
+			env:       map[string]string{config.PassphraseEnv: "wrong"},
+			wantErr:   "failed to decrypt token: the passphrase is wrong or the token is broken",
+		},
+		{
+			name:      "failure by the encrypted token without passphrase",
+			args:      []string{"get", "token"},
+			encryptBy: "passphrase",
+			wantErr:   "SDLOCAL_PASSPHRASE must be set to decrypt the token without a terminal",
+		},
+		{
+			name:    "failure by an invalid key",
+			args:    []string{"get", "invalid-key"},
+			wantErr: "invalid key invalid-key",
+		},
+		{
+			name:    "failure by too little args",
+			args:    []string{"get"},
+			wantErr: "accepts 1 arg(s), received 0",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			cnfPath := setupTestConfig(t, "config")
+			if tt.encryptBy != "" {
+				c, err := config.New(cnfPath)
+				assert.Nil(t, err)
+				assert.Nil(t, c.Entries["default"].EncryptToken("encrypted-sd-token", tt.encryptBy))
+				assert.Nil(t, c.Save())
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantOut, buf.String())
+		})
+	}
+}
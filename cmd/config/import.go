@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigImportCmd() *cobra.Command {
+	overwrite := false
+
+	configImportCmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import the configs of sd-local",
+		Long: `Import the configs exported by the export sub command.
+The configs are read from the standard input when the file is omitted or -.
+The existing configs are not replaced unless --overwrite is set.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			var in io.Reader = cmd.InOrStdin()
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("failed to open config to import: %v", err)
+				}
+				defer f.Close()
+				in = f
+			}
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			_, err = config.Import(in, overwrite)
+			if err != nil {
+				return err
+			}
+
+			err = config.Save()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	configImportCmd.Flags().BoolVar(
+		&overwrite,
+		"overwrite",
+		false,
+		"Replace the existing configs with the same names.")
+
+	return configImportCmd
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigImportCmd(t *testing.T) {
+	const imported = `configs:
+  test:
+    api-url: api-imported.screwdriver.com
+  work:
+    api-url: api-work.screwdriver.com
+    token: work-token
+`
+	dir := t.TempDir()
+	importFile := filepath.Join(dir, "imported.yaml")
+	if err := os.WriteFile(importFile, []byte(imported), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCase := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantTest string
+		wantErr  string
+	}{
+		{
+			name:     "success with the file",
+			args:     []string{"import", importFile, "--overwrite"},
+			wantTest: "api-imported.screwdriver.com",
+		},
+		{
+			name:     "success with stdin",
+			args:     []string{"import", "--overwrite"},
+			stdin:    imported,
+			wantTest: "api-imported.screwdriver.com",
+		},
+		{
+			name:    "failure by Entry that already exists",
+			args:    []string{"import", importFile},
+			wantErr: "config `test` already exists",
+		},
+		{
+			name:    "failure by the file that does not exist",
+			args:    []string{"import", filepath.Join(dir, "doesnotexist")},
+			wantErr: "failed to open config to import: open " + filepath.Join(dir, "doesnotexist") + ": no such file or directory",
+		},
+		{
+			name:    "failure by too many args",
+			args:    []string{"import", importFile, "many"},
+			wantErr: "accepts at most 1 arg(s), received 2",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			cnfPath := setupTestConfig(t, "config")
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetOut(bytes.NewBuffer(nil))
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+
+			c, err := config.New(cnfPath)
+			assert.Nil(t, err)
+			assert.Equal(t, "default", c.Current)
+			assert.Equal(t, tt.wantTest, c.Entries["test"].APIURL)
+			assert.Equal(t, "work-token", c.Entries["work"].Token)
+			assert.Equal(t, "sd-token", c.Entries["default"].Token)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newConfigRenameCmd() *cobra.Command {
+	configRenameCmd := &cobra.Command{
+		Use:   "rename [name] [new name]",
+		Short: "Rename the config of sd-local",
+		Long: `Rename the config of sd-local.
+The current config stays current with the new name.
+The secrets and the cached JWT of the config are moved to the new name.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			err = config.RenameEntry(args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			err = config.Save()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	return configRenameCmd
+}
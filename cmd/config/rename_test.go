@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigRenameCmd(t *testing.T) {
+	testCase := []struct {
+		name        string
+		args        []string
+		wantCurrent string
+		wantEntries []string
+		wantErr     string
+	}{
+		{
+			name:        "success",
+			args:        []string{"rename", "test", "work"},
+			wantCurrent: "default",
+			wantEntries: []string{"default", "work"},
+		},
+		{
+			name:        "success to rename the current config",
+			args:        []string{"rename", "default", "home"},
+			wantCurrent: "home",
+			wantEntries: []string{"home", "test"},
+		},
+		{
+			name:    "failure by Entry that does not exist",
+			args:    []string{"rename", "doesnotexist", "work"},
+			wantErr: "config `doesnotexist` does not exist",
+		},
+		{
+			name:    "failure by Entry that already exists",
+			args:    []string{"rename", "default", "test"},
+			wantErr: "config `test` already exists",
+		},
+		{
+			name:    "failure by too little args",
+			args:    []string{"rename", "default"},
+			wantErr: "accepts 2 arg(s), received 1",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			cnfPath := setupTestConfig(t, "config")
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(bytes.NewBuffer(nil))
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+
+			c, err := config.New(cnfPath)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantCurrent, c.Current)
+			for _, name := range tt.wantEntries {
+				assert.Contains(t, c.Entries, name)
+			}
+			assert.Len(t, c.Entries, len(tt.wantEntries))
+		})
+	}
+	t.Run("success to read the secret set before the rename", func(t *testing.T) {
+		cnfPath := setupTestConfig(t, "config")
+		secretsDir := filepath.Join(filepath.Dir(cnfPath), "secrets")
+		passphrase := func(bool) (string, error) { return "passphrase", nil }
+
+		secrets, err := secret.New(secretsDir, "test", passphrase)
+		assert.Nil(t, err)
+		assert.Nil(t, secrets.Set("NPM_TOKEN", "npm-token"))
+		assert.Nil(t, secrets.Save())
+
+		cmd := NewConfigCmd()
+		cmd.SetArgs([]string{"rename", "test", "work"})
+		cmd.SetOut(bytes.NewBuffer(nil))
+		assert.Nil(t, cmd.Execute())
+
+		secrets, err = secret.New(secretsDir, "work", passphrase)
+		assert.Nil(t, err)
+		resolved, err := secrets.Resolve([]string{"NPM_TOKEN"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"NPM_TOKEN": "npm-token"}, resolved)
+
+		secrets, err = secret.New(secretsDir, "test", passphrase)
+		assert.Nil(t, err)
+		assert.Empty(t, secrets.Names())
+	})
+}
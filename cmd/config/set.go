@@ -1,16 +1,87 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"strings"
 
+	"github.com/screwdriver-cd/sd-local/config"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func isInvalidKeyError(err error) bool {
 	return strings.Contains(err.Error(), "invalid key")
 }
 
+// terminal returns the file of the input when it is a terminal
+func terminal(cmd *cobra.Command) (*os.File, bool) {
+	f, ok := cmd.InOrStdin().(*os.File)
+	return f, ok && term.IsTerminal(int(f.Fd()))
+}
+
+// readSecret reads the line from the terminal without echoing it
+func readSecret(cmd *cobra.Command, f *os.File, prompt string) (string, error) {
+	fmt.Fprint(cmd.OutOrStdout(), prompt)
+	value, err := term.ReadPassword(int(f.Fd()))
+	fmt.Fprintln(cmd.OutOrStdout())
+	return string(value), err
+}
+
+// readValue reads the value from the terminal without echoing it, or from the piped input
+func readValue(cmd *cobra.Command, key string) (string, error) {
+	if f, ok := terminal(cmd); ok {
+		value, err := readSecret(cmd, f, fmt.Sprintf("Value of %s: ", key))
+		if err != nil {
+			return "", fmt.Errorf("failed to read the value: %v", err)
+		}
+		return value, nil
+	}
+
+	value, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return "", fmt.Errorf("failed to read the value: %v", err)
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(value), "\n"), "\r"), nil
+}
+
+// readPassphrase returns the passphrase of SDLOCAL_PASSPHRASE, or the one entered twice in the terminal
+func readPassphrase(cmd *cobra.Command) (string, error) {
+	if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	f, ok := terminal(cmd)
+	if !ok {
+		return "", fmt.Errorf("%s must be set to encrypt the token without a terminal", config.PassphraseEnv)
+	}
+
+	passphrase, err := readSecret(cmd, f, "Passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the passphrase: %v", err)
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase is empty")
+	}
+
+	confirmation, err := readSecret(cmd, f, "Confirm passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the passphrase: %v", err)
+	}
+	if passphrase != confirmation {
+		return "", errors.New("the passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
 func newConfigSetCmd() *cobra.Command {
+	readStdin := false
+	encrypt := false
+
 	configSetCmd := &cobra.Command{
 		Use:   "set [key] [value]",
 		Short: "Set the config of sd-local",
@@ -21,12 +92,32 @@ Can set the below settings:
 * Screwdriver.cd Token as "token"
 * Screwdriver.cd launcher version as "launcher-version"
 * Screwdriver.cd UUID as "uuid"
-* Screwdriver.cd launcher image as "launcher-image"`,
-		Args: cobra.ExactArgs(2),
+* Screwdriver.cd launcher image as "launcher-image"
+* Container runtime as "runtime" (docker, podman or docker-api)
+* Timeout of a request to the Screwdriver.cd API as "api-timeout" (e.g. 30s)
+* Number of retries of a request to the Screwdriver.cd API as "api-retries"
+* Wait before the first retry as "api-retry-backoff" (e.g. 1s)
+* Proxy to the Screwdriver.cd API as "api-proxy"
+* Path to the PEM bundle of the trusted CAs as "ca-cert"
+* Path to the PEM client certificate as "client-cert"
+* Path to the PEM key of the client certificate as "client-key"
+With --stdin, the value is read from the terminal without echo, or from the standard input.
+With --encrypt, the token is saved encrypted with the passphrase from the terminal or SDLOCAL_PASSPHRASE.
+e.g. SDLOCAL_PASSPHRASE=<passphrase> sd-local config set token --stdin --encrypt < token.txt`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if readStdin {
+				return cobra.ExactArgs(1)(cmd, args)
+			}
+
+			return cobra.ExactArgs(2)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 
-			key, value := args[0], args[1]
+			key := args[0]
+			if encrypt && key != "token" {
+				return fmt.Errorf("only the token can be encrypted, not %s", key)
+			}
 
 			path, err := filePath()
 			if err != nil {
@@ -43,12 +134,37 @@ Can set the below settings:
 				return err
 			}
 
-			err = entry.Set(key, value)
-			if err != nil {
-				if isInvalidKeyError(err) {
-					_ = cmd.Help()
+			var value string
+			if readStdin {
+				value, err = readValue(cmd, key)
+				if err != nil {
+					return err
+				}
+			} else {
+				value = args[1]
+			}
+
+			if encrypt {
+				if value == "" {
+					return errors.New("the token is empty")
+				}
+
+				passphrase, err := readPassphrase(cmd)
+				if err != nil {
+					return err
+				}
+
+				if err := entry.EncryptToken(value, passphrase); err != nil {
+					return err
+				}
+			} else {
+				err = entry.Set(key, value)
+				if err != nil {
+					if isInvalidKeyError(err) {
+						_ = cmd.Help()
+					}
+					return err
 				}
-				return err
 			}
 
 			err = config.Save()
@@ -59,5 +175,17 @@ Can set the below settings:
 		},
 	}
 
+	configSetCmd.Flags().BoolVar(
+		&readStdin,
+		"stdin",
+		false,
+		"Read the value from the terminal without echo, or from the standard input.")
+
+	configSetCmd.Flags().BoolVar(
+		&encrypt,
+		"encrypt",
+		false,
+		"Save the token encrypted with a passphrase.")
+
 	return configSetCmd
 }
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/screwdriver-cd/sd-local/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,3 +65,103 @@ func TestConfigSetCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigSetCmdToken(t *testing.T) {
+	cnfPath := filepath.Join(t.TempDir(), "config")
+
+	defFilePath := filePath
+	defer func() {
+		filePath = defFilePath
+	}()
+	filePath = func() (string, error) {
+		return cnfPath, nil
+	}
+
+	testCase := []struct {
+		name       string
+		args       []string
+		stdin      string
+		passphrase string
+		wantToken  string
+		wantErr    string
+	}{
+		{
+			name:      "success with stdin",
+			args:      []string{"set", "token", "--stdin"},
+			stdin:     "stdin-token\n",
+			wantToken: "stdin-token",
+		},
+		{
+			name:       "success with encryption",
+			args:       []string{"set", "token", "--stdin", "--encrypt"},
+			stdin:      "secret-token\n",
+			passphrase: "passphrase",
+			wantToken:  "secret-token",
+		},
+		{
+			name:       "success with encryption of the argument",
+			args:       []string{"set", "token", "arg-token", "--encrypt"},
+			passphrase: "passphrase",
+			wantToken:  "arg-token",
+		},
+		{
+			name:    "failure by encryption without passphrase",
+			args:    []string{"set", "token", "--stdin", "--encrypt"},
+			stdin:   "secret-token\n",
+			wantErr: "SDLOCAL_PASSPHRASE must be set to encrypt the token without a terminal",
+		},
+		{
+			name:       "failure by encryption of the other key",
+			args:       []string{"set", "api-url", "example.com", "--encrypt"},
+			passphrase: "passphrase",
+			wantErr:    "only the token can be encrypted, not api-url",
+		},
+		{
+			name:       "failure by encryption of empty token",
+			args:       []string{"set", "token", "--stdin", "--encrypt"},
+			passphrase: "passphrase",
+			wantErr:    "the token is empty",
+		},
+		{
+			name:    "failure by value with stdin",
+			args:    []string{"set", "token", "value", "--stdin"},
+			wantErr: "accepts 1 arg(s), received 2",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(config.PassphraseEnv, tt.passphrase)
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetOut(bytes.NewBuffer(nil))
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+
+			b, err := os.ReadFile(cnfPath)
+			assert.Nil(t, err)
+
+			c, err := config.New(cnfPath)
+			assert.Nil(t, err)
+			entry, err := c.Entry(c.Current)
+			assert.Nil(t, err)
+
+			if tt.passphrase == "" {
+				assert.Equal(t, tt.wantToken, entry.Token)
+				return
+			}
+
+			assert.NotContains(t, string(b), tt.wantToken)
+			assert.True(t, entry.TokenLocked())
+			assert.Nil(t, entry.DecryptToken(tt.passphrase))
+			assert.Equal(t, tt.wantToken, entry.Token)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newConfigUnsetCmd() *cobra.Command {
+	configUnsetCmd := &cobra.Command{
+		Use:   "unset [key]",
+		Short: "Unset the config of sd-local",
+		Long: `Unset the key in the current config of sd-local.
+The keys are the same as the set sub command.
+launcher-version, launcher-image and uuid are reset to the initial values.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			key := args[0]
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			entry, err := config.Entry(config.Current)
+			if err != nil {
+				return err
+			}
+
+			err = entry.Unset(key)
+			if err != nil {
+				if isInvalidKeyError(err) {
+					_ = cmd.Help()
+				}
+				return err
+			}
+
+			err = config.Save()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	return configUnsetCmd
+}
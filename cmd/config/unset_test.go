@@ -0,0 +1,65 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigUnsetCmd(t *testing.T) {
+	testCase := []struct {
+		name    string
+		args    []string
+		key     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "success",
+			args: []string{"unset", "token"},
+			key:  "token",
+			want: "",
+		},
+		{
+			name: "success to reset the launcher version",
+			args: []string{"unset", "launcher-version"},
+			key:  "launcher-version",
+			want: "stable",
+		},
+		{
+			name:    "failure by an invalid key",
+			args:    []string{"unset", "invalid-key"},
+			wantErr: "invalid key invalid-key",
+		},
+		{
+			name:    "failure by too many args",
+			args:    []string{"unset", "token", "many"},
+			wantErr: "accepts 1 arg(s), received 2",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			cnfPath := setupTestConfig(t, "config")
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(bytes.NewBuffer(nil))
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+
+			c, err := config.New(cnfPath)
+			assert.Nil(t, err)
+			got, err := c.Entries["default"].Get(tt.key)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
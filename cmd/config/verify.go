@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/checklist"
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/token"
+	"github.com/spf13/cobra"
+)
+
+var (
+	screwdriverNew   = screwdriver.New
+	checkAPIStatus   = screwdriver.CheckAPIStatus
+	checkStoreStatus = screwdriver.CheckStoreStatus
+	resolveImage     = launch.ResolveImage
+	now              = time.Now
+)
+
+// verifyURL checks that the URL of the key parses and responds to the status endpoint, returning true if it does
+func verifyURL(list *checklist.List, key, rawURL string, check func() error, fix string) bool {
+	if _, err := screwdriver.ParseURL(rawURL); err != nil {
+		list.Fail(key, err.Error(), fmt.Sprintf("Set the URL by `sd-local config set %s <URL>`", key))
+		return false
+	}
+
+	if err := check(); err != nil {
+		list.Fail(key, err.Error(), fix)
+		return false
+	}
+
+	list.OK(key, rawURL+" responds")
+	return true
+}
+
+// verifyToken checks that the token is exchanged for a JWT and shows its claims
+func verifyToken(list *checklist.List, entry *config.Entry, ua string, options screwdriver.HTTPOptions) {
+	api, err := screwdriverNew(entry.APIURL, entry.Token, ua, options)
+	if err == nil {
+		err = api.InitJWT()
+	}
+	if err != nil {
+		list.Fail("token", err.Error(), "Create an API token in the user settings of Screwdriver.cd, and set it by `sd-local config set token <API token>`")
+		list.Skip("JWT", "the token is not exchanged for a JWT")
+		return
+	}
+	list.OK("token", "exchanged for a JWT")
+
+	claims, err := token.ParseClaims(api.JWT())
+	if err != nil {
+		list.Fail("JWT", err.Error(), "Check that api-url is the URL of the Screwdriver.cd API")
+		return
+	}
+
+	detail := fmt.Sprintf("user %s, scope %s", claims.Username, strings.Join(claims.Scope, ","))
+	switch {
+	case claims.ExpiresAt.IsZero():
+		list.OK("JWT", detail+", no expiry")
+	case !claims.ExpiresAt.After(now()):
+		list.Fail("JWT", detail+", expired at "+claims.ExpiresAt.Format(time.RFC3339), "Check the clock of this machine")
+	default:
+		list.OK("JWT", detail+", expires at "+claims.ExpiresAt.Format(time.RFC3339))
+	}
+}
+
+// verifyEntry checks the entry against the Screwdriver.cd API, the store and the registry of the launcher
+func verifyEntry(list *checklist.List, entry *config.Entry) {
+	ua := fmt.Sprintf("sd-local (%s; %s)", runtime.GOOS, entry.UUID)
+
+	options, err := screwdriver.HTTPSettings{
+		Timeout:      entry.APITimeout,
+		Retries:      entry.APIRetries,
+		RetryBackoff: entry.APIRetryBackoff,
+		Proxy:        entry.APIProxy,
+		CACert:       entry.CACert,
+		ClientCert:   entry.ClientCert,
+		ClientKey:    entry.ClientKey,
+	}.Options()
+	if err != nil {
+		list.Fail("api settings", err.Error(), "Fix it by `sd-local config set`")
+		options = screwdriver.DefaultHTTPOptions()
+	}
+
+	apiOK := verifyURL(list, "api-url", entry.APIURL, func() error {
+		return checkAPIStatus(entry.APIURL, ua, options)
+	}, "Check that api-url is the URL of the Screwdriver.cd API, not the UI or the store")
+
+	verifyURL(list, "store-url", entry.StoreURL, func() error {
+		err := checkStoreStatus(entry.StoreURL, ua, options)
+		if err != nil && checkAPIStatus(entry.StoreURL, ua, options) == nil {
+			return fmt.Errorf("%s is the URL of the Screwdriver.cd API", entry.StoreURL)
+		}
+		return err
+	}, "Check that store-url is the URL of the Screwdriver.cd store, not the API")
+
+	switch {
+	case entry.Token == "":
+		list.Fail("token", "the token is not set", "Set the API token by `sd-local config set token <API token>`")
+		list.Skip("JWT", "the token is not set")
+	case !apiOK:
+		list.Skip("token", "api-url does not respond")
+		list.Skip("JWT", "api-url does not respond")
+	default:
+		verifyToken(list, entry, ua, options)
+	}
+
+	image := entry.Launcher.Image + ":" + entry.Launcher.Version
+	if err := resolveImage(entry.Launcher.Image, entry.Launcher.Version); err != nil {
+		list.Fail("launcher", err.Error(), "Fix launcher-image and launcher-version by `sd-local config set`, or reset them by `sd-local config unset`")
+	} else {
+		list.OK("launcher", image+" is found")
+	}
+}
+
+func newConfigVerifyCmd() *cobra.Command {
+	configVerifyCmd := &cobra.Command{
+		Use:   "verify [name]",
+		Short: "Verify the config of sd-local",
+		Long: `Verify the config of sd-local against Screwdriver.cd.
+Checks that api-url and store-url respond, that the token is exchanged for a JWT,
+and that launcher-image:launcher-version is found in the registry.
+The current config is verified when the name is omitted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			path, err := filePath()
+			if err != nil {
+				return err
+			}
+
+			config, err := configNew(path)
+			if err != nil {
+				return err
+			}
+
+			name := config.Current
+			if len(args) == 1 {
+				name = args[0]
+			}
+
+			entry, err := config.Entry(name)
+			if err != nil {
+				return err
+			}
+
+			if entry.TokenLocked() {
+				passphrase, err := readDecryptionPassphrase(cmd)
+				if err != nil {
+					return err
+				}
+
+				if err := entry.DecryptToken(passphrase); err != nil {
+					return err
+				}
+			}
+
+			list := &checklist.List{}
+			verifyEntry(list, entry)
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Verifying config `%s`:\n", name)
+			list.Print(cmd.OutOrStdout())
+
+			if n := list.Failed(); n > 0 {
+				return fmt.Errorf("config `%s` failed %d check(s)", name, n)
+			}
+			return nil
+		},
+	}
+
+	return configVerifyCmd
+}
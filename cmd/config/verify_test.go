@@ -0,0 +1,203 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/stretchr/testify/assert"
+)
+
+var verifyNow = time.Date(2026, 10, 17, 10, 15, 2, 0, time.UTC)
+
+type fakeAPI struct {
+	screwdriver.API
+	jwt     string
+	initErr error
+}
+
+func (f *fakeAPI) InitJWT() error { return f.initErr }
+
+func (f *fakeAPI) JWT() string { return f.jwt }
+
+// testJWT returns the unsigned JWT which expires at exp
+func testJWT(exp time.Time) string {
+	payload := fmt.Sprintf(`{"username":"sd-local","scope":["user"],"exp":%d}`, exp.Unix())
+	return "header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestConfigVerifyCmd(t *testing.T) {
+	defNew, defAPI, defStore, defResolve, defNow := screwdriverNew, checkAPIStatus, checkStoreStatus, resolveImage, now
+	defer func() {
+		screwdriverNew, checkAPIStatus, checkStoreStatus, resolveImage, now = defNew, defAPI, defStore, defResolve, defNow
+	}()
+	now = func() time.Time { return verifyNow }
+
+	// The status endpoints which the URLs in testdata/config_verify respond to
+	statusOf := map[string]string{
+		"https://api.screwdriver.com":        "api",
+		"https://store.screwdriver.com":      "store",
+		"https://api-test.screwdriver.com":   "api",
+		"https://store-test.screwdriver.com": "store",
+	}
+
+	testCase := []struct {
+		name       string
+		args       []string
+		apiURLs    map[string]string
+		jwt        string
+		initErr    error
+		imageErr   error
+		env        map[string]string
+		unsetToken bool
+		wantLines  []string
+		wantErr    string
+	}{
+		{
+			name: "success",
+			args: []string{"verify"},
+			jwt:  testJWT(verifyNow.Add(time.Hour)),
+			wantLines: []string{
+				"Verifying config `default`:",
+				"[OK]   api-url: https://api.screwdriver.com responds",
+				"[OK]   store-url: https://store.screwdriver.com responds",
+				"[OK]   token: exchanged for a JWT",
+				"[OK]   JWT: user sd-local, scope user, expires at 2026-10-17T11:15:02Z",
+				"[OK]   launcher: screwdrivercd/launcher:1.0.0 is found",
+			},
+		},
+		{
+			name:    "failure by the store URL of the API",
+			args:    []string{"verify"},
+			apiURLs: map[string]string{"https://store.screwdriver.com": "api"},
+			jwt:     testJWT(verifyNow.Add(time.Hour)),
+			wantLines: []string{
+				"[FAIL] store-url: https://store.screwdriver.com is the URL of the Screwdriver.cd API",
+				"       -> Check that store-url is the URL of the Screwdriver.cd store, not the API",
+			},
+			wantErr: "config `default` failed 1 check(s)",
+		},
+		{
+			name:    "failure by the API which does not respond",
+			args:    []string{"verify"},
+			apiURLs: map[string]string{"https://api.screwdriver.com": ""},
+			wantLines: []string{
+				"[FAIL] api-url: failed to request https://api.screwdriver.com",
+				"[SKIP] token: api-url does not respond",
+				"[SKIP] JWT: api-url does not respond",
+			},
+			wantErr: "config `default` failed 1 check(s)",
+		},
+		{
+			name:    "failure by the token",
+			args:    []string{"verify", "test"},
+			initErr: errors.New("failed to get JWT: StatusCode 401"),
+			wantLines: []string{
+				"Verifying config `test`:",
+				"[FAIL] token: failed to get JWT: StatusCode 401",
+				"[SKIP] JWT: the token is not exchanged for a JWT",
+			},
+			wantErr: "config `test` failed 1 check(s)",
+		},
+		{
+			name: "failure by the expired JWT",
+			args: []string{"verify"},
+			jwt:  testJWT(verifyNow.Add(-time.Hour)),
+			wantLines: []string{
+				"[FAIL] JWT: user sd-local, scope user, expired at 2026-10-17T09:15:02Z",
+			},
+			wantErr: "config `default` failed 1 check(s)",
+		},
+		{
+			name:     "failure by the launcher",
+			args:     []string{"verify"},
+			jwt:      testJWT(verifyNow.Add(time.Hour)),
+			imageErr: errors.New("screwdrivercd/launcher:1.0.0 is not found in registry-1.docker.io"),
+			wantLines: []string{
+				"[FAIL] launcher: screwdrivercd/launcher:1.0.0 is not found in registry-1.docker.io",
+			},
+			wantErr: "config `default` failed 1 check(s)",
+		},
+		{
+			name: "failure by the URL without scheme",
+			args: []string{"verify"},
+			env:  map[string]string{"SDLOCAL_API_URL": "api.screwdriver.com"},
+			wantLines: []string{
+				"[FAIL] api-url: invalid URL `api.screwdriver.com`: must start with http:// or https://",
+				"       -> Set the URL by `sd-local config set api-url <URL>`",
+			},
+			wantErr: "config `default` failed 1 check(s)",
+		},
+		{
+			name:       "failure by the empty token",
+			args:       []string{"verify"},
+			unsetToken: true,
+			wantLines: []string{
+				"[FAIL] token: the token is not set",
+				"[SKIP] JWT: the token is not set",
+			},
+			wantErr: "config `default` failed 1 check(s)",
+		},
+		{
+			name:    "failure by Entry that does not exist",
+			args:    []string{"verify", "doesnotexist"},
+			wantErr: "config `doesnotexist` does not exist",
+		},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			cnfPath := setupTestConfig(t, "config_verify")
+			if tt.unsetToken {
+				c, err := config.New(cnfPath)
+				assert.Nil(t, err)
+				assert.Nil(t, c.Entries["default"].Unset("token"))
+				assert.Nil(t, c.Save())
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			status := func(kind string) func(u, ua string, options screwdriver.HTTPOptions) error {
+				return func(u, ua string, options screwdriver.HTTPOptions) error {
+					got, ok := tt.apiURLs[u]
+					if !ok {
+						got = statusOf[u]
+					}
+					if got != kind {
+						return fmt.Errorf("failed to request %s", u)
+					}
+					return nil
+				}
+			}
+			checkAPIStatus = status("api")
+			checkStoreStatus = status("store")
+			screwdriverNew = func(apiURL, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+				return &fakeAPI{jwt: tt.jwt, initErr: tt.initErr}, nil
+			}
+			resolveImage = func(image, tag string) error {
+				return tt.imageErr
+			}
+
+			cmd := NewConfigCmd()
+			cmd.SetArgs(tt.args)
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetErr(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+			for _, line := range tt.wantLines {
+				assert.Contains(t, buf.String(), line+"\n")
+			}
+		})
+	}
+}
@@ -8,7 +8,21 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// overrideLines are the beginnings of the lines of the keys which the environment variables override
+var overrideLines = map[string]string{
+	"api-url":          "api-url:",
+	"store-url":        "store-url:",
+	"token":            "token:",
+	"launcher-version": "  version:",
+	"launcher-image":   "  image:",
+}
+
+// maskedToken is shown instead of the token unless --show-token is given
+const maskedToken = "<masked>"
+
 func newConfigViewCmd() *cobra.Command {
+	showToken := false
+
 	configViewCmd := &cobra.Command{
 		Use:   "view",
 		Short: "View the config of sd-local.",
@@ -19,7 +33,9 @@ Can see the below settings:
 * Screwdriver.cd Token
 * Screwdriver.cd launcher version
 * Screwdriver.cd UUID
-* Screwdriver.cd launcher image`,
+* Screwdriver.cd launcher image
+The tokens are masked unless --show-token is given, and the encrypted token is never shown.
+The values overridden by the environment variables are marked with the names of the variables.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 
@@ -34,21 +50,42 @@ Can see the below settings:
 			}
 
 			for name, entry := range config.Entries {
-				if name == config.Current {
+				if name == config.Current && config.CurrentOverride() != "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "* %s: # selected by %s\n", name, config.CurrentOverride())
+				} else if name == config.Current {
 					fmt.Fprintf(cmd.OutOrStdout(), "* %s:\n", name)
 				} else {
 					fmt.Fprintf(cmd.OutOrStdout(), "  %s:\n", name)
 				}
 
+				// The encrypted token is not shown, and the others are shown only when asked
+				e := *entry
+				switch {
+				case e.TokenLocked():
+					e.Token = "<encrypted>"
+				case e.Token != "" && !showToken:
+					e.Token = maskedToken
+				}
+				e.EncryptedToken = ""
+				entry = &e
+
 				yaml, err := yaml.Marshal(entry)
 				if err != nil {
 					return err
 				}
 
+				overrides := config.Overrides(name)
 				for _, line := range strings.Split(string(yaml), "\n") {
-					if line != "" {
-						fmt.Fprintf(cmd.OutOrStdout(), "    %s\n", line)
+					if line == "" {
+						continue
+					}
+
+					for key, env := range overrides {
+						if strings.HasPrefix(line, overrideLines[key]) {
+							line += " # overridden by " + env
+						}
 					}
+					fmt.Fprintf(cmd.OutOrStdout(), "    %s\n", line)
 				}
 
 			}
@@ -57,5 +94,11 @@ Can see the below settings:
 		},
 	}
 
+	configViewCmd.Flags().BoolVar(
+		&showToken,
+		"show-token",
+		false,
+		"Show the tokens which are not encrypted instead of masking them.")
+
 	return configViewCmd
 }
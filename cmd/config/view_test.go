@@ -21,10 +21,12 @@ func TestViewCmd(t *testing.T) {
 	}
 
 	testCase := []struct {
-		name   string
-		args   []string
-		expect []string
-		config string
+		name      string
+		args      []string
+		expect    []string
+		notExpect []string
+		config    string
+		env       map[string]string
 	}{
 		{
 			name: "success",
@@ -32,7 +34,7 @@ func TestViewCmd(t *testing.T) {
 			expect: []string{`* default:
     api-url: api.screwdriver.com
     store-url: store.screwdriver.com
-    token: sd-token
+    token: <masked>
     UUID: '-'
     launcher:
       version: 1.0.0
@@ -40,13 +42,14 @@ func TestViewCmd(t *testing.T) {
 				`  test:
     api-url: api-test.screwdriver.com
     store-url: store-test.screwdriver.com
-    token: sd-token-test
+    token: <masked>
     UUID: eb004dc1-614c-11eb-bab9-0242ac120002
     launcher:
       version: 1.0.0-test
       image: screwdrivercd/launcher
 `},
-			config: "./testdata/config",
+			notExpect: []string{"sd-token"},
+			config:    "./testdata/config",
 		},
 		{
 			name: "success with no current",
@@ -54,7 +57,7 @@ func TestViewCmd(t *testing.T) {
 			expect: []string{`  default:
     api-url: api.screwdriver.com
     store-url: store.screwdriver.com
-    token: sd-token
+    token: <masked>
     UUID: '-'
     launcher:
       version: 1.0.0
@@ -62,7 +65,7 @@ func TestViewCmd(t *testing.T) {
 				`  test:
     api-url: api-test.screwdriver.com
     store-url: store-test.screwdriver.com
-    token: sd-token-test
+    token: <masked>
     UUID: eb004dc1-614c-11eb-bab9-0242ac120002
     launcher:
       version: 1.0.0-test
@@ -70,10 +73,75 @@ func TestViewCmd(t *testing.T) {
 `},
 			config: "./testdata/config_no_current",
 		},
+		{
+			name: "success with --show-token",
+			args: []string{"view", "--show-token"},
+			expect: []string{`* default:
+    api-url: api.screwdriver.com
+    store-url: store.screwdriver.com
+    token: sd-token
+`,
+				`  test:
+    api-url: api-test.screwdriver.com
+    store-url: store-test.screwdriver.com
+    token: sd-token-test
+`},
+			config: "./testdata/config",
+		},
+		{
+			name: "success with the overridden token and --show-token",
+			args: []string{"view", "--show-token"},
+			expect: []string{`    token: env-token # overridden by SDLOCAL_TOKEN
+`},
+			config: "./testdata/config",
+			env: map[string]string{
+				"SDLOCAL_TOKEN": "env-token",
+			},
+		},
+		{
+			name: "success with encrypted token",
+			args: []string{"view"},
+			expect: []string{`* default:
+    api-url: api.screwdriver.com
+    store-url: store.screwdriver.com
+    token: <encrypted>
+    UUID: '-'
+`},
+			config: "./testdata/config_encrypted",
+		},
+		{
+			name: "success with environment variables",
+			args: []string{"view"},
+			expect: []string{`* test: # selected by SDLOCAL_CONFIG
+    api-url: https://api.example.com # overridden by SDLOCAL_API_URL
+    store-url: store-test.screwdriver.com
+    token: <masked> # overridden by SDLOCAL_TOKEN
+    UUID: eb004dc1-614c-11eb-bab9-0242ac120002
+    launcher:
+      version: 1.0.0-test
+      image: example/launcher # overridden by SDLOCAL_LAUNCHER_IMAGE
+`,
+				`  default:
+    api-url: api.screwdriver.com
+    store-url: store.screwdriver.com
+    token: <masked>
+`},
+			notExpect: []string{"sd-token", "env-token"},
+			config:    "./testdata/config",
+			env: map[string]string{
+				"SDLOCAL_CONFIG":         "test",
+				"SDLOCAL_API_URL":        "https://api.example.com",
+				"SDLOCAL_TOKEN":          "env-token",
+				"SDLOCAL_LAUNCHER_IMAGE": "example/launcher",
+			},
+		},
 	}
 
 	for _, tt := range testCase {
 		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
 			testConfig = tt.config
 			cmd := NewConfigCmd()
 			cmd.SetArgs(tt.args)
@@ -90,6 +158,9 @@ func TestViewCmd(t *testing.T) {
 				assert.True(t, strings.Contains(actual, expect), "expect to contain %q \nbut got \n%q", expect, actual)
 
 			}
+			for _, notExpect := range tt.notExpect {
+				assert.NotContains(t, actual, notExpect)
+			}
 		})
 	}
 }
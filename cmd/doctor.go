@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/spf13/cobra"
+)
+
+var launchDoctor = launch.Doctor
+
+// configRuntime returns the runtime of the current config unless it is given by the flag
+func configRuntime(runtime string) (string, error) {
+	if runtime != "" {
+		return runtime, launch.ValidateRuntime(runtime)
+	}
+
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return "", err
+	}
+
+	config, err := configNew(filepath.Join(sdlocalDir, "config"))
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := config.Entry(config.Current)
+	if err != nil {
+		return "", err
+	}
+
+	return entry.Runtime, launch.ValidateRuntime(entry.Runtime)
+}
+
+func newDoctorCmd() *cobra.Command {
+	options := launch.DoctorOptions{}
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the environment which builds run in.",
+		Long: `Diagnose the environment which builds run in.
+Checks the container runtime and its daemon, whether --sudo is needed,
+the ssh-agent socket, the free disk space, the resources left by the killed builds,
+git for --src-url and the pseudo terminals for -i.
+Each failed check is shown with the suggested fix.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			runtime, err := configRuntime(options.Runtime)
+			if err != nil {
+				return err
+			}
+			options.Runtime = runtime
+
+			list := launchDoctor(options)
+			list.Print(cmd.OutOrStdout())
+
+			if n := list.Failed(); n > 0 {
+				return fmt.Errorf("%d check(s) failed", n)
+			}
+			return nil
+		},
+	}
+
+	doctorCmd.Flags().StringVar(
+		&options.Runtime,
+		"runtime",
+		"",
+		"Container runtime to check. (docker, podman or docker-api) Default value is from config, otherwise docker.")
+
+	doctorCmd.Flags().BoolVar(
+		&options.UseSudo,
+		"sudo",
+		false,
+		"Check the container runtime with sudo command.")
+
+	doctorCmd.Flags().StringVarP(
+		&options.SocketPath,
+		"socket",
+		"S",
+		launch.DefaultSocketPath(),
+		"Path to the socket which is used in build container.")
+
+	doctorCmd.Flags().StringVar(
+		&options.ArtifactsDir,
+		"artifacts-dir",
+		launch.ArtifactsDir,
+		"Path to the host side directory which is mounted into $SD_ARTIFACTS_DIR.")
+
+	return doctorCmd
+}
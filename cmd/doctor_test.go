@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/checklist"
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctorCmd(t *testing.T) {
+	testCases := []struct {
+		name        string
+		args        []string
+		items       []checklist.Item
+		wantOptions launch.DoctorOptions
+		wantOut     string
+		wantErr     string
+	}{
+		{
+			name:        "success with the runtime of the config",
+			args:        []string{"--socket", "/auth.sock"},
+			items:       []checklist.Item{{Status: checklist.OK, Name: "runtime", Detail: "podman is found"}},
+			wantOptions: launch.DoctorOptions{Runtime: "podman", SocketPath: "/auth.sock", ArtifactsDir: launch.ArtifactsDir},
+			wantOut:     "[OK]   runtime: podman is found\n",
+		},
+		{
+			name:        "success with flags",
+			args:        []string{"--runtime", "docker", "--sudo", "--socket", "/auth.sock", "--artifacts-dir", "/tmp/artifacts"},
+			items:       []checklist.Item{{Status: checklist.Warn, Name: "sudo", Detail: "docker works without sudo", Fix: "Run the builds without `--sudo`"}},
+			wantOptions: launch.DoctorOptions{Runtime: "docker", UseSudo: true, SocketPath: "/auth.sock", ArtifactsDir: "/tmp/artifacts"},
+			wantOut:     "[WARN] sudo: docker works without sudo\n       -> Run the builds without `--sudo`\n",
+		},
+		{
+			name: "failure by failed checks",
+			args: []string{"--socket", "/auth.sock"},
+			items: []checklist.Item{
+				{Status: checklist.Fail, Name: "runtime", Detail: "podman is not found in $PATH", Fix: "Install podman"},
+				{Status: checklist.Skip, Name: "daemon", Detail: "podman is not found"},
+			},
+			wantOptions: launch.DoctorOptions{Runtime: "podman", SocketPath: "/auth.sock", ArtifactsDir: launch.ArtifactsDir},
+			wantOut:     "[FAIL] runtime: podman is not found in $PATH\n       -> Install podman\n[SKIP] daemon: podman is not found\n",
+			wantErr:     "1 check(s) failed",
+		},
+		{
+			name:    "failure by invalid runtime",
+			args:    []string{"--runtime", "invalid"},
+			wantErr: "unsupported container runtime `invalid`, must be one of [docker podman docker-api]",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(c func(string) (config.Config, error)) { configNew = c }(configNew)
+			configNew = func(confPath string) (config.Config, error) {
+				return config.Config{
+					Entries: map[string]*config.Entry{
+						"default": {Runtime: launch.PodmanRuntime},
+					},
+					Current: "default",
+				}, nil
+			}
+
+			var gotOptions launch.DoctorOptions
+			defer func(d func(launch.DoctorOptions) *checklist.List) { launchDoctor = d }(launchDoctor)
+			launchDoctor = func(options launch.DoctorOptions) *checklist.List {
+				gotOptions = options
+				return &checklist.List{Items: tt.items}
+			}
+
+			cmd := newDoctorCmd()
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetErr(bytes.NewBuffer(nil))
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+			if tt.wantOut != "" {
+				assert.Equal(t, tt.wantOptions, gotOptions)
+				assert.Equal(t, tt.wantOut, buf.String())
+			}
+		})
+	}
+}
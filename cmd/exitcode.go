@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/scm"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+)
+
+// The exit codes of sd-local. A failed step exits with ExitStep, not with the exit status of the step,
+// so that it is not confused with the other codes. The exit status of the step is shown in the summary
+// of the steps and recorded in steps.json and the history. The build container exiting with 126 or 127,
+// which docker and podman use when the command is not executable or not found, is reported as ExitRuntime.
+const (
+	// ExitFailure is the exit code of the errors not classified below, e.g. invalid flags or config
+	ExitFailure = 1
+	// ExitStep is the exit code when a step of the build fails
+	ExitStep = 2
+	// ExitValidation is the exit code when screwdriver.yaml is invalid or the Screwdriver.cd API fails
+	ExitValidation = 65
+	// ExitSource is the exit code when the source code cannot be fetched with --src-url
+	ExitSource = 66
+	// ExitRuntime is the exit code when the container runtime fails, e.g. pulling the image
+	ExitRuntime = 125
+	// exitSignal is added to the signal number when sd-local is aborted by a signal
+	exitSignal = 128
+)
+
+// abortSignal holds the number of the signal which aborted sd-local, or zero
+var abortSignal atomic.Int32
+
+// ExitCode returns the exit code of sd-local for the error returned by Execute
+func ExitCode(err error) int {
+	if sig := abortSignal.Load(); sig != 0 {
+		return exitSignal + int(sig)
+	}
+
+	if err == nil {
+		return 0
+	}
+
+	var (
+		stepErr       *launch.StepError
+		runtimeErr    *launch.RuntimeError
+		validationErr *screwdriver.ValidationError
+		apiErr        *screwdriver.APIError
+		scmErr        *scm.Error
+		logErr        *buildlog.Error
+	)
+
+	switch {
+	case errors.As(err, &stepErr):
+		return ExitStep
+	case errors.As(err, &runtimeErr), errors.As(err, &logErr):
+		return ExitRuntime
+	case errors.As(err, &validationErr), errors.As(err, &apiErr):
+		return ExitValidation
+	case errors.As(err, &scmErr):
+		return ExitSource
+	default:
+		return ExitFailure
+	}
+}
+
+// signalExitCode returns the exit code of sd-local aborted by the signal
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return exitSignal + int(s)
+	}
+	return ExitFailure
+}
+
+// abort records the signal so that ExitCode reports it even if the build returns its own error first
+func abort(sig syscall.Signal) {
+	abortSignal.CompareAndSwap(0, int32(sig))
+}
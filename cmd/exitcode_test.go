@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/scm"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: 0},
+		{name: "step failure", err: fmt.Errorf("failed to run job `test`: %w", fmt.Errorf("failed to run build: %w", &launch.StepError{Code: 3, Err: errors.New("exit status 3")})), want: ExitStep},
+		{name: "step failure with the status of the other code", err: &launch.StepError{Code: 65, Err: errors.New("exit status 65")}, want: ExitStep},
+		{name: "runtime error", err: &launch.RuntimeError{Err: errors.New("failed to pull user image")}, want: ExitRuntime},
+		{name: "build log error", err: &buildlog.Error{Err: errors.New("failed to open raw build log file")}, want: ExitRuntime},
+		{name: "validation error", err: &screwdriver.ValidationError{Err: errors.New("failed to parse screwdriver.yaml")}, want: ExitValidation},
+		{name: "api error", err: fmt.Errorf("failed to get job: %w", &screwdriver.APIError{Err: errors.New("failed to get JWT: StatusCode 401")}), want: ExitValidation},
+		{name: "scm error", err: &scm.Error{Err: errors.New("failed to clone remote repository")}, want: ExitSource},
+		{name: "other error", err: errors.New("invalid flag"), want: ExitFailure},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExitCode(tt.err))
+		})
+	}
+
+	t.Run("aborted by signal", func(t *testing.T) {
+		defer abortSignal.Store(0)
+
+		abort(syscall.SIGINT)
+		abort(syscall.SIGTERM)
+		assert.Equal(t, 130, ExitCode(&launch.StepError{Code: 137, Err: errors.New("exit status 137")}))
+	})
+}
+
+type otherSignal struct{}
+
+func (otherSignal) String() string { return "other" }
+
+func (otherSignal) Signal() {}
+
+func TestSignalExitCode(t *testing.T) {
+	assert.Equal(t, 130, signalExitCode(syscall.SIGINT))
+	assert.Equal(t, 143, signalExitCode(syscall.SIGTERM))
+	assert.Equal(t, ExitFailure, signalExitCode(otherSignal{}))
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/history"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/redact"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	historyNew = history.New
+	gitSHA     = func(dir string) string {
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+)
+
+// recordBuild saves the finished build in the history. The values registered in the redactor are masked.
+func (s *buildSetup) recordBuild(option launch.Option, steps []buildlog.StepResult, buildErr error, start, end time.Time) {
+	if s == nil || s.history == nil {
+		return
+	}
+
+	entryOption := option
+	entryOption.JWT = redact.Mask
+	entryJSON, err := launch.BuildEntryJSON(entryOption)
+	if err != nil {
+		logrus.Warnf("failed to record the build: %v", err)
+		return
+	}
+
+	files := history.Files{Entry: []byte(redactor.Redact(string(entryJSON)))}
+
+	if rawLog, err := os.ReadFile(filepath.Join(option.ArtifactsPath, launch.LogFile)); err == nil {
+		files.Log = strings.NewReader(redactor.Redact(string(rawLog)))
+	}
+
+	if len(steps) > 0 {
+		if files.Steps, err = json.MarshalIndent(steps, "", "  "); err != nil {
+			logrus.Warnf("failed to record the build: %v", err)
+			return
+		}
+	}
+
+	build := history.Build{
+		JobName:   option.JobName,
+		Config:    s.configName,
+		SrcPath:   option.SrcPath,
+		SHA:       gitSHA(option.SrcPath),
+		Image:     option.Job.Image,
+		StartTime: start,
+		EndTime:   end,
+		Duration:  end.Sub(start).Round(time.Millisecond).Seconds(),
+		Result:    history.ResultSuccess,
+	}
+	if buildErr != nil {
+		build.Result = history.ResultFailure
+		build.Error = redactor.Redact(buildErr.Error())
+		if code := exitCode(buildErr); code >= 0 {
+			build.ExitCode = &code
+		}
+	}
+
+	id, err := s.history.Save(build, files)
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+	logrus.Infof("The build is recorded as %s in the history", id)
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/history"
+	"github.com/spf13/cobra"
+)
+
+const buildsDirName = "builds"
+
+var storeDir = func() (string, error) {
+	base, err := config.BaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, buildsDirName), nil
+}
+
+func newStore() (*history.Store, error) {
+	dir, err := storeDir()
+	if err != nil {
+		return nil, err
+	}
+	return history.New(dir), nil
+}
+
+func formatDuration(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+// NewHistoryCmd return history command.
+func NewHistoryCmd() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Manage the history of sd-local builds.",
+		Long: `Manage the history of sd-local builds.
+Every build is recorded under ~/.sdlocal/builds with its raw log, build entry, steps and result.
+The newest 100 builds are kept.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := cmd.Help()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	historyCmd.AddCommand(
+		newHistoryLsCmd(),
+		newHistoryShowCmd(),
+		newHistoryRmCmd(),
+		newHistoryPruneCmd(),
+	)
+
+	return historyCmd
+}
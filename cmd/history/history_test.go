@@ -0,0 +1,51 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/history"
+	"github.com/stretchr/testify/assert"
+)
+
+var testStart = time.Date(2026, 10, 17, 10, 15, 2, 0, time.Local)
+
+// setupStore saves the builds of the jobs "test" and "lint" in a temporary directory and returns the store
+func setupStore(t *testing.T) *history.Store {
+	dir := t.TempDir()
+
+	sdir := storeDir
+	t.Cleanup(func() {
+		storeDir = sdir
+	})
+	storeDir = func() (string, error) {
+		return dir, nil
+	}
+
+	two := 2
+	store := history.New(dir)
+	for _, b := range []history.Build{
+		{JobName: "test", Config: "default", SHA: "0123456789abcdef", Image: "node:18", StartTime: testStart, EndTime: testStart.Add(72 * time.Second), Duration: 72, Result: history.ResultSuccess},
+		{JobName: "lint", Config: "local", Image: "node:20", StartTime: testStart.Add(time.Hour), EndTime: testStart.Add(time.Hour + 8*time.Second), Duration: 8.4, Result: history.ResultFailure, ExitCode: &two, Error: "failed to run build: exit status 2"},
+	} {
+		if _, err := store.Save(b, history.Files{
+			Entry: []byte(`{"environment":[{"SD_TOKEN":"***"}]}`),
+			Steps: []byte(`[{"name":"main","startTime":"2026-10-17T01:15:02Z","duration":8,"status":"FAILURE","exitCode":2}]`),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+func TestHistoryCmd(t *testing.T) {
+	cmd := NewHistoryCmd()
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{})
+
+	assert.Nil(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "Available Commands:")
+}
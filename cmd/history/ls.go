@@ -0,0 +1,62 @@
+package history
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func shortSHA(sha string) string {
+	if sha == "" {
+		return "-"
+	}
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func newHistoryLsCmd() *cobra.Command {
+	job := ""
+
+	historyLsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List the builds in the history.",
+		Long:  `List the builds in the history, the newest first.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			store, err := newStore()
+			if err != nil {
+				return err
+			}
+
+			builds, err := store.List()
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+			fmt.Fprintln(tw, "ID\tJOB\tCONFIG\tSHA\tIMAGE\tSTART\tDURATION\tRESULT")
+			for _, b := range builds {
+				if job != "" && b.JobName != job {
+					continue
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					b.ID, b.JobName, b.Config, shortSHA(b.SHA), b.Image, b.StartTime.Format("2006-01-02 15:04:05"), formatDuration(b.Duration), b.Result)
+			}
+
+			return tw.Flush()
+		},
+	}
+
+	historyLsCmd.Flags().StringVar(
+		&job,
+		"job",
+		"",
+		"List only the builds of the job.")
+
+	return historyLsCmd
+}
@@ -0,0 +1,44 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryLsCmd(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "success",
+			args: []string{"ls"},
+			want: "ID                    JOB    CONFIG    SHA       IMAGE     START                 DURATION   RESULT\n" +
+				"20261017-111502.000   lint   local     -         node:20   2026-10-17 11:15:02   8s         FAILURE\n" +
+				"20261017-101502.000   test   default   0123456   node:18   2026-10-17 10:15:02   1m12s      SUCCESS\n",
+		},
+		{
+			name: "success with job",
+			args: []string{"ls", "--job", "test"},
+			want: "ID                    JOB    CONFIG    SHA       IMAGE     START                 DURATION   RESULT\n" +
+				"20261017-101502.000   test   default   0123456   node:18   2026-10-17 10:15:02   1m12s      SUCCESS\n",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setupStore(t)
+
+			cmd := NewHistoryCmd()
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetArgs(tt.args)
+
+			assert.Nil(t, cmd.Execute())
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
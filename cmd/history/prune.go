@@ -0,0 +1,55 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newHistoryPruneCmd() *cobra.Command {
+	keep := 0
+	olderThan := time.Duration(0)
+
+	historyPruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove old builds from the history.",
+		Long: `Remove the builds except the newest ones given by --keep,
+and the builds which started before the duration given by --older-than.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if keep <= 0 && olderThan <= 0 {
+				return errors.New("specify --keep or --older-than to prune the history")
+			}
+			cmd.SilenceUsage = true
+
+			store, err := newStore()
+			if err != nil {
+				return err
+			}
+
+			removed, err := store.Prune(keep, olderThan)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d builds from the history\n", len(removed))
+			return nil
+		},
+	}
+
+	historyPruneCmd.Flags().IntVar(
+		&keep,
+		"keep",
+		0,
+		"Number of the newest builds to keep.")
+
+	historyPruneCmd.Flags().DurationVar(
+		&olderThan,
+		"older-than",
+		0,
+		"Remove the builds which started before this duration ago. (e.g. 168h)")
+
+	return historyPruneCmd
+}
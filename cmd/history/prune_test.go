@@ -0,0 +1,59 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryPruneCmd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		want      int
+		wantOut   string
+		wantError string
+	}{
+		{
+			name:    "success with keep",
+			args:    []string{"prune", "--keep", "1"},
+			want:    1,
+			wantOut: "Removed 1 builds from the history\n",
+		},
+		{
+			name:    "success with older than",
+			args:    []string{"prune", "--older-than", "1h"},
+			want:    0,
+			wantOut: "Removed 2 builds from the history\n",
+		},
+		{
+			name:      "failure without limits",
+			args:      []string{"prune"},
+			wantError: "specify --keep or --older-than to prune the history",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			store := setupStore(t)
+
+			cmd := NewHistoryCmd()
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantOut, buf.String())
+
+			builds, err := store.List()
+			assert.Nil(t, err)
+			assert.Len(t, builds, tt.want)
+		})
+	}
+}
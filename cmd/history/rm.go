@@ -0,0 +1,32 @@
+package history
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newHistoryRmCmd() *cobra.Command {
+	historyRmCmd := &cobra.Command{
+		Use:   "rm [id...]",
+		Short: "Remove builds from the history.",
+		Long:  `Remove the builds of the IDs from the history.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			store, err := newStore()
+			if err != nil {
+				return err
+			}
+
+			for _, id := range args {
+				if err := store.Remove(id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return historyRmCmd
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryRmCmd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		want      int
+		wantError string
+	}{
+		{
+			name: "success",
+			args: []string{"rm", "20261017-101502.000"},
+			want: 1,
+		},
+		{
+			name: "success with builds",
+			args: []string{"rm", "20261017-101502.000", "20261017-111502.000"},
+			want: 0,
+		},
+		{
+			name:      "failure by build that does not exist",
+			args:      []string{"rm", "20200101-000000.000"},
+			wantError: "build `20200101-000000.000` does not exist",
+		},
+		{
+			name:      "failure by too little args",
+			args:      []string{"rm"},
+			wantError: "requires at least 1 arg(s), only received 0",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			store := setupStore(t)
+
+			cmd := NewHistoryCmd()
+			cmd.SetOut(bytes.NewBuffer(nil))
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+
+			builds, err := store.List()
+			assert.Nil(t, err)
+			assert.Len(t, builds, tt.want)
+		})
+	}
+}
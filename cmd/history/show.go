@@ -0,0 +1,104 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/history"
+	"github.com/spf13/cobra"
+)
+
+func newHistoryShowCmd() *cobra.Command {
+	showEntry := false
+
+	historyShowCmd := &cobra.Command{
+		Use:   "show [id]",
+		Short: "Show a build in the history.",
+		Long: `Show the summary and the steps of a build in the history.
+The ID is shown by "sd-local history ls".`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			store, err := newStore()
+			if err != nil {
+				return err
+			}
+
+			b, err := store.Get(args[0])
+			if err != nil {
+				return err
+			}
+
+			dir := store.Dir(b.ID)
+			if showEntry {
+				entryJSON, err := os.ReadFile(filepath.Join(dir, history.EntryFile))
+				if err != nil {
+					return fmt.Errorf("failed to read build history: %v", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(entryJSON))
+				return nil
+			}
+
+			exitCode := "-"
+			if b.ExitCode != nil {
+				exitCode = fmt.Sprint(*b.ExitCode)
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 1, ' ', 0)
+			for _, row := range [][2]string{
+				{"ID", b.ID},
+				{"Job", b.JobName},
+				{"Config", b.Config},
+				{"Source", b.SrcPath},
+				{"SHA", b.SHA},
+				{"Image", b.Image},
+				{"Start", b.StartTime.Format("2006-01-02 15:04:05")},
+				{"End", b.EndTime.Format("2006-01-02 15:04:05")},
+				{"Duration", formatDuration(b.Duration)},
+				{"Result", b.Result},
+				{"Exit code", exitCode},
+				{"Error", b.Error},
+				{"Directory", dir},
+			} {
+				if row[1] == "" {
+					continue
+				}
+				fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			stepsJSON, err := os.ReadFile(filepath.Join(dir, history.StepsFile))
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return fmt.Errorf("failed to read build history: %v", err)
+			}
+
+			steps := []buildlog.StepResult{}
+			if err := json.Unmarshal(stepsJSON, &steps); err != nil {
+				return fmt.Errorf("failed to read build history: %v", err)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout())
+			buildlog.PrintSteps(cmd.OutOrStdout(), steps)
+
+			return nil
+		},
+	}
+
+	historyShowCmd.Flags().BoolVar(
+		&showEntry,
+		"entry",
+		false,
+		"Show the build entry passed to the launcher instead.")
+
+	return historyShowCmd
+}
@@ -0,0 +1,65 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryShowCmd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		want      []string
+		wantError string
+	}{
+		{
+			name: "success",
+			args: []string{"show", "20261017-111502.000"},
+			want: []string{
+				"ID:        20261017-111502.000\n",
+				"Job:       lint\n",
+				"Config:    local\n",
+				"Image:     node:20\n",
+				"Duration:  8s\n",
+				"Result:    FAILURE\n",
+				"Exit code: 2\n",
+				"Error:     failed to run build: exit status 2\n",
+				"STEP   START",
+				"main   ",
+			},
+		},
+		{
+			name: "success with entry",
+			args: []string{"show", "20261017-101502.000", "--entry"},
+			want: []string{`{"environment":[{"SD_TOKEN":"***"}]}` + "\n"},
+		},
+		{
+			name:      "failure by build that does not exist",
+			args:      []string{"show", "20200101-000000.000"},
+			wantError: "build `20200101-000000.000` does not exist",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setupStore(t)
+
+			cmd := NewHistoryCmd()
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			for _, w := range tt.want {
+				assert.Contains(t, buf.String(), w)
+			}
+		})
+	}
+}
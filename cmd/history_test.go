@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/history"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+)
+
+func TestRecordBuild(t *testing.T) {
+	defGitSHA := gitSHA
+	defer func() {
+		gitSHA = defGitSHA
+	}()
+	gitSHA = func(dir string) string { return "0123456789abcdef" }
+
+	redactor.Add("supersecretvalue")
+
+	artifactsPath := t.TempDir()
+	err := os.WriteFile(filepath.Join(artifactsPath, launch.LogFile), []byte(`{"t":1581662022394,"m":"token supersecretvalue","n":0,"s":"main"}`+"\n"), 0666)
+	assert.Nil(t, err)
+
+	store := history.New(t.TempDir())
+	s := &buildSetup{configName: "local", history: store}
+	option := launch.Option{
+		JobName:       "test",
+		JWT:           "jwt.payload.signature",
+		ArtifactsPath: artifactsPath,
+		SrcPath:       "/src",
+		Job:           screwdriver.Job{Image: "node:18"},
+		OptionEnv:     screwdriver.EnvVars{{"GIT_KEY": "supersecretvalue"}},
+	}
+	start := time.Now()
+	steps := []buildlog.StepResult{{Name: "main", Status: buildlog.StepFailure}}
+
+	s.recordBuild(option, steps, &launch.StepError{Code: 2, Err: errors.New("exit status 2")}, start, start.Add(1500*time.Millisecond))
+
+	builds, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, builds, 1)
+	b := builds[0]
+	assert.Equal(t, "test", b.JobName)
+	assert.Equal(t, "local", b.Config)
+	assert.Equal(t, "/src", b.SrcPath)
+	assert.Equal(t, "0123456789abcdef", b.SHA)
+	assert.Equal(t, "node:18", b.Image)
+	assert.Equal(t, 1.5, b.Duration)
+	assert.Equal(t, history.ResultFailure, b.Result)
+	assert.Equal(t, 2, *b.ExitCode)
+	assert.Equal(t, "exit status 2", b.Error)
+
+	entryJSON, err := os.ReadFile(filepath.Join(store.Dir(b.ID), history.EntryFile))
+	assert.Nil(t, err)
+	assert.Contains(t, string(entryJSON), `"SD_TOKEN": "***"`)
+	assert.False(t, strings.Contains(string(entryJSON), "supersecretvalue"))
+
+	rawLog, err := os.ReadFile(filepath.Join(store.Dir(b.ID), history.LogFile))
+	assert.Nil(t, err)
+	assert.Contains(t, string(rawLog), "token ***")
+
+	_, err = os.Stat(filepath.Join(store.Dir(b.ID), history.StepsFile))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/history"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/redact"
+	"github.com/spf13/cobra"
+)
+
+var historyDir = func() (string, error) {
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(sdlocalDir, "builds"), nil
+}
+
+// logSource is the raw build log to replay
+type logSource struct {
+	path    string
+	jobName string
+	// config is the name of the config which the build in the history used
+	config string
+	// running is true when the log may be written by a running build
+	running bool
+}
+
+// findLogSource returns the raw build log of the build, which is an ID in the history or the path to a raw build log or an artifacts directory.
+// The newest build in the history is returned when the build is empty, or the log of the running build in the artifacts directory with follow.
+func findLogSource(store *history.Store, build, artifactsPath string, follow bool) (logSource, error) {
+	if build == "" {
+		if follow {
+			return logSource{path: filepath.Join(artifactsPath, launch.LogFile), running: true}, nil
+		}
+
+		builds, err := store.List()
+		if err != nil {
+			return logSource{}, err
+		}
+		if len(builds) == 0 {
+			return logSource{}, errors.New("no builds are found in the history")
+		}
+		return logSource{path: filepath.Join(store.Dir(builds[0].ID), history.LogFile), jobName: builds[0].JobName, config: builds[0].Config}, nil
+	}
+
+	b, err := store.Get(build)
+	if err == nil {
+		return logSource{path: filepath.Join(store.Dir(b.ID), history.LogFile), jobName: b.JobName, config: b.Config}, nil
+	}
+
+	info, statErr := os.Stat(build)
+	if statErr != nil {
+		return logSource{}, err
+	}
+	if info.IsDir() {
+		return logSource{path: filepath.Join(build, launch.LogFile), running: follow}, nil
+	}
+	return logSource{path: build, running: follow}, nil
+}
+
+// registerLogsRedaction registers the values masked in the replayed log, since the log of a running build
+// or the one given by the path is not masked yet. They are the token and the secrets of the config,
+// the redact patterns and the JWTs. The current config is used when the config of the build is unknown.
+func registerLogsRedaction(configName string) error {
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return err
+	}
+
+	c, err := configNew(filepath.Join(sdlocalDir, "config"))
+	if err != nil {
+		return err
+	}
+	if configName == "" {
+		configName = c.Current
+	}
+
+	// The config of the build may have been removed since
+	if entry, err := c.Entry(configName); err == nil {
+		redactor.Add(entry.Token)
+	}
+
+	secrets, err := secretNew(filepath.Join(sdlocalDir, "secrets"), configName, secretPassphrase(configName))
+	if err != nil {
+		return err
+	}
+	values, err := secrets.Resolve(secrets.Names())
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		redactor.Add(v)
+	}
+
+	if err := redactor.AddPattern(redact.JWTPattern); err != nil {
+		return err
+	}
+
+	return redactor.AddPatternFile(filepath.Join(sdlocalDir, "redact-patterns"))
+}
+
+func newLogsCmd() *cobra.Command {
+	var (
+		flags         buildFlags
+		filter        buildlog.Filter
+		grep          string
+		follow        bool
+		artifactsPath string
+	)
+
+	logsCmd := &cobra.Command{
+		Use:   "logs [build]",
+		Short: "Print the build log of a build.",
+		Long: `Print the build log of a build in the same format as a running build.
+The build is an ID shown by "sd-local history ls", or the path to a raw build log or an artifacts directory.
+The newest build in the history is printed when it is omitted.
+With --follow, the lines appended by a running build are printed until interrupted.
+The running build in --artifacts-dir is followed when the build is omitted.`,
+		Example: `$ sd-local logs
+$ sd-local logs 20261017-101502.000 --step test --grep 'FAIL'
+$ sd-local logs --follow --tail 20`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if err := flags.validate(); err != nil {
+				return err
+			}
+
+			if grep != "" {
+				re, err := regexp.Compile(grep)
+				if err != nil {
+					return fmt.Errorf("invalid grep `%s`: %v", grep, err)
+				}
+				filter.Grep = re
+			}
+
+			if err := filter.Validate(); err != nil {
+				return err
+			}
+
+			dir, err := historyDir()
+			if err != nil {
+				return err
+			}
+
+			build := ""
+			if len(args) > 0 {
+				build = args[0]
+			}
+
+			source, err := findLogSource(history.New(dir), build, artifactsPath, follow)
+			if err != nil {
+				return err
+			}
+
+			if err := registerLogsRedaction(source.config); err != nil {
+				return err
+			}
+
+			file, err := os.Open(source.path)
+			if err != nil {
+				return &buildlog.Error{Err: fmt.Errorf("failed to open raw build log file: %w", err)}
+			}
+			defer file.Close()
+
+			redacted := redactor.Writer(cmd.OutOrStdout())
+			defer redacted.Close()
+
+			return buildlog.Replay(cmd.Context(), file, redacted, flags.logOptions(source.jobName), filter, source.running)
+		},
+	}
+
+	logsCmd.Flags().StringArrayVar(
+		&filter.Steps,
+		"step",
+		[]string{},
+		"Print only the lines of the step. Glob patterns like 'test-*' are supported. Can be specified multiple times.")
+
+	logsCmd.Flags().StringVar(
+		&grep,
+		"grep",
+		"",
+		"Print only the lines matching the regular expression.")
+
+	logsCmd.Flags().IntVar(
+		&filter.Tail,
+		"tail",
+		0,
+		"Print only the last N lines. All lines are printed with 0.")
+
+	logsCmd.Flags().BoolVarP(
+		&follow,
+		"follow",
+		"f",
+		false,
+		"Keep printing the lines appended by the running build.")
+
+	logsCmd.Flags().StringVar(
+		&artifactsPath,
+		"artifacts-dir",
+		launch.ArtifactsDir,
+		"Path to the artifacts directory of the running build to follow.")
+
+	flags.registerLogFormat(logsCmd)
+
+	return logsCmd
+}
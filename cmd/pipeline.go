@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	commitTrigger = "~commit"
+
+	jobSuccess = "SUCCESS"
+	jobFailure = "FAILURE"
+	jobSkipped = "SKIPPED"
+)
+
+type jobResult struct {
+	name     string
+	status   string
+	duration time.Duration
+}
+
+// joinReady returns true when all join sources of the job are triggered
+func joinReady(graph screwdriver.WorkflowGraph, job string, triggered map[string]bool) bool {
+	for _, e := range graph.Edges {
+		if e.Dest == job && e.Join && !triggered[e.Src] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parentJobs returns the jobs in the run which trigger the job
+func parentJobs(graph screwdriver.WorkflowGraph, job string, run map[string]bool) []string {
+	parents := make([]string, 0)
+	for _, e := range graph.Edges {
+		if e.Dest == job && run[e.Src] {
+			parents = append(parents, e.Src)
+		}
+	}
+
+	return parents
+}
+
+// pipelineJobs returns the jobs triggered from the start in dependency order.
+// An empty start means the jobs triggered by a commit.
+func pipelineJobs(pipeline screwdriver.Pipeline, start string) ([]string, error) {
+	graph := pipeline.WorkflowGraph
+
+	if start == "" {
+		start = commitTrigger
+	} else if _, ok := pipeline.Jobs[start]; !ok {
+		return nil, fmt.Errorf("not found '%s' in parsed screwdriver.yaml", start)
+	}
+
+	triggered := map[string]bool{start: true}
+	for changed := true; changed; {
+		changed = false
+		for _, e := range graph.Edges {
+			if triggered[e.Dest] || !triggered[e.Src] {
+				continue
+			}
+			if _, ok := pipeline.Jobs[e.Dest]; !ok {
+				continue
+			}
+			if e.Join && !joinReady(graph, e.Dest, triggered) {
+				continue
+			}
+			triggered[e.Dest] = true
+			changed = true
+		}
+	}
+
+	run := make(map[string]bool)
+	for name := range triggered {
+		if _, ok := pipeline.Jobs[name]; ok {
+			run[name] = true
+		}
+	}
+	if len(run) == 0 {
+		return nil, fmt.Errorf("no jobs are triggered by `%s`", start)
+	}
+
+	// The jobs are sorted topologically, keeping the order of the nodes among the jobs ready to run
+	order := make([]string, 0, len(run))
+	done := make(map[string]bool)
+	for len(order) < len(run) {
+		progressed := false
+		for _, node := range graph.Nodes {
+			name := node.Name
+			if !run[name] || done[name] {
+				continue
+			}
+
+			ready := true
+			if name != start {
+				for _, p := range parentJobs(graph, name, run) {
+					if !done[p] {
+						ready = false
+						break
+					}
+				}
+			}
+
+			if ready {
+				order = append(order, name)
+				done[name] = true
+				progressed = true
+			}
+		}
+
+		if !progressed {
+			return nil, errors.New("failed to order jobs, the workflow graph has a cycle")
+		}
+	}
+
+	return order, nil
+}
+
+// mergeMeta merges src into dst recursively as Screwdriver does for the meta of parent builds
+func mergeMeta(dst, src launch.Meta) launch.Meta {
+	merged := make(launch.Meta, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+
+	for k, v := range src {
+		d, dok := merged[k].(map[string]interface{})
+		s, sok := v.(map[string]interface{})
+		if dok && sok {
+			merged[k] = map[string]interface{}(mergeMeta(d, s))
+			continue
+		}
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// readJobMeta reads the meta which the build wrote in the meta directory
+func readJobMeta(metaPath string, meta launch.Meta) (launch.Meta, error) {
+	metaJSON, err := os.ReadFile(filepath.Join(metaPath, launch.MetaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return nil, fmt.Errorf("failed to read meta: %v", err)
+	}
+
+	var written launch.Meta
+	if err := json.Unmarshal(metaJSON, &written); err != nil {
+		return nil, fmt.Errorf("failed to parse meta %s: %v", string(metaJSON), err)
+	}
+
+	return written, nil
+}
+
+// makeMetaDir creates the private directory shared with the build container as /sd/meta
+func (f *buildFlags) makeMetaDir(baseDir, jobName string) (string, error) {
+	metaPath, err := os.MkdirTemp(baseDir, jobName+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create meta directory: %v", err)
+	}
+	f.chownBuildUser(metaPath)
+
+	return metaPath, nil
+}
+
+// runJobs runs the jobs in order, starting each job after all of its parent jobs succeed.
+// At most parallel jobs run at the same time and no job starts after a failure.
+// The jobs which have not started are reported as skipped.
+func runJobs(order []string, parents map[string][]string, parallel int, run func(name string) error) ([]jobResult, error) {
+	type finished struct {
+		name     string
+		duration time.Duration
+		err      error
+	}
+
+	results := make(map[string]jobResult, len(order))
+	started := make(map[string]bool, len(order))
+	succeeded := make(map[string]bool, len(order))
+	finishedCh := make(chan finished)
+	running := 0
+	var runErr error
+
+	for {
+		for _, name := range order {
+			if runErr != nil || running >= parallel {
+				break
+			}
+			if started[name] {
+				continue
+			}
+
+			ready := true
+			for _, p := range parents[name] {
+				if !succeeded[p] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			started[name] = true
+			running++
+			go func(name string) {
+				t := time.Now()
+				err := run(name)
+				finishedCh <- finished{name: name, duration: time.Since(t), err: err}
+			}(name)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		f := <-finishedCh
+		running--
+
+		result := jobResult{name: f.name, status: jobSuccess, duration: f.duration}
+		if f.err != nil {
+			result.status = jobFailure
+			if runErr == nil {
+				runErr = fmt.Errorf("failed to run job `%s`: %w", f.name, f.err)
+			}
+		} else {
+			succeeded[f.name] = true
+		}
+		results[f.name] = result
+	}
+
+	ordered := make([]jobResult, 0, len(order))
+	for _, name := range order {
+		result, ok := results[name]
+		if !ok {
+			result = jobResult{name: name, status: jobSkipped}
+		}
+		ordered = append(ordered, result)
+	}
+
+	return ordered, runErr
+}
+
+func printSummary(w io.Writer, results []jobResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tSTATUS\tDURATION")
+	for _, r := range results {
+		duration := "-"
+		if r.status != jobSkipped {
+			duration = r.duration.Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.name, r.status, duration)
+	}
+	tw.Flush()
+}
+
+func newPipelineCmd() *cobra.Command {
+	flags := &buildFlags{}
+	var parallel int
+
+	pipelineCmd := &cobra.Command{
+		Use:   "pipeline [start job name]",
+		Short: "Run screwdriver builds of the pipeline.",
+		Long: `Run screwdriver builds following the requires of the jobs.
+Without the start job name, the jobs triggered by a commit are run.
+The builds are run in dependency order and stopped on the first failure.
+The artifacts of each job are stored in the job name directory under the artifacts directory.
+With --parallel, the log lines of each job are prefixed with the job name except in json log format.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			err := cobra.MaximumNArgs(1)(cmd, args)
+
+			if err != nil {
+				return err
+			}
+
+			if parallel < 1 {
+				return fmt.Errorf("`parallel` must be a positive integer, got %d", parallel)
+			}
+
+			return flags.validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			start := ""
+			if len(args) > 0 {
+				start = args[0]
+			}
+
+			if flags.showOptions {
+				return flags.showEffectiveOptions(cmd.OutOrStdout())
+			}
+
+			s, err := flags.setup()
+			if err != nil {
+				return err
+			}
+			defer s.removeEventCache()
+			defer s.stopTokenServer()
+
+			pipeline, err := s.api.Pipeline(s.sdYAMLPath)
+			if err != nil {
+				return err
+			}
+
+			order, err := pipelineJobs(pipeline, start)
+			if err != nil {
+				return err
+			}
+
+			output, closeOutput, err := flags.openLogOutput(os.Stdout)
+			if err != nil {
+				return err
+			}
+			defer closeOutput()
+
+			metaBaseDir, err := os.MkdirTemp("", "sd-local-meta-")
+			if err != nil {
+				return fmt.Errorf("failed to create meta directory: %v", err)
+			}
+			defer os.RemoveAll(metaBaseDir)
+
+			run := make(map[string]bool, len(order))
+			for _, name := range order {
+				run[name] = true
+			}
+
+			parents := make(map[string][]string, len(order))
+			for _, name := range order {
+				if name != start {
+					parents[name] = parentJobs(pipeline.WorkflowGraph, name, run)
+				}
+			}
+
+			var metaMutex sync.Mutex
+			metas := make(map[string]launch.Meta, len(order))
+			logMutex := &sync.Mutex{}
+
+			results, runErr := runJobs(order, parents, parallel, func(name string) error {
+				logrus.Infof("Start job %s", name)
+
+				metaMutex.Lock()
+				meta := s.meta
+				if len(parents[name]) > 0 {
+					meta = launch.Meta{}
+					for _, p := range parents[name] {
+						meta = mergeMeta(meta, metas[p])
+					}
+				}
+				metaMutex.Unlock()
+
+				artifactsPath, err := makeDir(filepath.Join(artifactsDir, name))
+				if err != nil {
+					return err
+				}
+
+				// Each job has its own utils directory, since the launcher removes it in cleaning while the other jobs run
+				sdUtilsPath, err := makeDir(filepath.Join(sdUtilsDir, name))
+				if err != nil {
+					return err
+				}
+
+				metaPath, err := flags.makeMetaDir(metaBaseDir, name)
+				if err != nil {
+					return err
+				}
+
+				option, err := flags.launchOption(s, pipeline.Jobs[name], name, artifactsPath, sdUtilsPath)
+				if err != nil {
+					return err
+				}
+				option.Meta = meta
+				option.MetaPath = metaPath
+				option.BuildName = name
+
+				writer := output
+				if parallel > 1 {
+					// JSON lines have the job name in themselves
+					prefix := fmt.Sprintf("[%s] ", name)
+					if flags.logFormat == buildlog.JSONFormat {
+						prefix = ""
+					}
+					prefixed := buildlog.NewPrefixWriter(output, prefix, logMutex)
+					defer func() {
+						if err := prefixed.Close(); err != nil {
+							logrus.Warn(err)
+						}
+					}()
+					writer = prefixed
+				}
+
+				if _, err := runBuild(s, option, writer, flags.logOptions(name)); err != nil {
+					return err
+				}
+
+				jobMeta, err := readJobMeta(metaPath, meta)
+				if err != nil {
+					return err
+				}
+
+				metaMutex.Lock()
+				metas[name] = jobMeta
+				metaMutex.Unlock()
+
+				return nil
+			})
+
+			// The utils directories of the jobs are removed by their launchers
+			if path, err := filepath.Abs(sdUtilsDir); err == nil {
+				os.Remove(path)
+			}
+
+			printSummary(cmd.OutOrStdout(), results)
+
+			return runErr
+		},
+	}
+
+	flags.register(pipelineCmd)
+
+	pipelineCmd.Flags().IntVar(
+		&parallel,
+		"parallel",
+		1,
+		"Number of jobs which run at the same time. Jobs run in parallel when they do not depend on each other.")
+
+	return pipelineCmd
+}
@@ -0,0 +1,470 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPipelineAPI struct {
+	mockAPI
+	pipeline screwdriver.Pipeline
+}
+
+func (mock mockPipelineAPI) Pipeline(filePath string) (screwdriver.Pipeline, error) {
+	return mock.pipeline, nil
+}
+
+type mockPipelineLaunch struct {
+	mockLaunch
+	option launch.Option
+	meta   launch.Meta
+	err    error
+}
+
+func (mock mockPipelineLaunch) Run() error {
+	if mock.err != nil {
+		return mock.err
+	}
+
+	if mock.meta != nil {
+		metaJSON, err := json.Marshal(mock.meta)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(mock.option.MetaPath, launch.MetaFile), metaJSON, 0666); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newTestPipeline() screwdriver.Pipeline {
+	job := func(name string, requires ...string) screwdriver.Job {
+		return screwdriver.Job{
+			Steps:    []screwdriver.Step{{Name: name, Command: "echo " + name}},
+			Image:    "alpine",
+			Requires: requires,
+		}
+	}
+
+	return screwdriver.Pipeline{
+		Jobs: map[string]screwdriver.Job{
+			"test":    job("test", "~commit"),
+			"lint":    job("lint", "~commit"),
+			"build":   job("build", "~test"),
+			"publish": job("publish", "build", "lint"),
+			"pr":      job("pr", "~pr"),
+		},
+		WorkflowGraph: screwdriver.WorkflowGraph{
+			Nodes: []screwdriver.WorkflowNode{
+				{Name: "~pr"},
+				{Name: "~commit"},
+				{Name: "test"},
+				{Name: "lint"},
+				{Name: "build"},
+				{Name: "publish"},
+				{Name: "pr"},
+			},
+			Edges: []screwdriver.WorkflowEdge{
+				{Src: "~commit", Dest: "test"},
+				{Src: "~commit", Dest: "lint"},
+				{Src: "test", Dest: "build"},
+				{Src: "build", Dest: "publish", Join: true},
+				{Src: "lint", Dest: "publish", Join: true},
+				{Src: "~pr", Dest: "pr"},
+			},
+		},
+	}
+}
+
+func TestPipelineJobs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		start     string
+		pipeline  screwdriver.Pipeline
+		want      []string
+		wantError string
+	}{
+		{
+			name:     "jobs triggered by a commit",
+			pipeline: newTestPipeline(),
+			want:     []string{"test", "lint", "build", "publish"},
+		},
+		{
+			name:     "jobs triggered by the start job",
+			start:    "test",
+			pipeline: newTestPipeline(),
+			want:     []string{"test", "build"},
+		},
+		{
+			name:      "not found start job",
+			start:     "deploy",
+			pipeline:  newTestPipeline(),
+			wantError: "not found 'deploy' in parsed screwdriver.yaml",
+		},
+		{
+			name:      "no jobs triggered by a commit",
+			pipeline:  screwdriver.Pipeline{Jobs: map[string]screwdriver.Job{"pr": {}}},
+			wantError: "no jobs are triggered by `~commit`",
+		},
+		{
+			name: "cycle",
+			pipeline: screwdriver.Pipeline{
+				Jobs: map[string]screwdriver.Job{"a": {}, "b": {}},
+				WorkflowGraph: screwdriver.WorkflowGraph{
+					Nodes: []screwdriver.WorkflowNode{{Name: "~commit"}, {Name: "a"}, {Name: "b"}},
+					Edges: []screwdriver.WorkflowEdge{
+						{Src: "~commit", Dest: "a"},
+						{Src: "a", Dest: "b"},
+						{Src: "b", Dest: "a"},
+					},
+				},
+			},
+			wantError: "failed to order jobs, the workflow graph has a cycle",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pipelineJobs(tt.pipeline, tt.start)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunJobs(t *testing.T) {
+	order := []string{"test", "lint", "build", "publish"}
+	parents := map[string][]string{
+		"build":   {"test"},
+		"publish": {"build", "lint"},
+	}
+
+	t.Run("run independent jobs in parallel", func(t *testing.T) {
+		var mutex sync.Mutex
+		running, maxRunning := 0, 0
+		finished := []string{}
+
+		results, err := runJobs(order, parents, 2, func(name string) error {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			for _, p := range parents[name] {
+				assert.Contains(t, finished, p)
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			running--
+			finished = append(finished, name)
+			mutex.Unlock()
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, maxRunning)
+		assert.Len(t, finished, 4)
+		for i, r := range results {
+			assert.Equal(t, order[i], r.name)
+			assert.Equal(t, jobSuccess, r.status)
+		}
+	})
+
+	t.Run("run jobs one by one", func(t *testing.T) {
+		started := []string{}
+		_, err := runJobs(order, parents, 1, func(name string) error {
+			started = append(started, name)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, order, started)
+	})
+
+	t.Run("stop starting jobs after a failure", func(t *testing.T) {
+		results, err := runJobs(order, parents, 2, func(name string) error {
+			if name == "test" {
+				return errors.New("exit status 1")
+			}
+			return nil
+		})
+		assert.EqualError(t, err, "failed to run job `test`: exit status 1")
+
+		statuses := map[string]string{}
+		for _, r := range results {
+			statuses[r.name] = r.status
+		}
+		assert.Equal(t, map[string]string{
+			"test":    jobFailure,
+			"lint":    jobSuccess,
+			"build":   jobSkipped,
+			"publish": jobSkipped,
+		}, statuses)
+	})
+}
+
+func TestMergeMeta(t *testing.T) {
+	got := mergeMeta(
+		launch.Meta{"foo": "foo", "nested": map[string]interface{}{"a": "a", "b": "b"}},
+		launch.Meta{"bar": "bar", "nested": map[string]interface{}{"b": "B"}},
+	)
+	assert.Equal(t, launch.Meta{
+		"foo":    "foo",
+		"bar":    "bar",
+		"nested": map[string]interface{}{"a": "a", "b": "B"},
+	}, got)
+}
+
+func TestMakeMetaDir(t *testing.T) {
+	testCases := []struct {
+		name      string
+		buildUser string
+		wantChown []int
+	}{
+		{name: "success with the user of the image", buildUser: ""},
+		{name: "success with the user name", buildUser: "node"},
+		{name: "success with the host user", buildUser: fmt.Sprint(os.Getuid())},
+		{name: "success with the UID", buildUser: "100001", wantChown: []int{100001, -1}},
+		{name: "success with the UID and GID", buildUser: "100001:100002", wantChown: []int{100001, 100002}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { osChown = os.Chown }()
+			var chown []int
+			osChown = func(name string, uid, gid int) error {
+				chown = []int{uid, gid}
+				return nil
+			}
+
+			baseDir := t.TempDir()
+			flags := &buildFlags{buildUser: tt.buildUser}
+			metaPath, err := flags.makeMetaDir(baseDir, "test")
+			assert.Nil(t, err)
+			assert.Equal(t, baseDir, filepath.Dir(metaPath))
+			assert.True(t, strings.HasPrefix(filepath.Base(metaPath), "test-"))
+			assert.Equal(t, tt.wantChown, chown)
+
+			info, err := os.Stat(metaPath)
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+		})
+	}
+}
+
+func TestPipelineCmd(t *testing.T) {
+	defer func() {
+		setup()
+	}()
+
+	apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+		return mockPipelineAPI{pipeline: newTestPipeline()}, nil
+	}
+
+	t.Run("Success pipeline cmd", func(t *testing.T) {
+		metas := map[string]launch.Meta{
+			"test": {"test": "done"},
+			"lint": {"lint": "done"},
+		}
+		got := map[string]launch.Option{}
+		order := []string{}
+		launchNew = func(option launch.Option) launch.Launcher {
+			got[option.JobName] = option
+			order = append(order, option.JobName)
+			return mockPipelineLaunch{option: option, meta: metas[option.JobName]}
+		}
+
+		root := newPipelineCmd()
+		root.SetArgs([]string{"--meta", `{"user": "meta"}`})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"test", "lint", "build", "publish"}, order)
+
+		assert.Equal(t, launch.Meta{"user": "meta"}, got["test"].Meta)
+		assert.Equal(t, launch.Meta{"test": "done"}, got["build"].Meta)
+		assert.Equal(t, launch.Meta{"test": "done", "lint": "done"}, got["publish"].Meta)
+		assert.Equal(t, "http://sd-local.host:8765/token", got["publish"].TokenURL)
+		assert.Equal(t, "testkey", got["publish"].TokenKey)
+
+		artifactsPath, _ := filepath.Abs(filepath.Join("sd-artifacts", "build"))
+		assert.Equal(t, artifactsPath, got["build"].ArtifactsPath)
+		sdUtilsPath, _ := filepath.Abs(filepath.Join(launch.SdUtilsDir, "build"))
+		assert.Equal(t, sdUtilsPath, got["build"].SdUtilsPath)
+		assert.NotEqual(t, "", got["build"].MetaPath)
+		assert.Equal(t, "build", got["build"].BuildName)
+
+		want := "JOB       STATUS    DURATION\n" +
+			"test      SUCCESS   0s\n" +
+			"lint      SUCCESS   0s\n" +
+			"build     SUCCESS   0s\n" +
+			"publish   SUCCESS   0s\n"
+		assert.Equal(t, want, buf.String())
+	})
+
+	t.Run("Failed pipeline cmd stops on the first failure", func(t *testing.T) {
+		order := []string{}
+		launchNew = func(option launch.Option) launch.Launcher {
+			order = append(order, option.JobName)
+			if option.JobName == "build" {
+				return mockPipelineLaunch{option: option, err: errors.New("exit status 1")}
+			}
+			return mockPipelineLaunch{option: option}
+		}
+
+		root := newPipelineCmd()
+		root.SetArgs([]string{"test"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.EqualError(t, err, "failed to run job `build`: exit status 1")
+		assert.Equal(t, []string{"test", "build"}, order)
+
+		want := "JOB     STATUS    DURATION\n" +
+			"test    SUCCESS   0s\n" +
+			"build   FAILURE   0s\n"
+		assert.Equal(t, want, buf.String())
+	})
+
+	t.Run("Failed pipeline cmd with skipped jobs", func(t *testing.T) {
+		launchNew = func(option launch.Option) launch.Launcher {
+			if option.JobName == "test" {
+				return mockPipelineLaunch{option: option, err: errors.New("exit status 1")}
+			}
+			return mockPipelineLaunch{option: option}
+		}
+
+		root := newPipelineCmd()
+		root.SetArgs([]string{})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.EqualError(t, err, "failed to run job `test`: exit status 1")
+
+		want := "JOB       STATUS    DURATION\n" +
+			"test      FAILURE   0s\n" +
+			"lint      SKIPPED   -\n" +
+			"build     SKIPPED   -\n" +
+			"publish   SKIPPED   -\n"
+		assert.Equal(t, want, buf.String())
+	})
+
+	t.Run("Failed pipeline cmd with too many args", func(t *testing.T) {
+		root := newPipelineCmd()
+		root.SetArgs([]string{"test", "build"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.EqualError(t, err, "accepts at most 1 arg(s), received 2")
+	})
+
+	t.Run("Success pipeline cmd with --parallel", func(t *testing.T) {
+		var mutex sync.Mutex
+		started := []string{}
+		launchNew = func(option launch.Option) launch.Launcher {
+			mutex.Lock()
+			started = append(started, option.JobName)
+			mutex.Unlock()
+			return mockPipelineLaunch{option: option}
+		}
+
+		root := newPipelineCmd()
+		root.SetArgs([]string{"--parallel", "2"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"test", "lint", "build", "publish"}, started)
+	})
+
+	t.Run("Success pipeline cmd with --parallel and json --log-format", func(t *testing.T) {
+		defBuildLogNew := buildLogNew
+		defer func() {
+			buildLogNew = defBuildLogNew
+		}()
+
+		buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}, options buildlog.Options) (buildlog.Logger, error) {
+			return mockOutputLogger{done: done, writer: writer, output: fmt.Sprintf("{\"job\":\"%s\"}\n", options.JobName)}, nil
+		}
+		launchNew = func(option launch.Option) launch.Launcher {
+			return mockPipelineLaunch{option: option}
+		}
+
+		logOutput := filepath.Join(t.TempDir(), "pipeline.log")
+		root := newPipelineCmd()
+		root.SetArgs([]string{"--parallel", "2", "--log-format", "json", "--log-output", logOutput})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.Nil(t, err)
+
+		written, err := os.ReadFile(logOutput)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(written), "\n"), "\n")
+		assert.ElementsMatch(t, []string{`{"job":"test"}`, `{"job":"lint"}`, `{"job":"build"}`, `{"job":"publish"}`}, lines)
+	})
+
+	t.Run("Success pipeline cmd with --parallel and the last line without a newline", func(t *testing.T) {
+		defBuildLogNew := buildLogNew
+		defer func() {
+			buildLogNew = defBuildLogNew
+		}()
+
+		buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}, options buildlog.Options) (buildlog.Logger, error) {
+			return mockOutputLogger{done: done, writer: writer, output: options.JobName + ": done"}, nil
+		}
+		launchNew = func(option launch.Option) launch.Launcher {
+			return mockPipelineLaunch{option: option}
+		}
+
+		logOutput := filepath.Join(t.TempDir(), "pipeline.log")
+		root := newPipelineCmd()
+		root.SetArgs([]string{"--parallel", "2", "--log-output", logOutput})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.Nil(t, err)
+
+		written, err := os.ReadFile(logOutput)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(written), "\n"), "\n")
+		assert.ElementsMatch(t, []string{"[test] test: done", "[lint] lint: done", "[build] build: done", "[publish] publish: done"}, lines)
+	})
+
+	t.Run("Failed pipeline cmd with invalid --parallel", func(t *testing.T) {
+		root := newPipelineCmd()
+		root.SetArgs([]string{"--parallel", "0"})
+		buf := bytes.NewBuffer(nil)
+		root.SetOut(buf)
+
+		err := root.Execute()
+		assert.EqualError(t, err, "`parallel` must be a positive integer, got 0")
+	})
+}
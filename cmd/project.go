@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/project"
+	"github.com/screwdriver-cd/sd-local/redact"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+var projectFind = project.Find
+
+const (
+	flagSource    = "flag"
+	defaultSource = "default"
+)
+
+// projectPathFlags are the flags of the host side paths, which are relative to the directory of the project file
+var projectPathFlags = map[string]bool{
+	"artifacts-dir": true,
+	"utils-dir":     true,
+	"env-file":      true,
+	"meta-file":     true,
+	"log-output":    true,
+	"ca-cert":       true,
+	"client-cert":   true,
+	"client-key":    true,
+}
+
+// projectIgnoredFlags can't be set in the project file
+var projectIgnoredFlags = map[string]bool{
+	"help":                   true,
+	"src-url":                true,
+	"show-effective-options": true,
+	"trust-project":          true,
+}
+
+// projectUntrustedFlags are ignored in the project file unless --trust-project is given,
+// since the repository could send the token to another host or give the build access to the host with them
+var projectUntrustedFlags = map[string]bool{
+	"api-proxy":     true,
+	"ca-cert":       true,
+	"client-cert":   true,
+	"client-key":    true,
+	"sudo":          true,
+	"privileged":    true,
+	"vol":           true,
+	"socket":        true,
+	"artifacts-dir": true,
+	"utils-dir":     true,
+	"env-file":      true,
+	"meta-file":     true,
+	"log-output":    true,
+}
+
+// projectValue returns the value of the flag in the project file, resolving the relative host side paths
+func projectValue(file *project.File, name, value string) string {
+	if projectPathFlags[name] {
+		return file.Resolve(value)
+	}
+
+	if name == "vol" {
+		// Only the paths starting with . are relative, since the others are the names of the volumes
+		host, container, found := strings.Cut(value, ":")
+		if found && strings.HasPrefix(host, ".") {
+			return file.Resolve(host) + ":" + container
+		}
+	}
+
+	return value
+}
+
+// applyProject finds the project file from the source directory, and sets its values to the flags which are not set explicitly.
+// The security-sensitive flags, and the config of the source pulled by --src-url, are ignored with a warning unless --trust-project is given.
+func (f *buildFlags) applyProject(srcPath string, pulled bool) error {
+	if f.flagSet == nil {
+		return nil
+	}
+
+	f.sources = make(map[string]string)
+	f.flagSet.Visit(func(flag *pflag.Flag) {
+		f.sources[flag.Name] = flagSource
+	})
+
+	file, err := projectFind(srcPath)
+	if err != nil {
+		return err
+	}
+	f.project = file
+	if file == nil {
+		return nil
+	}
+
+	if pulled && !f.trustProject && file.Config != "" {
+		logrus.Warnf("config in %s of the source pulled by --src-url is ignored, use --trust-project to apply it", file.Path)
+		file.Config = ""
+	}
+
+	for _, name := range file.Names() {
+		flag := f.flagSet.Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("unknown flag `%s` in %s", name, file.Path)
+		}
+		if projectIgnoredFlags[name] {
+			return fmt.Errorf("flag `%s` can't be set in %s", name, file.Path)
+		}
+		if flag.Changed {
+			continue
+		}
+		if projectUntrustedFlags[name] && !f.trustProject {
+			logrus.Warnf("flag `%s` in %s is ignored, pass it as the flag or use --trust-project to apply it", name, file.Path)
+			continue
+		}
+
+		values, err := file.Values(name)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := f.flagSet.Set(name, projectValue(file, name, v)); err != nil {
+				return fmt.Errorf("invalid value of `%s` in %s: %v", name, file.Path, err)
+			}
+		}
+		f.sources[name] = file.Path
+	}
+
+	return nil
+}
+
+// selectConfig selects the config entry which the project file specifies, unless SDLOCAL_CONFIG is set
+func (f *buildFlags) selectConfig(c *config.Config) error {
+	if f.project == nil || f.project.Config == "" {
+		return nil
+	}
+
+	if err := c.Select(f.project.Config); err != nil {
+		return fmt.Errorf("invalid config in %s: %v", f.project.Path, err)
+	}
+
+	return nil
+}
+
+// printEffectiveOptions prints the values of the flags and the config merged with the project file, and where they come from
+func (f *buildFlags) printEffectiveOptions(w io.Writer, c *config.Config, configPath string) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "OPTION\tVALUE\tSOURCE")
+
+	configSource := configPath
+	if env := c.CurrentOverride(); env != "" {
+		configSource = env
+	} else if f.project != nil && f.project.Config != "" {
+		configSource = f.project.Path
+	}
+	fmt.Fprintf(tw, "config\t%s\t%s\n", c.Current, configSource)
+
+	f.flagSet.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" || flag.Name == "show-effective-options" {
+			return
+		}
+
+		value := flag.Value.String()
+		if flag.Name == "env" {
+			value = maskedEnv(f.flagEnv)
+		}
+		if value == "" {
+			value = "-"
+		}
+
+		source, ok := f.sources[flag.Name]
+		if !ok {
+			source = defaultSource
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", flag.Name, value, source)
+	})
+	tw.Flush()
+}
+
+// maskedEnv returns the environment variables of --env in the format of the flag, masking the values whose names look like secrets
+func maskedEnv(env map[string]string) string {
+	pairs := make([]string, 0, len(env))
+	for k, v := range env {
+		if redact.SecretLike(k) {
+			v = redact.Mask
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return "[" + strings.Join(pairs, ",") + "]"
+}
+
+// showEffectiveOptions prints the options merged with the project file without running the builds
+func (f *buildFlags) showEffectiveOptions(w io.Writer) error {
+	sdlocalDir, err := config.BaseDir()
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.prepareSource(sdlocalDir); err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(sdlocalDir, "config")
+	c, err := configNew(configPath)
+	if err != nil {
+		return err
+	}
+
+	if err := f.selectConfig(&c); err != nil {
+		return err
+	}
+
+	f.printEffectiveOptions(w, &c, configPath)
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/project"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupProject writes the project file in a temporary directory and makes it found, returning its path
+func setupProject(t *testing.T, content string) string {
+	dir := t.TempDir()
+	p := filepath.Join(dir, project.FileName)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defMemory := memory
+	t.Cleanup(func() {
+		memory = defMemory
+		setup()
+	})
+	projectFind = func(srcPath string) (*project.File, error) {
+		return project.Find(dir)
+	}
+
+	return p
+}
+
+// captureLogs captures the logs written during the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	logrus.SetOutput(buf)
+	t.Cleanup(func() {
+		logrus.SetOutput(os.Stderr)
+	})
+
+	return buf
+}
+
+func TestApplyProject(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		p := setupProject(t, `config: work
+flags:
+  memory: 4g
+  user: node
+  no-image-pull: true
+  env-file: .env.local
+  vol:
+    - ./cache:/cache
+    - gomod:/go/pkg/mod
+  env:
+    NODE_ENV: test
+`)
+		dir := filepath.Dir(p)
+
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+		assert.Nil(t, cmd.ParseFlags([]string{"--user", "root", "--trust-project"}))
+
+		assert.Nil(t, flags.applyProject(t.TempDir(), false))
+		assert.Equal(t, "4g", memory)
+		assert.Equal(t, "root", flags.buildUser)
+		assert.True(t, flags.noImagePull)
+		assert.Equal(t, filepath.Join(dir, ".env.local"), flags.envFilePath)
+		assert.Equal(t, []string{filepath.Join(dir, "cache") + ":/cache", "gomod:/go/pkg/mod"}, flags.localVolumes)
+		assert.Equal(t, map[string]string{"NODE_ENV": "test"}, flags.flagEnv)
+
+		c := config.Config{Entries: map[string]*config.Entry{"default": {}, "work": {}}, Current: "default"}
+		assert.Nil(t, flags.selectConfig(&c))
+		assert.Equal(t, "work", c.Current)
+		assert.Equal(t, map[string]string{
+			"user":          flagSource,
+			"trust-project": flagSource,
+			"memory":        p,
+			"no-image-pull": p,
+			"env-file":      p,
+			"vol":           p,
+			"env":           p,
+		}, flags.sources)
+	})
+
+	t.Run("success without project file", func(t *testing.T) {
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+
+		assert.Nil(t, flags.applyProject(t.TempDir(), false))
+		assert.Nil(t, flags.project)
+
+		c := config.Config{Entries: map[string]*config.Entry{"default": {}, "work": {}}, Current: "default"}
+		assert.Nil(t, flags.selectConfig(&c))
+		assert.Equal(t, "default", c.Current)
+	})
+
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "failure by unknown flag",
+			content: "flags:\n  cpu: 2\n",
+			wantErr: "unknown flag `cpu` in %s",
+		},
+		{
+			name:    "failure by src-url",
+			content: "flags:\n  src-url: git@github.com:screwdriver-cd/sd-local.git\n",
+			wantErr: "flag `src-url` can't be set in %s",
+		},
+		{
+			name:    "failure by invalid value",
+			content: "flags:\n  no-image-pull: maybe\n",
+			wantErr: "invalid value of `no-image-pull` in %s: invalid argument \"maybe\" for \"--no-image-pull\" flag: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := setupProject(t, tt.content)
+
+			cmd := &cobra.Command{}
+			flags := &buildFlags{}
+			flags.register(cmd)
+
+			err := flags.applyProject(t.TempDir(), false)
+			assert.EqualError(t, err, regexp.MustCompile("%s").ReplaceAllLiteralString(tt.wantErr, p))
+		})
+	}
+}
+
+func TestApplyUntrustedProject(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{name: "api-proxy", content: "flags:\n  api-proxy: http://attacker.example.com:8080\n"},
+		{name: "ca-cert", content: "flags:\n  ca-cert: ./ca.pem\n"},
+		{name: "client-cert", content: "flags:\n  client-cert: ./cert.pem\n"},
+		{name: "client-key", content: "flags:\n  client-key: ./key.pem\n"},
+		{name: "sudo", content: "flags:\n  sudo: true\n"},
+		{name: "privileged", content: "flags:\n  privileged: true\n"},
+		{name: "vol", content: "flags:\n  vol:\n    - /:/host\n"},
+		{name: "socket", content: "flags:\n  socket: /tmp/docker.sock\n"},
+		{name: "artifacts-dir", content: "flags:\n  artifacts-dir: /home/user/.ssh\n"},
+		{name: "utils-dir", content: "flags:\n  utils-dir: /etc\n"},
+		{name: "env-file", content: "flags:\n  env-file: /home/user/.aws/credentials\n"},
+		{name: "meta-file", content: "flags:\n  meta-file: /etc/passwd\n"},
+		{name: "log-output", content: "flags:\n  log-output: /home/user/.bashrc\n"},
+	}
+
+	for _, tt := range testCases {
+		for _, pulled := range []bool{false, true} {
+			t.Run(fmt.Sprintf("success to ignore %s (pulled: %v)", tt.name, pulled), func(t *testing.T) {
+				p := setupProject(t, tt.content)
+				logs := captureLogs(t)
+
+				cmd := &cobra.Command{}
+				flags := &buildFlags{}
+				flags.register(cmd)
+
+				assert.Nil(t, flags.applyProject(t.TempDir(), pulled))
+				assert.NotContains(t, flags.sources, tt.name)
+				assert.Equal(t, launch.DefaultSocketPath(), flags.socketPath)
+				assert.Empty(t, flags.localVolumes)
+				assert.Contains(t, logs.String(), "flag `"+tt.name+"` in "+p+" is ignored, pass it as the flag or use --trust-project to apply it")
+			})
+		}
+	}
+
+	t.Run("success to apply them with --trust-project", func(t *testing.T) {
+		p := setupProject(t, "config: work\nflags:\n  socket: /tmp/docker.sock\n  vol:\n    - ./cache:/cache\n")
+
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+		assert.Nil(t, cmd.ParseFlags([]string{"--trust-project"}))
+
+		assert.Nil(t, flags.applyProject(t.TempDir(), true))
+		assert.Equal(t, "/tmp/docker.sock", flags.socketPath)
+		assert.Equal(t, []string{filepath.Join(filepath.Dir(p), "cache") + ":/cache"}, flags.localVolumes)
+		assert.Equal(t, "work", flags.project.Config)
+	})
+
+	t.Run("success to ignore the config of the pulled source", func(t *testing.T) {
+		p := setupProject(t, "config: work\n")
+		logs := captureLogs(t)
+
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+
+		assert.Nil(t, flags.applyProject(t.TempDir(), true))
+		assert.Contains(t, logs.String(), "config in "+p+" of the source pulled by --src-url is ignored, use --trust-project to apply it")
+
+		c := config.Config{Entries: map[string]*config.Entry{"default": {}, "work": {}}, Current: "default"}
+		assert.Nil(t, flags.selectConfig(&c))
+		assert.Equal(t, "default", c.Current)
+	})
+
+	t.Run("success with the other flags", func(t *testing.T) {
+		setupProject(t, "flags:\n  memory: 4g\n  user: node\n  env:\n    NODE_ENV: test\n")
+
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+
+		assert.Nil(t, flags.applyProject(t.TempDir(), true))
+		assert.Equal(t, "4g", memory)
+		assert.Equal(t, "node", flags.buildUser)
+		assert.Equal(t, map[string]string{"NODE_ENV": "test"}, flags.flagEnv)
+	})
+}
+
+func TestShowEffectiveOptions(t *testing.T) {
+	p := setupProject(t, `flags:
+  memory: 4g
+  env:
+    NODE_ENV: test
+`)
+
+	root := newBuildCmd()
+	root.SetArgs([]string{"test", "--show-effective-options", "--log-format", "json", "--env", "GITHUB_TOKEN=ghp_secret"})
+	buf := bytes.NewBuffer(nil)
+	root.SetOut(buf)
+	assert.Nil(t, root.Execute())
+
+	out := buf.String()
+	assert.Regexp(t, `(?m)^OPTION\s+VALUE\s+SOURCE$`, out)
+	assert.Regexp(t, `(?m)^config\s+default\s+\S+config$`, out)
+	assert.Regexp(t, `(?m)^memory\s+4g\s+`+regexp.QuoteMeta(p)+`$`, out)
+	assert.Regexp(t, `(?m)^log-format\s+json\s+flag$`, out)
+	assert.Regexp(t, `(?m)^artifacts-dir\s+sd-artifacts\s+default$`, out)
+	assert.Regexp(t, `(?m)^user\s+-\s+default$`, out)
+	assert.Regexp(t, `(?m)^env\s+\[GITHUB_TOKEN=\*\*\*\]\s+flag$`, out)
+	assert.NotContains(t, out, "ghp_secret")
+	assert.NotContains(t, out, "show-effective-options")
+}
+
+func TestSelectConfig(t *testing.T) {
+	newConfig := func() config.Config {
+		c, err := config.New(filepath.Join(t.TempDir(), "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.AddEntry("work", config.DefaultEntry()); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	t.Run("success with SDLOCAL_CONFIG", func(t *testing.T) {
+		setupProject(t, "config: work\n")
+		t.Setenv(config.ConfigEnv, "default")
+
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+		assert.Nil(t, flags.applyProject(t.TempDir(), false))
+
+		c := newConfig()
+		assert.Nil(t, flags.selectConfig(&c))
+		assert.Equal(t, "default", c.Current)
+	})
+
+	t.Run("failure by unknown config", func(t *testing.T) {
+		p := setupProject(t, "config: ci\n")
+
+		cmd := &cobra.Command{}
+		flags := &buildFlags{}
+		flags.register(cmd)
+		assert.Nil(t, flags.applyProject(t.TempDir(), false))
+
+		c := newConfig()
+		assert.EqualError(t, flags.selectConfig(&c), "invalid config in "+p+": config `ci` does not exist")
+	})
+}
@@ -3,14 +3,23 @@ package cmd
 import (
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
+	"github.com/screwdriver-cd/sd-local/cmd/cache"
 	"github.com/screwdriver-cd/sd-local/cmd/config"
+	"github.com/screwdriver-cd/sd-local/cmd/history"
+	"github.com/screwdriver-cd/sd-local/cmd/secret"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/state"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cleaners []Cleaner
+	cleaners = &cleanerSet{}
+	// session records the resources of the builds so that `sd-local clean` can remove them if sd-local is killed
+	session *state.Session
 )
 
 // Cleaner will post-process sd-local.
@@ -19,6 +28,50 @@ type Cleaner interface {
 	Clean()
 }
 
+// cleanerSet holds the cleaners called when sd-local exits.
+// Builds running in parallel add and remove their cleaners concurrently.
+type cleanerSet struct {
+	mutex    sync.Mutex
+	nextID   int
+	cleaners map[int]Cleaner
+}
+
+// add adds the cleaner and returns the function to remove it
+func (s *cleanerSet) add(c Cleaner) func() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cleaners == nil {
+		s.cleaners = make(map[int]Cleaner)
+	}
+
+	id := s.nextID
+	s.nextID++
+	s.cleaners[id] = c
+
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		delete(s.cleaners, id)
+	}
+}
+
+// list returns the cleaners in the order they were added
+func (s *cleanerSet) list() []Cleaner {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	list := make([]Cleaner, 0, len(s.cleaners))
+	for id := 0; id < s.nextID; id++ {
+		if c, ok := s.cleaners[id]; ok {
+			list = append(list, c)
+		}
+	}
+
+	return list
+}
+
 var (
 	flagVerbose bool
 )
@@ -51,36 +104,61 @@ a mostly the same environment as Screwdriver.cd's`,
 }
 
 func kill(sig os.Signal) {
-	for _, v := range cleaners {
+	for _, v := range cleaners.list() {
 		v.Kill(sig)
 	}
 }
 
 func clean() {
-	for _, v := range cleaners {
+	for _, v := range cleaners.list() {
 		v.Clean()
 	}
+
+	if err := session.Delete(); err != nil {
+		logrus.Warn(err)
+	}
 }
 
 // Execute executes the root command.
 func Execute() error {
-	cleaners = make([]Cleaner, 0, 2)
+	cleaners = &cleanerSet{}
+	if dir, err := stateDir(); err == nil {
+		session = state.New(dir).NewSession()
+	}
 	defer clean()
 
+	// The JWT and secrets are masked in the logs, e.g. docker commands in verbose mode
+	logrus.SetFormatter(redactor.Formatter(logrus.StandardLogger().Formatter))
+
+	// The errors of the docker and podman commands may have them as well
+	stderr := redactor.Writer(os.Stderr)
+	defer stderr.Close()
+	launch.Stderr = stderr
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 		sig := <-quit
+		if s, ok := sig.(syscall.Signal); ok {
+			abort(s)
+		}
 		kill(sig)
 		clean()
-		os.Exit(1)
+		os.Exit(signalExitCode(sig))
 	}()
 
 	rootCmd := newRootCmd()
 	rootCmd.SilenceErrors = true
 	rootCmd.AddCommand(
 		newBuildCmd(),
+		newPipelineCmd(),
 		config.NewConfigCmd(),
+		cache.NewCacheCmd(),
+		secret.NewSecretCmd(),
+		history.NewHistoryCmd(),
+		newLogsCmd(),
+		newDoctorCmd(),
+		newCleanCmd(),
 		newVersionCmd(),
 		newUpdateCmd(),
 	)
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,26 +12,48 @@ import (
 
 	"github.com/screwdriver-cd/sd-local/buildlog"
 	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/history"
 	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/project"
 
 	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/token"
 )
 
 type mockAPI struct{}
-type mockLogger struct{}
+type mockLogger struct {
+	done chan<- struct{}
+}
 type mockLaunch struct{}
+type mockTokenServer struct{}
 
 func (mock mockAPI) Job(jobName, filePath string) (screwdriver.Job, error) {
 	return screwdriver.Job{}, nil
 }
 
+func (mock mockAPI) Pipeline(filePath string) (screwdriver.Pipeline, error) {
+	return screwdriver.Pipeline{}, nil
+}
+
 func (mock mockAPI) JWT() string { return "" }
 
 func (mock mockAPI) InitJWT() error { return nil }
 
+func (mock mockAPI) SetJWT(jwt string) {}
+
+func (mock mockTokenServer) Port() int { return 8765 }
+
+func (mock mockTokenServer) NewKey() (string, error) { return "testkey", nil }
+
+func (mock mockTokenServer) RevokeKey(key string) {}
+
+func (mock mockTokenServer) Close() error { return nil }
+
 func (mock mockLogger) Run() {}
 
-func (mock mockLogger) Stop() { close(loggerDone) }
+func (mock mockLogger) Stop() { close(mock.done) }
+
+func (mock mockLogger) Steps() []buildlog.Step { return nil }
 
 func (mock mockLaunch) Run() error { return nil }
 
@@ -46,24 +69,45 @@ func buildLocalFlags() string {
 
 	return fmt.Sprintf(`
 Flags:
-      --artifacts-dir string   Path to the host side directory which is mounted into $SD_ARTIFACTS_DIR. (default "sd-artifacts")
-  -e, --env stringToString     Set key and value relationship which is set as environment variables of Build Container. (<key>=<value>) (default [])
-      --env-file string        Path to config file of environment variables. '.env' format file can be used.
-  -h, --help                   help for build
-  -i, --interactive            Attach the build container in interactive mode.
-  -m, --memory string          Memory limit for build container, which take a positive integer, followed by a suffix of b, k, m, g.
-      --meta string            Metadata to pass into the build environment, which is represented with JSON format
-      --meta-file string       Path to the meta file. meta file is represented with JSON format.
-      --no-image-pull          Skip container image pulls to save time.
-      --privileged             Use privileged mode for container runtime.
-  -S, --socket string          Path to the socket. It will used in build container.%s
-      --src-url string         Specify the source url to build.
-                               ex) git@github.com:<org>/<repo>.git[#<branch>]
-                                   https://github.com/<org>/<repo>.git[#<branch>]
-      --sudo                   Use sudo command for container runtime.
-  -u, --user string            Change default build user. Default value is from container in use.
-      --utils-dir string       Path to the host side directory that is created to mount utility files for interactive mode. (default ".sd-utils")
-      --vol strings            Volumes to mount into build container.
+      --api-proxy string           URL of the proxy to the Screwdriver.cd API. Default value is from config, otherwise HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used.
+      --api-retries string         Number of retries of a request to the Screwdriver.cd API on network errors and 429, 502, 503 or 504. Default value is from config, otherwise 3.
+      --api-retry-backoff string   Wait before the first retry, which is doubled for each retry, e.g. 2s. Default value is from config, otherwise 1s.
+      --api-timeout string         Timeout of a request to the Screwdriver.cd API, e.g. 30s. Default value is from config, otherwise 60s.
+      --artifacts-dir string       Path to the host side directory which is mounted into $SD_ARTIFACTS_DIR. (default "sd-artifacts")
+      --ca-cert string             Path to the PEM bundle of the CAs trusted in addition to the system ones. Default value is from config.
+      --client-cert string         Path to the PEM client certificate sent to the Screwdriver.cd API. Default value is from config.
+      --client-key string          Path to the PEM key of the client certificate. Default value is from config.
+  -e, --env stringToString         Set key and value relationship which is set as environment variables of Build Container. (<key>=<value>) (default [])
+      --env-file string            Path to config file of environment variables. '.env' format file can be used.
+      --from-step string           Run the steps from the step. Shell-style globs are allowed.
+  -h, --help                       help for build
+  -i, --interactive                Attach the build container in interactive mode.
+      --log-format string          Format of the build log. (text, json or timestamped) (default "text")
+      --log-output string          Path to the file which the formatted build log is written to in addition to stdout.
+      --log-time string            Time prefixed to the build log in timestamped format. (wall or elapsed) (default "wall")
+  -m, --memory string              Memory limit for build container, which take a positive integer, followed by a suffix of b, k, m, g.
+      --meta string                Metadata to pass into the build environment, which is represented with JSON format
+      --meta-file string           Path to the meta file. meta file is represented with JSON format.
+      --no-image-pull              Skip container image pulls to save time.
+      --offline                    Parse screwdriver.yaml locally without the Screwdriver.cd API. Templates are not supported.
+      --privileged                 Use privileged mode for container runtime.
+      --redact stringArray         Regular expression to mask in the build output and logs. Can be specified multiple times.
+      --runtime string             Container runtime to run the build with. (docker, podman or docker-api) Default value is from config, otherwise docker.
+      --show-effective-options     Print the options merged with .sdlocal.yaml and where each value comes from, without running the build.
+      --skip-step stringArray      Skip the step. Shell-style globs are allowed. Can be specified multiple times.
+  -S, --socket string              Path to the socket. It will used in build container.%s
+      --src-url string             Specify the source url to build.
+                                   ex) git@github.com:<org>/<repo>.git[#<branch>]
+                                       https://github.com/<org>/<repo>.git[#<branch>]
+      --step stringArray           Run only the step. Shell-style globs are allowed. Can be specified multiple times.
+      --sudo                       Use sudo command for container runtime.
+      --to-step string             Run the steps until the step. Shell-style globs are allowed.
+      --trust-project              Apply the security-sensitive flags in .sdlocal.yaml, and its config in the source pulled by --src-url, which are ignored by default.
+  -u, --user string                Change default build user. Default value is from container in use.
+      --utils-dir string           Path to the host side directory that is created to mount utility files for interactive mode. (default ".sd-utils")
+      --vol strings                Volumes to mount into build container.
+      --watch                      Run the build again whenever the source files change. The files in .gitignore are not watched.
+      --watch-ignore stringArray   Pattern of the files not to watch in .gitignore format. Can be specified multiple times.
 
 `, defaultSocketPath)
 }
@@ -85,19 +129,37 @@ func setup() {
 			Current: "default",
 		}, nil
 	}
-	apiNew = func(url, token, ua string) screwdriver.API { return mockAPI{} }
-	buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}) (logger buildlog.Logger, err error) {
-		return mockLogger{}, nil
+	apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+		return mockAPI{}, nil
+	}
+	buildLogNew = func(filepath string, writer io.Writer, done chan<- struct{}, options buildlog.Options) (logger buildlog.Logger, err error) {
+		return mockLogger{done: done}, nil
 	}
 	launchNew = func(option launch.Option) launch.Launcher {
 		return mockLaunch{}
 	}
 	osMkdirAll = func(path string, filemode os.FileMode) error { return nil }
+	historyNew = func(baseDir string) *history.Store { return nil }
+	tokenNew = func(baseDir string) *token.Store { return token.New(testTokenDir) }
+	tokenServerNew = func(jwt func() (string, error)) (tokenServer, error) { return mockTokenServer{}, nil }
+	projectFind = func(dir string) (*project.File, error) { return nil, nil }
+	readPassphrase = func(prompt string) (string, error) { return "", errors.New("no terminal in tests") }
 }
 
+// testTokenDir is the JWT cache of the tests not to use the one of the user
+var testTokenDir string
+
 func TestMain(m *testing.M) {
+	var err error
+	testTokenDir, err = os.MkdirTemp("", "sd-local-tokens-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	setup()
 	ret := m.Run()
+	os.RemoveAll(testTokenDir)
 	os.Exit(ret)
 }
 
@@ -0,0 +1,32 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newSecretLsCmd() *cobra.Command {
+	secretLsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List the secrets of sd-local.",
+		Long:  `List the names of the secrets of the current config. The values are not shown.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			store, err := newStore(cmd)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range store.Names() {
+				fmt.Fprintln(cmd.OutOrStdout(), name)
+			}
+
+			return nil
+		},
+	}
+
+	return secretLsCmd
+}
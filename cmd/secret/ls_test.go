@@ -0,0 +1,53 @@
+package secret
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretLsCmd(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		wantOut  string
+		checkErr bool
+	}{
+		{
+			name:     "success",
+			args:     []string{"ls"},
+			wantOut:  "GITHUB_TOKEN\nGIT_KEY\n",
+			checkErr: false,
+		},
+		{
+			name:     "failure by too many args",
+			args:     []string{"ls", "GIT_KEY"},
+			checkErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupDir(t)
+			store, err := secret.New(dir, "default", testPassphrase)
+			assert.Nil(t, err)
+			assert.Nil(t, store.Set("GIT_KEY", "key"))
+			assert.Nil(t, store.Set("GITHUB_TOKEN", "token"))
+			assert.Nil(t, store.Save())
+
+			cmd := NewSecretCmd()
+			cmd.SetArgs(tt.args)
+			buf := bytes.NewBuffer(nil)
+			cmd.SetOut(buf)
+			err = cmd.Execute()
+			if tt.checkErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantOut, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package secret
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newSecretRmCmd() *cobra.Command {
+	secretRmCmd := &cobra.Command{
+		Use:   "rm [name]",
+		Short: "Remove a secret of sd-local.",
+		Long:  `Remove a secret of the current config.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			store, err := newStore(cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := store.Remove(args[0]); err != nil {
+				return err
+			}
+
+			return store.Save()
+		},
+	}
+
+	return secretRmCmd
+}
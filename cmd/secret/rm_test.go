@@ -0,0 +1,59 @@
+package secret
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretRmCmd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		want      []string
+		wantError string
+	}{
+		{
+			name: "success",
+			args: []string{"rm", "GIT_KEY"},
+			want: []string{"GITHUB_TOKEN"},
+		},
+		{
+			name:      "failure by secret that does not exist",
+			args:      []string{"rm", "NPM_TOKEN"},
+			wantError: "secret `NPM_TOKEN` does not exist",
+		},
+		{
+			name:      "failure by too little args",
+			args:      []string{"rm"},
+			wantError: "accepts 1 arg(s), received 0",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupDir(t)
+			store, err := secret.New(dir, "default", testPassphrase)
+			assert.Nil(t, err)
+			assert.Nil(t, store.Set("GIT_KEY", "key"))
+			assert.Nil(t, store.Set("GITHUB_TOKEN", "token"))
+			assert.Nil(t, store.Save())
+
+			cmd := NewSecretCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(bytes.NewBuffer(nil))
+			err = cmd.Execute()
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+
+			store, err = secret.New(dir, "default", testPassphrase)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, store.Names())
+		})
+	}
+}
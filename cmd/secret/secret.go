@@ -0,0 +1,100 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/spf13/cobra"
+)
+
+const (
+	configFileName = "config"
+	secretsDirName = "secrets"
+)
+
+var (
+	baseDir   = config.BaseDir
+	configNew = config.New
+)
+
+// passphrase returns the passphrase of the secrets of the config, which is SDLOCAL_PASSPHRASE or the one entered in the terminal.
+// It is entered twice when the secrets are created.
+func passphrase(cmd *cobra.Command, configName string) secret.Passphrase {
+	return func(confirm bool) (string, error) {
+		if p := os.Getenv(config.PassphraseEnv); p != "" {
+			return p, nil
+		}
+
+		f, ok := terminal(cmd)
+		if !ok {
+			return "", fmt.Errorf("%s must be set to encrypt the secrets without a terminal", config.PassphraseEnv)
+		}
+
+		p, err := readHidden(cmd, f, fmt.Sprintf("Passphrase of the secrets of config `%s`: ", configName))
+		if err != nil {
+			return "", fmt.Errorf("failed to read the passphrase: %v", err)
+		}
+		if p == "" {
+			return "", errors.New("the passphrase is empty")
+		}
+		if !confirm {
+			return p, nil
+		}
+
+		confirmation, err := readHidden(cmd, f, "Confirm passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("failed to read the passphrase: %v", err)
+		}
+		if p != confirmation {
+			return "", errors.New("the passphrases do not match")
+		}
+
+		return p, nil
+	}
+}
+
+// newStore opens the secrets of the current config
+func newStore(cmd *cobra.Command) (*secret.Store, error) {
+	base, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := configNew(filepath.Join(base, configFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.New(filepath.Join(base, secretsDirName), c.Current, passphrase(cmd, c.Current))
+}
+
+// NewSecretCmd return secret command.
+func NewSecretCmd() *cobra.Command {
+	secretCmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Manage secrets passed to sd-local builds.",
+		Long: `Manage secrets passed to sd-local builds.
+The secrets are stored for each config under ~/.sdlocal/secrets,
+encrypted with the key derived from a passphrase, which is read from SDLOCAL_PASSPHRASE or the terminal.
+Only the secrets declared in the secrets of a job are passed to its build.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := cmd.Help()
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	secretCmd.AddCommand(
+		newSecretSetCmd(),
+		newSecretLsCmd(),
+		newSecretRmCmd(),
+	)
+
+	return secretCmd
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// terminal returns the input of the command when it is a terminal
+func terminal(cmd *cobra.Command) (*os.File, bool) {
+	f, ok := cmd.InOrStdin().(*os.File)
+	return f, ok && term.IsTerminal(int(f.Fd()))
+}
+
+// readHidden reads a line from the terminal without echoing it
+func readHidden(cmd *cobra.Command, f *os.File, prompt string) (string, error) {
+	fmt.Fprint(cmd.OutOrStdout(), prompt)
+	value, err := term.ReadPassword(int(f.Fd()))
+	fmt.Fprintln(cmd.OutOrStdout())
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+// readValue reads the secret value from the terminal without echoing it, or from the piped input
+func readValue(cmd *cobra.Command, name string) (string, error) {
+	if f, ok := terminal(cmd); ok {
+		value, err := readHidden(cmd, f, fmt.Sprintf("Value of %s: ", name))
+		if err != nil {
+			return "", fmt.Errorf("failed to read the value: %v", err)
+		}
+		return value, nil
+	}
+
+	value, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return "", fmt.Errorf("failed to read the value: %v", err)
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(value), "\n"), "\r"), nil
+}
+
+func newSecretSetCmd() *cobra.Command {
+	secretSetCmd := &cobra.Command{
+		Use:   "set [name]",
+		Short: "Set a secret of sd-local.",
+		Long: `Set a secret of sd-local.
+The value is read from the terminal without echo, or from the standard input.
+e.g. sd-local secret set GIT_KEY < ~/.ssh/id_rsa`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			return secret.ValidateName(args[0])
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			name := args[0]
+
+			store, err := newStore(cmd)
+			if err != nil {
+				return err
+			}
+
+			value, err := readValue(cmd, name)
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				return errors.New("the value of the secret is empty")
+			}
+
+			if err := store.Set(name, value); err != nil {
+				return err
+			}
+
+			return store.Save()
+		},
+	}
+
+	return secretSetCmd
+}
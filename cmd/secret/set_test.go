@@ -0,0 +1,112 @@
+package secret
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPassphrase is the passphrase of the secrets in the tests
+func testPassphrase(bool) (string, error) {
+	return "passphrase", nil
+}
+
+// setupDir makes the commands use a temporary directory with the "default" config
+
+func setupDir(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv(config.PassphraseEnv, "passphrase")
+
+	bdir, cnew := baseDir, configNew
+	t.Cleanup(func() {
+		baseDir, configNew = bdir, cnew
+	})
+	baseDir = func() (string, error) {
+		return dir, nil
+	}
+	configNew = func(configPath string) (config.Config, error) {
+		return config.Config{Current: "default"}, nil
+	}
+
+	return filepath.Join(dir, secretsDirName)
+}
+
+func TestSecretSetCmd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		in        string
+		want      map[string]string
+		wantError string
+	}{
+		{
+			name: "success",
+			args: []string{"set", "GIT_KEY"},
+			in:   "key\n",
+			want: map[string]string{"GIT_KEY": "key"},
+		},
+		{
+			name: "success with multiple lines",
+			args: []string{"set", "GIT_KEY"},
+			in:   "line1\nline2\n",
+			want: map[string]string{"GIT_KEY": "line1\nline2"},
+		},
+		{
+			name:      "failure by empty value",
+			args:      []string{"set", "GIT_KEY"},
+			in:        "",
+			wantError: "the value of the secret is empty",
+		},
+		{
+			name:      "failure by invalid name",
+			args:      []string{"set", "git-key"},
+			in:        "key",
+			wantError: "invalid secret name `git-key`, it must consist of uppercase letters, digits and underscores and not start with a digit",
+		},
+		{
+			name:      "failure by too many args",
+			args:      []string{"set", "GIT_KEY", "key"},
+			in:        "key",
+			wantError: "accepts 1 arg(s), received 2",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupDir(t)
+
+			cmd := NewSecretCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetIn(strings.NewReader(tt.in))
+			cmd.SetOut(bytes.NewBuffer(nil))
+			err := cmd.Execute()
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+
+			store, err := secret.New(dir, "default", testPassphrase)
+			assert.Nil(t, err)
+			got, err := store.Resolve(store.Names())
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSecretSetCmdWithoutPassphrase(t *testing.T) {
+	setupDir(t)
+	t.Setenv(config.PassphraseEnv, "")
+
+	cmd := NewSecretCmd()
+	cmd.SetArgs([]string{"set", "GIT_KEY"})
+	cmd.SetIn(bytes.NewBufferString("key"))
+	cmd.SetOut(bytes.NewBuffer(nil))
+	assert.EqualError(t, cmd.Execute(), "SDLOCAL_PASSPHRASE must be set to encrypt the secrets without a terminal")
+}
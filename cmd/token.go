@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/secret"
+	"github.com/screwdriver-cd/sd-local/token"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// tokenServer serves the refreshed JWT to the build containers
+type tokenServer interface {
+	Port() int
+	NewKey() (string, error)
+	RevokeKey(key string)
+	Close() error
+}
+
+var (
+	tokenNew       = token.New
+	tokenServerNew = func(jwt func() (string, error)) (tokenServer, error) {
+		return token.NewServer(jwt)
+	}
+	readPassphrase = func(prompt string) (string, error) {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("%s must be set to decrypt without a terminal", config.PassphraseEnv)
+		}
+
+		fmt.Print(prompt)
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the passphrase: %v", err)
+		}
+		return string(passphrase), nil
+	}
+)
+
+// unlockToken decrypts the encrypted token of the config entry with SDLOCAL_PASSPHRASE or the passphrase entered in the terminal
+func unlockToken(entry *config.Entry, configName string) error {
+	if !entry.TokenLocked() {
+		return nil
+	}
+
+	passphrase := os.Getenv(config.PassphraseEnv)
+	if passphrase == "" {
+		var err error
+		passphrase, err = readPassphrase(fmt.Sprintf("Passphrase of the token of config `%s`: ", configName))
+		if err != nil {
+			return err
+		}
+	}
+
+	return entry.DecryptToken(passphrase)
+}
+
+// secretPassphrase returns the passphrase of the secrets of the config, which is SDLOCAL_PASSPHRASE or the one entered in the terminal.
+// The builds never create the secrets, so that the passphrase is not confirmed.
+func secretPassphrase(configName string) secret.Passphrase {
+	return func(bool) (string, error) {
+		if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+			return passphrase, nil
+		}
+
+		return readPassphrase(fmt.Sprintf("Passphrase of the secrets of config `%s`: ", configName))
+	}
+}
+
+// newJWTSource returns the source of the JWT of the config entry, which is cached under the directory.
+// The JWT of the entry whose token is encrypted is cached only in memory, not to leave it in plaintext.
+func newJWTSource(api screwdriver.API, dir, configName string, entry *config.Entry) *token.Source {
+	key := token.Key{Config: configName, APIURL: entry.APIURL, UserToken: entry.Token}
+
+	store := tokenNew(dir)
+	if entry.EncryptedToken != "" {
+		// The JWT cached before the token was encrypted is removed
+		if err := store.Delete(configName); err != nil {
+			logrus.Warn(err)
+		}
+		store = nil
+	}
+
+	return token.NewSource(store, key, func() (string, error) {
+		if err := api.InitJWT(); err != nil {
+			return "", err
+		}
+		// The refreshed JWT is masked as well as the first one
+		redactor.Add(api.JWT())
+		return api.JWT(), nil
+	})
+}
+
+// initJWT sets the JWT of the source to the API. The API gets the JWT by itself without the source.
+func initJWT(api screwdriver.API, source *token.Source) error {
+	if source == nil {
+		return api.InitJWT()
+	}
+
+	jwt, err := source.JWT()
+	if err != nil {
+		return err
+	}
+	api.SetJWT(jwt)
+
+	return nil
+}
+
+// jwt returns the JWT passed to a build, which is refreshed when it expires soon
+func (s *buildSetup) jwt() (string, error) {
+	if s.jwtSource == nil {
+		return s.api.JWT(), nil
+	}
+
+	return s.jwtSource.JWT()
+}
+
+// startTokenServer starts the server which the build containers get the refreshed JWT from.
+// Builds run without it when it can't be started.
+func (s *buildSetup) startTokenServer() {
+	if s.jwtSource == nil {
+		return
+	}
+
+	server, err := tokenServerNew(s.jwtSource.JWT)
+	if err != nil {
+		logrus.Warnf("%v, the JWT is not refreshed during builds", err)
+		return
+	}
+
+	s.tokenServer = server
+}
+
+// stopTokenServer stops the server started by startTokenServer
+func (s *buildSetup) stopTokenServer() {
+	if s.tokenServer == nil {
+		return
+	}
+
+	if err := s.tokenServer.Close(); err != nil {
+		logrus.Warnf("failed to stop token server: %v", err)
+	}
+}
+
+// newTokenKey returns the key which a build presents to the token server
+func (s *buildSetup) newTokenKey() (string, error) {
+	if s.tokenServer == nil {
+		return "", nil
+	}
+
+	key, err := s.tokenServer.NewKey()
+	if err != nil {
+		return "", err
+	}
+	redactor.Add(key)
+
+	return key, nil
+}
+
+// revokeTokenKey rejects the requests with the key of the finished build
+func (s *buildSetup) revokeTokenKey(key string) {
+	if s.tokenServer == nil || key == "" {
+		return
+	}
+
+	s.tokenServer.RevokeKey(key)
+}
+
+// tokenURL returns the endpoint of the token server seen from the build containers
+func (s *buildSetup) tokenURL() string {
+	if s.tokenServer == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("http://%s:%d%s", launch.TokenHost, s.tokenServer.Port(), token.Path)
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/config"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWT returns the unsigned JWT which expires in an hour
+func testJWT(n int) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d,"n":%d}`, time.Now().Add(time.Hour).Unix(), n)))
+	return "header." + payload + ".signature"
+}
+
+// mockRefreshAPI returns the JWT which expires in an hour, counting the requests
+type mockRefreshAPI struct {
+	mockAPI
+	jwt     string
+	initErr error
+	inits   *int
+}
+
+func (mock *mockRefreshAPI) InitJWT() error {
+	*mock.inits++
+	if mock.initErr != nil {
+		return mock.initErr
+	}
+	mock.jwt = testJWT(*mock.inits)
+	return nil
+}
+
+func (mock *mockRefreshAPI) JWT() string { return mock.jwt }
+
+func (mock *mockRefreshAPI) SetJWT(jwt string) { mock.jwt = jwt }
+
+func TestInitJWT(t *testing.T) {
+	defer func() {
+		tokenNew = func(baseDir string) *token.Store { return token.New(testTokenDir) }
+	}()
+	tokenNew = token.New
+
+	entry := &config.Entry{APIURL: "https://api.screwdriver.cd", Token: "user-token"}
+
+	t.Run("success with the cached JWT", func(t *testing.T) {
+		dir := t.TempDir()
+		inits := 0
+
+		api := &mockRefreshAPI{inits: &inits}
+		assert.Nil(t, initJWT(api, newJWTSource(api, dir, "default", entry)))
+		first := api.JWT()
+		assert.NotEmpty(t, first)
+
+		// Another run uses the cached JWT without the request
+		api = &mockRefreshAPI{inits: &inits}
+		assert.Nil(t, initJWT(api, newJWTSource(api, dir, "default", entry)))
+		assert.Equal(t, first, api.JWT())
+		assert.Equal(t, 1, inits)
+	})
+
+	t.Run("success without the cache file of the encrypted token", func(t *testing.T) {
+		dir := t.TempDir()
+		inits := 0
+
+		// The JWT cached before the token was encrypted is removed as well
+		api := &mockRefreshAPI{inits: &inits}
+		assert.Nil(t, initJWT(api, newJWTSource(api, dir, "default", entry)))
+		assert.FileExists(t, filepath.Join(dir, "default.json"))
+
+		encrypted := &config.Entry{APIURL: entry.APIURL, Token: entry.Token, EncryptedToken: "scrypt-aes256gcm:encrypted"}
+		source := newJWTSource(api, dir, "default", encrypted)
+		assert.NoFileExists(t, filepath.Join(dir, "default.json"))
+
+		assert.Nil(t, initJWT(api, source))
+		assert.Equal(t, 2, inits)
+		assert.NoFileExists(t, filepath.Join(dir, "default.json"))
+
+		// The JWT is cached in memory
+		jwt, err := source.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, api.JWT(), jwt)
+		assert.Equal(t, 2, inits)
+	})
+
+	t.Run("success without source", func(t *testing.T) {
+		inits := 0
+		api := &mockRefreshAPI{inits: &inits}
+		assert.Nil(t, initJWT(api, nil))
+		assert.NotEmpty(t, api.JWT())
+		assert.Equal(t, 1, inits)
+	})
+
+	t.Run("failure by API", func(t *testing.T) {
+		inits := 0
+		api := &mockRefreshAPI{inits: &inits, initErr: errors.New("failed to get JWT: StatusCode 401")}
+		assert.EqualError(t, initJWT(api, newJWTSource(api, t.TempDir(), "default", entry)), "failed to get JWT: StatusCode 401")
+	})
+}
+
+// keyTokenServer records the keys of the builds
+type keyTokenServer struct {
+	mockTokenServer
+	keys map[string]bool
+}
+
+func (s *keyTokenServer) NewKey() (string, error) {
+	key := fmt.Sprintf("key%d", len(s.keys)+1)
+	s.keys[key] = true
+	return key, nil
+}
+
+func (s *keyTokenServer) RevokeKey(key string) { delete(s.keys, key) }
+
+func TestTokenServer(t *testing.T) {
+	defer func() {
+		tokenServerNew = func(jwt func() (string, error)) (tokenServer, error) { return mockTokenServer{}, nil }
+	}()
+
+	jwt := testJWT(0)
+	source := token.NewSource(token.New(t.TempDir()), token.Key{Config: "default"}, func() (string, error) { return jwt, nil })
+
+	t.Run("success", func(t *testing.T) {
+		s := &buildSetup{api: mockAPI{}, jwtSource: source}
+		s.startTokenServer()
+		defer s.stopTokenServer()
+
+		assert.Equal(t, "http://sd-local.host:8765/token", s.tokenURL())
+
+		got, err := s.jwt()
+		assert.Nil(t, err)
+		assert.Equal(t, jwt, got)
+	})
+
+	t.Run("success with the key of each build", func(t *testing.T) {
+		server := &keyTokenServer{keys: map[string]bool{}}
+		tokenServerNew = func(jwt func() (string, error)) (tokenServer, error) { return server, nil }
+
+		s := &buildSetup{api: mockAPI{}, jwtSource: source}
+		s.startTokenServer()
+		defer s.stopTokenServer()
+
+		key1, err := s.newTokenKey()
+		assert.Nil(t, err)
+		key2, err := s.newTokenKey()
+		assert.Nil(t, err)
+		assert.NotEqual(t, key1, key2)
+		assert.Equal(t, map[string]bool{key1: true, key2: true}, server.keys)
+
+		s.revokeTokenKey(key1)
+		assert.Equal(t, map[string]bool{key2: true}, server.keys)
+	})
+
+	t.Run("success without token server in offline mode", func(t *testing.T) {
+		s := &buildSetup{api: screwdriver.NewOffline()}
+		s.startTokenServer()
+		defer s.stopTokenServer()
+
+		assert.Equal(t, "", s.tokenURL())
+
+		got, err := s.jwt()
+		assert.Nil(t, err)
+		assert.Equal(t, "", got)
+	})
+
+	t.Run("success with the token server which can't be started", func(t *testing.T) {
+		tokenServerNew = func(jwt func() (string, error)) (tokenServer, error) {
+			return nil, errors.New("failed to start token server: address already in use")
+		}
+
+		s := &buildSetup{api: mockAPI{}, jwtSource: source}
+		s.startTokenServer()
+		defer s.stopTokenServer()
+
+		assert.Nil(t, s.tokenServer)
+		assert.Equal(t, "", s.tokenURL())
+	})
+}
+
+func TestUnlockToken(t *testing.T) {
+	defer func() {
+		readPassphrase = func(prompt string) (string, error) { return "", errors.New("no terminal in tests") }
+	}()
+
+	newLockedEntry := func() *config.Entry {
+		entry := config.DefaultEntry()
+		if err := entry.EncryptToken("user-token", "passphrase"); err != nil {
+			t.Fatal(err)
+		}
+		entry.Token = ""
+		return entry
+	}
+
+	t.Run("success with SDLOCAL_PASSPHRASE", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "passphrase")
+		readPassphrase = func(prompt string) (string, error) {
+			t.Fatal("the passphrase must not be read from the terminal")
+			return "", nil
+		}
+
+		entry := newLockedEntry()
+		assert.Nil(t, unlockToken(entry, "default"))
+		assert.Equal(t, "user-token", entry.Token)
+	})
+
+	t.Run("success with the passphrase from the terminal", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "")
+		readPassphrase = func(prompt string) (string, error) {
+			assert.Equal(t, "Passphrase of the token of config `default`: ", prompt)
+			return "passphrase", nil
+		}
+
+		entry := newLockedEntry()
+		assert.Nil(t, unlockToken(entry, "default"))
+		assert.Equal(t, "user-token", entry.Token)
+	})
+
+	t.Run("success without the encrypted token", func(t *testing.T) {
+		entry := &config.Entry{Token: "user-token"}
+		assert.Nil(t, unlockToken(entry, "default"))
+		assert.Equal(t, "user-token", entry.Token)
+	})
+
+	t.Run("failure by wrong passphrase", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "wrong")
+
+		assert.EqualError(t, unlockToken(newLockedEntry(), "default"), "failed to decrypt token: the passphrase is wrong or the token is broken")
+	})
+
+	t.Run("failure without terminal", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "")
+		readPassphrase = func(prompt string) (string, error) {
+			return "", errors.New("SDLOCAL_PASSPHRASE must be set to decrypt without a terminal")
+		}
+
+		assert.EqualError(t, unlockToken(newLockedEntry(), "default"), "SDLOCAL_PASSPHRASE must be set to decrypt without a terminal")
+	})
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/watch"
+	"github.com/sirupsen/logrus"
+)
+
+// changeWatcher notifies the changed paths of the source directory
+type changeWatcher interface {
+	Events() <-chan string
+	Close() error
+}
+
+var (
+	watchNew = func(root string, ignore []string) (changeWatcher, error) {
+		return watch.New(root, ignore)
+	}
+	// watchDebounce is how long the changes are gathered before the build is restarted
+	watchDebounce = 300 * time.Millisecond
+)
+
+type buildResult struct {
+	steps []buildlog.StepResult
+	err   error
+}
+
+// watchIgnores returns the ignore patterns of the directories written by the build under the source directory,
+// since a change there would restart the build endlessly
+func watchIgnores(srcPath string, dirs ...string) []string {
+	ignores := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(srcPath, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		ignores = append(ignores, "/"+filepath.ToSlash(rel)+"/")
+	}
+	return ignores
+}
+
+// waitChanges waits for a change and returns when no more changes come within watchDebounce.
+// It returns false when the watcher is closed.
+func waitChanges(events <-chan string) bool {
+	path, ok := <-events
+	if !ok {
+		return false
+	}
+
+	timer := time.NewTimer(watchDebounce)
+	defer timer.Stop()
+	for {
+		select {
+		case p, ok := <-events:
+			if !ok {
+				return false
+			}
+			path = p
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			logrus.Infof("%s is changed", path)
+			return true
+		}
+	}
+}
+
+// watchBuild runs the build, and runs it again whenever the source files change until the watcher is closed.
+// A running build is killed on a change. The same launcher is used so that its volumes and images are reused.
+func watchBuild(s *buildSetup, w changeWatcher, option launch.Option, writer, summary io.Writer, logOptions buildlog.Options) error {
+	defer s.revokeTokenKey(option.TokenKey)
+	forget := recordResources(launch.Resources(option)...)
+	launcher := launchNew(option)
+	if l, ok := launcher.(Cleaner); ok {
+		remove := cleaners.add(l)
+		defer func() {
+			l.Clean()
+			remove()
+			forget()
+		}()
+	}
+
+	for {
+		done := make(chan buildResult, 1)
+		go func() {
+			steps, err := runLauncher(s, launcher, option, writer, logOptions)
+			done <- buildResult{steps: steps, err: err}
+		}()
+
+		changed := make(chan bool, 1)
+		go func() {
+			changed <- waitChanges(w.Events())
+		}()
+
+		select {
+		case result := <-done:
+			if len(result.steps) > 0 {
+				fmt.Fprintln(summary)
+				buildlog.PrintSteps(summary, result.steps)
+			}
+			if result.err != nil {
+				logrus.Error(result.err)
+			}
+			logrus.Info("Waiting for changes of the source files...")
+
+			if !<-changed {
+				return nil
+			}
+		case ok := <-changed:
+			logrus.Info("Stopping the running build...")
+			launcher.Kill(os.Interrupt)
+			<-done
+
+			if !ok {
+				return nil
+			}
+		}
+
+		logrus.Info("Restarting the build...")
+	}
+}
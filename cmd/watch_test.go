@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/screwdriver-cd/sd-local/buildlog"
+	"github.com/screwdriver-cd/sd-local/launch"
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+)
+
+type mockWatcher struct {
+	events chan string
+}
+
+func (m *mockWatcher) Events() <-chan string { return m.events }
+
+func (m *mockWatcher) Close() error { return nil }
+
+// mockWatchLaunch blocks the first run until it is killed
+type mockWatchLaunch struct {
+	mutex   sync.Mutex
+	runs    int
+	kills   int
+	cleans  int
+	started chan struct{}
+	killed  chan struct{}
+}
+
+func (m *mockWatchLaunch) Run() error {
+	m.mutex.Lock()
+	m.runs++
+	runs := m.runs
+	m.mutex.Unlock()
+
+	if runs == 1 {
+		close(m.started)
+		<-m.killed
+		return &launch.StepError{Code: 130, Err: os.ErrProcessDone}
+	}
+	return nil
+}
+
+func (m *mockWatchLaunch) Kill(os.Signal) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.kills++
+	close(m.killed)
+}
+
+func (m *mockWatchLaunch) Clean() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.cleans++
+}
+
+func TestWatchIgnores(t *testing.T) {
+	got := watchIgnores("/src", "/src/sd-artifacts", "/src/.sd-utils", "/tmp/artifacts", "/src")
+	assert.Equal(t, []string{"/sd-artifacts/", "/.sd-utils/"}, got)
+}
+
+func TestWaitChanges(t *testing.T) {
+	defDebounce := watchDebounce
+	watchDebounce = 10 * time.Millisecond
+	defer func() {
+		watchDebounce = defDebounce
+	}()
+
+	events := make(chan string, 10)
+	events <- "a.js"
+	events <- "b.js"
+	assert.True(t, waitChanges(events))
+	assert.Len(t, events, 0)
+
+	close(events)
+	assert.False(t, waitChanges(events))
+}
+
+func TestWatchBuild(t *testing.T) {
+	defDebounce := watchDebounce
+	defLaunchNew := launchNew
+	watchDebounce = 10 * time.Millisecond
+	defer func() {
+		watchDebounce = defDebounce
+		launchNew = defLaunchNew
+	}()
+
+	l := &mockWatchLaunch{started: make(chan struct{}), killed: make(chan struct{})}
+	launchNew = func(option launch.Option) launch.Launcher {
+		return l
+	}
+
+	w := &mockWatcher{events: make(chan string)}
+	finished := make(chan error)
+	go func() {
+		finished <- watchBuild(&buildSetup{}, w, launch.Option{ArtifactsPath: t.TempDir()}, bytes.NewBuffer(nil), bytes.NewBuffer(nil), buildlog.Options{})
+	}()
+
+	// The running build is killed and run again on a change
+	<-l.started
+	w.events <- "index.js"
+	assert.Eventually(t, func() bool {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		return l.runs == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	close(w.events)
+
+	select {
+	case err := <-finished:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch is not finished")
+	}
+
+	assert.Equal(t, 2, l.runs)
+	assert.Equal(t, 1, l.kills)
+	assert.Equal(t, 1, l.cleans)
+}
+
+func TestBuildCmdWithWatch(t *testing.T) {
+	t.Run("Failed build cmd with --watch and --interactive", func(t *testing.T) {
+		defer func() {
+			interactiveMode = false
+		}()
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--watch", "-i"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.EqualError(t, err, "can't pass the both options `watch` and `interactive`")
+	})
+
+	t.Run("Failed build cmd with --watch and dind", func(t *testing.T) {
+		defAPINew := apiNew
+		defer func() {
+			apiNew = defAPINew
+		}()
+
+		apiNew = func(url, token, ua string, options screwdriver.HTTPOptions) (screwdriver.API, error) {
+			return mockJobAPI{job: screwdriver.Job{Annotations: map[string]interface{}{"screwdriver.cd/dockerEnabled": true}}}, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--watch"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.EqualError(t, err, "watch mode does not support the job with screwdriver.cd/dockerEnabled")
+	})
+
+	t.Run("Success build cmd with --watch", func(t *testing.T) {
+		defWatchNew := watchNew
+		defer func() {
+			watchNew = defWatchNew
+		}()
+
+		var ignore []string
+		watchNew = func(root string, patterns []string) (changeWatcher, error) {
+			ignore = patterns
+			w := &mockWatcher{events: make(chan string)}
+			close(w.events)
+			return w, nil
+		}
+
+		root := newBuildCmd()
+		root.SetArgs([]string{"test", "--watch", "--watch-ignore", "*.tmp"})
+		root.SetOut(bytes.NewBuffer(nil))
+
+		err := root.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"/sd-artifacts/", "/.sd-utils/", "*.tmp"}, ignore)
+	})
+}
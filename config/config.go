@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/go-yaml/yaml"
+	"github.com/mitchellh/go-homedir"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -22,13 +25,70 @@ type Entry struct {
 	Token    string   `yaml:"token" mapstructure:"token"`
 	UUID     string   `yaml:"UUID" mapstructure:"uuid"`
 	Launcher Launcher `yaml:"launcher" mapstructure:",squash"`
+	Runtime  string   `yaml:"runtime,omitempty" mapstructure:"runtime"`
+	// The settings of the requests to the API, which are the strings as the flags of the commands
+	APITimeout      string `yaml:"api-timeout,omitempty" mapstructure:"api-timeout"`
+	APIRetries      string `yaml:"api-retries,omitempty" mapstructure:"api-retries"`
+	APIRetryBackoff string `yaml:"api-retry-backoff,omitempty" mapstructure:"api-retry-backoff"`
+	APIProxy        string `yaml:"api-proxy,omitempty" mapstructure:"api-proxy"`
+	CACert          string `yaml:"ca-cert,omitempty" mapstructure:"ca-cert"`
+	ClientCert      string `yaml:"client-cert,omitempty" mapstructure:"client-cert"`
+	ClientKey       string `yaml:"client-key,omitempty" mapstructure:"client-key"`
+	// EncryptedToken is the token encrypted with a passphrase, which is saved instead of Token
+	EncryptedToken string `yaml:"encrypted-token,omitempty" mapstructure:"-"`
 }
 
 // Config is a set of sd-local config entities
 type Config struct {
+	Version  int               `yaml:"version"`
 	Entries  map[string]*Entry `yaml:"configs"`
 	Current  string            `yaml:"current"`
 	filePath string            `yaml:"-"`
+	// The version of the file which is migrated in memory, which is backed up when the config is saved
+	migrated    bool
+	fileVersion int
+	// The values overridden by the environment variables, which are not saved
+	currentOverride *override
+	overriddenEntry string
+	entryOverrides  map[string]*override
+}
+
+const (
+	// HomeEnv is the environment variable which overrides the base directory
+	HomeEnv = "SDLOCAL_HOME"
+	// ConfigEnv is the environment variable which overrides the current config
+	ConfigEnv = "SDLOCAL_CONFIG"
+)
+
+// EntryEnvs are the environment variables which override the keys of the current config
+var EntryEnvs = map[string]string{
+	"api-url":          "SDLOCAL_API_URL",
+	"store-url":        "SDLOCAL_STORE_URL",
+	"token":            "SDLOCAL_TOKEN",
+	"launcher-image":   "SDLOCAL_LAUNCHER_IMAGE",
+	"launcher-version": "SDLOCAL_LAUNCHER_VERSION",
+}
+
+// override is a value overridden by an environment variable, with the value in the config file
+type override struct {
+	env   string
+	value string
+	saved string
+}
+
+// BaseDir returns the directory which sd-local stores the config and the data in.
+// It is ~/.sdlocal unless SDLOCAL_HOME is set.
+func BaseDir() (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".sdlocal"), nil
 }
 
 // DefaultEntry describes the initial value of an entry
@@ -53,17 +113,21 @@ func create(configPath string) error {
 	}
 
 	dir := filepath.Dir(configPath)
-	err = os.MkdirAll(dir, 0777)
+	err = os.MkdirAll(dir, 0700)
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(configPath)
+	if err := restrictDir(dir); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(configPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
 	err = yaml.NewEncoder(file).Encode(Config{
+		Version: CurrentVersion,
 		Entries: map[string]*Entry{
 			"default": DefaultEntry(),
 		},
@@ -76,7 +140,9 @@ func create(configPath string) error {
 	return nil
 }
 
-// New returns parsed config
+// New returns parsed config.
+// The config of the older version is migrated to the current version in memory.
+// The file is rewritten only when the config is saved, and the old one is backed up then.
 func New(configPath string) (Config, error) {
 	err := create(configPath)
 	if err != nil {
@@ -102,9 +168,111 @@ func New(configPath string) (Config, error) {
 		c.Entries = make(map[string]*Entry)
 	}
 
+	fileVersion := c.Version
+	if err := c.upgrade(); err != nil {
+		return Config{}, err
+	}
+	if c.Version != fileVersion {
+		c.migrated, c.fileVersion = true, fileVersion
+	}
+
+	if name := os.Getenv(ConfigEnv); name != "" {
+		c.currentOverride = &override{env: ConfigEnv, value: name, saved: c.Current}
+		c.Current = name
+	}
+	if err := c.overrideEntry(c.Current); err != nil {
+		return Config{}, err
+	}
+
 	return c, nil
 }
 
+// overrideEntry sets the values of the environment variables to the entry, restoring the entry overridden before
+func (c *Config) overrideEntry(name string) error {
+	if entry, ok := c.Entries[c.overriddenEntry]; ok {
+		for key, o := range c.entryOverrides {
+			if err := entry.restore(key, o); err != nil {
+				return err
+			}
+		}
+	}
+	c.overriddenEntry = ""
+	c.entryOverrides = nil
+
+	entry, ok := c.Entries[name]
+	if !ok {
+		return nil
+	}
+
+	for key, env := range EntryEnvs {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+
+		saved, err := entry.get(key)
+		if err != nil {
+			return err
+		}
+		if err := entry.set(key, value); err != nil {
+			return err
+		}
+
+		if c.entryOverrides == nil {
+			c.entryOverrides = make(map[string]*override)
+		}
+		c.entryOverrides[key] = &override{env: env, value: value, saved: saved}
+		c.overriddenEntry = name
+	}
+
+	return nil
+}
+
+// Select makes the entry current without saving it, unless SDLOCAL_CONFIG is set.
+// The environment variables override the keys of the selected entry.
+func (c *Config) Select(name string) error {
+	if c.CurrentOverride() != "" {
+		return nil
+	}
+
+	if _, err := c.Entry(name); err != nil {
+		return err
+	}
+
+	if name != c.Current {
+		if c.currentOverride == nil {
+			c.currentOverride = &override{saved: c.Current}
+		}
+		c.currentOverride.value = name
+		c.Current = name
+	}
+
+	return c.overrideEntry(name)
+}
+
+// CurrentOverride returns the environment variable which overrides the current config, or empty if it is not overridden
+func (c *Config) CurrentOverride() string {
+	if c.currentOverride == nil {
+		return ""
+	}
+
+	return c.currentOverride.env
+}
+
+// Overrides returns the environment variables which override the keys of the entry
+func (c *Config) Overrides(name string) map[string]string {
+	overrides := make(map[string]string)
+	if name != c.overriddenEntry {
+		return overrides
+	}
+
+	for key, o := range c.entryOverrides {
+		overrides[key] = o.env
+	}
+
+	return overrides
+}
+
 // AddEntry create new Entry and add it to Config
 func (c *Config) AddEntry(name string, entry *Entry) error {
 	_, exist := c.Entries[name]
@@ -126,19 +294,76 @@ func (c *Config) Entry(name string) (*Entry, error) {
 	return entry, nil
 }
 
-// DeleteEntry deletes Entry object named `name`
+// DeleteEntry deletes Entry object named `name`, and its secrets and JWT cache
 func (c *Config) DeleteEntry(name string) error {
-	if name == c.Current {
+	if name == c.Current || (c.currentOverride != nil && name == c.currentOverride.saved) {
 		return fmt.Errorf("config `%s` is current config", name)
 	}
 	_, exist := c.Entries[name]
 	if !exist {
 		return fmt.Errorf("config `%s` does not exist", name)
 	}
+	if err := c.removeEntryFiles(name); err != nil {
+		return err
+	}
+	delete(c.Entries, name)
+	return nil
+}
+
+// RenameEntry renames Entry object named `name` to `newName`, and the current config as well.
+// The secrets and the JWT cache of the entry are moved to the new name.
+func (c *Config) RenameEntry(name, newName string) error {
+	entry, err := c.Entry(name)
+	if err != nil {
+		return err
+	}
+	if _, exist := c.Entries[newName]; exist {
+		return fmt.Errorf("config `%s` already exists", newName)
+	}
+	if err := c.moveEntryFiles(name, newName); err != nil {
+		return err
+	}
+
 	delete(c.Entries, name)
+	c.Entries[newName] = entry
+
+	if c.Current == name {
+		c.Current = newName
+	}
+	if c.overriddenEntry == name {
+		c.overriddenEntry = newName
+	}
+	if o := c.currentOverride; o != nil {
+		if o.value == name {
+			o.value = newName
+		}
+		if o.saved == name {
+			o.saved = newName
+		}
+	}
+
 	return nil
 }
 
+// CopyEntry adds the copy of Entry object named `src` as `dst`, copying its secrets and JWT cache.
+// The copy has the values in the file, not the ones overridden by the environment variables.
+func (c *Config) CopyEntry(src, dst string) (*Entry, error) {
+	entry, err := c.savedEntry(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.AddEntry(dst, entry); err != nil {
+		return nil, err
+	}
+	if err := c.copyEntryFiles(src, dst); err != nil {
+		delete(c.Entries, dst)
+		return nil, err
+	}
+
+	return entry, nil
+}
+
 // SetCurrent set a specified entry as current config
 func (c *Config) SetCurrent(name string) error {
 	_, err := c.Entry(name)
@@ -147,19 +372,59 @@ func (c *Config) SetCurrent(name string) error {
 	}
 
 	c.Current = name
+	// The current config set explicitly is saved even if SDLOCAL_CONFIG is the same
+	c.currentOverride = nil
+
+	return nil
+}
+
+// restrictDir makes the base directory private, since it has the tokens and the secrets.
+// The other directories are not changed.
+func restrictDir(dir string) error {
+	base, err := BaseDir()
+	if err != nil || filepath.Clean(dir) != base {
+		return nil
+	}
+
+	if err := os.Chmod(dir, 0700); err != nil {
+		return fmt.Errorf("failed to change permissions of %s: %v", dir, err)
+	}
 
 	return nil
 }
 
-// Save write Config to config file
+// Save write Config to config file.
+// The values overridden by the environment variables are saved as they were in the file, unless they are changed after loading.
+// The decrypted tokens are not saved. The file can be read only by the owner.
 func (c *Config) Save() error {
-	file, err := os.OpenFile(c.filePath, os.O_RDWR|os.O_TRUNC, 0666)
+	saved, err := c.toSave()
+	if err != nil {
+		return err
+	}
+
+	if err := restrictDir(filepath.Dir(c.filePath)); err != nil {
+		return err
+	}
+
+	if c.migrated {
+		if err := backup(c.filePath, c.fileVersion); err != nil {
+			return err
+		}
+		c.migrated = false
+	}
+
+	file, err := os.OpenFile(c.filePath, os.O_RDWR|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	err = yaml.NewEncoder(file).Encode(c)
+	// The permissions of the existing file are not changed by OpenFile
+	if err := file.Chmod(0600); err != nil {
+		return fmt.Errorf("failed to change permissions of %s: %v", c.filePath, err)
+	}
+
+	err = yaml.NewEncoder(file).Encode(saved)
 	if err != nil {
 		return err
 	}
@@ -167,6 +432,101 @@ func (c *Config) Save() error {
 	return nil
 }
 
+// toSave returns the copy of the config which is saved.
+// The overridden values are the ones in the file, and the encrypted tokens are not decrypted.
+func (c *Config) toSave() (*Config, error) {
+	saved := &Config{
+		Version: c.Version,
+		Entries: make(map[string]*Entry, len(c.Entries)),
+		Current: c.Current,
+	}
+
+	if c.currentOverride != nil && c.Current == c.currentOverride.value {
+		saved.Current = c.currentOverride.saved
+	}
+
+	for name := range c.Entries {
+		e, err := c.savedEntry(name)
+		if err != nil {
+			return nil, err
+		}
+		saved.Entries[name] = e
+	}
+
+	return saved, nil
+}
+
+// savedEntry returns the copy of the entry which is saved
+func (c *Config) savedEntry(name string) (*Entry, error) {
+	entry, err := c.Entry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	e := *entry
+	if name == c.overriddenEntry {
+		for key, o := range c.entryOverrides {
+			if err := e.restore(key, o); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if e.EncryptedToken != "" {
+		e.Token = ""
+	}
+
+	return &e, nil
+}
+
+// get returns the value of the key
+func (e *Entry) get(key string) (string, error) {
+	var m map[string]interface{}
+	if err := mapstructure.Decode(e, &m); err != nil {
+		return "", err
+	}
+
+	v, ok := m[key].(string)
+	if !ok {
+		return "", fmt.Errorf("invalid key %s", key)
+	}
+
+	return v, nil
+}
+
+// set sets the value of the key without the validation of Set
+func (e *Entry) set(key, value string) error {
+	var m map[string]interface{}
+	if err := mapstructure.Decode(e, &m); err != nil {
+		return err
+	}
+	m[key] = value
+
+	return mapstructure.Decode(m, e)
+}
+
+// restore sets the value in the config file to the key, unless the overridden value is changed
+func (e *Entry) restore(key string, o *override) error {
+	v, err := e.get(key)
+	if err != nil {
+		return err
+	}
+	if v != o.value {
+		return nil
+	}
+
+	return e.set(key, o.saved)
+}
+
+// Get returns the value of the key
+func (e *Entry) Get(key string) (string, error) {
+	return e.get(key)
+}
+
+// Unset resets the key to the initial value
+func (e *Entry) Unset(key string) error {
+	return e.Set(key, "")
+}
+
 // Set preserve sd-local config with new value.
 func (e *Entry) Set(key, value string) error {
 	// Update the receiver(*Entry) with the args `key` and `value` as follows.
@@ -195,10 +555,26 @@ func (e *Entry) Set(key, value string) error {
 		if value == "" {
 			value = "-"
 		}
+	case "api-timeout", "api-retry-backoff":
+		if value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid value of %s: %v", key, err)
+			}
+		}
+	case "api-retries":
+		if value != "" {
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				return fmt.Errorf("invalid value of %s: %s is not 0 or a positive number", key, value)
+			}
+		}
 	}
 	m[key] = value
 	if err := mapstructure.Decode(m, &e); err != nil {
 		return err
 	}
+	// The token set in plaintext replaces the encrypted one
+	if key == "token" {
+		e.EncryptedToken = ""
+	}
 	return nil
 }
@@ -32,6 +32,7 @@ func dummyEntry() *Entry {
 // dummyConfig is eqeual to ./testdata/successConfig
 func dummyConfig() Config {
 	return Config{
+		Version: CurrentVersion,
 		Entries: map[string]*Entry{
 			"default": dummyEntry(),
 		},
@@ -46,6 +47,7 @@ func TestCreateConfig(t *testing.T) {
 		defer os.Remove(cnfPath)
 
 		expect := Config{
+			Version: CurrentVersion,
 			Entries: map[string]*Entry{
 				"default": DefaultEntry(),
 			},
@@ -66,6 +68,7 @@ func TestCreateConfig(t *testing.T) {
 		defer os.Remove(cnfPath)
 
 		expect := Config{
+			Version: CurrentVersion,
 			Entries: map[string]*Entry{
 				"default": DefaultEntry(),
 			},
@@ -153,6 +156,7 @@ func TestConfigAddEntry(t *testing.T) {
 		"successfully added a test entry": {
 			addedEntryName: "test",
 			expectConfig: Config{
+				Version: CurrentVersion,
 				Entries: map[string]*Entry{
 					"default": dummyEntry(),
 					"test":    DefaultEntry(),
@@ -164,6 +168,7 @@ func TestConfigAddEntry(t *testing.T) {
 		"failure by the name that exists": {
 			addedEntryName: "default",
 			expectConfig: Config{
+				Version: CurrentVersion,
 				Entries: map[string]*Entry{
 					"default": dummyEntry(),
 				},
@@ -280,6 +285,137 @@ func TestConfigSetCurrent(t *testing.T) {
 	}
 }
 
+func TestConfigRenameEntry(t *testing.T) {
+	cases := map[string]struct {
+		oldName       string
+		newName       string
+		expectEntries []string
+		expectCurrent string
+		expectErr     error
+	}{
+		"success to rename an entry": {
+			oldName:       "test",
+			newName:       "work",
+			expectEntries: []string{"default", "work"},
+			expectCurrent: "default",
+			expectErr:     nil,
+		},
+		"success to rename the current entry": {
+			oldName:       "default",
+			newName:       "work",
+			expectEntries: []string{"test", "work"},
+			expectCurrent: "work",
+			expectErr:     nil,
+		},
+		"failure by the name that does not exist": {
+			oldName:       "doesnotexist",
+			newName:       "work",
+			expectEntries: []string{"default", "test"},
+			expectCurrent: "default",
+			expectErr:     fmt.Errorf("config `doesnotexist` does not exist"),
+		},
+		"failure by the new name that already exists": {
+			oldName:       "default",
+			newName:       "test",
+			expectEntries: []string{"default", "test"},
+			expectCurrent: "default",
+			expectErr:     fmt.Errorf("config `test` already exists"),
+		},
+	}
+
+	for name, test := range cases {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				Current: "default",
+				Entries: map[string]*Entry{
+					"default": dummyEntry(),
+					"test":    DefaultEntry(),
+				},
+			}
+			err := config.RenameEntry(test.oldName, test.newName)
+			assert.Equal(t, test.expectErr, err)
+			assert.Equal(t, test.expectCurrent, config.Current)
+
+			names := make([]string, 0, len(config.Entries))
+			for n := range config.Entries {
+				names = append(names, n)
+			}
+			assert.ElementsMatch(t, test.expectEntries, names)
+		})
+	}
+
+	t.Run("success to rename the current entry selected by SDLOCAL_CONFIG", func(t *testing.T) {
+		t.Setenv(ConfigEnv, "test")
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.Nil(t, c.RenameEntry("test", "work"))
+		assert.Nil(t, c.RenameEntry("default", "home"))
+		assert.Equal(t, "work", c.Current)
+		assert.Equal(t, map[string]string{"token": "SDLOCAL_TOKEN"}, c.Overrides("work"))
+		assert.Nil(t, c.Save())
+
+		saved := readTestConfig(t, p)
+		assert.Equal(t, "home", saved.Current)
+		assert.Equal(t, "test_token", saved.Entries["work"].Token)
+	})
+}
+
+func TestConfigCopyEntry(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		entry, err := c.CopyEntry("default", "copy")
+		assert.Nil(t, err)
+		assert.Same(t, entry, c.Entries["copy"])
+		assert.NotSame(t, c.Entries["default"], entry)
+		// The copy has the token in the file
+		assert.Equal(t, "dummy_token", entry.Token)
+		assert.Equal(t, "api-url", entry.APIURL)
+		assert.Equal(t, "env_token", c.Entries["default"].Token)
+	})
+
+	t.Run("failure by the name that does not exist", func(t *testing.T) {
+		c := dummyConfig()
+		_, err := c.CopyEntry("doesnotexist", "copy")
+		assert.EqualError(t, err, "config `doesnotexist` does not exist")
+	})
+
+	t.Run("failure by the new name that already exists", func(t *testing.T) {
+		c := dummyConfig()
+		_, err := c.CopyEntry("default", "default")
+		assert.EqualError(t, err, "config `default` already exists")
+	})
+}
+
+func TestEntryGetUnset(t *testing.T) {
+	entry := dummyEntry()
+	entry.UUID = "eb004dc1-614c-11eb-bab9-0242ac120002"
+
+	v, err := entry.Get("launcher-version")
+	assert.Nil(t, err)
+	assert.Equal(t, "latest", v)
+
+	_, err = entry.Get("invalid-key")
+	assert.EqualError(t, err, "invalid key invalid-key")
+
+	assert.Nil(t, entry.Unset("api-url"))
+	assert.Nil(t, entry.Unset("launcher-version"))
+	assert.Nil(t, entry.Unset("uuid"))
+	assert.Equal(t, "", entry.APIURL)
+	assert.Equal(t, "stable", entry.Launcher.Version)
+	assert.Equal(t, "-", entry.UUID)
+
+	assert.EqualError(t, entry.Unset("invalid-key"), "invalid key invalid-key")
+}
+
 func TestConfigSave(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		rand.Seed(time.Now().UnixNano())
@@ -376,6 +512,50 @@ func TestSetEntry(t *testing.T) {
 			},
 			expectValue: "-",
 		},
+		"set runtime": {
+			input: setting{
+				key:   "runtime",
+				value: "podman",
+			},
+			expectValue: "podman",
+		},
+		"set api-timeout": {
+			input: setting{
+				key:   "api-timeout",
+				value: "30s",
+			},
+			expectValue: "30s",
+		},
+		"set invalid api-retry-backoff": {
+			input: setting{
+				key:   "api-retry-backoff",
+				value: "1",
+			},
+			expectValue: "",
+			expectErr:   fmt.Errorf("invalid value of api-retry-backoff: time: missing unit in duration \"1\""),
+		},
+		"set api-retries": {
+			input: setting{
+				key:   "api-retries",
+				value: "5",
+			},
+			expectValue: "5",
+		},
+		"set invalid api-retries": {
+			input: setting{
+				key:   "api-retries",
+				value: "-1",
+			},
+			expectValue: "",
+			expectErr:   fmt.Errorf("invalid value of api-retries: -1 is not 0 or a positive number"),
+		},
+		"set ca-cert": {
+			input: setting{
+				key:   "ca-cert",
+				value: "/etc/ssl/corp-ca.pem",
+			},
+			expectValue: "/etc/ssl/corp-ca.pem",
+		},
 		"set invalid-key": {
 			input: setting{
 				key:   "invalid-key",
@@ -401,3 +581,186 @@ func TestSetEntry(t *testing.T) {
 		})
 	}
 }
+
+func TestBaseDir(t *testing.T) {
+	t.Run("success with SDLOCAL_HOME", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv(HomeEnv, dir)
+
+		got, err := BaseDir()
+		assert.Nil(t, err)
+		assert.Equal(t, dir, got)
+	})
+
+	t.Run("success without SDLOCAL_HOME", func(t *testing.T) {
+		t.Setenv(HomeEnv, "")
+
+		got, err := BaseDir()
+		assert.Nil(t, err)
+		assert.Equal(t, ".sdlocal", filepath.Base(got))
+	})
+}
+
+// writeTestConfig writes the config with the entries "default" and "test" in a temporary directory and returns its path
+func writeTestConfig(t *testing.T) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "config")
+	content := `configs:
+  default:
+    api-url: api-url
+    store-url: store-api-url
+    token: dummy_token
+    launcher:
+      version: latest
+      image: screwdrivercd/launcher
+  test:
+    api-url: test-api-url
+    store-url: test-store-api-url
+    token: test_token
+    launcher:
+      version: stable
+      image: screwdrivercd/launcher
+current: default
+`
+	if err := os.WriteFile(p, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// readTestConfig reads the config file without the environment variables
+func readTestConfig(t *testing.T, p string) Config {
+	t.Helper()
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Run("success to override the current entry", func(t *testing.T) {
+		t.Setenv("SDLOCAL_API_URL", "https://api.example.com")
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+		t.Setenv("SDLOCAL_LAUNCHER_VERSION", "v6.0.0")
+
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		entry, err := c.Entry(c.Current)
+		assert.Nil(t, err)
+		assert.Equal(t, "https://api.example.com", entry.APIURL)
+		assert.Equal(t, "store-api-url", entry.StoreURL)
+		assert.Equal(t, "env_token", entry.Token)
+		assert.Equal(t, "v6.0.0", entry.Launcher.Version)
+		assert.Equal(t, map[string]string{
+			"api-url":          "SDLOCAL_API_URL",
+			"token":            "SDLOCAL_TOKEN",
+			"launcher-version": "SDLOCAL_LAUNCHER_VERSION",
+		}, c.Overrides("default"))
+		assert.Equal(t, map[string]string{}, c.Overrides("test"))
+		assert.Equal(t, "", c.CurrentOverride())
+	})
+
+	t.Run("success to override the current config", func(t *testing.T) {
+		t.Setenv(ConfigEnv, "test")
+		t.Setenv("SDLOCAL_STORE_URL", "https://store.example.com")
+
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		assert.Equal(t, "test", c.Current)
+		assert.Equal(t, ConfigEnv, c.CurrentOverride())
+		assert.Equal(t, "https://store.example.com", c.Entries["test"].StoreURL)
+		assert.Equal(t, "store-api-url", c.Entries["default"].StoreURL)
+		assert.Equal(t, map[string]string{"store-url": "SDLOCAL_STORE_URL"}, c.Overrides("test"))
+
+		// The config selected by the project file does not take precedence
+		assert.Nil(t, c.Select("default"))
+		assert.Equal(t, "test", c.Current)
+
+		// The current config in the file can't be deleted
+		assert.EqualError(t, c.DeleteEntry("default"), "config `default` is current config")
+	})
+
+	t.Run("success to save without the overrides", func(t *testing.T) {
+		t.Setenv(ConfigEnv, "test")
+		t.Setenv("SDLOCAL_API_URL", "https://api.example.com")
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		// The value changed after loading is saved
+		assert.Nil(t, c.Entries["test"].Set("token", "new_token"))
+		assert.Nil(t, c.Entries["test"].Set("uuid", "eb004dc1-614c-11eb-bab9-0242ac120002"))
+		assert.Nil(t, c.Save())
+
+		saved := readTestConfig(t, p)
+		assert.Equal(t, "default", saved.Current)
+		assert.Equal(t, "test-api-url", saved.Entries["test"].APIURL)
+		assert.Equal(t, "new_token", saved.Entries["test"].Token)
+		assert.Equal(t, "eb004dc1-614c-11eb-bab9-0242ac120002", saved.Entries["test"].UUID)
+
+		// The loaded config keeps the overridden values
+		assert.Equal(t, "https://api.example.com", c.Entries["test"].APIURL)
+		assert.Equal(t, "test", c.Current)
+	})
+
+	t.Run("success to save the current config set explicitly", func(t *testing.T) {
+		t.Setenv(ConfigEnv, "test")
+
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.Nil(t, c.SetCurrent("test"))
+		assert.Nil(t, c.Save())
+		assert.Equal(t, "test", readTestConfig(t, p).Current)
+	})
+
+	t.Run("success to select the entry", func(t *testing.T) {
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.Nil(t, c.Select("test"))
+		assert.Equal(t, "test", c.Current)
+		assert.Equal(t, "", c.CurrentOverride())
+		assert.Equal(t, "env_token", c.Entries["test"].Token)
+		assert.Equal(t, "dummy_token", c.Entries["default"].Token)
+		assert.Equal(t, map[string]string{"token": "SDLOCAL_TOKEN"}, c.Overrides("test"))
+		assert.Equal(t, map[string]string{}, c.Overrides("default"))
+
+		assert.Nil(t, c.Save())
+		saved := readTestConfig(t, p)
+		assert.Equal(t, "default", saved.Current)
+		assert.Equal(t, "test_token", saved.Entries["test"].Token)
+		assert.Equal(t, "dummy_token", saved.Entries["default"].Token)
+
+		assert.EqualError(t, c.Select("doesnotexist"), "config `doesnotexist` does not exist")
+	})
+
+	t.Run("success with the current config which does not exist", func(t *testing.T) {
+		t.Setenv(ConfigEnv, "doesnotexist")
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		_, err = c.Entry(c.Current)
+		assert.EqualError(t, err, "config `doesnotexist` does not exist")
+		assert.Equal(t, "dummy_token", c.Entries["default"].Token)
+	})
+}
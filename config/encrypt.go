@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv is the environment variable of the passphrase of the encrypted tokens and the secrets
+const PassphraseEnv = "SDLOCAL_PASSPHRASE"
+
+const (
+	// encryptedPrefix is the prefix of the encrypted token, which tells the key derivation and the cipher
+	encryptedPrefix = "scrypt-aes256gcm:"
+	saltSize        = 16
+	keySize         = 32
+)
+
+// scryptN is the CPU and memory cost of scrypt, which is recommended for interactive logins
+var scryptN = 1 << 15
+
+// deriveKey derives the key of AES-256 from the passphrase
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, 8, 1, keySize)
+}
+
+// errDecrypt is returned when the data can't be decrypted with the passphrase
+var errDecrypt = errors.New("the passphrase is wrong or the data is broken")
+
+// Encrypt encrypts the data with the key derived from the passphrase.
+// The result is the salt, the nonce and the ciphertext.
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("the passphrase is empty")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(append(salt, nonce...), nonce, data, nil), nil
+}
+
+// Decrypt decrypts the data encrypted by Encrypt
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, errors.New("data is too short")
+	}
+
+	salt, data := data[:saltSize], data[saltSize:]
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("data is too short")
+	}
+
+	nonce, data := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, errDecrypt
+	}
+
+	return plain, nil
+}
+
+// encryptToken encrypts the token with the key derived from the passphrase.
+// The result is the prefix followed by the base64 of the salt, the nonce and the ciphertext.
+func encryptToken(token, passphrase string) (string, error) {
+	data, err := Encrypt([]byte(token), passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt token: %v", err)
+	}
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decryptToken decrypts the token encrypted by encryptToken
+func decryptToken(encrypted, passphrase string) (string, error) {
+	if !strings.HasPrefix(encrypted, encryptedPrefix) {
+		return "", errors.New("failed to decrypt token: unsupported encryption")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %v", err)
+	}
+
+	token, err := Decrypt(data, passphrase)
+	if errors.Is(err, errDecrypt) {
+		return "", errors.New("failed to decrypt token: the passphrase is wrong or the token is broken")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %v", err)
+	}
+
+	return string(token), nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptToken sets the token, which is saved encrypted with the passphrase
+func (e *Entry) EncryptToken(token, passphrase string) error {
+	encrypted, err := encryptToken(token, passphrase)
+	if err != nil {
+		return err
+	}
+
+	e.Token = token
+	e.EncryptedToken = encrypted
+
+	return nil
+}
+
+// TokenLocked returns true when the token is encrypted and has not been decrypted
+func (e *Entry) TokenLocked() bool {
+	return e.EncryptedToken != "" && e.Token == ""
+}
+
+// DecryptToken decrypts the token with the passphrase
+func (e *Entry) DecryptToken(passphrase string) error {
+	token, err := decryptToken(e.EncryptedToken, passphrase)
+	if err != nil {
+		return err
+	}
+
+	e.Token = token
+
+	return nil
+}
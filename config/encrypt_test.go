@@ -0,0 +1,213 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fastScrypt lowers the cost of scrypt in the tests
+func fastScrypt(t *testing.T) {
+	n := scryptN
+	t.Cleanup(func() {
+		scryptN = n
+	})
+	scryptN = 1 << 10
+}
+
+func TestEncryptToken(t *testing.T) {
+	fastScrypt(t)
+
+	t.Run("success", func(t *testing.T) {
+		encrypted, err := encryptToken("sd-token", "passphrase")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(encrypted, encryptedPrefix))
+		assert.NotContains(t, encrypted, "sd-token")
+
+		// The salt and the nonce are random
+		again, err := encryptToken("sd-token", "passphrase")
+		assert.Nil(t, err)
+		assert.NotEqual(t, encrypted, again)
+
+		token, err := decryptToken(encrypted, "passphrase")
+		assert.Nil(t, err)
+		assert.Equal(t, "sd-token", token)
+	})
+
+	t.Run("failure by empty passphrase", func(t *testing.T) {
+		_, err := encryptToken("sd-token", "")
+		assert.EqualError(t, err, "failed to encrypt token: the passphrase is empty")
+	})
+
+	testCases := []struct {
+		name      string
+		encrypted string
+		wantErr   string
+	}{
+		{
+			name:      "failure by unsupported encryption",
+			encrypted: "sd-token",
+			wantErr:   "failed to decrypt token: unsupported encryption",
+		},
+		{
+			name:      "failure by invalid base64",
+			encrypted: encryptedPrefix + "!",
+			wantErr:   "failed to decrypt token: illegal base64 data at input byte 0",
+		},
+		{
+			name:      "failure by short data",
+			encrypted: encryptedPrefix + "AAAA",
+			wantErr:   "failed to decrypt token: data is too short",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decryptToken(tt.encrypted, "passphrase")
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("failure by wrong passphrase", func(t *testing.T) {
+		encrypted, err := encryptToken("sd-token", "passphrase")
+		assert.Nil(t, err)
+
+		_, err = decryptToken(encrypted, "wrong")
+		assert.EqualError(t, err, "failed to decrypt token: the passphrase is wrong or the token is broken")
+	})
+}
+
+func TestEncrypt(t *testing.T) {
+	fastScrypt(t)
+
+	t.Run("success", func(t *testing.T) {
+		encrypted, err := Encrypt([]byte("secrets"), "passphrase")
+		assert.Nil(t, err)
+		assert.NotContains(t, string(encrypted), "secrets")
+
+		plain, err := Decrypt(encrypted, "passphrase")
+		assert.Nil(t, err)
+		assert.Equal(t, "secrets", string(plain))
+	})
+
+	t.Run("failure by wrong passphrase", func(t *testing.T) {
+		encrypted, err := Encrypt([]byte("secrets"), "passphrase")
+		assert.Nil(t, err)
+
+		_, err = Decrypt(encrypted, "wrong")
+		assert.EqualError(t, err, "the passphrase is wrong or the data is broken")
+	})
+
+	t.Run("failure by short data", func(t *testing.T) {
+		_, err := Decrypt([]byte("short"), "passphrase")
+		assert.EqualError(t, err, "data is too short")
+	})
+}
+
+func TestEntryEncryptToken(t *testing.T) {
+	fastScrypt(t)
+
+	t.Run("success to save only the encrypted token", func(t *testing.T) {
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		entry := c.Entries["default"]
+		assert.Nil(t, entry.EncryptToken("new-token", "passphrase"))
+		assert.Equal(t, "new-token", entry.Token)
+		assert.False(t, entry.TokenLocked())
+		assert.Nil(t, c.Save())
+
+		b, err := os.ReadFile(p)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(b), "new-token")
+		assert.Contains(t, string(b), "encrypted-token: "+encryptedPrefix)
+
+		loaded, err := New(p)
+		assert.Nil(t, err)
+		entry = loaded.Entries["default"]
+		assert.True(t, entry.TokenLocked())
+		assert.EqualError(t, entry.DecryptToken("wrong"), "failed to decrypt token: the passphrase is wrong or the token is broken")
+		assert.True(t, entry.TokenLocked())
+		assert.Nil(t, entry.DecryptToken("passphrase"))
+		assert.Equal(t, "new-token", entry.Token)
+		assert.False(t, entry.TokenLocked())
+
+		// The decrypted token is not saved
+		assert.Nil(t, loaded.Save())
+		b, err = os.ReadFile(p)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(b), "new-token")
+	})
+
+	t.Run("success to replace the encrypted token with plaintext", func(t *testing.T) {
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		entry := c.Entries["default"]
+		assert.Nil(t, entry.EncryptToken("new-token", "passphrase"))
+		assert.Nil(t, entry.Set("token", "plain-token"))
+		assert.Equal(t, "", entry.EncryptedToken)
+		assert.Nil(t, c.Save())
+
+		assert.Equal(t, "plain-token", readTestConfig(t, p).Entries["default"].Token)
+	})
+}
+
+func TestConfigFilePermissions(t *testing.T) {
+	t.Run("success to create the private base directory", func(t *testing.T) {
+		base := filepath.Join(t.TempDir(), "sdlocal")
+		t.Setenv(HomeEnv, base)
+
+		_, err := New(filepath.Join(base, "config"))
+		assert.Nil(t, err)
+
+		info, err := os.Stat(base)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+		info, err = os.Stat(filepath.Join(base, "config"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("success to restrict the existing file", func(t *testing.T) {
+		base := t.TempDir()
+		t.Setenv(HomeEnv, base)
+		assert.Nil(t, os.Chmod(base, 0755))
+
+		p := filepath.Join(base, "config")
+		assert.Nil(t, os.WriteFile(p, []byte("configs: {}\ncurrent: default\n"), 0666))
+		assert.Nil(t, os.Chmod(p, 0666))
+
+		c, err := New(p)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Save())
+
+		info, err := os.Stat(base)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+		info, err = os.Stat(p)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("success without changing the other directory", func(t *testing.T) {
+		t.Setenv(HomeEnv, t.TempDir())
+		dir := t.TempDir()
+		assert.Nil(t, os.Chmod(dir, 0755))
+
+		c, err := New(filepath.Join(dir, "config"))
+		assert.Nil(t, err)
+		assert.Nil(t, c.Save())
+
+		info, err := os.Stat(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	})
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-yaml/yaml"
+)
+
+// exported is the format of the entries moved between machines, which is the part of the config file
+type exported struct {
+	Version int               `yaml:"version"`
+	Entries map[string]*Entry `yaml:"configs"`
+}
+
+// Export writes the entries named `names`, or all the entries if it is empty, as YAML.
+// The entries have the values in the file, and the tokens are excluded unless withToken is true.
+func (c *Config) Export(w io.Writer, names []string, withToken bool) error {
+	if len(names) == 0 {
+		for name := range c.Entries {
+			names = append(names, name)
+		}
+	}
+
+	out := exported{Version: CurrentVersion, Entries: make(map[string]*Entry, len(names))}
+	for _, name := range names {
+		entry, err := c.savedEntry(name)
+		if err != nil {
+			return err
+		}
+		if !withToken {
+			entry.Token = ""
+			entry.EncryptedToken = ""
+		}
+		out.Entries[name] = entry
+	}
+
+	if err := yaml.NewEncoder(w).Encode(out); err != nil {
+		return fmt.Errorf("failed to export config: %v", err)
+	}
+
+	return nil
+}
+
+// Import adds the entries written by Export, and returns their names.
+// The entries of the older version are migrated, but the ones of the newer version are rejected.
+// The existing entries are replaced only if overwrite is true.
+func (c *Config) Import(r io.Reader, overwrite bool) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config to import: %v", err)
+	}
+
+	var in exported
+	if err := yaml.UnmarshalStrict(b, &in); err != nil {
+		return nil, fmt.Errorf("failed to parse config to import: %v", err)
+	}
+	if len(in.Entries) == 0 {
+		return nil, errors.New("no config to import")
+	}
+
+	// The entries exported by the older sd-local are migrated as well
+	old := &Config{Version: in.Version, Entries: in.Entries}
+	if err := old.upgrade(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(in.Entries))
+	for name := range in.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if in.Entries[name] == nil {
+			return nil, fmt.Errorf("config `%s` to import is empty", name)
+		}
+		if _, exist := c.Entries[name]; exist && !overwrite {
+			return nil, fmt.Errorf("config `%s` already exists", name)
+		}
+	}
+
+	for _, name := range names {
+		// The replaced entry is overridden again by the environment variables below
+		if name == c.overriddenEntry {
+			c.overriddenEntry = ""
+			c.entryOverrides = nil
+		}
+		c.Entries[name] = in.Entries[name]
+	}
+
+	if err := c.overrideEntry(c.Current); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	t.Run("success with the tokens", func(t *testing.T) {
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		buf := bytes.NewBuffer(nil)
+		assert.Nil(t, c.Export(buf, []string{"default"}, true))
+		assert.Equal(t, `version: 1
+configs:
+  default:
+    api-url: api-url
+    store-url: store-api-url
+    token: dummy_token
+    UUID: ""
+    launcher:
+      version: latest
+      image: screwdrivercd/launcher
+`, buf.String())
+	})
+
+	t.Run("success without the tokens", func(t *testing.T) {
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+		c.Entries["test"].EncryptedToken = encryptedPrefix + "AAAA"
+
+		buf := bytes.NewBuffer(nil)
+		assert.Nil(t, c.Export(buf, nil, false))
+		assert.NotContains(t, buf.String(), "dummy_token")
+		assert.NotContains(t, buf.String(), "test_token")
+		assert.NotContains(t, buf.String(), "encrypted-token")
+		assert.Contains(t, buf.String(), "  default:\n")
+		assert.Contains(t, buf.String(), "  test:\n")
+
+		// The config is not changed
+		assert.Equal(t, "dummy_token", c.Entries["default"].Token)
+	})
+
+	t.Run("failure by the name that does not exist", func(t *testing.T) {
+		c := dummyConfig()
+		assert.EqualError(t, c.Export(bytes.NewBuffer(nil), []string{"doesnotexist"}, true), "config `doesnotexist` does not exist")
+	})
+}
+
+func TestImport(t *testing.T) {
+	const imported = `configs:
+  test:
+    api-url: imported-api-url
+    token: imported_token
+  work:
+    api-url: work-api-url
+    launcher:
+      version: stable
+      image: screwdrivercd/launcher
+`
+
+	t.Run("success", func(t *testing.T) {
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		buf := bytes.NewBuffer(nil)
+		assert.Nil(t, c.Export(buf, []string{"default"}, true))
+		assert.Nil(t, c.DeleteEntry("test"))
+		c.Current = "test"
+		assert.Nil(t, c.DeleteEntry("default"))
+
+		names, err := c.Import(buf, false)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"default"}, names)
+		assert.Equal(t, dummyEntry(), c.Entries["default"])
+	})
+
+	t.Run("success to migrate the old entries", func(t *testing.T) {
+		c, err := New(writeTestConfig(t))
+		assert.Nil(t, err)
+
+		// The entries exported without version have the empty launcher
+		names, err := c.Import(strings.NewReader("configs:\n  work:\n    api-url: work-api-url\n"), false)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"work"}, names)
+		assert.Equal(t, DefaultEntry().Launcher, c.Entries["work"].Launcher)
+	})
+
+	t.Run("success to overwrite the existing entries", func(t *testing.T) {
+		t.Setenv(ConfigEnv, "test")
+		t.Setenv("SDLOCAL_TOKEN", "env_token")
+
+		p := writeTestConfig(t)
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		names, err := c.Import(strings.NewReader(imported), true)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"test", "work"}, names)
+		assert.Equal(t, "imported-api-url", c.Entries["test"].APIURL)
+		assert.Equal(t, "work-api-url", c.Entries["work"].APIURL)
+
+		// The imported entry is overridden by the environment variables as well
+		assert.Equal(t, "env_token", c.Entries["test"].Token)
+		assert.Nil(t, c.Save())
+		assert.Equal(t, "imported_token", readTestConfig(t, p).Entries["test"].Token)
+	})
+
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "failure by the existing entry",
+			content: imported,
+			wantErr: "config `test` already exists",
+		},
+		{
+			name:    "failure by empty input",
+			content: "",
+			wantErr: "no config to import",
+		},
+		{
+			name:    "failure by empty entry",
+			content: "configs:\n  work:\n",
+			wantErr: "config `work` to import is empty",
+		},
+		{
+			name:    "failure by future version",
+			content: "version: 2\nconfigs:\n  work:\n    api-url: work-api-url\n",
+			wantErr: "config version 2 is newer than the supported version 1, please upgrade sd-local",
+		},
+		{
+			name:    "failure by unknown key",
+			content: "configs:\n  work:\n    cpu: 2\n",
+			wantErr: "failed to parse config to import: yaml: unmarshal errors:\n  line 3: field cpu not found in type config.Entry",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(writeTestConfig(t))
+			assert.Nil(t, err)
+
+			_, err = c.Import(strings.NewReader(tt.content), false)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Len(t, c.Entries, 2)
+		})
+	}
+}
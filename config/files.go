@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The directories next to the config file which have the secrets and the JWT cache named after each config
+const (
+	secretsDir = "secrets"
+	tokensDir  = "tokens"
+)
+
+// entryFiles returns the files of the entry, which follow the entry when it is renamed, copied or deleted.
+// The config which is not read from a file has none, and so does the name which the stores reject as a file name.
+func (c *Config) entryFiles(name string) []string {
+	if c.filePath == "" || name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil
+	}
+
+	dir := filepath.Dir(c.filePath)
+	return []string{
+		filepath.Join(dir, secretsDir, name),
+		filepath.Join(dir, tokensDir, name+".json"),
+	}
+}
+
+// newEntryFiles returns the files of the entry and the ones of the new name
+func (c *Config) newEntryFiles(name, newName string) ([]string, []string, error) {
+	from, to := c.entryFiles(name), c.entryFiles(newName)
+	if len(from) != len(to) {
+		return nil, nil, fmt.Errorf("invalid config name `%s` for the secrets and the JWT cache", newName)
+	}
+
+	return from, to, nil
+}
+
+// moveEntryFiles moves the files of the entry to the ones of the new name.
+// The files moved before a failure are moved back, so that the files of the entry are not split.
+func (c *Config) moveEntryFiles(name, newName string) error {
+	from, to, err := c.newEntryFiles(name, newName)
+	if err != nil {
+		return err
+	}
+
+	moved := 0
+	for i := range from {
+		err := os.Rename(from[i], to[i])
+		if os.IsNotExist(err) {
+			// The file left by the config of the same name which was deleted before must not be taken over
+			err = removeFile(to[i])
+		}
+		if err != nil {
+			for j := moved - 1; j >= 0; j-- {
+				_ = os.Rename(to[j], from[j])
+			}
+			return fmt.Errorf("failed to move %s: %v", from[i], err)
+		}
+		moved++
+	}
+
+	return nil
+}
+
+// copyEntryFiles copies the files of the entry to the ones of the new name
+func (c *Config) copyEntryFiles(name, newName string) error {
+	from, to, err := c.newEntryFiles(name, newName)
+	if err != nil {
+		return err
+	}
+
+	for i := range from {
+		b, err := os.ReadFile(from[i])
+		if os.IsNotExist(err) {
+			if err := removeFile(to[i]); err != nil {
+				return fmt.Errorf("failed to copy %s: %v", from[i], err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %v", from[i], err)
+		}
+
+		if err := os.WriteFile(to[i], b, 0600); err != nil {
+			return fmt.Errorf("failed to copy %s: %v", from[i], err)
+		}
+	}
+
+	return nil
+}
+
+// removeEntryFiles removes the files of the entry
+func (c *Config) removeEntryFiles(name string) error {
+	for _, f := range c.entryFiles(name) {
+		if err := removeFile(f); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", f, err)
+		}
+	}
+
+	return nil
+}
+
+// removeFile removes the file, ignoring the one which does not exist
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeEntryFiles writes the secrets and the JWT cache of the entry next to the config file
+func writeEntryFiles(t *testing.T, p, name string) {
+	t.Helper()
+
+	dir := filepath.Dir(p)
+	for _, f := range []string{filepath.Join(dir, secretsDir, name), filepath.Join(dir, tokensDir, name+".json")} {
+		if err := os.MkdirAll(filepath.Dir(f), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readEntryFiles returns the contents of the secrets and the JWT cache of the entry, which are empty when they do not exist
+func readEntryFiles(t *testing.T, p, name string) []string {
+	t.Helper()
+
+	dir := filepath.Dir(p)
+	contents := []string{}
+	for _, f := range []string{filepath.Join(dir, secretsDir, name), filepath.Join(dir, tokensDir, name+".json")} {
+		b, err := os.ReadFile(f)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(b))
+	}
+
+	return contents
+}
+
+func TestEntryFiles(t *testing.T) {
+	t.Run("success to move the files by rename", func(t *testing.T) {
+		p := writeTestConfig(t)
+		writeEntryFiles(t, p, "test")
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.Nil(t, c.RenameEntry("test", "work"))
+		assert.Equal(t, []string{"", ""}, readEntryFiles(t, p, "test"))
+		assert.Equal(t, []string{"test", "test"}, readEntryFiles(t, p, "work"))
+	})
+
+	t.Run("success to rename without taking over the files left by the deleted config", func(t *testing.T) {
+		p := writeTestConfig(t)
+		writeEntryFiles(t, p, "work")
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.Nil(t, c.RenameEntry("test", "work"))
+		assert.Equal(t, []string{"", ""}, readEntryFiles(t, p, "work"))
+	})
+
+	t.Run("success to copy the files", func(t *testing.T) {
+		p := writeTestConfig(t)
+		writeEntryFiles(t, p, "test")
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		_, err = c.CopyEntry("test", "work")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"test", "test"}, readEntryFiles(t, p, "test"))
+		assert.Equal(t, []string{"test", "test"}, readEntryFiles(t, p, "work"))
+
+		info, err := os.Stat(filepath.Join(filepath.Dir(p), secretsDir, "work"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("success to remove the files by delete", func(t *testing.T) {
+		p := writeTestConfig(t)
+		writeEntryFiles(t, p, "test")
+		writeEntryFiles(t, p, "default")
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.Nil(t, c.DeleteEntry("test"))
+		assert.Equal(t, []string{"", ""}, readEntryFiles(t, p, "test"))
+		assert.Equal(t, []string{"default", "default"}, readEntryFiles(t, p, "default"))
+
+		// The config without the files is deleted as well
+		assert.Nil(t, c.AddEntry("work", DefaultEntry()))
+		assert.Nil(t, c.DeleteEntry("work"))
+	})
+
+	t.Run("failure to rename to the name which can't have the files", func(t *testing.T) {
+		p := writeTestConfig(t)
+		writeEntryFiles(t, p, "test")
+		c, err := New(p)
+		assert.Nil(t, err)
+
+		assert.EqualError(t, c.RenameEntry("test", "../config"), "invalid config name `../config` for the secrets and the JWT cache")
+		assert.Contains(t, c.Entries, "test")
+		assert.Equal(t, []string{"test", "test"}, readEntryFiles(t, p, "test"))
+
+		// The config file is never removed as the files of the entry
+		assert.Nil(t, c.AddEntry("../config", DefaultEntry()))
+		assert.Nil(t, c.DeleteEntry("../config"))
+		_, err = os.Stat(p)
+		assert.Nil(t, err)
+	})
+
+	t.Run("success without the config file", func(t *testing.T) {
+		c := dummyConfig()
+		assert.Nil(t, c.RenameEntry("default", "work"))
+		assert.Nil(t, c.AddEntry("test", DefaultEntry()))
+		_, err := c.CopyEntry("test", "copy")
+		assert.Nil(t, err)
+		assert.Nil(t, c.DeleteEntry("copy"))
+	})
+}
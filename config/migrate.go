@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// CurrentVersion is the version of the config file which this sd-local reads and writes
+const CurrentVersion = 1
+
+// migration converts the config of a version to the next version
+type migration func(c *Config) error
+
+// migrations[i] converts the config of version i to version i+1.
+// The files without version are version 0.
+var migrations = []migration{
+	migrateLauncherDefaults,
+}
+
+// migrateLauncherDefaults sets the defaults to the empty launcher version and image,
+// which the old sd-local has replaced only when they are set.
+func migrateLauncherDefaults(c *Config) error {
+	defaults := DefaultEntry().Launcher
+	for _, entry := range c.Entries {
+		if entry == nil {
+			continue
+		}
+		if entry.Launcher.Version == "" {
+			entry.Launcher.Version = defaults.Version
+		}
+		if entry.Launcher.Image == "" {
+			entry.Launcher.Image = defaults.Image
+		}
+	}
+
+	return nil
+}
+
+// checkVersion returns error if the version can't be migrated
+func checkVersion(version int) error {
+	if version > CurrentVersion {
+		return fmt.Errorf("config version %d is newer than the supported version %d, please upgrade sd-local", version, CurrentVersion)
+	}
+	if version < 0 {
+		return fmt.Errorf("invalid config version %d", version)
+	}
+
+	return nil
+}
+
+// backupPath returns the path of the backup of the config file of the version
+func backupPath(configPath string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", configPath, version)
+}
+
+// backup copies the config file of the version before it is rewritten in the current version
+func backup(configPath string, version int) error {
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to back up config file: %v", err)
+	}
+
+	if err := os.WriteFile(backupPath(configPath, version), b, 0600); err != nil {
+		return fmt.Errorf("failed to back up config file: %v", err)
+	}
+
+	return nil
+}
+
+// upgrade runs the migrations from the version of the config to the current version in memory
+func (c *Config) upgrade() error {
+	if err := checkVersion(c.Version); err != nil {
+		return err
+	}
+
+	for c.Version < CurrentVersion {
+		from := c.Version
+		if err := migrations[from](c); err != nil {
+			return fmt.Errorf("failed to migrate config from version %d: %v", from, err)
+		}
+		c.Version = from + 1
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// copyTestConfig copies the config in testdata to a temporary directory, returning its path
+func copyTestConfig(t *testing.T, name string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(testDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(p, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestMigrations(t *testing.T) {
+	assert.Len(t, migrations, CurrentVersion)
+}
+
+func TestMigrate(t *testing.T) {
+	emptyLauncherEntry := dummyEntry()
+	emptyLauncherEntry.Launcher = DefaultEntry().Launcher
+
+	testCases := []struct {
+		name        string
+		fixture     string
+		wantEntries map[string]*Entry
+		wantBackups []string
+		wantErr     string
+	}{
+		{
+			name:        "success from version 0",
+			fixture:     "v0Config",
+			wantEntries: map[string]*Entry{"default": dummyEntry()},
+			wantBackups: []string{"config.v0.bak"},
+		},
+		{
+			name:    "success from version 0 with empty launcher",
+			fixture: "v0EmptyLauncherConfig",
+			wantEntries: map[string]*Entry{
+				"default": emptyLauncherEntry,
+				"test": {
+					APIURL:   "test-api-url",
+					Token:    "test_token",
+					Launcher: DefaultEntry().Launcher,
+				},
+			},
+			wantBackups: []string{"config.v0.bak"},
+		},
+		{
+			name:        "success without migration",
+			fixture:     "successConfig",
+			wantEntries: map[string]*Entry{"default": dummyEntry()},
+		},
+		{
+			name:    "failure by future version",
+			fixture: "futureVersionConfig",
+			wantErr: "config version 2 is newer than the supported version 1, please upgrade sd-local",
+		},
+		{
+			name:    "failure by invalid version",
+			fixture: "invalidVersionConfig",
+			wantErr: "invalid config version -1",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := copyTestConfig(t, tt.fixture)
+			original, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := New(p)
+
+			backups := func() []string {
+				paths, _ := filepath.Glob(p + ".v*.bak")
+				names := []string{}
+				for _, path := range paths {
+					names = append(names, filepath.Base(path))
+				}
+				return names
+			}
+
+			// The file is not changed until the config is saved
+			assertUnchanged := func() {
+				b, err := os.ReadFile(p)
+				assert.Nil(t, err)
+				assert.Equal(t, original, b)
+				assert.Empty(t, backups())
+			}
+			assertUnchanged()
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, CurrentVersion, c.Version)
+			assert.Equal(t, tt.wantEntries, c.Entries)
+
+			assert.Nil(t, c.Save())
+			assert.ElementsMatch(t, tt.wantBackups, backups())
+
+			// The backup is the old file and the file is migrated
+			for _, b := range tt.wantBackups {
+				saved, err := os.ReadFile(filepath.Join(filepath.Dir(p), b))
+				assert.Nil(t, err)
+				assert.Equal(t, original, saved)
+			}
+			saved := readTestConfig(t, p)
+			assert.Equal(t, CurrentVersion, saved.Version)
+			assert.Equal(t, tt.wantEntries, saved.Entries)
+
+			// The backup is made only once
+			for _, b := range tt.wantBackups {
+				assert.Nil(t, os.Remove(filepath.Join(filepath.Dir(p), b)))
+			}
+			assert.Nil(t, c.Save())
+			assert.Empty(t, backups())
+		})
+	}
+}
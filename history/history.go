@@ -0,0 +1,229 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// BuildFile is the file of the summary of the build
+	BuildFile = "build.json"
+	// EntryFile is the file of the build entry passed to the launcher, with the token redacted
+	EntryFile = "buildEntry.json"
+	// LogFile is the file of the raw build log
+	LogFile = "builds.log"
+	// StepsFile is the file of the step results
+	StepsFile = "steps.json"
+
+	// ResultSuccess is the result of the build which finished successfully
+	ResultSuccess = "SUCCESS"
+	// ResultFailure is the result of the build which failed
+	ResultFailure = "FAILURE"
+
+	// DefaultMaxBuilds is the number of the builds kept when a build is saved
+	DefaultMaxBuilds = 100
+)
+
+// Build is the summary of a build in the history
+type Build struct {
+	ID        string    `json:"id"`
+	JobName   string    `json:"jobName"`
+	Config    string    `json:"config"`
+	SrcPath   string    `json:"srcPath"`
+	SHA       string    `json:"sha"`
+	Image     string    `json:"image"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	// Duration is in seconds
+	Duration float64 `json:"duration"`
+	Result   string  `json:"result"`
+	// ExitCode is nil when the build succeeded or the exit code is unknown
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Files is the content saved with the build
+type Files struct {
+	// Entry is the build entry JSON
+	Entry []byte
+	// Log is the raw build log
+	Log io.Reader
+	// Steps is the step results JSON, which is not saved when it is empty
+	Steps []byte
+}
+
+// Store manages the builds under the base directory. A build is stored in <base>/<id>.
+type Store struct {
+	baseDir string
+	// MaxBuilds is the number of the newest builds kept when a build is saved. Zero keeps all the builds.
+	MaxBuilds int
+}
+
+// New returns the store of the builds in the base directory
+func New(baseDir string) *Store {
+	return &Store{baseDir: baseDir, MaxBuilds: DefaultMaxBuilds}
+}
+
+// newID returns the ID which sorts the builds by their start time
+func newID(start time.Time) string {
+	return start.Format("20060102-150405.000")
+}
+
+// Dir returns the directory of the build
+func (s *Store) Dir(id string) string {
+	return filepath.Join(s.baseDir, id)
+}
+
+// Save stores the build with the files and returns its ID. The oldest builds over MaxBuilds are removed.
+func (s *Store) Save(build Build, files Files) (string, error) {
+	if err := os.MkdirAll(s.baseDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to save build history: %v", err)
+	}
+
+	// The builds of a pipeline may start at the same time, so the directory is made exclusively
+	for start := build.StartTime; ; start = start.Add(time.Millisecond) {
+		build.ID = newID(start)
+		err := os.Mkdir(s.Dir(build.ID), 0700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to save build history: %v", err)
+		}
+	}
+	dir := s.Dir(build.ID)
+
+	buildJSON, err := json.MarshalIndent(build, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to save build history: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, BuildFile), buildJSON, 0600); err != nil {
+		return "", fmt.Errorf("failed to save build history: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, EntryFile), files.Entry, 0600); err != nil {
+		return "", fmt.Errorf("failed to save build history: %v", err)
+	}
+
+	if len(files.Steps) > 0 {
+		if err := os.WriteFile(filepath.Join(dir, StepsFile), files.Steps, 0600); err != nil {
+			return "", fmt.Errorf("failed to save build history: %v", err)
+		}
+	}
+
+	if files.Log != nil {
+		f, err := os.OpenFile(filepath.Join(dir, LogFile), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return "", fmt.Errorf("failed to save build history: %v", err)
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(f, files.Log); err != nil {
+			return "", fmt.Errorf("failed to save build history: %v", err)
+		}
+	}
+
+	if s.MaxBuilds > 0 {
+		if _, err := s.Prune(s.MaxBuilds, 0); err != nil {
+			return "", err
+		}
+	}
+
+	return build.ID, nil
+}
+
+// List returns the builds, the newest first
+func (s *Store) List() ([]Build, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Build{}, nil
+		}
+		return nil, fmt.Errorf("failed to read build history: %v", err)
+	}
+
+	builds := make([]Build, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		build, err := s.Get(e.Name())
+		if err != nil {
+			// A build being saved or broken by hand is skipped
+			continue
+		}
+		builds = append(builds, build)
+	}
+
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].ID > builds[j].ID
+	})
+
+	return builds, nil
+}
+
+// Get returns the build of the ID
+func (s *Store) Get(id string) (Build, error) {
+	if id == "" || filepath.Base(id) != id {
+		return Build{}, fmt.Errorf("build `%s` does not exist", id)
+	}
+
+	buildJSON, err := os.ReadFile(filepath.Join(s.Dir(id), BuildFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Build{}, fmt.Errorf("build `%s` does not exist", id)
+		}
+		return Build{}, fmt.Errorf("failed to read build history: %v", err)
+	}
+
+	build := Build{}
+	if err := json.Unmarshal(buildJSON, &build); err != nil {
+		return Build{}, fmt.Errorf("failed to read build history: %v", err)
+	}
+
+	return build, nil
+}
+
+// Remove removes the build of the ID
+func (s *Store) Remove(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(s.Dir(id)); err != nil {
+		return fmt.Errorf("failed to remove build `%s`: %v", id, err)
+	}
+
+	return nil
+}
+
+// Prune removes the builds except the newest keep builds, and the builds which started before maxAge ago.
+// Zero keep or maxAge means no limit. It returns the IDs of the removed builds.
+func (s *Store) Prune(keep int, maxAge time.Duration) ([]string, error) {
+	builds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	for i, b := range builds {
+		overCount := keep > 0 && i >= keep
+		tooOld := maxAge > 0 && time.Since(b.StartTime) > maxAge
+		if !overCount && !tooOld {
+			continue
+		}
+
+		if err := s.Remove(b.ID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, b.ID)
+	}
+
+	return removed, nil
+}
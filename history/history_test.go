@@ -0,0 +1,147 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBuild(job string, start time.Time, result string) Build {
+	return Build{
+		JobName:   job,
+		Config:    "default",
+		SHA:       "0123456789abcdef",
+		Image:     "node:18",
+		StartTime: start,
+		EndTime:   start.Add(3 * time.Second),
+		Duration:  3,
+		Result:    result,
+	}
+}
+
+func TestSave(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "builds"))
+	start := time.Date(2026, 10, 17, 10, 15, 2, 0, time.Local)
+
+	id, err := s.Save(newBuild("test", start, ResultSuccess), Files{
+		Entry: []byte(`{"environment":[{"SD_TOKEN":"***"}]}`),
+		Log:   strings.NewReader(`{"t":1581662022394,"m":"hello","n":0,"s":"main"}` + "\n"),
+		Steps: []byte(`[{"name":"main"}]`),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "20261017-101502.000", id)
+
+	// The build started at the same time gets the next ID
+	id2, err := s.Save(newBuild("lint", start, ResultFailure), Files{Entry: []byte("{}")})
+	assert.Nil(t, err)
+	assert.Equal(t, "20261017-101502.001", id2)
+
+	got, err := s.Get(id)
+	assert.Nil(t, err)
+	want := newBuild("test", start, ResultSuccess)
+	want.ID = id
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.JobName, got.JobName)
+	assert.True(t, want.StartTime.Equal(got.StartTime))
+
+	for _, f := range []string{BuildFile, EntryFile, LogFile, StepsFile} {
+		info, err := os.Stat(filepath.Join(s.Dir(id), f))
+		assert.Nil(t, err, f)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), f)
+	}
+
+	_, err = os.Stat(filepath.Join(s.Dir(id2), StepsFile))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(s.Dir(id2), LogFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSaveWithMaxBuilds(t *testing.T) {
+	s := New(t.TempDir())
+	s.MaxBuilds = 2
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := s.Save(newBuild("test", start.Add(time.Duration(i)*time.Second), ResultSuccess), Files{})
+		assert.Nil(t, err)
+	}
+
+	builds, err := s.List()
+	assert.Nil(t, err)
+	assert.Len(t, builds, 2)
+	assert.Equal(t, newID(start.Add(2*time.Second)), builds[0].ID)
+	assert.Equal(t, newID(start.Add(time.Second)), builds[1].ID)
+}
+
+func TestList(t *testing.T) {
+	t.Run("success with no builds", func(t *testing.T) {
+		builds, err := New(filepath.Join(t.TempDir(), "notExist")).List()
+		assert.Nil(t, err)
+		assert.Equal(t, []Build{}, builds)
+	})
+
+	t.Run("success skipping broken builds", func(t *testing.T) {
+		dir := t.TempDir()
+		s := New(dir)
+		_, err := s.Save(newBuild("test", time.Now(), ResultSuccess), Files{})
+		assert.Nil(t, err)
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, "broken"), 0700))
+
+		builds, err := s.List()
+		assert.Nil(t, err)
+		assert.Len(t, builds, 1)
+		assert.Equal(t, "test", builds[0].JobName)
+	})
+}
+
+func TestRemove(t *testing.T) {
+	s := New(t.TempDir())
+	id, err := s.Save(newBuild("test", time.Now(), ResultSuccess), Files{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Remove(id))
+	_, err = os.Stat(s.Dir(id))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.EqualError(t, s.Remove(id), "build `"+id+"` does not exist")
+	assert.EqualError(t, s.Remove("../builds"), "build `../builds` does not exist")
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name   string
+		keep   int
+		maxAge time.Duration
+		want   int
+	}{
+		{name: "keep", keep: 2, want: 2},
+		{name: "max age", maxAge: 36 * time.Hour, want: 2},
+		{name: "keep and max age", keep: 1, maxAge: 36 * time.Hour, want: 1},
+		{name: "no limit", want: 4},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(t.TempDir())
+			s.MaxBuilds = 0
+			for _, age := range []time.Duration{0, 24 * time.Hour, 48 * time.Hour, 72 * time.Hour} {
+				_, err := s.Save(newBuild("test", now.Add(-age), ResultSuccess), Files{})
+				assert.Nil(t, err)
+			}
+
+			removed, err := s.Prune(tt.keep, tt.maxAge)
+			assert.Nil(t, err)
+			assert.Len(t, removed, 4-tt.want)
+
+			builds, err := s.List()
+			assert.Nil(t, err)
+			assert.Len(t, builds, tt.want)
+		})
+	}
+}
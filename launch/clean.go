@@ -0,0 +1,271 @@
+package launch
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/screwdriver-cd/sd-local/state"
+)
+
+// Resource is a volume, a container or a network found in the container runtime
+type Resource struct {
+	state.Resource
+	// Session and PID are the values of the labels, which are empty for the resources created by the older sd-local
+	Session string
+	PID     int
+}
+
+// isResourceName returns true if the name is the one which the builds give to the resource of the kind
+func isResourceName(kind, name string) bool {
+	for _, prefix := range leftoverPrefixes[kind] {
+		if name == prefix || strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newResource returns the resource if it has the labels or the name of the resources of sd-local
+func newResource(runtime string, sudo bool, kind, name, session, pid string) (Resource, bool) {
+	if session == "" && !isResourceName(kind, name) {
+		return Resource{}, false
+	}
+
+	r := Resource{
+		Resource: state.Resource{Runtime: runtime, Sudo: sudo, Kind: kind, Name: name},
+		Session:  session,
+	}
+	r.PID, _ = strconv.Atoi(pid)
+
+	return r, true
+}
+
+// runtimeCommand runs the command of the runtime and returns its output
+func runtimeCommand(runtime string, sudo bool, args ...string) (string, error) {
+	name := runtimeCommands[runtime]
+	if sudo {
+		args = append([]string{name}, args...)
+		name = "sudo"
+	}
+
+	cmd := execCommand(name, args...)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, firstLine(msg))
+		}
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// listFormat returns the format to print the name and the labels of sd-local, which differs between docker and podman
+func listFormat(runtime, nameField string) string {
+	label := `{{.Label "%s"}}`
+	if runtime == PodmanRuntime {
+		label = `{{index .Labels "%s"}}`
+	}
+
+	return strings.Join([]string{
+		"{{" + nameField + "}}",
+		fmt.Sprintf(label, state.SessionLabel),
+		fmt.Sprintf(label, state.PIDLabel),
+	}, "\t")
+}
+
+func listCommandResources(runtime string, sudo bool) ([]Resource, error) {
+	lists := []struct {
+		kind string
+		args []string
+	}{
+		{state.ContainerKind, []string{"ps", "--all", "--format", listFormat(runtime, ".Names")}},
+		{state.NetworkKind, []string{"network", "ls", "--format", listFormat(runtime, ".Name")}},
+		{state.VolumeKind, []string{"volume", "ls", "--format", listFormat(runtime, ".Name")}},
+	}
+
+	resources := []Resource{}
+	for _, l := range lists {
+		out, err := runtimeCommand(runtime, sudo, l.args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %ss: %v", l.kind, err)
+		}
+
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Split(strings.TrimSpace(line), "\t")
+			if fields[0] == "" {
+				continue
+			}
+			for len(fields) < 3 {
+				fields = append(fields, "")
+			}
+			// podman prints "<no value>" for the missing labels
+			for i := 1; i < 3; i++ {
+				if fields[i] == "<no value>" {
+					fields[i] = ""
+				}
+			}
+
+			if r, ok := newResource(runtime, sudo, l.kind, fields[0], fields[1], fields[2]); ok {
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// newDockerAPIClient returns the client of the Docker Engine API to list and remove the resources
+func newDockerAPIClient() (*dockerAPI, error) {
+	socket, err := dockerSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dockerAPI{client: newUnixSocketClient(socket)}, nil
+}
+
+func listDockerAPIResources() ([]Resource, error) {
+	d, err := newDockerAPIClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []struct {
+		Names  []string          `json:"Names"`
+		Labels map[string]string `json:"Labels"`
+	}
+	if err := d.call(http.MethodGet, "/containers/json", url.Values{"all": {"1"}}, nil, &containers); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	var networks []struct {
+		Name   string            `json:"Name"`
+		Labels map[string]string `json:"Labels"`
+	}
+	if err := d.call(http.MethodGet, "/networks", nil, nil, &networks); err != nil {
+		return nil, fmt.Errorf("failed to list networks: %v", err)
+	}
+
+	var volumes struct {
+		Volumes []struct {
+			Name   string            `json:"Name"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Volumes"`
+	}
+	if err := d.call(http.MethodGet, "/volumes", nil, nil, &volumes); err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %v", err)
+	}
+
+	resources := []Resource{}
+	add := func(kind, name string, labels map[string]string) {
+		if r, ok := newResource(DockerAPIRuntime, false, kind, name, labels[state.SessionLabel], labels[state.PIDLabel]); ok {
+			resources = append(resources, r)
+		}
+	}
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			add(state.ContainerKind, strings.TrimPrefix(c.Names[0], "/"), c.Labels)
+		}
+	}
+	for _, n := range networks {
+		add(state.NetworkKind, n.Name, n.Labels)
+	}
+	for _, v := range volumes.Volumes {
+		add(state.VolumeKind, v.Name, v.Labels)
+	}
+
+	return resources, nil
+}
+
+// ListResources returns the volumes, the containers and the networks which sd-local created.
+// They are found by the labels, or by the names for the ones created by the older sd-local.
+func ListResources(runtime string, sudo bool) ([]Resource, error) {
+	if err := ValidateRuntime(runtime); err != nil {
+		return nil, err
+	}
+
+	switch runtime {
+	case DockerAPIRuntime:
+		return listDockerAPIResources()
+	case PodmanRuntime:
+		// podman does not use sudo
+		return listCommandResources(PodmanRuntime, false)
+	case "":
+		return listCommandResources(DockerRuntime, sudo)
+	default:
+		return listCommandResources(runtime, sudo)
+	}
+}
+
+// RemoveResource removes the volume, the container or the network with the runtime which created it
+func RemoveResource(r state.Resource) error {
+	runtime := r.Runtime
+	if runtime == "" {
+		runtime = DockerRuntime
+	}
+
+	if runtime == DockerAPIRuntime {
+		d, err := newDockerAPIClient()
+		if err != nil {
+			return err
+		}
+
+		switch r.Kind {
+		case state.ContainerKind:
+			return d.call(http.MethodDelete, "/containers/"+r.Name, url.Values{"force": {"1"}, "v": {"1"}}, nil, nil)
+		case state.NetworkKind:
+			return d.call(http.MethodDelete, "/networks/"+r.Name, nil, nil, nil)
+		case state.VolumeKind:
+			return d.call(http.MethodDelete, "/volumes/"+r.Name, url.Values{"force": {"1"}}, nil, nil)
+		}
+		return fmt.Errorf("unsupported resource `%s`", r.Kind)
+	}
+
+	var args []string
+	switch r.Kind {
+	case state.ContainerKind:
+		args = []string{"container", "rm", "--force", "--volumes", r.Name}
+	case state.NetworkKind:
+		args = []string{"network", "rm", r.Name}
+	case state.VolumeKind:
+		args = []string{"volume", "rm", "--force", r.Name}
+	default:
+		return fmt.Errorf("unsupported resource `%s`", r.Kind)
+	}
+
+	_, err := runtimeCommand(runtime, r.Sudo && runtime == DockerRuntime, args...)
+	return err
+}
+
+// removalRank returns the order to remove the resource. The containers are removed before the networks and
+// the volumes which they use, and the hab volumes before the bin volumes since they are mounted inside.
+func removalRank(r state.Resource) int {
+	switch {
+	case r.Kind == state.ContainerKind:
+		return 0
+	case r.Kind == state.NetworkKind:
+		return 1
+	case r.Kind == state.VolumeKind && (r.Name == habVolumePrefix || strings.HasPrefix(r.Name, habVolumePrefix+"-")):
+		return 2
+	case r.Kind == state.VolumeKind:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// SortResources sorts the resources in the order to remove them
+func SortResources(resources []state.Resource) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		return removalRank(resources[i]) < removalRank(resources[j])
+	})
+}
@@ -0,0 +1,187 @@
+package launch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListResources(t *testing.T) {
+	dockerFormat := func(name string) string {
+		return fmt.Sprintf(`{{%s}}	{{.Label "%s"}}	{{.Label "%s"}}`, name, state.SessionLabel, state.PIDLabel)
+	}
+	podmanFormat := func(name string) string {
+		return fmt.Sprintf(`{{%s}}	{{index .Labels "%s"}}	{{index .Labels "%s"}}`, name, state.SessionLabel, state.PIDLabel)
+	}
+
+	resource := func(runtime string, sudo bool, kind, name, session string, pid int) Resource {
+		return Resource{
+			Resource: state.Resource{Runtime: runtime, Sudo: sudo, Kind: kind, Name: name},
+			Session:  session,
+			PID:      pid,
+		}
+	}
+
+	t.Run("success with docker", func(t *testing.T) {
+		commands := fakeDoctorExec(t, map[string]doctorResult{
+			"sudo docker ps --all --format " + dockerFormat(".Names"):  {out: "sd-local-dind\t\t\nclever_build\tsession1\t123\nweb\t\t\n"},
+			"sudo docker network ls --format " + dockerFormat(".Name"): {out: "bridge\t\t\nsd-local-dind-bridge-job\tsession1\t123\n"},
+			"sudo docker volume ls --format " + dockerFormat(".Name"):  {out: "SD_LAUNCH_BIN\t\t\nSD_LAUNCH_CACHE\t\t\nSD_DIND_CERT-job\tsession1\tbroken\n"},
+		})
+
+		resources, err := ListResources(DockerRuntime, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []Resource{
+			resource(DockerRuntime, true, state.ContainerKind, "sd-local-dind", "", 0),
+			resource(DockerRuntime, true, state.ContainerKind, "clever_build", "session1", 123),
+			resource(DockerRuntime, true, state.NetworkKind, "sd-local-dind-bridge-job", "session1", 123),
+			resource(DockerRuntime, true, state.VolumeKind, "SD_LAUNCH_BIN", "", 0),
+			resource(DockerRuntime, true, state.VolumeKind, "SD_DIND_CERT-job", "session1", 0),
+		}, resources)
+		assert.Len(t, *commands, 3)
+	})
+
+	t.Run("success with podman", func(t *testing.T) {
+		commands := fakeDoctorExec(t, map[string]doctorResult{
+			"podman ps --all --format " + podmanFormat(".Names"): {out: "build\tsession2\t456\n"},
+			"podman volume ls --format " + podmanFormat(".Name"): {out: "SD_LAUNCH_HAB\t<no value>\t<no value>\n"},
+		})
+
+		// podman does not use sudo
+		resources, err := ListResources(PodmanRuntime, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []Resource{
+			resource(PodmanRuntime, false, state.ContainerKind, "build", "session2", 456),
+			resource(PodmanRuntime, false, state.VolumeKind, "SD_LAUNCH_HAB", "", 0),
+		}, resources)
+		assert.Equal(t, "podman network ls --format "+podmanFormat(".Name"), (*commands)[1])
+	})
+
+	t.Run("success with docker-api", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		f.lists = map[string]string{
+			"/containers/json": fmt.Sprintf(`[{"Names": ["/build"], "Labels": {"%s": "session3", "%s": "789"}}, {"Names": ["/web"], "Labels": {}}]`, state.SessionLabel, state.PIDLabel),
+			"/networks":        `[{"Name": "sd-local-dind-bridge", "Labels": null}, {"Name": "host"}]`,
+			"/volumes":         `{"Volumes": [{"Name": "SD_DIND_SHARE", "Labels": null}]}`,
+		}
+		t.Setenv("DOCKER_HOST", "unix://"+f.socketPath)
+
+		resources, err := ListResources(DockerAPIRuntime, true)
+		assert.Nil(t, err)
+		assert.Equal(t, []Resource{
+			resource(DockerAPIRuntime, false, state.ContainerKind, "build", "session3", 789),
+			resource(DockerAPIRuntime, false, state.NetworkKind, "sd-local-dind-bridge", "", 0),
+			resource(DockerAPIRuntime, false, state.VolumeKind, "SD_DIND_SHARE", "", 0),
+		}, resources)
+		assert.Equal(t, []string{"GET /containers/json?all=1", "GET /networks", "GET /volumes"}, f.requests)
+	})
+
+	t.Run("failure by listing", func(t *testing.T) {
+		fakeDoctorExec(t, map[string]doctorResult{
+			"docker ps --all --format " + dockerFormat(".Names"): {code: 1},
+		})
+
+		_, err := ListResources(DockerRuntime, false)
+		assert.EqualError(t, err, "failed to list containers: exit status 1")
+	})
+
+	t.Run("failure with docker-api", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		f.lists = map[string]string{"/containers/json": "[]"}
+		f.failPath = "/networks"
+		t.Setenv("DOCKER_HOST", "unix://"+f.socketPath)
+
+		_, err := ListResources(DockerAPIRuntime, false)
+		assert.EqualError(t, err, "failed to list networks: GET /networks: fake error")
+	})
+
+	t.Run("failure by invalid runtime", func(t *testing.T) {
+		_, err := ListResources("invalid", false)
+		assert.EqualError(t, err, "unsupported container runtime `invalid`, must be one of [docker podman docker-api]")
+	})
+}
+
+func TestRemoveResource(t *testing.T) {
+	t.Run("success with the commands", func(t *testing.T) {
+		commands := fakeDoctorExec(t, map[string]doctorResult{})
+
+		assert.Nil(t, RemoveResource(state.Resource{Kind: state.ContainerKind, Name: "sd-local-dind"}))
+		assert.Nil(t, RemoveResource(state.Resource{Runtime: DockerRuntime, Sudo: true, Kind: state.NetworkKind, Name: "sd-local-dind-bridge"}))
+		assert.Nil(t, RemoveResource(state.Resource{Runtime: PodmanRuntime, Sudo: true, Kind: state.VolumeKind, Name: "SD_LAUNCH_BIN"}))
+		assert.Equal(t, []string{
+			"docker container rm --force --volumes sd-local-dind",
+			"sudo docker network rm sd-local-dind-bridge",
+			"podman volume rm --force SD_LAUNCH_BIN",
+		}, *commands)
+	})
+
+	t.Run("success with docker-api", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		t.Setenv("DOCKER_HOST", "unix://"+f.socketPath)
+
+		for _, kind := range []string{state.ContainerKind, state.NetworkKind, state.VolumeKind} {
+			assert.Nil(t, RemoveResource(state.Resource{Runtime: DockerAPIRuntime, Kind: kind, Name: "name"}))
+		}
+		assert.Equal(t, []string{
+			"DELETE /containers/name?force=1&v=1",
+			"DELETE /networks/name",
+			"DELETE /volumes/name?force=1",
+		}, f.requests)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		fakeDoctorExec(t, map[string]doctorResult{
+			"docker volume rm --force SD_LAUNCH_BIN": {code: 1},
+		})
+
+		assert.EqualError(t, RemoveResource(state.Resource{Kind: state.VolumeKind, Name: "SD_LAUNCH_BIN"}), "exit status 1")
+		assert.EqualError(t, RemoveResource(state.Resource{Kind: state.CloneKind, Name: "/repo/1"}), "unsupported resource `clone`")
+	})
+}
+
+func TestSortResources(t *testing.T) {
+	resources := []state.Resource{
+		{Kind: state.CloneKind, Name: "/repo/1"},
+		{Kind: state.VolumeKind, Name: "SD_LAUNCH_BIN-job"},
+		{Kind: state.VolumeKind, Name: "SD_LAUNCH_HAB-job"},
+		{Kind: state.NetworkKind, Name: "sd-local-dind-bridge"},
+		{Kind: state.VolumeKind, Name: "SD_DIND_CERT"},
+		{Kind: state.ContainerKind, Name: "sd-local-dind"},
+	}
+
+	SortResources(resources)
+	assert.Equal(t, []state.Resource{
+		{Kind: state.ContainerKind, Name: "sd-local-dind"},
+		{Kind: state.NetworkKind, Name: "sd-local-dind-bridge"},
+		{Kind: state.VolumeKind, Name: "SD_LAUNCH_HAB-job"},
+		{Kind: state.VolumeKind, Name: "SD_LAUNCH_BIN-job"},
+		{Kind: state.VolumeKind, Name: "SD_DIND_CERT"},
+		{Kind: state.CloneKind, Name: "/repo/1"},
+	}, resources)
+}
+
+func TestResources(t *testing.T) {
+	option := Option{Runtime: PodmanRuntime, UseSudo: true, BuildName: "main/job"}
+	assert.Equal(t, []state.Resource{
+		{Runtime: PodmanRuntime, Kind: state.VolumeKind, Name: "SD_LAUNCH_BIN-main-job"},
+		{Runtime: PodmanRuntime, Kind: state.VolumeKind, Name: "SD_LAUNCH_HAB-main-job"},
+	}, Resources(option))
+
+	option = Option{UseSudo: true}
+	option.Job.Annotations = map[string]interface{}{"screwdriver.cd/dockerEnabled": true}
+	assert.Equal(t, []state.Resource{
+		{Runtime: DockerRuntime, Sudo: true, Kind: state.VolumeKind, Name: "SD_LAUNCH_BIN"},
+		{Runtime: DockerRuntime, Sudo: true, Kind: state.VolumeKind, Name: "SD_LAUNCH_HAB"},
+		{Runtime: DockerRuntime, Sudo: true, Kind: state.ContainerKind, Name: "sd-local-dind"},
+		{Runtime: DockerRuntime, Sudo: true, Kind: state.NetworkKind, Name: "sd-local-dind-bridge"},
+		{Runtime: DockerRuntime, Sudo: true, Kind: state.VolumeKind, Name: "SD_DIND_CERT"},
+		{Runtime: DockerRuntime, Sudo: true, Kind: state.VolumeKind, Name: "SD_DIND_SHARE"},
+	}, Resources(option))
+}
+
+func TestLabelArgs(t *testing.T) {
+	assert.Empty(t, labelArgs(nil))
+	assert.Equal(t, []string{"--label", "a=1", "--label", "b=2"}, labelArgs(map[string]string{"b": "2", "a": "1"}))
+}
@@ -46,6 +46,7 @@ type docker struct {
 	buildUser         string
 	noImagePull       bool
 	dind              DinD
+	labels            map[string]string
 }
 
 type signalFunc func(*os.Process, os.Signal) error
@@ -74,10 +75,10 @@ const (
 	orgRepo = "sd-local/local-build"
 )
 
-func newDocker(setupImage, setupImageVer string, useSudo bool, interactiveMode bool, sdUtilsPath string, socketPath string, flagVerbose bool, localVolumes []string, buildUser string, noImagePull bool, dindEnabled bool) runner {
+func newDocker(setupImage, setupImageVer string, useSudo bool, interactiveMode bool, sdUtilsPath string, socketPath string, flagVerbose bool, localVolumes []string, buildUser string, noImagePull bool, dindEnabled bool, buildName string, labels map[string]string) runner {
 	return &docker{
-		volume:            "SD_LAUNCH_BIN",
-		habVolume:         "SD_LAUNCH_HAB",
+		volume:            resourceName(binVolumePrefix, buildName),
+		habVolume:         resourceName(habVolumePrefix, buildName),
 		setupImage:        setupImage,
 		setupImageVersion: setupImageVer,
 		useSudo:           useSudo,
@@ -93,16 +94,24 @@ func newDocker(setupImage, setupImageVer string, useSudo bool, interactiveMode b
 		noImagePull:       noImagePull,
 		dind: DinD{
 			enabled:         dindEnabled,
-			volume:          "SD_DIND_CERT",
-			shareVolumeName: "SD_DIND_SHARE",
+			volume:          resourceName(dindVolumePrefix, buildName),
+			shareVolumeName: resourceName(dindShareVolumePrefix, buildName),
 			shareVolumePath: "/opt/sd_dind_share",
-			container:       "sd-local-dind",
-			network:         "sd-local-dind-bridge",
+			container:       resourceName(dindContainerPrefix, buildName),
+			network:         resourceName(dindNetworkPrefix, buildName),
 			image:           "docker:23.0.1-dind-rootless",
 		},
+		labels: labels,
 	}
 }
 
+// createVolume creates the volume with the labels. It is not an error if the volume exists.
+func (d *docker) createVolume(name string) error {
+	args := append(append([]string{"volume", "create"}, labelArgs(d.labels)...), name)
+	_, err := d.execDockerCommand(args...)
+	return err
+}
+
 func (d *docker) setupBin() error {
 	mount := fmt.Sprintf("%s:/opt/sd/", d.volume)
 	habMount := fmt.Sprintf("%s:/hab", d.habVolume)
@@ -116,11 +125,18 @@ func (d *docker) setupBin() error {
 	}
 
 	// The mechanism for population is that VOLUMEs were declared in the image, so they copy what was in their layer to
-	// the mounted location on first mount of non-existing volumes
-	// NOTE: docker allows copying to first-time mounted as well, but both docker and podman copy to non-existing ones.
-	//       therefore, volumes are not pre-created, but created on first mention by the image that populates them
-	//       and then used by subsequent images that then use their content.
-	_, err := d.execDockerCommand("container", "run", "--rm", "--pull", "never", "-v", mount, "-v", habMount, "--entrypoint", "/bin/echo", image, "set up bin")
+	// the mounted location on first mount of empty volumes.
+	// NOTE: docker copies to the volumes which are created but not mounted yet, so the volumes are created
+	//       beforehand to be labeled, and then populated on first mention by the image.
+	for _, v := range []string{d.volume, d.habVolume} {
+		if err := d.createVolume(v); err != nil {
+			return fmt.Errorf("failed to create volume %s: %v", v, err)
+		}
+	}
+
+	args := append([]string{"container", "run", "--rm", "--pull", "never"}, labelArgs(d.labels)...)
+	args = append(args, "-v", mount, "-v", habMount, "--entrypoint", "/bin/echo", image, "set up bin")
+	_, err := d.execDockerCommand(args...)
 	if err != nil {
 		return fmt.Errorf("failed to prepare build scripts: %v", err)
 	}
@@ -128,9 +144,9 @@ func (d *docker) setupBin() error {
 	return nil
 }
 
-func (d *docker) setupInteractiveMode(buildEntry *buildEntry) error {
-	sdUtilsPath := d.sdUtilsPath
-
+// setupInteractiveMode writes the sdrun helper and the step scripts into sdUtilsPath,
+// then replaces the steps of buildEntry with the one to export the build environment.
+func setupInteractiveMode(sdUtilsPath string, buildEntry *buildEntry) error {
 	if err := osMkdirAll(fmt.Sprintf("%s/bin", sdUtilsPath), 0777); err != nil {
 		return err
 	}
@@ -159,9 +175,19 @@ func (d *docker) setupInteractiveMode(buildEntry *buildEntry) error {
           echo "  --help     help for sdrun"
           echo "  --list     show all steps"
           echo "  --all      run all steps"
-        elif [ "$1" = "--list" ]; then
+          return 0
+        fi
+        if [ "$1" = "--list" ]; then
           echo $(cat "${step_dir}/.steps")
-        elif [ "$1" = "--all" ]; then
+          return 0
+        fi
+        if [ -n "${SD_LOCAL_TOKEN_URL}" ]; then
+          sd_token="$(curl -sf -H "Authorization: Bearer ${SD_LOCAL_TOKEN_KEY}" "${SD_LOCAL_TOKEN_URL}" 2>/dev/null || wget -qO- --header "Authorization: Bearer ${SD_LOCAL_TOKEN_KEY}" "${SD_LOCAL_TOKEN_URL}" 2>/dev/null)"
+          if [ -n "${sd_token}" ]; then
+            export SD_TOKEN="${sd_token}"
+          fi
+        fi
+        if [ "$1" = "--all" ]; then
           steps=$(cat "${step_dir}/.steps")
           for step_name in $steps; do
             tail -n +2 "${step_dir}/${step_name}" | sed "s/^/${step_name}: /"
@@ -262,6 +288,7 @@ func (d *docker) runBuild(buildEntry buildEntry) error {
 	habVol := fmt.Sprintf("%s:%s", d.habVolume, "/opt/sd/hab")
 
 	dockerVolumes := append(d.localVolumes, srcVol, artVol, binVol, habVol, fmt.Sprintf("%s:/tmp/auth.sock:rw", d.socketPath))
+	dockerVolumes = append(dockerVolumes, metaVolumes(buildEntry)...)
 	for _, v := range dockerVolumes {
 		dockerCommandOptions = append(dockerCommandOptions, "-v", v)
 	}
@@ -274,8 +301,12 @@ func (d *docker) runBuild(buildEntry buildEntry) error {
 		dockerCommandOptions = append(dockerCommandOptions, "--privileged")
 	}
 
+	if GetEnv(environment, "SD_LOCAL_TOKEN_URL") != "" {
+		dockerCommandOptions = append(dockerCommandOptions, "--add-host", TokenHost+":host-gateway")
+	}
+
 	if d.interactiveMode {
-		if err := d.setupInteractiveMode(&buildEntry); err != nil {
+		if err := setupInteractiveMode(d.sdUtilsPath, &buildEntry); err != nil {
 			return err
 		}
 
@@ -290,6 +321,8 @@ func (d *docker) runBuild(buildEntry buildEntry) error {
 		dockerCommandOptions = append(dockerCommandOptions, fmt.Sprintf("-u%s", d.buildUser))
 	}
 
+	dockerCommandOptions = append(dockerCommandOptions, labelArgs(d.labels)...)
+
 	// Disable automatic image pulling
 	// Build image is explicitly pulled when the --no-image-pull option is not used
 	dockerCommandOptions = append(dockerCommandOptions, "--pull", "never")
@@ -353,7 +386,7 @@ func (d *docker) runBuild(buildEntry buildEntry) error {
 		dockerCommandOptions = append(dockerCommandOptions, launchCommands...)
 
 		if _, err := d.execDockerCommand(append(dockerCommandArgs, dockerCommandOptions...)...); err != nil {
-			return fmt.Errorf("failed to run build container: %v", err)
+			return fmt.Errorf("failed to run build container: %w", stepError(err))
 		}
 	}
 
@@ -369,24 +402,30 @@ func (d *docker) runDinD() error {
 		}
 	}
 
-	if _, err := d.execDockerCommand([]string{"network", "create", d.dind.network}...); err != nil {
+	if _, err := d.execDockerCommand(append(append([]string{"network", "create"}, labelArgs(d.labels)...), d.dind.network)...); err != nil {
 		return fmt.Errorf("failed to create network: %v", err)
 	}
 
+	for _, v := range []string{d.dind.volume, d.dind.shareVolumeName} {
+		if err := d.createVolume(v); err != nil {
+			return fmt.Errorf("failed to create volume %s: %v", v, err)
+		}
+	}
+
 	dockerCommandArgs := []string{"container", "run"}
 	dockerCommandOptions := []string{
 		"--rm",
 		"--privileged",
 		"--pull", "never",
-		"--name", "sd-local-dind",
+		"--name", d.dind.container,
 		"-d",
 		"--network", d.dind.network,
 		"--network-alias", "docker",
 		"-e", "DOCKER_TLS_CERTDIR=/certs",
 		"-v", fmt.Sprintf("%s:/certs/client", d.dind.volume),
 		"-v", fmt.Sprintf("%s:/opt/sd_dind_share", d.dind.shareVolumeName),
-		d.dind.image,
 	}
+	dockerCommandOptions = append(append(dockerCommandOptions, labelArgs(d.labels)...), d.dind.image)
 
 	if _, err := d.execDockerCommand(append(dockerCommandArgs, dockerCommandOptions...)...); err != nil {
 		return fmt.Errorf("failed to run dind container: %v", err)
@@ -427,7 +466,7 @@ func (d *docker) execDockerCommand(args ...string) (string, error) {
 		logrus.Infof("%s", out)
 	}
 	if err != nil {
-		io.Copy(os.Stderr, buf)
+		io.Copy(Stderr, buf)
 		return strings.TrimRight(string(out), "\n"), err
 	}
 	return strings.TrimRight(string(out), "\n"), nil
@@ -440,10 +479,11 @@ func (d *docker) kill(sig os.Signal) {
 	for _, v := range d.commands {
 		var err error
 		d.mutex.Lock()
-		if v.ProcessState != nil {
+		finished := v.ProcessState != nil || v.Process == nil
+		d.mutex.Unlock()
+		if finished {
 			continue
 		}
-		d.mutex.Unlock()
 		if d.useSudo {
 			cmd := execCommand("sudo", "kill", fmt.Sprintf("-%v", signum(sig)), strconv.Itoa(v.Process.Pid))
 			err = cmd.Run()
@@ -458,7 +498,7 @@ func (d *docker) kill(sig os.Signal) {
 		}
 	}
 
-	err := d.waitForProcess(killedCmds)
+	err := waitForProcess(d.mutex, killedCmds)
 	if err != nil {
 		logrus.Warn(err)
 	}
@@ -509,7 +549,7 @@ func (d *docker) clean() {
 	}
 }
 
-func (d *docker) waitForProcess(cmds []*exec.Cmd) error {
+func waitForProcess(mutex *sync.Mutex, cmds []*exec.Cmd) error {
 	// Reducing this value will make the test faster.
 	// However, be sure to specify a time when you can sufficiently confirm that the process is dead.
 	t := time.NewTicker(1 * time.Second)
@@ -523,11 +563,11 @@ func (d *docker) waitForProcess(cmds []*exec.Cmd) error {
 			finish := true
 
 			for _, v := range cmds {
-				d.mutex.Lock()
+				mutex.Lock()
 				if v.ProcessState == nil {
 					finish = false
 				}
-				d.mutex.Unlock()
+				mutex.Unlock()
 			}
 			if finish {
 				return nil
@@ -540,19 +580,16 @@ func (d *docker) waitForProcess(cmds []*exec.Cmd) error {
 	}
 }
 
+// signum returns the number of the signal sent to the build.
+// It is SIGTERM, the default of kill, for the signal which is not a valid syscall.Signal.
 func signum(sig os.Signal) int {
 	const numSig = 65
 
-	switch sig := sig.(type) {
-	case syscall.Signal:
-		i := int(sig)
-		if i < 0 || i >= numSig {
-			return -1
-		}
-		return i
-	default:
-		return -1
+	if sig, ok := sig.(syscall.Signal); ok && sig > 0 && int(sig) < numSig {
+		return int(sig)
 	}
+
+	return int(syscall.SIGTERM)
 }
 
 // GetEnv returns the newest value corresponding to the key
@@ -565,3 +602,7 @@ func GetEnv(en []map[string]string, key string) string {
 	}
 	return s
 }
+
+func (d *docker) skipImagePull() {
+	d.noImagePull = true
+}
@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
@@ -109,7 +110,7 @@ func TestNewDocker(t *testing.T) {
 			},
 		}
 
-		d := newDocker("launcher", "latest", false, false, ".sd-utils", "/auth.sock", false, []string{"path:path"}, "jithin", false, true)
+		d := newDocker("launcher", "latest", false, false, ".sd-utils", "/auth.sock", false, []string{"path:path"}, "jithin", false, true, "", nil)
 
 		assert.Equal(t, expected, d)
 	})
@@ -145,6 +146,26 @@ func TestSetupBin(t *testing.T) {
 			assert.Equal(t, tt.expectError, err)
 		})
 	}
+
+	t.Run("success with labels", func(t *testing.T) {
+		d := &docker{
+			volume:            "SD_LAUNCH_BIN",
+			habVolume:         "SD_LAUNCH_HAB",
+			setupImage:        "launcher",
+			setupImageVersion: "latest",
+			noImagePull:       true,
+			labels:            map[string]string{"b": "2", "a": "1"},
+		}
+		c := newFakeExecCommand("SUCCESS_SETUP_BIN")
+		execCommand = c.execCmd
+
+		assert.Nil(t, d.setupBin())
+		assert.Equal(t, []string{
+			"docker volume create --label a=1 --label b=2 SD_LAUNCH_BIN",
+			"docker volume create --label a=1 --label b=2 SD_LAUNCH_HAB",
+			"docker container run --rm --pull never --label a=1 --label b=2 -v SD_LAUNCH_BIN:/opt/sd/ -v SD_LAUNCH_HAB:/hab --entrypoint /bin/echo launcher:latest set up bin",
+		}, c.commands)
+	})
 }
 
 func TestSetupBinWithSudo(t *testing.T) {
@@ -220,6 +241,20 @@ func TestRunBuild(t *testing.T) {
 			newBuildEntry(func(b *buildEntry) {
 				b.MemoryLimit = "2GB"
 			})},
+		{"success with meta path", "SUCCESS_RUN_BUILD", nil,
+			[]string{
+				"docker pull node:12",
+				fmt.Sprintf("docker container run --rm --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v %s:/opt/sd -v %s:/opt/sd/hab -v %s -v /tmp/meta/:/sd/meta --pull never node:12 /opt/sd/local_run.sh ", d.volume, d.habVolume, sshSocket)},
+			newBuildEntry(func(b *buildEntry) {
+				b.MetaPath = "/tmp/meta"
+			})},
+		{"success with token server", "SUCCESS_RUN_BUILD", nil,
+			[]string{
+				"docker pull node:12",
+				fmt.Sprintf("docker container run --rm --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v %s:/opt/sd -v %s:/opt/sd/hab -v %s --add-host sd-local.host:host-gateway --pull never node:12 /opt/sd/local_run.sh ", d.volume, d.habVolume, sshSocket)},
+			newBuildEntry(func(b *buildEntry) {
+				b.Environment = append(b.Environment, map[string]string{"SD_LOCAL_TOKEN_URL": "http://sd-local.host:8765/token"})
+			})},
 		{"failure build run", "FAIL_BUILD_CONTAINER_RUN", fmt.Errorf("failed to run build container: exit status 1"), []string{}, newBuildEntry()},
 		{"failure build image pull", "FAIL_BUILD_IMAGE_PULL", fmt.Errorf("failed to pull user image exit status 1"), []string{}, newBuildEntry()},
 	}
@@ -273,6 +308,8 @@ func TestRunBuildWithDind(t *testing.T) {
 			[]string{
 				"docker pull docker:23.0.1-dind-rootless",
 				"docker network create sd-local-dind-bridge",
+				"docker volume create SD_DIND_CERT",
+				"docker volume create SD_DIND_SHARE",
 				"docker container run --rm --privileged --pull never --name sd-local-dind -d --network sd-local-dind-bridge --network-alias docker -e DOCKER_TLS_CERTDIR=/certs -v SD_DIND_CERT:/certs/client -v SD_DIND_SHARE:/opt/sd_dind_share docker:23.0.1-dind-rootless",
 				"docker pull node:12",
 				fmt.Sprintf("docker container run --network %s -e DOCKER_TLS_CERTDIR=/certs -e DOCKER_HOST=tcp://docker:2376 -e DOCKER_TLS_VERIFY=1 -e DOCKER_CERT_PATH=/certs/client -e SD_DIND_SHARE_PATH=%s -v %s:/certs/client:ro -v %s:%s --rm --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v %s:/opt/sd -v %s:/opt/sd/hab -v %s --pull never node:12 /opt/sd/local_run.sh ", d.dind.network, d.dind.shareVolumePath, d.dind.volume, d.dind.shareVolumeName, d.dind.shareVolumePath, d.volume, d.habVolume, sshSocket)},
@@ -330,6 +367,8 @@ func TestRunBuildWithNoImagePull(t *testing.T) {
 		{"success with no image pull", "SUCCESS_RUN_BUILD", nil,
 			[]string{
 				"docker network create sd-local-dind-bridge",
+				"docker volume create SD_DIND_CERT",
+				"docker volume create SD_DIND_SHARE",
 				"docker container run --rm --privileged --pull never --name sd-local-dind -d --network sd-local-dind-bridge --network-alias docker -e DOCKER_TLS_CERTDIR=/certs -v SD_DIND_CERT:/certs/client -v SD_DIND_SHARE:/opt/sd_dind_share docker:23.0.1-dind-rootless",
 				fmt.Sprintf("docker container run --network %s -e DOCKER_TLS_CERTDIR=/certs -e DOCKER_HOST=tcp://docker:2376 -e DOCKER_TLS_VERIFY=1 -e DOCKER_CERT_PATH=/certs/client -e SD_DIND_SHARE_PATH=%s -v %s:/certs/client:ro -v %s:%s --rm --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v %s:/opt/sd -v %s:/opt/sd/hab -v %s --pull never node:12 /opt/sd/local_run.sh ", d.dind.network, d.dind.shareVolumePath, d.dind.volume, d.dind.shareVolumeName, d.dind.shareVolumePath, d.volume, d.habVolume, sshSocket)},
 			newBuildEntry(func(b *buildEntry) {
@@ -505,6 +544,24 @@ func TestRunBuildWithInteractiveMode(t *testing.T) {
 	}
 }
 
+func TestSetupInteractiveMode(t *testing.T) {
+	sdUtilsPath := t.TempDir()
+	b := newBuildEntry(func(b *buildEntry) {
+		b.Steps = []screwdriver.Step{{Name: "step1", Command: "echo step1"}}
+	})
+
+	assert.Nil(t, setupInteractiveMode(sdUtilsPath, &b))
+
+	sdrun, err := os.ReadFile(filepath.Join(sdUtilsPath, "bin", "sdrun"))
+	assert.Nil(t, err)
+	// The JWT is refreshed from the token server before the steps are run
+	assert.Contains(t, string(sdrun), `export SD_TOKEN="${sd_token}"`)
+
+	step, err := os.ReadFile(filepath.Join(sdUtilsPath, "steps", "step1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "#!/bin/sh -e\necho step1\n", string(step))
+}
+
 func TestDockerKill(t *testing.T) {
 	t.Run("success with no commands", func(t *testing.T) {
 		defer func() {
@@ -694,6 +751,31 @@ func TestDockerClean(t *testing.T) {
 	})
 }
 
+func TestExecCommandStderr(t *testing.T) {
+	defer func() {
+		execCommand = exec.Command
+		Stderr = os.Stderr
+	}()
+
+	d := newDocker("launcher", "latest", false, false, ".sd-utils", "/auth.sock", false, nil, "", false, false, "", nil).(*docker)
+	p := newPodman("launcher", "latest", false, ".sd-utils", "/auth.sock", false, nil, "", false, false, "", nil).(*podman)
+
+	for name, run := range map[string]func(args ...string) (string, error){
+		"docker": d.execDockerCommand,
+		"podman": p.execPodmanCommand,
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			Stderr = buf
+			execCommand = newFakeExecCommand("FAIL_WITH_STDERR").execCmd
+
+			_, err := run("volume", "create", "SD_LAUNCH_BIN")
+			assert.EqualError(t, err, "exit status 1")
+			assert.Equal(t, "Error: invalid token secret-token\n", buf.String())
+		})
+	}
+}
+
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -727,6 +809,9 @@ func TestHelperProcess(t *testing.T) {
 		os.Exit(1)
 	case "SUCCESS_SETUP_BIN":
 		os.Exit(0)
+	case "FAIL_WITH_STDERR":
+		fmt.Fprint(os.Stderr, "Error: invalid token secret-token\n")
+		os.Exit(1)
 	case "SUCCESS_SETUP_BIN_SUDO":
 		os.Exit(0)
 	case "SUCCESS_SETUP_BIN_INTERACT":
@@ -814,6 +899,16 @@ func TestHelperProcess(t *testing.T) {
 			os.Exit(0)
 		}
 		os.Exit(1)
+	case "FAIL_PODMAN_VOLUME_CREATE":
+		if subcmd == "volume" {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "FAIL_PODMAN_ATTACH":
+		if subcmd == "attach" {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	case "SUCCESS_TO_CLEAN":
 		os.Exit(0)
 	case "FAIL_TO_CLEAN":
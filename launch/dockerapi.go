@@ -0,0 +1,651 @@
+package launch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDockerSocket = "/var/run/docker.sock"
+	// dockerAPIBaseURL is a dummy host because every request is sent over the unix socket.
+	dockerAPIBaseURL = "http://docker"
+)
+
+var memoryLimitRegex = regexp.MustCompile(`^(\d+)([bkmgBKMG]?)[bB]?$`)
+
+// dockerAPI runs builds by talking to the Docker Engine API over the unix socket
+type dockerAPI struct {
+	client            *http.Client
+	volume            string
+	habVolume         string
+	setupImage        string
+	setupImageVersion string
+	interactiveMode   bool
+	containers        []string
+	mutex             *sync.Mutex
+	flagVerbose       bool
+	socketPath        string
+	localVolumes      []string
+	buildUser         string
+	noImagePull       bool
+	output            io.Writer
+	dind              DinD
+	labels            map[string]string
+	// killed is true when the build is killed, so that its exit status is not taken for the one of a failed step
+	killed atomic.Bool
+}
+
+type dockerAPIError struct {
+	Message string `json:"message"`
+}
+
+type dockerPullProgress struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Progress       string `json:"progress"`
+	Error          string `json:"error"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+type dockerHostConfig struct {
+	Binds       []string `json:"Binds,omitempty"`
+	Memory      int64    `json:"Memory,omitempty"`
+	Privileged  bool     `json:"Privileged,omitempty"`
+	NetworkMode string   `json:"NetworkMode,omitempty"`
+	ExtraHosts  []string `json:"ExtraHosts,omitempty"`
+}
+
+type dockerEndpointConfig struct {
+	Aliases []string `json:"Aliases,omitempty"`
+}
+
+type dockerNetworkingConfig struct {
+	EndpointsConfig map[string]dockerEndpointConfig `json:"EndpointsConfig,omitempty"`
+}
+
+type dockerContainerConfig struct {
+	Image            string                  `json:"Image"`
+	Labels           map[string]string       `json:"Labels,omitempty"`
+	Entrypoint       []string                `json:"Entrypoint,omitempty"`
+	Cmd              []string                `json:"Cmd,omitempty"`
+	Env              []string                `json:"Env,omitempty"`
+	User             string                  `json:"User,omitempty"`
+	AttachStdout     bool                    `json:"AttachStdout"`
+	AttachStderr     bool                    `json:"AttachStderr"`
+	HostConfig       dockerHostConfig        `json:"HostConfig"`
+	NetworkingConfig *dockerNetworkingConfig `json:"NetworkingConfig,omitempty"`
+}
+
+// dockerCreateRequest is the body to create a volume or a network
+type dockerCreateRequest struct {
+	Name   string            `json:"Name"`
+	Labels map[string]string `json:"Labels,omitempty"`
+}
+
+type dockerContainerCreated struct {
+	ID string `json:"Id"`
+}
+
+type dockerContainerWait struct {
+	StatusCode int `json:"StatusCode"`
+	Error      *struct {
+		Message string `json:"Message"`
+	} `json:"Error"`
+}
+
+var _ runner = (*dockerAPI)(nil)
+
+// dockerSocketPath returns the path of the Docker Engine API socket, honoring DOCKER_HOST.
+func dockerSocketPath() (string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		return defaultDockerSocket, nil
+	}
+
+	u, err := url.Parse(host)
+	if err != nil || u.Scheme != "unix" {
+		return "", fmt.Errorf("DOCKER_HOST %q is not supported, only unix sockets can be used", host)
+	}
+
+	return u.Path, nil
+}
+
+func newUnixSocketClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+func newDockerAPI(setupImage, setupImageVer string, interactiveMode bool, socketPath string, flagVerbose bool, localVolumes []string, buildUser string, noImagePull bool, dindEnabled bool, buildName string, labels map[string]string) runner {
+	dockerSocket, err := dockerSocketPath()
+	if err != nil {
+		logrus.Warn(err)
+		dockerSocket = defaultDockerSocket
+	}
+
+	output := io.Discard
+	if flagVerbose {
+		output = os.Stdout
+	}
+
+	return &dockerAPI{
+		client:            newUnixSocketClient(dockerSocket),
+		volume:            resourceName(binVolumePrefix, buildName),
+		habVolume:         resourceName(habVolumePrefix, buildName),
+		setupImage:        setupImage,
+		setupImageVersion: setupImageVer,
+		interactiveMode:   interactiveMode,
+		containers:        make([]string, 0, 3),
+		mutex:             &sync.Mutex{},
+		flagVerbose:       flagVerbose,
+		socketPath:        socketPath,
+		localVolumes:      localVolumes,
+		buildUser:         buildUser,
+		noImagePull:       noImagePull,
+		output:            output,
+		dind: DinD{
+			enabled:         dindEnabled,
+			volume:          resourceName(dindVolumePrefix, buildName),
+			shareVolumeName: resourceName(dindShareVolumePrefix, buildName),
+			shareVolumePath: "/opt/sd_dind_share",
+			container:       resourceName(dindContainerPrefix, buildName),
+			network:         resourceName(dindNetworkPrefix, buildName),
+			image:           "docker:23.0.1-dind-rootless",
+		},
+		labels: labels,
+	}
+}
+
+// parseMemoryLimit converts the memory limit (e.g. 512m, 2g) into bytes
+func parseMemoryLimit(limit string) (int64, error) {
+	results := memoryLimitRegex.FindStringSubmatch(limit)
+	if len(results) == 0 {
+		return 0, fmt.Errorf("invalid memory limit %q", limit)
+	}
+
+	n, err := strconv.ParseInt(results[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit %q: %v", limit, err)
+	}
+
+	switch strings.ToLower(results[2]) {
+	case "k":
+		n *= 1 << 10
+	case "m":
+		n *= 1 << 20
+	case "g":
+		n *= 1 << 30
+	}
+
+	return n, nil
+}
+
+// splitImage splits the image reference into the repository and the tag for the pull API
+func splitImage(image string) (string, string) {
+	if strings.Contains(image, "@") {
+		return image, ""
+	}
+
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, "latest"
+	}
+
+	return image[:i], image[i+1:]
+}
+
+func (d *dockerAPI) request(method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	u := dockerAPIBaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if d.flagVerbose {
+		logrus.Infof("> %s %s", method, u)
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		apiErr := dockerAPIError{}
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+			return nil, fmt.Errorf("%s %s: StatusCode %d", method, path, res.StatusCode)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, path, apiErr.Message)
+	}
+
+	return res, nil
+}
+
+// call sends a request and decodes the JSON response into out if it is not nil
+func (d *dockerAPI) call(method, path string, query url.Values, body, out interface{}) error {
+	res, err := d.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (d *dockerAPI) pullImage(image string) error {
+	repository, tag := splitImage(image)
+	query := url.Values{"fromImage": {repository}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	res, err := d.request(http.MethodPost, "/images/create", query, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	for {
+		progress := dockerPullProgress{}
+		if err := decoder.Decode(&progress); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to parse pull progress: %v", err)
+		}
+
+		if progress.Error != "" {
+			return fmt.Errorf("%s", progress.Error)
+		}
+
+		switch {
+		case progress.ProgressDetail.Total > 0:
+			if d.flagVerbose {
+				logrus.Infof("%s: %s %d/%d", progress.ID, progress.Status, progress.ProgressDetail.Current, progress.ProgressDetail.Total)
+			}
+		case progress.ID != "":
+			logrus.Infof("%s: %s", progress.ID, progress.Status)
+		default:
+			logrus.Info(progress.Status)
+		}
+	}
+
+	return nil
+}
+
+func (d *dockerAPI) createVolume(name string) error {
+	return d.call(http.MethodPost, "/volumes/create", nil, dockerCreateRequest{Name: name, Labels: d.labels}, nil)
+}
+
+func (d *dockerAPI) createContainer(name string, config dockerContainerConfig) (string, error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+	config.Labels = d.labels
+
+	created := dockerContainerCreated{}
+	if err := d.call(http.MethodPost, "/containers/create", query, config, &created); err != nil {
+		return "", err
+	}
+
+	d.mutex.Lock()
+	d.containers = append(d.containers, created.ID)
+	d.mutex.Unlock()
+
+	return created.ID, nil
+}
+
+func (d *dockerAPI) removeContainer(id string) error {
+	err := d.call(http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}}, nil, nil)
+
+	d.mutex.Lock()
+	for i, c := range d.containers {
+		if c == id {
+			d.containers = append(d.containers[:i], d.containers[i+1:]...)
+			break
+		}
+	}
+	d.mutex.Unlock()
+
+	return err
+}
+
+// runContainer creates, attaches, starts and waits for the container, then returns its exit code.
+func (d *dockerAPI) runContainer(config dockerContainerConfig) (int, error) {
+	config.AttachStdout = true
+	config.AttachStderr = true
+
+	id, err := d.createContainer("", config)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := d.removeContainer(id); err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove container: %v", err))
+		}
+	}()
+
+	attach, err := d.request(http.MethodPost, "/containers/"+id+"/attach", url.Values{"stream": {"1"}, "stdout": {"1"}, "stderr": {"1"}}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to attach container: %v", err)
+	}
+	defer attach.Body.Close()
+
+	copied := make(chan error, 1)
+	go func() {
+		copied <- demuxStream(d.output, attach.Body)
+	}()
+
+	if err := d.call(http.MethodPost, "/containers/"+id+"/start", nil, nil, nil); err != nil {
+		return 0, fmt.Errorf("failed to start container: %v", err)
+	}
+
+	wait := dockerContainerWait{}
+	if err := d.call(http.MethodPost, "/containers/"+id+"/wait", nil, nil, &wait); err != nil {
+		return 0, fmt.Errorf("failed to wait container: %v", err)
+	}
+	if wait.Error != nil && wait.Error.Message != "" {
+		return 0, fmt.Errorf("failed to wait container: %s", wait.Error.Message)
+	}
+
+	if err := <-copied; err != nil {
+		logrus.Warn(fmt.Errorf("failed to read container output: %v", err))
+	}
+
+	return wait.StatusCode, nil
+}
+
+// demuxStream copies the multiplexed stdout/stderr stream of the attach API into w
+func demuxStream(w io.Writer, r io.Reader) error {
+	reader := bufio.NewReader(r)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *dockerAPI) setupBin() error {
+	image := fmt.Sprintf("%s:%s", d.setupImage, d.setupImageVersion)
+
+	if !d.noImagePull {
+		if err := d.pullImage(image); err != nil {
+			return fmt.Errorf("failed to pull launcher image: %v", err)
+		}
+	}
+
+	for _, v := range []string{d.volume, d.habVolume} {
+		if err := d.createVolume(v); err != nil {
+			return fmt.Errorf("failed to create volume %s: %v", v, err)
+		}
+	}
+
+	// The empty volumes are populated with the content of the launcher image on the first mount.
+	code, err := d.runContainer(dockerContainerConfig{
+		Image:      image,
+		Entrypoint: []string{"/bin/echo"},
+		Cmd:        []string{"set up bin"},
+		HostConfig: dockerHostConfig{
+			Binds: []string{fmt.Sprintf("%s:/opt/sd/", d.volume), fmt.Sprintf("%s:/hab", d.habVolume)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare build scripts: %v", err)
+	}
+	if code != 0 {
+		return fmt.Errorf("failed to prepare build scripts: exit status %d", code)
+	}
+
+	return nil
+}
+
+func (d *dockerAPI) runBuild(buildEntry buildEntry) error {
+	if d.interactiveMode {
+		return fmt.Errorf("interactive mode is not supported with the %s runtime", DockerAPIRuntime)
+	}
+
+	environment := buildEntry.Environment
+	containerArtDir := GetEnv(environment, "SD_ARTIFACTS_DIR")
+
+	config := dockerContainerConfig{
+		Image:      buildEntry.Image,
+		Entrypoint: []string{"/bin/sh"},
+		Env:        []string{"SSH_AUTH_SOCK=/tmp/auth.sock"},
+		User:       d.buildUser,
+		HostConfig: dockerHostConfig{
+			Privileged: buildEntry.UsePrivileged,
+		},
+	}
+
+	if d.dind.enabled {
+		if err := d.runDinD(); err != nil {
+			return fmt.Errorf("failed to prepare dind container: %v", err)
+		}
+
+		config.Env = append(config.Env,
+			"DOCKER_TLS_CERTDIR=/certs",
+			"DOCKER_HOST=tcp://docker:2376",
+			"DOCKER_TLS_VERIFY=1",
+			"DOCKER_CERT_PATH=/certs/client",
+			fmt.Sprintf("SD_DIND_SHARE_PATH=%s", d.dind.shareVolumePath))
+		config.HostConfig.NetworkMode = d.dind.network
+		config.HostConfig.Binds = append(config.HostConfig.Binds,
+			fmt.Sprintf("%s:/certs/client:ro", d.dind.volume),
+			fmt.Sprintf("%s:%s", d.dind.shareVolumeName, d.dind.shareVolumePath))
+	}
+
+	config.HostConfig.Binds = append(config.HostConfig.Binds, d.localVolumes...)
+	config.HostConfig.Binds = append(config.HostConfig.Binds,
+		fmt.Sprintf("%s/:/sd/workspace/src/%s/%s", buildEntry.SrcPath, scmHost, orgRepo),
+		fmt.Sprintf("%s/:%s", buildEntry.ArtifactsPath, containerArtDir),
+		fmt.Sprintf("%s:%s", d.volume, "/opt/sd"),
+		fmt.Sprintf("%s:%s", d.habVolume, "/opt/sd/hab"),
+		fmt.Sprintf("%s:/tmp/auth.sock:rw", d.socketPath))
+	config.HostConfig.Binds = append(config.HostConfig.Binds, metaVolumes(buildEntry)...)
+
+	if GetEnv(environment, "SD_LOCAL_TOKEN_URL") != "" {
+		config.HostConfig.ExtraHosts = []string{TokenHost + ":host-gateway"}
+	}
+
+	if buildEntry.MemoryLimit != "" {
+		memory, err := parseMemoryLimit(buildEntry.MemoryLimit)
+		if err != nil {
+			return err
+		}
+		config.HostConfig.Memory = memory
+	}
+
+	configJSON, err := json.Marshal(buildEntry)
+	if err != nil {
+		return err
+	}
+
+	config.Cmd = []string{
+		"/opt/sd/local_run.sh",
+		string(configJSON),
+		buildEntry.JobName,
+		GetEnv(environment, "SD_API_URL"),
+		GetEnv(environment, "SD_STORE_URL"),
+		filepath.Join(containerArtDir, LogFile),
+	}
+
+	if !d.noImagePull {
+		logrus.Infof("Pulling docker image from %s...", buildEntry.Image)
+
+		if err := d.pullImage(buildEntry.Image); err != nil {
+			return fmt.Errorf("failed to pull user image %v", err)
+		}
+	}
+
+	// The build run again in watch mode is not killed yet
+	d.killed.Store(false)
+
+	code, err := d.runContainer(config)
+	if err != nil {
+		return fmt.Errorf("failed to run build container: %v", err)
+	}
+	if code != 0 && d.killed.Load() {
+		return fmt.Errorf("failed to run build container: killed with exit status %d", code)
+	}
+	if code != 0 {
+		return fmt.Errorf("failed to run build container: %w", &StepError{Code: code, Err: fmt.Errorf("exit status %d", code)})
+	}
+
+	return nil
+}
+
+func (d *dockerAPI) runDinD() error {
+	if !d.noImagePull {
+		logrus.Infof("Pulling dind image from %s...", d.dind.image)
+		if err := d.pullImage(d.dind.image); err != nil {
+			return fmt.Errorf("failed to pull user image %v", err)
+		}
+	}
+
+	if err := d.call(http.MethodPost, "/networks/create", nil, dockerCreateRequest{Name: d.dind.network, Labels: d.labels}, nil); err != nil {
+		return fmt.Errorf("failed to create network: %v", err)
+	}
+
+	for _, v := range []string{d.dind.volume, d.dind.shareVolumeName} {
+		if err := d.createVolume(v); err != nil {
+			return fmt.Errorf("failed to create volume %s: %v", v, err)
+		}
+	}
+
+	id, err := d.createContainer(d.dind.container, dockerContainerConfig{
+		Image: d.dind.image,
+		Env:   []string{"DOCKER_TLS_CERTDIR=/certs"},
+		HostConfig: dockerHostConfig{
+			Privileged:  true,
+			NetworkMode: d.dind.network,
+			Binds: []string{
+				fmt.Sprintf("%s:/certs/client", d.dind.volume),
+				fmt.Sprintf("%s:%s", d.dind.shareVolumeName, d.dind.shareVolumePath),
+			},
+		},
+		NetworkingConfig: &dockerNetworkingConfig{
+			EndpointsConfig: map[string]dockerEndpointConfig{
+				d.dind.network: {Aliases: []string{"docker"}},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run dind container: %v", err)
+	}
+
+	if err := d.call(http.MethodPost, "/containers/"+id+"/start", nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to run dind container: %v", err)
+	}
+
+	return nil
+}
+
+func (d *dockerAPI) kill(sig os.Signal) {
+	d.killed.Store(true)
+
+	d.mutex.Lock()
+	containers := append([]string{}, d.containers...)
+	d.mutex.Unlock()
+
+	for _, id := range containers {
+		err := d.call(http.MethodPost, "/containers/"+id+"/kill", url.Values{"signal": {strconv.Itoa(signum(sig))}}, nil, nil)
+		if err != nil {
+			logrus.Warn(fmt.Errorf("failed to stop container: %v", err))
+		}
+	}
+}
+
+func (d *dockerAPI) clean() {
+	d.mutex.Lock()
+	containers := append([]string{}, d.containers...)
+	d.mutex.Unlock()
+
+	for _, id := range containers {
+		if err := d.removeContainer(id); err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove container: %v", err))
+		}
+	}
+
+	// Since the habVolume is mounted inside the mountpoint for volume, it must be removed first.
+	if err := d.call(http.MethodDelete, "/volumes/"+d.habVolume, url.Values{"force": {"1"}}, nil, nil); err != nil {
+		logrus.Warn(fmt.Errorf("failed to remove hab volume: %v", err))
+	}
+
+	if err := d.call(http.MethodDelete, "/volumes/"+d.volume, url.Values{"force": {"1"}}, nil, nil); err != nil {
+		logrus.Warn(fmt.Errorf("failed to remove volume: %v", err))
+	}
+
+	if d.dind.enabled {
+		if err := d.call(http.MethodDelete, "/networks/"+d.dind.network, nil, nil, nil); err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind network: %v", err))
+		}
+
+		if err := d.call(http.MethodDelete, "/volumes/"+d.dind.volume, url.Values{"force": {"1"}}, nil, nil); err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind volume: %v", err))
+		}
+
+		if err := d.call(http.MethodDelete, "/volumes/"+d.dind.shareVolumeName, url.Values{"force": {"1"}}, nil, nil); err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind share volume: %v", err))
+		}
+	}
+}
+
+func (d *dockerAPI) skipImagePull() {
+	d.noImagePull = true
+}
@@ -0,0 +1,475 @@
+package launch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDockerEngine struct {
+	server     *httptest.Server
+	socketPath string
+	mutex      sync.Mutex
+	requests   []string
+	configs    []dockerContainerConfig
+	failPath   string
+	exitCode   int
+	output     string
+	// onWait is called before the response of the wait request
+	onWait func()
+	// lists are the responses of the GET requests by the paths
+	lists map[string]string
+}
+
+func newFakeDockerEngine(t *testing.T) *fakeDockerEngine {
+	t.Helper()
+
+	f := &fakeDockerEngine{}
+	dir, err := os.MkdirTemp("", "dockerapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	f.socketPath = filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", f.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.server = httptest.NewUnstartedServer(http.HandlerFunc(f.handle))
+	f.server.Listener = listener
+	f.server.Start()
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeDockerEngine) handle(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	request := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
+	}
+	f.requests = append(f.requests, request)
+	f.mutex.Unlock()
+
+	if f.failPath != "" && strings.HasPrefix(r.URL.Path, f.failPath) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"message": "fake error"}`)
+		return
+	}
+
+	if list, ok := f.lists[r.URL.Path]; ok && r.Method == http.MethodGet {
+		fmt.Fprint(w, list)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/images/create":
+		fmt.Fprintln(w, `{"status": "Pulling from library/node", "id": "12"}`)
+		fmt.Fprintln(w, `{"status": "Downloading", "id": "abc", "progressDetail": {"current": 1, "total": 2}}`)
+		fmt.Fprintln(w, `{"status": "Status: Downloaded newer image for node:12"}`)
+	case r.URL.Path == "/containers/create":
+		config := dockerContainerConfig{}
+		_ = json.NewDecoder(r.Body).Decode(&config)
+		f.mutex.Lock()
+		f.configs = append(f.configs, config)
+		id := fmt.Sprintf("container%d", len(f.configs))
+		f.mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id": "%s"}`, id)
+	case strings.HasSuffix(r.URL.Path, "/attach"):
+		w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(f.output)))
+		w.Write(append(header, []byte(f.output)...))
+	case strings.HasSuffix(r.URL.Path, "/wait"):
+		if f.onWait != nil {
+			f.onWait()
+		}
+		fmt.Fprintf(w, `{"StatusCode": %d}`, f.exitCode)
+	case r.URL.Path == "/volumes/create":
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeDockerEngine) dockerAPI(options ...func(d *dockerAPI)) *dockerAPI {
+	d := &dockerAPI{
+		client:            newUnixSocketClient(f.socketPath),
+		volume:            "SD_LAUNCH_BIN",
+		habVolume:         "SD_LAUNCH_HAB",
+		setupImage:        "launcher",
+		setupImageVersion: "latest",
+		containers:        make([]string, 0, 3),
+		mutex:             &sync.Mutex{},
+		socketPath:        "/auth.sock",
+		output:            bytes.NewBuffer(nil),
+		dind: DinD{
+			volume:          "SD_DIND_CERT",
+			shareVolumeName: "SD_DIND_SHARE",
+			shareVolumePath: "/opt/sd_dind_share",
+			container:       "sd-local-dind",
+			network:         "sd-local-dind-bridge",
+			image:           "docker:23.0.1-dind-rootless",
+		},
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return d
+}
+
+func TestNewDockerAPI(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		r := newDockerAPI("launcher", "latest", false, "/auth.sock", false, []string{"path:path"}, "jithin", true, true, "", nil)
+		d, ok := r.(*dockerAPI)
+		assert.True(t, ok)
+		assert.Equal(t, "SD_LAUNCH_BIN", d.volume)
+		assert.Equal(t, "SD_LAUNCH_HAB", d.habVolume)
+		assert.Equal(t, "launcher", d.setupImage)
+		assert.Equal(t, "/auth.sock", d.socketPath)
+		assert.Equal(t, []string{"path:path"}, d.localVolumes)
+		assert.Equal(t, "jithin", d.buildUser)
+		assert.True(t, d.noImagePull)
+		assert.True(t, d.dind.enabled)
+		assert.Equal(t, io.Discard, d.output)
+	})
+}
+
+func TestDockerSocketPath(t *testing.T) {
+	testCase := []struct {
+		name       string
+		dockerHost string
+		expected   string
+		expectErr  bool
+	}{
+		{"default", "", "/var/run/docker.sock", false},
+		{"unix socket", "unix:///tmp/docker.sock", "/tmp/docker.sock", false},
+		{"tcp is not supported", "tcp://127.0.0.1:2375", "", true},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_HOST", tt.dockerHost)
+			actual, err := dockerSocketPath()
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.expectErr, err != nil)
+		})
+	}
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	testCase := []struct {
+		limit     string
+		expected  int64
+		expectErr bool
+	}{
+		{"1024", 1024, false},
+		{"100b", 100, false},
+		{"2k", 2 * 1024, false},
+		{"512m", 512 * 1024 * 1024, false},
+		{"2GB", 2 * 1024 * 1024 * 1024, false},
+		{"2t", 0, true},
+		{"big", 0, true},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.limit, func(t *testing.T) {
+			actual, err := parseMemoryLimit(tt.limit)
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.expectErr, err != nil)
+		})
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	testCase := []struct {
+		image      string
+		repository string
+		tag        string
+	}{
+		{"node:12", "node", "12"},
+		{"node", "node", "latest"},
+		{"localhost:5000/node", "localhost:5000/node", "latest"},
+		{"localhost:5000/node:12", "localhost:5000/node", "12"},
+		{"node@sha256:abc", "node@sha256:abc", ""},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.image, func(t *testing.T) {
+			repository, tag := splitImage(tt.image)
+			assert.Equal(t, tt.repository, repository)
+			assert.Equal(t, tt.tag, tag)
+		})
+	}
+}
+
+func TestDockerAPISetupBin(t *testing.T) {
+	testCase := []struct {
+		name             string
+		failPath         string
+		exitCode         int
+		expectError      error
+		expectedRequests []string
+	}{
+		{"success", "", 0, nil,
+			[]string{
+				"POST /images/create?fromImage=launcher&tag=latest",
+				"POST /volumes/create",
+				"POST /volumes/create",
+				"POST /containers/create",
+				"POST /containers/container1/attach?stderr=1&stdout=1&stream=1",
+				"POST /containers/container1/start",
+				"POST /containers/container1/wait",
+				"DELETE /containers/container1?force=1",
+			}},
+		{"failure launcher image pull", "/images/create", 0, fmt.Errorf("failed to pull launcher image: POST /images/create: fake error"), nil},
+		{"failure volume create", "/volumes/create", 0, fmt.Errorf("failed to create volume SD_LAUNCH_BIN: POST /volumes/create: fake error"), nil},
+		{"failure container exit code", "", 1, fmt.Errorf("failed to prepare build scripts: exit status 1"), nil},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDockerEngine(t)
+			f.failPath = tt.failPath
+			f.exitCode = tt.exitCode
+			d := f.dockerAPI()
+
+			err := d.setupBin()
+			assert.Equal(t, tt.expectError, err)
+			if tt.expectedRequests != nil {
+				assert.Equal(t, tt.expectedRequests, f.requests)
+				assert.Equal(t, []string{"/bin/echo"}, f.configs[0].Entrypoint)
+				assert.Equal(t, []string{"SD_LAUNCH_BIN:/opt/sd/", "SD_LAUNCH_HAB:/hab"}, f.configs[0].HostConfig.Binds)
+				assert.Empty(t, d.containers)
+			}
+		})
+	}
+
+	t.Run("success with labels", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		d := f.dockerAPI(func(d *dockerAPI) {
+			d.labels = map[string]string{"a": "1"}
+		})
+
+		assert.Nil(t, d.setupBin())
+		assert.Equal(t, map[string]string{"a": "1"}, f.configs[0].Labels)
+	})
+}
+
+func TestDockerAPIRunBuild(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		f.output = "build output"
+		output := bytes.NewBuffer(nil)
+		d := f.dockerAPI(func(d *dockerAPI) {
+			d.output = output
+			d.buildUser = "sd-buildbot"
+		})
+
+		err := d.runBuild(newBuildEntry(func(b *buildEntry) {
+			b.MemoryLimit = "2g"
+			b.UsePrivileged = true
+			b.Environment = append(b.Environment, map[string]string{"SD_LOCAL_TOKEN_URL": "http://sd-local.host:8765/token"})
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, "POST /images/create?fromImage=node&tag=12", f.requests[0])
+		assert.Equal(t, "build output", output.String())
+
+		config := f.configs[0]
+		assert.Equal(t, "node:12", config.Image)
+		assert.Equal(t, []string{"/bin/sh"}, config.Entrypoint)
+		assert.Equal(t, "/opt/sd/local_run.sh", config.Cmd[0])
+		assert.Equal(t, "test", config.Cmd[2])
+		assert.Equal(t, "/test/artifacts/builds.log", config.Cmd[5])
+		assert.Equal(t, "sd-buildbot", config.User)
+		assert.Equal(t, []string{"SSH_AUTH_SOCK=/tmp/auth.sock"}, config.Env)
+		assert.Equal(t, int64(2*1024*1024*1024), config.HostConfig.Memory)
+		assert.True(t, config.HostConfig.Privileged)
+		assert.Equal(t, []string{"sd-local.host:host-gateway"}, config.HostConfig.ExtraHosts)
+		assert.Equal(t, []string{
+			"/:/sd/workspace/src/screwdriver.cd/sd-local/local-build",
+			"sd-artifacts/:/test/artifacts",
+			"SD_LAUNCH_BIN:/opt/sd",
+			"SD_LAUNCH_HAB:/opt/sd/hab",
+			"/auth.sock:/tmp/auth.sock:rw",
+		}, config.HostConfig.Binds)
+	})
+
+	t.Run("success with dind and no image pull", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		d := f.dockerAPI(func(d *dockerAPI) {
+			d.dind.enabled = true
+			d.noImagePull = true
+		})
+
+		err := d.runBuild(newBuildEntry())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"POST /networks/create",
+			"POST /volumes/create",
+			"POST /volumes/create",
+			"POST /containers/create?name=sd-local-dind",
+			"POST /containers/container1/start",
+			"POST /containers/create",
+			"POST /containers/container2/attach?stderr=1&stdout=1&stream=1",
+			"POST /containers/container2/start",
+			"POST /containers/container2/wait",
+			"DELETE /containers/container2?force=1",
+		}, f.requests)
+
+		dind := f.configs[0]
+		assert.True(t, dind.HostConfig.Privileged)
+		assert.Equal(t, "sd-local-dind-bridge", dind.HostConfig.NetworkMode)
+		assert.Equal(t, []string{"docker"}, dind.NetworkingConfig.EndpointsConfig["sd-local-dind-bridge"].Aliases)
+
+		build := f.configs[1]
+		assert.Equal(t, "sd-local-dind-bridge", build.HostConfig.NetworkMode)
+		assert.Contains(t, build.Env, "DOCKER_HOST=tcp://docker:2376")
+		assert.Contains(t, build.HostConfig.Binds, "SD_DIND_CERT:/certs/client:ro")
+		assert.Equal(t, []string{"container1"}, d.containers)
+	})
+
+	testCase := []struct {
+		name        string
+		failPath    string
+		exitCode    int
+		interactive bool
+		memory      string
+		expectError error
+	}{
+		{"failure exit code", "", 2, false, "", fmt.Errorf("failed to run build container: exit status 2")},
+		{"failure build image pull", "/images/create", 0, false, "", fmt.Errorf("failed to pull user image POST /images/create: fake error")},
+		{"failure container create", "/containers/create", 0, false, "", fmt.Errorf("failed to run build container: POST /containers/create: fake error")},
+		{"failure invalid memory limit", "", 0, false, "lots", fmt.Errorf("invalid memory limit \"lots\"")},
+		{"failure interactive mode", "", 0, true, "", fmt.Errorf("interactive mode is not supported with the docker-api runtime")},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDockerEngine(t)
+			f.failPath = tt.failPath
+			f.exitCode = tt.exitCode
+			d := f.dockerAPI(func(d *dockerAPI) { d.interactiveMode = tt.interactive })
+
+			err := d.runBuild(newBuildEntry(func(b *buildEntry) { b.MemoryLimit = tt.memory }))
+			assert.EqualError(t, err, tt.expectError.Error())
+
+			var stepErr *StepError
+			assert.Equal(t, tt.exitCode != 0, errors.As(err, &stepErr))
+		})
+	}
+}
+
+// fakeSignal is the signal which is not a syscall.Signal
+type fakeSignal struct{}
+
+func (fakeSignal) String() string { return "fake" }
+
+func (fakeSignal) Signal() {}
+
+func TestDockerAPIKill(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		d := f.dockerAPI(func(d *dockerAPI) { d.containers = []string{"container1"} })
+
+		d.kill(syscall.SIGINT)
+		assert.Equal(t, []string{"POST /containers/container1/kill?signal=2"}, f.requests)
+	})
+
+	t.Run("success with the signal which is not a syscall.Signal", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		d := f.dockerAPI(func(d *dockerAPI) { d.containers = []string{"container1"} })
+
+		d.kill(os.Kill)
+		d.kill(fakeSignal{})
+		assert.Equal(t, []string{"POST /containers/container1/kill?signal=9", "POST /containers/container1/kill?signal=15"}, f.requests)
+	})
+
+	t.Run("success to report the killed build as not a failed step", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		f.exitCode = 143
+		d := f.dockerAPI(func(d *dockerAPI) { d.noImagePull = true })
+		f.onWait = func() { d.kill(syscall.SIGTERM) }
+
+		err := d.runBuild(newBuildEntry())
+		assert.EqualError(t, err, "failed to run build container: killed with exit status 143")
+		var stepErr *StepError
+		assert.False(t, errors.As(err, &stepErr))
+
+		// The build run again is not taken for the killed one
+		f.onWait = nil
+		err = d.runBuild(newBuildEntry())
+		assert.True(t, errors.As(err, &stepErr))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		defer logrus.SetOutput(os.Stderr)
+		f := newFakeDockerEngine(t)
+		f.failPath = "/containers"
+		d := f.dockerAPI(func(d *dockerAPI) { d.containers = []string{"container1"} })
+
+		buf := bytes.NewBuffer(nil)
+		logrus.SetOutput(buf)
+
+		d.kill(syscall.SIGINT)
+		assert.Contains(t, buf.String(), "failed to stop container:")
+	})
+}
+
+func TestDockerAPIClean(t *testing.T) {
+	t.Run("success with dind", func(t *testing.T) {
+		f := newFakeDockerEngine(t)
+		d := f.dockerAPI(func(d *dockerAPI) {
+			d.dind.enabled = true
+			d.containers = []string{"container1"}
+		})
+
+		d.clean()
+		assert.Equal(t, []string{
+			"DELETE /containers/container1?force=1",
+			"DELETE /volumes/SD_LAUNCH_HAB?force=1",
+			"DELETE /volumes/SD_LAUNCH_BIN?force=1",
+			"DELETE /networks/sd-local-dind-bridge",
+			"DELETE /volumes/SD_DIND_CERT?force=1",
+			"DELETE /volumes/SD_DIND_SHARE?force=1",
+		}, f.requests)
+		assert.Empty(t, d.containers)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		defer logrus.SetOutput(os.Stderr)
+		f := newFakeDockerEngine(t)
+		f.failPath = "/volumes"
+		d := f.dockerAPI()
+
+		buf := bytes.NewBuffer(nil)
+		logrus.SetOutput(buf)
+
+		d.clean()
+		assert.Contains(t, buf.String(), "failed to remove hab volume:")
+		assert.Contains(t, buf.String(), "failed to remove volume:")
+	})
+}
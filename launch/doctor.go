@@ -0,0 +1,352 @@
+package launch
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/creack/pty"
+	"github.com/screwdriver-cd/sd-local/checklist"
+	"github.com/screwdriver-cd/sd-local/state"
+)
+
+const (
+	// lowDiskSpace and criticalDiskSpace are the free spaces which the builds may run out of
+	lowDiskSpace      = 5 << 30
+	criticalDiskSpace = 1 << 30
+	// darwinSocketPath is the ssh-agent socket in the Docker Desktop VM
+	darwinSocketPath = "/run/host-services/ssh-auth.sock"
+)
+
+var (
+	ptyOpen  = pty.Open
+	diskFree = statfsFree
+)
+
+// leftoverPrefixes are the names of the resources which the builds remove when they finish
+var leftoverPrefixes = map[string][]string{
+	state.VolumeKind:    {binVolumePrefix, habVolumePrefix, dindVolumePrefix, dindShareVolumePrefix},
+	state.ContainerKind: {dindContainerPrefix},
+	state.NetworkKind:   {dindNetworkPrefix},
+}
+
+// statfsFree returns the bytes available to the user in the file system of the path
+func statfsFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// DoctorOptions are the options of the builds which the environment is checked for
+type DoctorOptions struct {
+	Runtime      string
+	UseSudo      bool
+	SocketPath   string
+	ArtifactsDir string
+}
+
+type doctor struct {
+	options DoctorOptions
+	list    *checklist.List
+	command string
+	// sudo is true when the runtime command works only with sudo
+	sudo bool
+}
+
+// Doctor checks the environment which the builds run in, with the suggested fixes of the problems
+func Doctor(options DoctorOptions) *checklist.List {
+	if options.Runtime == "" {
+		options.Runtime = DockerRuntime
+	}
+
+	d := &doctor{
+		options: options,
+		list:    &checklist.List{},
+		command: runtimeCommands[options.Runtime],
+		sudo:    options.UseSudo,
+	}
+
+	if d.checkRuntime() {
+		d.checkLeftovers()
+		d.checkDataDiskSpace()
+	}
+	d.checkArtifactsDiskSpace()
+	d.checkSocket()
+	d.checkGit()
+	d.checkPty()
+
+	return d.list
+}
+
+// run runs the runtime command with sudo if it is needed, returning the combined output
+func (d *doctor) run(sudo bool, args ...string) (string, error) {
+	name := d.command
+	if sudo {
+		// sudo does not ask the password, which the diagnostics can't enter
+		args = append([]string{"-n", name}, args...)
+		name = "sudo"
+	}
+
+	out, err := execCommand(name, args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// fixCommand returns the runtime command to suggest, which is run with sudo if it is needed
+func (d *doctor) fixCommand(args string) string {
+	if d.sudo {
+		return "sudo " + d.command + " " + args
+	}
+	return d.command + " " + args
+}
+
+// firstLine returns the first line of the output, which has the reason of the error
+func firstLine(out string) string {
+	line, _, _ := strings.Cut(out, "\n")
+	return line
+}
+
+// checkRuntime checks the runtime command, its daemon and whether sudo is needed, returning true if the runtime works
+func (d *doctor) checkRuntime() bool {
+	if d.options.Runtime == DockerAPIRuntime {
+		return d.checkDockerAPI()
+	}
+
+	if _, err := lookPath(d.command); err != nil {
+		d.list.Fail("runtime", fmt.Sprintf("%s is not found in $PATH", d.command), fmt.Sprintf("Install %s, or choose the other runtime by `sd-local config set runtime`", d.command))
+		d.list.Skip("daemon", d.command+" is not found")
+		return false
+	}
+	d.list.OK("runtime", d.command+" is found")
+
+	out, err := d.run(d.sudo, "info")
+	if err == nil {
+		d.list.OK("daemon", d.command+" responds")
+		d.checkSudo()
+		return true
+	}
+
+	if !d.sudo && strings.Contains(strings.ToLower(out), "permission denied") {
+		if _, sudoErr := d.run(true, "info"); sudoErr == nil {
+			d.sudo = true
+			d.list.OK("daemon", d.command+" responds with sudo")
+			d.list.Fail("sudo", d.command+" needs sudo", "Run the builds with `--sudo`, or add the user to the docker group")
+			return true
+		}
+	}
+
+	fix := "Start the Docker daemon, e.g. Docker Desktop or `sudo systemctl start docker`"
+	if d.options.Runtime == PodmanRuntime {
+		fix = "Start the Podman machine by `podman machine start`"
+	}
+	d.list.Fail("daemon", firstLine(out), fix)
+
+	return false
+}
+
+// checkSudo checks that sudo is not used when it is not needed
+func (d *doctor) checkSudo() {
+	if !d.sudo {
+		d.list.OK("sudo", "not needed")
+		return
+	}
+
+	if _, err := d.run(false, "info"); err == nil {
+		d.list.Warn("sudo", d.command+" works without sudo", "Run the builds without `--sudo`")
+		return
+	}
+	d.list.OK("sudo", "needed and `--sudo` is set")
+}
+
+// checkDockerAPI checks the Docker Engine API socket
+func (d *doctor) checkDockerAPI() bool {
+	socket, err := dockerSocketPath()
+	if err != nil {
+		d.list.Fail("runtime", err.Error(), "Unset DOCKER_HOST, or set it to unix:///var/run/docker.sock")
+		d.list.Skip("daemon", "no socket")
+		return false
+	}
+	d.list.OK("runtime", "the socket is "+socket)
+
+	res, err := newUnixSocketClient(socket).Get(dockerAPIBaseURL + "/_ping")
+	if err != nil {
+		fix := "Start the Docker daemon, e.g. Docker Desktop or `sudo systemctl start docker`"
+		if os.IsPermission(err) || strings.Contains(err.Error(), "permission denied") {
+			fix = "Add the user to the docker group, since docker-api can't use sudo"
+		}
+		d.list.Fail("daemon", err.Error(), fix)
+		return false
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		d.list.Fail("daemon", fmt.Sprintf("StatusCode %d from %s", res.StatusCode, socket), "Restart the Docker daemon")
+		return false
+	}
+	d.list.OK("daemon", "the Docker Engine API responds")
+	d.list.Skip("sudo", "docker-api does not use sudo")
+
+	return true
+}
+
+// checkLeftovers finds the volumes, the containers and the networks which the builds killed did not remove
+func (d *doctor) checkLeftovers() {
+	if d.options.Runtime == DockerAPIRuntime {
+		d.list.Skip("leftovers", "not checked with docker-api")
+		return
+	}
+
+	lists := map[string][]string{
+		state.VolumeKind:    {"volume", "ls", "--format", "{{.Name}}"},
+		state.ContainerKind: {"ps", "--all", "--format", "{{.Names}}"},
+		state.NetworkKind:   {"network", "ls", "--format", "{{.Name}}"},
+	}
+
+	var found []string
+	for _, kind := range []string{state.VolumeKind, state.ContainerKind, state.NetworkKind} {
+		out, err := d.run(d.sudo, lists[kind]...)
+		if err != nil {
+			d.list.Fail("leftovers", firstLine(out), "Check that the daemon is running")
+			return
+		}
+
+		var names []string
+		for _, name := range strings.Fields(out) {
+			if isResourceName(kind, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		found = append(found, names...)
+	}
+
+	if len(found) == 0 {
+		d.list.OK("leftovers", "no resources of the finished builds are left")
+		return
+	}
+	sudo := ""
+	if d.sudo {
+		sudo = " --sudo"
+	}
+	d.list.Warn("leftovers", strings.Join(found, ", "), fmt.Sprintf("Remove the ones of the killed builds by `sd-local clean%s`, or all of them by `sd-local clean --all%s` unless the builds are running", sudo, sudo))
+}
+
+// checkDiskSpace checks the free space of the file system of the path
+func (d *doctor) checkDiskSpace(name, path, fix string) {
+	free, err := diskFree(path)
+	if err != nil {
+		d.list.Fail(name, err.Error(), "Check the permissions of "+path)
+		return
+	}
+
+	detail := fmt.Sprintf("%.1fGB free in %s", float64(free)/(1<<30), path)
+	switch {
+	case free < criticalDiskSpace:
+		d.list.Fail(name, detail, fix)
+	case free < lowDiskSpace:
+		d.list.Warn(name, detail, fix)
+	default:
+		d.list.OK(name, detail)
+	}
+}
+
+// checkArtifactsDiskSpace checks the free space of the artifacts directory, which may not exist yet
+func (d *doctor) checkArtifactsDiskSpace() {
+	dir, err := filepath.Abs(d.options.ArtifactsDir)
+	if err != nil {
+		d.list.Fail("artifacts disk", err.Error(), "Check --artifacts-dir")
+		return
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	d.checkDiskSpace("artifacts disk", dir, "Free up the disk, or change the directory by `--artifacts-dir`")
+}
+
+// checkDataDiskSpace checks the free space of the directory which the runtime stores the images and the volumes in
+func (d *doctor) checkDataDiskSpace() {
+	if d.options.Runtime == DockerAPIRuntime {
+		d.list.Skip("data disk", "not checked with docker-api")
+		return
+	}
+
+	format := "{{.DockerRootDir}}"
+	if d.options.Runtime == PodmanRuntime {
+		format = "{{.Store.GraphRoot}}"
+	}
+
+	dir, err := d.run(d.sudo, "info", "--format", format)
+	if err != nil {
+		d.list.Fail("data disk", firstLine(dir), "Check that the daemon is running")
+		return
+	}
+	if _, err := os.Stat(dir); err != nil {
+		// Docker Desktop and Podman machine store them in the VM
+		d.list.Skip("data disk", dir+" is not on this machine")
+		return
+	}
+
+	d.checkDiskSpace("data disk", dir, "Remove the unused images and volumes by `"+d.fixCommand("system prune")+"`")
+}
+
+// checkSocket checks the ssh-agent socket which is mounted into the build containers
+func (d *doctor) checkSocket() {
+	socket := d.options.SocketPath
+	if socket == "" {
+		d.list.Warn("ssh-agent", "SSH_AUTH_SOCK is not set", "Start ssh-agent and add the keys by `ssh-add` to use SSH in the builds")
+		return
+	}
+	if runtime.GOOS == "darwin" && socket == darwinSocketPath {
+		d.list.OK("ssh-agent", socket+" in the Docker Desktop VM")
+		return
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		d.list.Fail("ssh-agent", err.Error(), "Restart ssh-agent, or pass the socket by `--socket`")
+		return
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		d.list.Fail("ssh-agent", socket+" is not a socket", "Pass the ssh-agent socket by `--socket`")
+		return
+	}
+	d.list.OK("ssh-agent", socket)
+}
+
+// checkGit checks git, which --src-url clones the repository with
+func (d *doctor) checkGit() {
+	if _, err := lookPath("git"); err != nil {
+		d.list.Fail("git", "git is not found in $PATH", "Install git to build with `--src-url`")
+		return
+	}
+
+	out, err := execCommand("git", "--version").CombinedOutput()
+	if err != nil {
+		d.list.Fail("git", firstLine(strings.TrimSpace(string(out))), "Reinstall git to build with `--src-url`")
+		return
+	}
+	d.list.OK("git", strings.TrimSpace(string(out)))
+}
+
+// checkPty checks the pseudo terminals, which the interactive mode runs the shell in
+func (d *doctor) checkPty() {
+	ptmx, tty, err := ptyOpen()
+	if err != nil {
+		d.list.Fail("pty", err.Error(), "Check that /dev/ptmx is available to use `-i`")
+		return
+	}
+	ptmx.Close()
+	tty.Close()
+
+	d.list.OK("pty", "pseudo terminals are available")
+}
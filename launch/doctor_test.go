@@ -0,0 +1,324 @@
+package launch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/creack/pty"
+	"github.com/screwdriver-cd/sd-local/checklist"
+	"github.com/stretchr/testify/assert"
+)
+
+// doctorResult is the output and the exit code of the fake command
+type doctorResult struct {
+	out  string
+	code int
+}
+
+// fakeDoctorExec returns the results of the commands, or succeeds with no output for the others
+func fakeDoctorExec(t *testing.T, results map[string]doctorResult) *[]string {
+	commands := []string{}
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		command := strings.TrimSpace(name + " " + strings.Join(args, " "))
+		commands = append(commands, command)
+		r := results[command]
+
+		cmd := exec.Command(os.Args[0], "-test.run=TestDoctorHelperProcess")
+		cmd.Env = []string{"GO_WANT_DOCTOR_HELPER_PROCESS=1", "DOCTOR_OUT=" + r.out, fmt.Sprintf("DOCTOR_CODE=%d", r.code)}
+		return cmd
+	}
+	t.Cleanup(func() {
+		execCommand = exec.Command
+	})
+
+	return &commands
+}
+
+func TestDoctorHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_DOCTOR_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	fmt.Print(os.Getenv("DOCTOR_OUT"))
+	if os.Getenv("DOCTOR_CODE") != "0" {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// fakeDoctorEnv fakes lookPath, diskFree and ptyOpen to pass the checks
+func fakeDoctorEnv(t *testing.T, free uint64, missing ...string) {
+	lookPath = func(cmd string) (string, error) {
+		for _, m := range missing {
+			if cmd == m {
+				return "", errors.New("executable file not found in $PATH")
+			}
+		}
+		return "/usr/bin/" + cmd, nil
+	}
+	diskFree = func(path string) (uint64, error) {
+		return free, nil
+	}
+	ptyOpen = func() (*os.File, *os.File, error) {
+		ptmx, err := os.Open(os.DevNull)
+		if err != nil {
+			return nil, nil, err
+		}
+		tty, err := os.Open(os.DevNull)
+		return ptmx, tty, err
+	}
+	t.Cleanup(func() {
+		lookPath = exec.LookPath
+		diskFree = statfsFree
+		ptyOpen = pty.Open
+	})
+}
+
+// newTestSocket returns the path of the listening unix socket
+func newTestSocket(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "doctor")
+	assert.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", path)
+	assert.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	return path
+}
+
+func findItem(t *testing.T, list *checklist.List, name string) checklist.Item {
+	t.Helper()
+	for _, item := range list.Items {
+		if item.Name == name {
+			return item
+		}
+	}
+	t.Fatalf("no check `%s` in %v", name, list.Items)
+	return checklist.Item{}
+}
+
+func TestDoctor(t *testing.T) {
+	socket := newTestSocket(t)
+	dataDir := t.TempDir()
+
+	t.Run("success", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		commands := fakeDoctorExec(t, map[string]doctorResult{
+			"docker info --format {{.DockerRootDir}}": {out: dataDir},
+			"docker volume ls --format {{.Name}}":     {out: "SD_LAUNCH_CACHE\nother\n"},
+			"git --version":                           {out: "git version 2.40.0\n"},
+		})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: filepath.Join(t.TempDir(), "not", "exist")})
+
+		assert.Equal(t, 0, list.Failed())
+		for _, item := range list.Items {
+			assert.Equal(t, checklist.OK, item.Status, item.Name)
+		}
+		assert.Equal(t, "git version 2.40.0", findItem(t, list, "git").Detail)
+		assert.Equal(t, fmt.Sprintf("100.0GB free in %s", dataDir), findItem(t, list, "data disk").Detail)
+		assert.Equal(t, []string{
+			"docker info",
+			"docker volume ls --format {{.Name}}",
+			"docker ps --all --format {{.Names}}",
+			"docker network ls --format {{.Name}}",
+			"docker info --format {{.DockerRootDir}}",
+			"git --version",
+		}, *commands)
+	})
+
+	t.Run("success with sudo and podman", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		commands := fakeDoctorExec(t, map[string]doctorResult{
+			"podman info": {out: "permission denied", code: 1},
+			"sudo -n podman info --format {{.Store.GraphRoot}}": {out: "/not/exist"},
+		})
+
+		list := Doctor(DoctorOptions{Runtime: PodmanRuntime, UseSudo: true, SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 0, list.Failed())
+		assert.Equal(t, "needed and `--sudo` is set", findItem(t, list, "sudo").Detail)
+		assert.Equal(t, checklist.Skip, findItem(t, list, "data disk").Status)
+		assert.Contains(t, *commands, "sudo -n podman volume ls --format {{.Name}}")
+
+		list = Doctor(DoctorOptions{Runtime: PodmanRuntime, SocketPath: socket, ArtifactsDir: "."})
+		assert.Equal(t, checklist.Fail, findItem(t, list, "sudo").Status)
+		assert.Equal(t, checklist.OK, findItem(t, list, "leftovers").Status)
+	})
+
+	t.Run("failure by sudo needed", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{
+			"docker info": {out: "Got permission denied while trying to connect to the Docker daemon socket\nmore", code: 1},
+		})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 1, list.Failed())
+		assert.Equal(t, checklist.OK, findItem(t, list, "daemon").Status)
+		assert.Equal(t, checklist.Item{
+			Status: checklist.Fail,
+			Name:   "sudo",
+			Detail: "docker needs sudo",
+			Fix:    "Run the builds with `--sudo`, or add the user to the docker group",
+		}, findItem(t, list, "sudo"))
+	})
+
+	t.Run("warning by sudo not needed", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{})
+
+		list := Doctor(DoctorOptions{UseSudo: true, SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, checklist.Warn, findItem(t, list, "sudo").Status)
+	})
+
+	t.Run("failure by runtime not found", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30, "docker", "git")
+		commands := fakeDoctorExec(t, map[string]doctorResult{})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 2, list.Failed())
+		assert.Equal(t, checklist.Fail, findItem(t, list, "runtime").Status)
+		assert.Equal(t, checklist.Skip, findItem(t, list, "daemon").Status)
+		assert.Equal(t, "Install git to build with `--src-url`", findItem(t, list, "git").Fix)
+		assert.Empty(t, *commands)
+	})
+
+	t.Run("failure by daemon not running", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{
+			"docker info": {out: "Cannot connect to the Docker daemon at unix:///var/run/docker.sock\n", code: 1},
+		})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 1, list.Failed())
+		assert.Equal(t, checklist.Item{
+			Status: checklist.Fail,
+			Name:   "daemon",
+			Detail: "Cannot connect to the Docker daemon at unix:///var/run/docker.sock",
+			Fix:    "Start the Docker daemon, e.g. Docker Desktop or `sudo systemctl start docker`",
+		}, findItem(t, list, "daemon"))
+		for _, item := range list.Items {
+			assert.NotEqual(t, "leftovers", item.Name)
+		}
+	})
+
+	t.Run("warning by leftovers", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{
+			"docker volume ls --format {{.Name}}":  {out: "SD_LAUNCH_HAB\nSD_LAUNCH_BIN\nSD_DIND_CERT-job\n"},
+			"docker ps --all --format {{.Names}}":  {out: "sd-local-dind\n"},
+			"docker network ls --format {{.Name}}": {out: "bridge\n"},
+		})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, checklist.Item{
+			Status: checklist.Warn,
+			Name:   "leftovers",
+			Detail: "SD_DIND_CERT-job, SD_LAUNCH_BIN, SD_LAUNCH_HAB, sd-local-dind",
+			Fix:    "Remove the ones of the killed builds by `sd-local clean`, or all of them by `sd-local clean --all` unless the builds are running",
+		}, findItem(t, list, "leftovers"))
+	})
+
+	t.Run("failure by disk space", func(t *testing.T) {
+		fakeDoctorEnv(t, 512<<20)
+		fakeDoctorExec(t, map[string]doctorResult{
+			"docker info --format {{.DockerRootDir}}": {out: dataDir},
+		})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 2, list.Failed())
+		assert.Equal(t, "Remove the unused images and volumes by `docker system prune`", findItem(t, list, "data disk").Fix)
+		assert.Equal(t, checklist.Fail, findItem(t, list, "artifacts disk").Status)
+	})
+
+	t.Run("warning by low disk space", func(t *testing.T) {
+		fakeDoctorEnv(t, 2<<30)
+		fakeDoctorExec(t, map[string]doctorResult{})
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, checklist.Warn, findItem(t, list, "artifacts disk").Status)
+	})
+
+	t.Run("failure by ssh-agent socket", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{})
+
+		list := Doctor(DoctorOptions{ArtifactsDir: "."})
+		assert.Equal(t, checklist.Warn, findItem(t, list, "ssh-agent").Status)
+
+		list = Doctor(DoctorOptions{SocketPath: filepath.Join(dataDir, "not-exist.sock"), ArtifactsDir: "."})
+		assert.Equal(t, checklist.Fail, findItem(t, list, "ssh-agent").Status)
+
+		list = Doctor(DoctorOptions{SocketPath: dataDir, ArtifactsDir: "."})
+		assert.Equal(t, checklist.Item{
+			Status: checklist.Fail,
+			Name:   "ssh-agent",
+			Detail: dataDir + " is not a socket",
+			Fix:    "Pass the ssh-agent socket by `--socket`",
+		}, findItem(t, list, "ssh-agent"))
+	})
+
+	t.Run("failure by pty", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{})
+		ptyOpen = func() (*os.File, *os.File, error) {
+			return nil, nil, errors.New("open /dev/ptmx: no such file or directory")
+		}
+
+		list := Doctor(DoctorOptions{SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, checklist.Item{
+			Status: checklist.Fail,
+			Name:   "pty",
+			Detail: "open /dev/ptmx: no such file or directory",
+			Fix:    "Check that /dev/ptmx is available to use `-i`",
+		}, findItem(t, list, "pty"))
+	})
+
+	t.Run("success with docker-api", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		commands := fakeDoctorExec(t, map[string]doctorResult{})
+		f := newFakeDockerEngine(t)
+		t.Setenv("DOCKER_HOST", "unix://"+f.socketPath)
+
+		list := Doctor(DoctorOptions{Runtime: DockerAPIRuntime, SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 0, list.Failed())
+		assert.Equal(t, checklist.OK, findItem(t, list, "daemon").Status)
+		assert.Equal(t, checklist.Skip, findItem(t, list, "leftovers").Status)
+		assert.Equal(t, []string{"GET /_ping"}, f.requests)
+		assert.Equal(t, []string{"git --version"}, *commands)
+	})
+
+	t.Run("failure with docker-api", func(t *testing.T) {
+		fakeDoctorEnv(t, 100<<30)
+		fakeDoctorExec(t, map[string]doctorResult{})
+		t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(dataDir, "not-exist.sock"))
+
+		list := Doctor(DoctorOptions{Runtime: DockerAPIRuntime, SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, 1, list.Failed())
+		assert.Equal(t, checklist.Fail, findItem(t, list, "daemon").Status)
+
+		t.Setenv("DOCKER_HOST", "tcp://localhost:2375")
+		list = Doctor(DoctorOptions{Runtime: DockerAPIRuntime, SocketPath: socket, ArtifactsDir: "."})
+
+		assert.Equal(t, checklist.Fail, findItem(t, list, "runtime").Status)
+		assert.Equal(t, checklist.Skip, findItem(t, list, "daemon").Status)
+	})
+}
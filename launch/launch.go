@@ -1,15 +1,22 @@
 package launch
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
 	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/screwdriver-cd/sd-local/config"
 	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/screwdriver-cd/sd-local/state"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,11 +26,17 @@ var (
 	storeVersion = "v1"
 )
 
+// Stderr is the writer which the errors of the container runtime commands are copied to.
+// The caller may replace it, e.g. to mask the secrets in the errors.
+var Stderr io.Writer = os.Stderr
+
 type runner interface {
 	runBuild(buildEntry buildEntry) error
 	setupBin() error
 	kill(os.Signal)
 	clean()
+	// skipImagePull stops pulling the images which are already pulled
+	skipImagePull()
 }
 
 // Launcher able to run local build
@@ -35,9 +48,58 @@ type Launcher interface {
 
 var _ (Launcher) = (*launch)(nil)
 
+// StepError is returned when a step of the build fails. Code is the exit status of the step.
+type StepError struct {
+	Code int
+	Err  error
+}
+
+func (e *StepError) Error() string { return e.Err.Error() }
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// ExitCode returns the exit status of the failed step
+func (e *StepError) ExitCode() int { return e.Code }
+
+// RuntimeError is returned when the container runtime fails to prepare or run the build,
+// e.g. pulling the image or starting the container
+type RuntimeError struct {
+	Err error
+}
+
+func (e *RuntimeError) Error() string { return e.Err.Error() }
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+// The exit statuses of docker and podman when the runtime itself fails,
+// and when the command of the container is not executable or not found
+const (
+	runtimeExitCode       = 125
+	notExecutableExitCode = 126
+	notFoundExitCode      = 127
+)
+
+// stepError returns StepError when the build container exited with the status of a failed step.
+// The exit statuses of the runtime are returned as they are, which Run reports as RuntimeError.
+func stepError(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() <= 0 {
+		return err
+	}
+
+	switch exitErr.ExitCode() {
+	case runtimeExitCode, notExecutableExitCode, notFoundExitCode:
+		return err
+	default:
+		return &StepError{Code: exitErr.ExitCode(), Err: err}
+	}
+}
+
 type launch struct {
 	buildEntry buildEntry
 	runner     runner
+	runtime    string
+	prepared   bool
 }
 
 // Meta is a map for metadata
@@ -63,6 +125,7 @@ type buildEntry struct {
 	SocketPath      string                 `json:"-"`
 	UsePrivileged   bool                   `json:"-"`
 	LocalVolumes    []string               `json:"-"`
+	MetaPath        string                 `json:"-"`
 }
 
 // Option is option for launch New
@@ -85,13 +148,65 @@ type Option struct {
 	LocalVolumes    []string
 	BuildUser       string
 	NoImagePull     bool
+	Runtime         string
+	MetaPath        string
+	BuildName       string
+	Secrets         map[string]string
+	// Labels are put on the volumes, the containers and the networks of the build
+	Labels map[string]string
+	// TokenURL is the endpoint which returns the refreshed JWT to the build, which requires TokenKey as the bearer token
+	TokenURL string
+	TokenKey string
 }
 
+// TokenHost is the host name of the host machine in the build container, which serves the refreshed JWT
+const TokenHost = "sd-local.host"
+
 const (
 	defaultArtDir     = "/sd/workspace/artifacts"
 	defaultSdUtilsDir = "/sd/workspace/sd-utils"
+	defaultMetaDir    = "/sd/meta"
+)
+
+// MetaFile is the file name of the meta which the launcher writes in the meta directory
+const MetaFile = "meta.json"
+
+const (
+	// DockerRuntime runs builds with the docker command
+	DockerRuntime = "docker"
+	// PodmanRuntime runs builds with the podman command
+	PodmanRuntime = "podman"
+	// DockerAPIRuntime runs builds with the Docker Engine API over the unix socket
+	DockerAPIRuntime = "docker-api"
 )
 
+// runtimeCommands maps the runtimes to the commands which must be found in $PATH
+var runtimeCommands = map[string]string{
+	DockerRuntime: "docker",
+	PodmanRuntime: "podman",
+}
+
+// Runtimes returns the container runtimes which can be used for builds.
+func Runtimes() []string {
+	return []string{DockerRuntime, PodmanRuntime, DockerAPIRuntime}
+}
+
+// ValidateRuntime returns an error if the runtime is not supported.
+// An empty runtime means the default one.
+func ValidateRuntime(runtime string) error {
+	if runtime == "" {
+		return nil
+	}
+
+	for _, r := range Runtimes() {
+		if r == runtime {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported container runtime `%s`, must be one of %v", runtime, Runtimes())
+}
+
 // DefaultSocketPath is a socket path on the localhost to bring in the build container.
 func DefaultSocketPath() string {
 	socketPath := os.Getenv("SSH_AUTH_SOCK")
@@ -125,7 +240,19 @@ func createBuildEntry(option Option) buildEntry {
 
 	env := []map[string]string{{"SD_TOKEN": option.JWT}, {"SD_ARTIFACTS_DIR": defaultArtDir}, {"SD_UTILS_DIR": defaultSdUtilsDir}, {"SD_API_URL": apiURL}, {"SD_STORE_URL": storeURL}, {"SD_BASE_COMMAND_PATH": "/sd/commands/"}}
 
+	if option.TokenURL != "" {
+		env = append(env, map[string]string{"SD_LOCAL_TOKEN_URL": option.TokenURL}, map[string]string{"SD_LOCAL_TOKEN_KEY": option.TokenKey})
+	}
+
 	env = append(env, option.Job.Environment...)
+
+	// Only the secrets declared in the job are passed to the build
+	for _, name := range option.Job.Secrets {
+		if value, ok := option.Secrets[name]; ok {
+			env = append(env, map[string]string{name: value})
+		}
+	}
+
 	env = append(env, option.OptionEnv...)
 
 	return buildEntry{
@@ -148,33 +275,164 @@ func createBuildEntry(option Option) buildEntry {
 		SocketPath:      option.SocketPath,
 		UsePrivileged:   option.UsePrivileged,
 		LocalVolumes:    option.LocalVolumes,
+		MetaPath:        option.MetaPath,
 	}
 }
 
+// BuildEntryJSON returns the build entry passed to the launcher as indented JSON
+func BuildEntryJSON(option Option) ([]byte, error) {
+	return json.MarshalIndent(createBuildEntry(option), "", "  ")
+}
+
+// ContainerPath returns the absolute path in the build container, expanding the environment variables and "~".
+// "~" is the home directory of root, since the home directory of the build user is not known before the build.
+func ContainerPath(p string, environment screwdriver.EnvVars) (string, error) {
+	defaults := map[string]string{
+		"SD_ROOT_DIR":      "/sd/workspace",
+		"SD_SOURCE_DIR":    path.Join("/sd/workspace/src", scmHost, orgRepo),
+		"SD_ARTIFACTS_DIR": defaultArtDir,
+	}
+
+	expanded := os.Expand(p, func(key string) string {
+		if v := GetEnv(environment, key); v != "" {
+			return v
+		}
+		return defaults[key]
+	})
+
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") {
+		expanded = "/root" + strings.TrimPrefix(expanded, "~")
+	}
+
+	if !path.IsAbs(expanded) {
+		return "", fmt.Errorf("`%s` is not an absolute path in the build container", p)
+	}
+
+	return path.Clean(expanded), nil
+}
+
+const (
+	binVolumePrefix       = "SD_LAUNCH_BIN"
+	habVolumePrefix       = "SD_LAUNCH_HAB"
+	dindVolumePrefix      = "SD_DIND_CERT"
+	dindShareVolumePrefix = "SD_DIND_SHARE"
+	dindContainerPrefix   = "sd-local-dind"
+	dindNetworkPrefix     = "sd-local-dind-bridge"
+)
+
+var invalidResourceNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// resourceName returns the name of a volume, container or network which is unique to the build.
+// The fixed name is used when the build has no name, so that a single build keeps the same resources as before.
+func resourceName(name, buildName string) string {
+	if buildName == "" {
+		return name
+	}
+
+	return name + "-" + invalidResourceNameChars.ReplaceAllString(buildName, "-")
+}
+
+// labelArgs returns the options of the docker and podman commands to put the labels, sorted by the keys
+func labelArgs(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		args = append(args, "--label", k+"="+labels[k])
+	}
+
+	return args
+}
+
+// Resources returns the volumes, the container and the network which the build creates with the fixed names.
+// The build containers are not included, which are found by the labels since they have no names.
+func Resources(option Option) []state.Resource {
+	runtime := option.Runtime
+	if runtime == "" {
+		runtime = DockerRuntime
+	}
+	sudo := option.UseSudo && runtime == DockerRuntime
+
+	resource := func(kind, prefix string) state.Resource {
+		return state.Resource{Runtime: runtime, Sudo: sudo, Kind: kind, Name: resourceName(prefix, option.BuildName)}
+	}
+
+	resources := []state.Resource{
+		resource(state.VolumeKind, binVolumePrefix),
+		resource(state.VolumeKind, habVolumePrefix),
+	}
+	if dindEnabled, _ := option.Job.Annotations["screwdriver.cd/dockerEnabled"].(bool); dindEnabled {
+		resources = append(resources,
+			resource(state.ContainerKind, dindContainerPrefix),
+			resource(state.NetworkKind, dindNetworkPrefix),
+			resource(state.VolumeKind, dindVolumePrefix),
+			resource(state.VolumeKind, dindShareVolumePrefix),
+		)
+	}
+
+	return resources
+}
+
+// metaVolumes returns the volume to share the meta directory of the build container with the host
+func metaVolumes(buildEntry buildEntry) []string {
+	if buildEntry.MetaPath == "" {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s/:%s", buildEntry.MetaPath, defaultMetaDir)}
+}
+
 // New creates new Launcher interface.
 func New(option Option) Launcher {
 	l := new(launch)
 	dindEnabled, _ := option.Job.Annotations["screwdriver.cd/dockerEnabled"].(bool)
 
-	l.runner = newDocker(option.Entry.Launcher.Image, option.Entry.Launcher.Version, option.UseSudo, option.InteractiveMode, option.SdUtilsPath, option.SocketPath, option.FlagVerbose, option.LocalVolumes, option.BuildUser, option.NoImagePull, dindEnabled)
+	switch option.Runtime {
+	case PodmanRuntime:
+		l.runtime = PodmanRuntime
+		l.runner = newPodman(option.Entry.Launcher.Image, option.Entry.Launcher.Version, option.InteractiveMode, option.SdUtilsPath, option.SocketPath, option.FlagVerbose, option.LocalVolumes, option.BuildUser, option.NoImagePull, dindEnabled, option.BuildName, option.Labels)
+	case DockerAPIRuntime:
+		l.runtime = DockerAPIRuntime
+		l.runner = newDockerAPI(option.Entry.Launcher.Image, option.Entry.Launcher.Version, option.InteractiveMode, option.SocketPath, option.FlagVerbose, option.LocalVolumes, option.BuildUser, option.NoImagePull, dindEnabled, option.BuildName, option.Labels)
+	default:
+		l.runtime = DockerRuntime
+		l.runner = newDocker(option.Entry.Launcher.Image, option.Entry.Launcher.Version, option.UseSudo, option.InteractiveMode, option.SdUtilsPath, option.SocketPath, option.FlagVerbose, option.LocalVolumes, option.BuildUser, option.NoImagePull, dindEnabled, option.BuildName, option.Labels)
+	}
 	l.buildEntry = createBuildEntry(option)
 
 	return l
 }
 
 // Run runs the build specified.
+// It can be called again after the build finishes or is killed, e.g. in watch mode.
+// Then the launcher volumes and the pulled images are reused until Clean is called.
 func (l *launch) Run() error {
-	if _, err := lookPath("docker"); err != nil {
-		return fmt.Errorf("`docker` command is not found in $PATH: %v", err)
-	}
+	if l.prepared {
+		l.runner.skipImagePull()
+	} else {
+		if command, ok := runtimeCommands[l.runtime]; ok {
+			if _, err := lookPath(command); err != nil {
+				return &RuntimeError{Err: fmt.Errorf("`%s` command is not found in $PATH: %v", command, err)}
+			}
+		}
 
-	if err := l.runner.setupBin(); err != nil {
-		return fmt.Errorf("failed to setup build: %v", err)
+		if err := l.runner.setupBin(); err != nil {
+			return &RuntimeError{Err: fmt.Errorf("failed to setup build: %v", err)}
+		}
+		l.prepared = true
 	}
 
 	err := l.runner.runBuild(l.buildEntry)
 	if err != nil {
-		return fmt.Errorf("failed to run build: %v", err)
+		var stepErr *StepError
+		if errors.As(err, &stepErr) {
+			return fmt.Errorf("failed to run build: %w", err)
+		}
+		return &RuntimeError{Err: fmt.Errorf("failed to run build: %w", err)}
 	}
 
 	return nil
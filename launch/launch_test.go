@@ -2,6 +2,7 @@ package launch
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -114,13 +115,175 @@ func TestNew(t *testing.T) {
 		assert.True(t, ok)
 		assert.Equal(t, expectedBuildEntry, l.buildEntry)
 	})
+
+	t.Run("success with only the secrets declared in the job", func(t *testing.T) {
+		buf, _ := os.ReadFile(filepath.Join(testDir, "job.json"))
+		job := screwdriver.Job{}
+		_ = json.Unmarshal(buf, &job)
+		job.Annotations = map[string]interface{}{}
+		job.Secrets = []string{"GIT_KEY"}
+
+		config := config.Entry{
+			APIURL:   "http://api-test.screwdriver.cd",
+			StoreURL: "http://store-test.screwdriver.cd",
+			Token:    "testtoken",
+			Launcher: config.Launcher{Version: "latest", Image: "screwdrivercd/launcher"},
+		}
+
+		expectedBuildEntry := newBuildEntry()
+		expectedBuildEntry.Environment[1] = map[string]string{"SD_ARTIFACTS_DIR": "/sd/workspace/artifacts"}
+		expectedBuildEntry.Environment[2] = map[string]string{"SD_UTILS_DIR": "/sd/workspace/sd-utils"}
+		expectedBuildEntry.Environment = append(expectedBuildEntry.Environment, map[string]string{"GIT_KEY": "key"}, map[string]string{"BAR": "bar"})
+
+		option := Option{
+			Job:           job,
+			Entry:         config,
+			JobName:       "test",
+			JWT:           "testjwt",
+			ArtifactsPath: "sd-artifacts",
+			SdUtilsPath:   ".sd-utils",
+			Meta:          Meta{},
+			OptionEnv:     screwdriver.EnvVars{{"BAR": "bar"}},
+			Secrets:       map[string]string{"GIT_KEY": "key", "NPM_TOKEN": "npm"},
+		}
+
+		launcher := New(option)
+		l, ok := launcher.(*launch)
+		assert.True(t, ok)
+		assert.Equal(t, expectedBuildEntry, l.buildEntry)
+	})
+}
+
+func TestNewWithRuntime(t *testing.T) {
+	buf, _ := os.ReadFile(filepath.Join(testDir, "job.json"))
+	job := screwdriver.Job{}
+	_ = json.Unmarshal(buf, &job)
+
+	testCase := []struct {
+		name          string
+		runtime       string
+		expectRuntime string
+		expectRunner  runner
+	}{
+		{"default runtime", "", DockerRuntime, &docker{}},
+		{"docker runtime", DockerRuntime, DockerRuntime, &docker{}},
+		{"podman runtime", PodmanRuntime, PodmanRuntime, &podman{}},
+		{"docker-api runtime", DockerAPIRuntime, DockerAPIRuntime, &dockerAPI{}},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			launcher := New(Option{Job: job, Runtime: tt.runtime})
+			l, ok := launcher.(*launch)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expectRuntime, l.runtime)
+			assert.IsType(t, tt.expectRunner, l.runner)
+		})
+	}
+}
+
+func TestNewWithBuildName(t *testing.T) {
+	testCase := []struct {
+		name      string
+		buildName string
+		volume    string
+		dind      string
+		network   string
+	}{
+		{"without build name", "", "SD_LAUNCH_BIN", "sd-local-dind", "sd-local-dind-bridge"},
+		{"with build name", "lint", "SD_LAUNCH_BIN-lint", "sd-local-dind-lint", "sd-local-dind-bridge-lint"},
+		{"with build name including invalid characters", "sd@123:main", "SD_LAUNCH_BIN-sd-123-main", "sd-local-dind-sd-123-main", "sd-local-dind-bridge-sd-123-main"},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			launcher := New(Option{BuildName: tt.buildName})
+			d, ok := launcher.(*launch).runner.(*docker)
+			assert.True(t, ok)
+			assert.Equal(t, tt.volume, d.volume)
+			assert.Equal(t, tt.dind, d.dind.container)
+			assert.Equal(t, tt.network, d.dind.network)
+		})
+	}
+}
+
+func TestBuildEntryJSON(t *testing.T) {
+	got, err := BuildEntryJSON(Option{
+		JobName: "test",
+		JWT:     "***",
+		Job:     screwdriver.Job{Image: "node:18", Steps: []screwdriver.Step{{Name: "test", Command: "npm test"}}},
+		Entry:   config.Entry{APIURL: "http://api-test.screwdriver.cd", StoreURL: "http://store-test.screwdriver.cd"},
+	})
+	assert.Nil(t, err)
+
+	b := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(got, &b))
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "test", "command": "npm test"}}, b["steps"])
+	assert.Equal(t, map[string]interface{}{"SD_TOKEN": "***"}, b["environment"].([]interface{})[0])
+	assert.Contains(t, string(got), "\n  \"steps\": [")
+	assert.NotContains(t, string(got), "SD_LOCAL_TOKEN_URL")
+
+	t.Run("success with token server", func(t *testing.T) {
+		got, err := BuildEntryJSON(Option{
+			JobName:  "test",
+			TokenURL: "http://sd-local.host:8765/token",
+			TokenKey: "key",
+		})
+		assert.Nil(t, err)
+
+		b := buildEntry{}
+		assert.Nil(t, json.Unmarshal(got, &b))
+		assert.Equal(t, "http://sd-local.host:8765/token", GetEnv(b.Environment, "SD_LOCAL_TOKEN_URL"))
+		assert.Equal(t, "key", GetEnv(b.Environment, "SD_LOCAL_TOKEN_KEY"))
+	})
+}
+
+func TestContainerPath(t *testing.T) {
+	env := screwdriver.EnvVars{{"GOPATH": "/go"}, {"SD_ROOT_DIR": "/workspace"}}
+
+	testCases := []struct {
+		name      string
+		path      string
+		want      string
+		wantError string
+	}{
+		{"absolute path", "/tmp/cache/", "/tmp/cache", ""},
+		{"source dir", "$SD_SOURCE_DIR/node_modules", "/sd/workspace/src/screwdriver.cd/sd-local/local-build/node_modules", ""},
+		{"environment of the job", "${GOPATH}/pkg/mod", "/go/pkg/mod", ""},
+		{"environment overriding the default", "$SD_ROOT_DIR/cache", "/workspace/cache", ""},
+		{"home directory", "~/.npm", "/root/.npm", ""},
+		{"relative path", "node_modules", "", "`node_modules` is not an absolute path in the build container"},
+		{"unknown environment", "$UNKNOWN", "", "`$UNKNOWN` is not an absolute path in the build container"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ContainerPath(tt.path, env)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateRuntime(t *testing.T) {
+	assert.Nil(t, ValidateRuntime(""))
+	assert.Nil(t, ValidateRuntime("docker"))
+	assert.Nil(t, ValidateRuntime("podman"))
+	assert.Nil(t, ValidateRuntime("docker-api"))
+	assert.Equal(t, fmt.Errorf("unsupported container runtime `rkt`, must be one of [docker podman docker-api]"), ValidateRuntime("rkt"))
 }
 
 type mockRunner struct {
-	errorRunBuild    error
-	errorSetupBin    error
-	killCalledCount  int
-	cleanCalledCount int
+	errorRunBuild      error
+	errorSetupBin      error
+	killCalledCount    int
+	cleanCalledCount   int
+	setupBinCount      int
+	skipImagePullCount int
 }
 
 func (m *mockRunner) runBuild(buildEntry buildEntry) error {
@@ -128,9 +291,14 @@ func (m *mockRunner) runBuild(buildEntry buildEntry) error {
 }
 
 func (m *mockRunner) setupBin() error {
+	m.setupBinCount++
 	return m.errorSetupBin
 }
 
+func (m *mockRunner) skipImagePull() {
+	m.skipImagePullCount++
+}
+
 func (m *mockRunner) clean() {
 	m.cleanCalledCount++
 }
@@ -151,6 +319,7 @@ func TestRun(t *testing.T) {
 				errorRunBuild: nil,
 				errorSetupBin: nil,
 			},
+			runtime: DockerRuntime,
 		}
 
 		lookPath = func(cmd string) (string, error) {
@@ -166,6 +335,28 @@ func TestRun(t *testing.T) {
 		assert.Equal(t, nil, err)
 	})
 
+	t.Run("success to run again reusing the volumes and images", func(t *testing.T) {
+		runner := &mockRunner{}
+		launch := launch{
+			buildEntry: newBuildEntry(),
+			runner:     runner,
+			runtime:    DockerRuntime,
+		}
+
+		lookPath = func(cmd string) (string, error) {
+			return "/bin/docker", nil
+		}
+
+		defer func() {
+			lookPath = exec.LookPath
+		}()
+
+		assert.Nil(t, launch.Run())
+		assert.Nil(t, launch.Run())
+		assert.Equal(t, 1, runner.setupBinCount)
+		assert.Equal(t, 1, runner.skipImagePullCount)
+	})
+
 	t.Run("failure in lookPath", func(t *testing.T) {
 		buf, _ := os.ReadFile(filepath.Join(testDir, "job.json"))
 		job := screwdriver.Job{}
@@ -177,6 +368,7 @@ func TestRun(t *testing.T) {
 				errorRunBuild: nil,
 				errorSetupBin: nil,
 			},
+			runtime: DockerRuntime,
 		}
 
 		lookPath = func(cmd string) (string, error) {
@@ -189,7 +381,48 @@ func TestRun(t *testing.T) {
 
 		err := launch.Run()
 
-		assert.Equal(t, fmt.Errorf("`docker` command is not found in $PATH: exec: \"docker\": executable file not found in $PATH"), err)
+		assert.EqualError(t, err, "`docker` command is not found in $PATH: exec: \"docker\": executable file not found in $PATH")
+		assert.IsType(t, &RuntimeError{}, err)
+	})
+
+	t.Run("failure in lookPath with podman", func(t *testing.T) {
+		launch := launch{
+			buildEntry: newBuildEntry(),
+			runner:     &mockRunner{},
+			runtime:    PodmanRuntime,
+		}
+
+		lookPath = func(cmd string) (string, error) {
+			assert.Equal(t, "podman", cmd)
+			return "", fmt.Errorf("exec: \"podman\": executable file not found in $PATH")
+		}
+
+		defer func() {
+			lookPath = exec.LookPath
+		}()
+
+		err := launch.Run()
+
+		assert.EqualError(t, err, "`podman` command is not found in $PATH: exec: \"podman\": executable file not found in $PATH")
+		assert.IsType(t, &RuntimeError{}, err)
+	})
+
+	t.Run("success without lookPath with docker-api", func(t *testing.T) {
+		launch := launch{
+			buildEntry: newBuildEntry(),
+			runner:     &mockRunner{},
+			runtime:    DockerAPIRuntime,
+		}
+
+		lookPath = func(cmd string) (string, error) {
+			return "", fmt.Errorf("exec: %q: executable file not found in $PATH", cmd)
+		}
+
+		defer func() {
+			lookPath = exec.LookPath
+		}()
+
+		assert.Nil(t, launch.Run())
 	})
 
 	t.Run("failure in SetupBin", func(t *testing.T) {
@@ -203,6 +436,7 @@ func TestRun(t *testing.T) {
 				errorRunBuild: nil,
 				errorSetupBin: fmt.Errorf("docker: Error response from daemon"),
 			},
+			runtime: DockerRuntime,
 		}
 
 		lookPath = func(cmd string) (string, error) {
@@ -215,7 +449,8 @@ func TestRun(t *testing.T) {
 
 		err := launch.Run()
 
-		assert.Equal(t, fmt.Errorf("failed to setup build: docker: Error response from daemon"), err)
+		assert.EqualError(t, err, "failed to setup build: docker: Error response from daemon")
+		assert.IsType(t, &RuntimeError{}, err)
 	})
 
 	t.Run("failure in RunBuild", func(t *testing.T) {
@@ -229,6 +464,7 @@ func TestRun(t *testing.T) {
 				errorRunBuild: fmt.Errorf("docker: Error response from daemon"),
 				errorSetupBin: nil,
 			},
+			runtime: DockerRuntime,
 		}
 
 		lookPath = func(cmd string) (string, error) {
@@ -241,8 +477,62 @@ func TestRun(t *testing.T) {
 
 		err := launch.Run()
 
-		assert.Equal(t, fmt.Errorf("failed to run build: docker: Error response from daemon"), err)
+		assert.EqualError(t, err, "failed to run build: docker: Error response from daemon")
+		assert.IsType(t, &RuntimeError{}, err)
 	})
+
+	t.Run("failure in a step", func(t *testing.T) {
+		launch := launch{
+			buildEntry: newBuildEntry(),
+			runner: &mockRunner{
+				errorRunBuild: fmt.Errorf("failed to run build container: %w", &StepError{Code: 2, Err: fmt.Errorf("exit status 2")}),
+			},
+			runtime: DockerRuntime,
+		}
+
+		lookPath = func(cmd string) (string, error) {
+			return "/bin/docker", nil
+		}
+
+		defer func() {
+			lookPath = exec.LookPath
+		}()
+
+		err := launch.Run()
+
+		assert.EqualError(t, err, "failed to run build: failed to run build container: exit status 2")
+		var stepErr *StepError
+		assert.True(t, errors.As(err, &stepErr))
+		assert.Equal(t, 2, stepErr.ExitCode())
+	})
+}
+
+func TestStepError(t *testing.T) {
+	exitError := func(code int) error {
+		return exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	}
+
+	testCases := []struct {
+		name     string
+		err      error
+		wantStep bool
+	}{
+		{name: "failed step", err: exitError(2), wantStep: true},
+		{name: "step exited with 1", err: exitError(1), wantStep: true},
+		{name: "runtime failure", err: exitError(125)},
+		{name: "command not executable", err: exitError(126)},
+		{name: "command not found", err: exitError(127)},
+		{name: "not exit error", err: errors.New("failed to start")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := stepError(tt.err)
+			var stepErr *StepError
+			assert.Equal(t, tt.wantStep, errors.As(err, &stepErr))
+			assert.Equal(t, tt.err.Error(), err.Error())
+		})
+	}
 }
 
 func TestKill(t *testing.T) {
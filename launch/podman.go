@@ -0,0 +1,381 @@
+package launch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+type podman struct {
+	volume            string
+	habVolume         string
+	setupImage        string
+	setupImageVersion string
+	interactiveMode   bool
+	sdUtilsPath       string
+	commands          []*exec.Cmd
+	mutex             *sync.Mutex
+	flagVerbose       bool
+	interact          Interacter
+	socketPath        string
+	localVolumes      []string
+	buildUser         string
+	noImagePull       bool
+	dind              DinD
+	labels            map[string]string
+}
+
+var _ runner = (*podman)(nil)
+
+func newPodman(setupImage, setupImageVer string, interactiveMode bool, sdUtilsPath string, socketPath string, flagVerbose bool, localVolumes []string, buildUser string, noImagePull bool, dindEnabled bool, buildName string, labels map[string]string) runner {
+	return &podman{
+		volume:            resourceName(binVolumePrefix, buildName),
+		habVolume:         resourceName(habVolumePrefix, buildName),
+		setupImage:        setupImage,
+		setupImageVersion: setupImageVer,
+		interactiveMode:   interactiveMode,
+		commands:          make([]*exec.Cmd, 0, 10),
+		mutex:             &sync.Mutex{},
+		flagVerbose:       flagVerbose,
+		interact:          &Interact{flagVerbose: flagVerbose},
+		sdUtilsPath:       sdUtilsPath,
+		socketPath:        socketPath,
+		localVolumes:      localVolumes,
+		buildUser:         buildUser,
+		noImagePull:       noImagePull,
+		dind: DinD{
+			enabled:         dindEnabled,
+			volume:          resourceName(dindVolumePrefix, buildName),
+			shareVolumeName: resourceName(dindShareVolumePrefix, buildName),
+			shareVolumePath: "/opt/sd_dind_share",
+			container:       resourceName(dindContainerPrefix, buildName),
+			network:         resourceName(dindNetworkPrefix, buildName),
+			image:           "docker:23.0.1-dind-rootless",
+		},
+		labels: labels,
+	}
+}
+
+// createVolume creates the volume with the labels. It is not an error if the volume exists.
+func (p *podman) createVolume(name string) error {
+	args := append(append([]string{"volume", "create", "--ignore"}, labelArgs(p.labels)...), name)
+	_, err := p.execPodmanCommand(args...)
+	return err
+}
+
+func (p *podman) setupBin() error {
+	image := fmt.Sprintf("%s:%s", p.setupImage, p.setupImageVersion)
+
+	if !p.noImagePull {
+		_, err := p.execPodmanCommand("pull", image)
+		if err != nil {
+			return fmt.Errorf("failed to pull launcher image: %v", err)
+		}
+	}
+
+	// Unlike docker, podman does not reliably populate volumes which are implicitly created by `-v`.
+	// The volumes are created explicitly and the `copy` option asks podman to copy the content of the image into them.
+	for _, v := range []string{p.volume, p.habVolume} {
+		if err := p.createVolume(v); err != nil {
+			return fmt.Errorf("failed to create volume %s: %v", v, err)
+		}
+	}
+
+	mount := fmt.Sprintf("%s:/opt/sd/:copy", p.volume)
+	habMount := fmt.Sprintf("%s:/hab:copy", p.habVolume)
+
+	args := append([]string{"container", "run", "--rm", "--pull=never"}, labelArgs(p.labels)...)
+	args = append(args, "-v", mount, "-v", habMount, "--entrypoint", "/bin/echo", image, "set up bin")
+	_, err := p.execPodmanCommand(args...)
+	if err != nil {
+		return fmt.Errorf("failed to prepare build scripts: %v", err)
+	}
+
+	return nil
+}
+
+func (p *podman) runBuild(buildEntry buildEntry) error {
+	podmanCommandArgs := []string{"container", "run"}
+	// SELinux labels are disabled instead of relabeling the bind mounted host directories.
+	podmanCommandOptions := []string{"--rm", "--security-opt", "label=disable", "--entrypoint", "/bin/sh", "-e", "SSH_AUTH_SOCK=/tmp/auth.sock"}
+
+	if p.dind.enabled {
+		if err := p.runDinD(); err != nil {
+			return fmt.Errorf("failed to prepare dind container: %v", err)
+		}
+
+		podmanCommandOptions = append(
+			[]string{
+				"--network", p.dind.network,
+				"-e", "DOCKER_TLS_CERTDIR=/certs",
+				"-e", "DOCKER_HOST=tcp://docker:2376",
+				"-e", "DOCKER_TLS_VERIFY=1",
+				"-e", "DOCKER_CERT_PATH=/certs/client",
+				"-e", fmt.Sprintf("SD_DIND_SHARE_PATH=%s", p.dind.shareVolumePath),
+				"-v", fmt.Sprintf("%s:/certs/client:ro", p.dind.volume),
+				"-v", fmt.Sprintf("%s:%s", p.dind.shareVolumeName, p.dind.shareVolumePath),
+			},
+			podmanCommandOptions...)
+	}
+
+	environment := buildEntry.Environment
+
+	srcDir := buildEntry.SrcPath
+	hostArtDir := buildEntry.ArtifactsPath
+	containerArtDir := GetEnv(environment, "SD_ARTIFACTS_DIR")
+	buildImage := buildEntry.Image
+	logfilePath := filepath.Join(containerArtDir, LogFile)
+
+	srcVol := fmt.Sprintf("%s/:/sd/workspace/src/%s/%s", srcDir, scmHost, orgRepo)
+	artVol := fmt.Sprintf("%s/:%s", hostArtDir, containerArtDir)
+	binVol := fmt.Sprintf("%s:%s", p.volume, "/opt/sd")
+	habVol := fmt.Sprintf("%s:%s", p.habVolume, "/opt/sd/hab")
+
+	podmanVolumes := append(p.localVolumes, srcVol, artVol, binVol, habVol, fmt.Sprintf("%s:/tmp/auth.sock:rw", p.socketPath))
+	podmanVolumes = append(podmanVolumes, metaVolumes(buildEntry)...)
+	for _, v := range podmanVolumes {
+		podmanCommandOptions = append(podmanCommandOptions, "-v", v)
+	}
+
+	if buildEntry.MemoryLimit != "" {
+		podmanCommandOptions = append(podmanCommandOptions, fmt.Sprintf("-m%s", buildEntry.MemoryLimit))
+	}
+
+	if buildEntry.UsePrivileged {
+		podmanCommandOptions = append(podmanCommandOptions, "--privileged")
+	}
+
+	if GetEnv(environment, "SD_LOCAL_TOKEN_URL") != "" {
+		podmanCommandOptions = append(podmanCommandOptions, "--add-host", TokenHost+":host-gateway")
+	}
+
+	if p.interactiveMode {
+		if err := setupInteractiveMode(p.sdUtilsPath, &buildEntry); err != nil {
+			return err
+		}
+
+		hostSdUtilsDir := p.sdUtilsPath
+		containerSdUtilsDir := GetEnv(environment, "SD_UTILS_DIR")
+		utlVol := fmt.Sprintf("%s/:%s", hostSdUtilsDir, containerSdUtilsDir)
+
+		podmanCommandOptions = append(podmanCommandOptions, "-itd", "-v", utlVol)
+	}
+
+	if p.buildUser != "" {
+		podmanCommandOptions = append(podmanCommandOptions, fmt.Sprintf("-u%s", p.buildUser))
+	}
+
+	podmanCommandOptions = append(podmanCommandOptions, labelArgs(p.labels)...)
+
+	// Build image is explicitly pulled when the --no-image-pull option is not used
+	podmanCommandOptions = append(podmanCommandOptions, "--pull=never", buildImage)
+
+	configJSON, err := json.Marshal(buildEntry)
+	if err != nil {
+		return err
+	}
+
+	configJSONArg := string(configJSON)
+	if p.interactiveMode {
+		configJSONArg = fmt.Sprintf("%q", configJSONArg)
+	}
+
+	launchCommands := []string{
+		"/opt/sd/local_run.sh",
+		configJSONArg,
+		buildEntry.JobName,
+		GetEnv(environment, "SD_API_URL"),
+		GetEnv(environment, "SD_STORE_URL"),
+		logfilePath,
+	}
+
+	if !p.noImagePull {
+		logrus.Infof("Pulling podman image from %s...", buildImage)
+
+		if _, err := p.execPodmanCommand("pull", buildImage); err != nil {
+			return fmt.Errorf("failed to pull user image %v", err)
+		}
+	}
+
+	if p.interactiveMode {
+		cid, err := p.execPodmanCommand(append(podmanCommandArgs, podmanCommandOptions...)...)
+		if err != nil {
+			return fmt.Errorf("failed to run build container: %v", err)
+		}
+
+		commands := [][]string{
+			launchCommands,
+			{"set", "-a"},
+			{".", "/tmp/sd-local.env"},
+			{"set", "+a"},
+			{"export", "PS1='sd-local# '"},
+			{"sdrun() { . /$SD_UTILS_DIR/bin/sdrun $@; }"},
+			{". /$SD_UTILS_DIR/bin/sdrun-bash-completion"},
+			{"cd", "$SD_CHECKOUT_DIR"},
+		}
+
+		c := execCommand("podman", "attach", cid)
+		if p.flagVerbose {
+			logrus.Infof("$ %s", c.String())
+		}
+
+		if err := p.interact.Run(c, commands); err != nil {
+			return fmt.Errorf("failed to attach build container: %v", err)
+		}
+	} else {
+		podmanCommandOptions = append(podmanCommandOptions, launchCommands...)
+
+		if _, err := p.execPodmanCommand(append(podmanCommandArgs, podmanCommandOptions...)...); err != nil {
+			return fmt.Errorf("failed to run build container: %w", stepError(err))
+		}
+	}
+
+	return nil
+}
+
+func (p *podman) runDinD() error {
+	if !p.noImagePull {
+		logrus.Infof("Pulling dind image from %s...", p.dind.image)
+		_, err := p.execPodmanCommand("pull", p.dind.image)
+		if err != nil {
+			return fmt.Errorf("failed to pull user image %v", err)
+		}
+	}
+
+	if _, err := p.execPodmanCommand(append(append([]string{"network", "create"}, labelArgs(p.labels)...), p.dind.network)...); err != nil {
+		return fmt.Errorf("failed to create network: %v", err)
+	}
+
+	for _, v := range []string{p.dind.volume, p.dind.shareVolumeName} {
+		if err := p.createVolume(v); err != nil {
+			return fmt.Errorf("failed to create volume %s: %v", v, err)
+		}
+	}
+
+	podmanCommandArgs := []string{"container", "run"}
+	podmanCommandOptions := []string{
+		"--rm",
+		"--privileged",
+		"--pull=never",
+		"--name", p.dind.container,
+		"-d",
+		"--network", p.dind.network,
+		"--network-alias", "docker",
+		"-e", "DOCKER_TLS_CERTDIR=/certs",
+		"-v", fmt.Sprintf("%s:/certs/client", p.dind.volume),
+		"-v", fmt.Sprintf("%s:%s", p.dind.shareVolumeName, p.dind.shareVolumePath),
+	}
+	podmanCommandOptions = append(append(podmanCommandOptions, labelArgs(p.labels)...), p.dind.image)
+
+	if _, err := p.execPodmanCommand(append(podmanCommandArgs, podmanCommandOptions...)...); err != nil {
+		return fmt.Errorf("failed to run dind container: %v", err)
+	}
+
+	return nil
+}
+
+func (p *podman) execPodmanCommand(args ...string) (string, error) {
+	cmd := execCommand("podman", args...)
+	if p.flagVerbose {
+		logrus.Infof("$ podman %s", strings.Join(args, " "))
+	}
+	p.mutex.Lock()
+	p.commands = append(p.commands, cmd)
+	p.mutex.Unlock()
+	buf := bytes.NewBuffer(nil)
+	cmd.Stderr = buf
+	out, err := cmd.Output()
+	if p.flagVerbose {
+		logrus.Infof("%s", out)
+	}
+	if err != nil {
+		io.Copy(Stderr, buf)
+	}
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+func (p *podman) kill(sig os.Signal) {
+	killedCmds := make([]*exec.Cmd, 0, 10)
+
+	p.mutex.Lock()
+	commands := append([]*exec.Cmd{}, p.commands...)
+	p.mutex.Unlock()
+
+	for _, v := range commands {
+		p.mutex.Lock()
+		finished := v.ProcessState != nil || v.Process == nil
+		p.mutex.Unlock()
+		if finished {
+			continue
+		}
+
+		// rootless podman runs with the privileges of the current user, so sudo is never needed.
+		if err := signalFn(v.Process, sig); err != nil {
+			logrus.Warn(fmt.Errorf("failed to stop process: %v", err))
+		} else {
+			killedCmds = append(killedCmds, v)
+		}
+	}
+
+	err := waitForProcess(p.mutex, killedCmds)
+	if err != nil {
+		logrus.Warn(err)
+	}
+}
+
+func (p *podman) clean() {
+	// Since the habVolume is mounted inside the mountpoint for volume, it must be removed first.
+	_, err := p.execPodmanCommand("volume", "rm", "--force", p.habVolume)
+
+	if err != nil {
+		logrus.Warn(fmt.Errorf("failed to remove hab volume: %v", err))
+	}
+
+	_, err = p.execPodmanCommand("volume", "rm", "--force", p.volume)
+
+	if err != nil {
+		logrus.Warn(fmt.Errorf("failed to remove volume: %v", err))
+	}
+
+	if err := os.RemoveAll(p.sdUtilsPath); err != nil {
+		logrus.Warn(fmt.Errorf("failed to remove sd-utils directory %s: %v", p.sdUtilsPath, err))
+	}
+
+	if p.dind.enabled {
+		_, err = p.execPodmanCommand("kill", p.dind.container)
+
+		if err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind container: %v", err))
+		}
+
+		_, err = p.execPodmanCommand("network", "rm", "--force", p.dind.network)
+
+		if err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind network: %v", err))
+		}
+
+		_, err = p.execPodmanCommand("volume", "rm", "--force", p.dind.volume)
+
+		if err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind volume: %v", err))
+		}
+
+		_, err = p.execPodmanCommand("volume", "rm", "--force", p.dind.shareVolumeName)
+
+		if err != nil {
+			logrus.Warn(fmt.Errorf("failed to remove dind share volume: %v", err))
+		}
+	}
+}
+
+func (p *podman) skipImagePull() {
+	p.noImagePull = true
+}
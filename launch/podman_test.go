@@ -0,0 +1,394 @@
+package launch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPodman(options ...func(p *podman)) *podman {
+	p := &podman{
+		volume:            "SD_LAUNCH_BIN",
+		habVolume:         "SD_LAUNCH_HAB",
+		setupImage:        "launcher",
+		setupImageVersion: "latest",
+		commands:          make([]*exec.Cmd, 0, 10),
+		mutex:             &sync.Mutex{},
+		socketPath:        os.Getenv("SSH_AUTH_SOCK"),
+		dind: DinD{
+			enabled:         false,
+			volume:          "SD_DIND_CERT",
+			shareVolumeName: "SD_DIND_SHARE",
+			shareVolumePath: "/opt/sd_dind_share",
+			container:       "sd-local-dind",
+			network:         "sd-local-dind-bridge",
+			image:           "docker:23.0.1-dind-rootless",
+		},
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+func TestNewPodman(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		expected := &podman{
+			volume:            "SD_LAUNCH_BIN",
+			habVolume:         "SD_LAUNCH_HAB",
+			setupImage:        "launcher",
+			setupImageVersion: "latest",
+			interactiveMode:   false,
+			sdUtilsPath:       ".sd-utils",
+			commands:          make([]*exec.Cmd, 0, 10),
+			mutex:             &sync.Mutex{},
+			flagVerbose:       false,
+			interact:          &Interact{},
+			socketPath:        "/auth.sock",
+			localVolumes:      []string{"path:path"},
+			buildUser:         "jithin",
+			noImagePull:       false,
+			dind: DinD{
+				enabled:         true,
+				volume:          "SD_DIND_CERT",
+				shareVolumeName: "SD_DIND_SHARE",
+				shareVolumePath: "/opt/sd_dind_share",
+				container:       "sd-local-dind",
+				network:         "sd-local-dind-bridge",
+				image:           "docker:23.0.1-dind-rootless",
+			},
+		}
+
+		p := newPodman("launcher", "latest", false, ".sd-utils", "/auth.sock", false, []string{"path:path"}, "jithin", false, true, "", nil)
+
+		assert.Equal(t, expected, p)
+	})
+}
+
+func TestPodmanSetupBin(t *testing.T) {
+	defer func() {
+		execCommand = exec.Command
+	}()
+
+	testCase := []struct {
+		name             string
+		id               string
+		noImagePull      bool
+		expectError      error
+		expectedCommands []string
+	}{
+		{"success", "SUCCESS_SETUP_BIN", false, nil,
+			[]string{
+				"podman pull launcher:latest",
+				"podman volume create --ignore SD_LAUNCH_BIN",
+				"podman volume create --ignore SD_LAUNCH_HAB",
+				"podman container run --rm --pull=never -v SD_LAUNCH_BIN:/opt/sd/:copy -v SD_LAUNCH_HAB:/hab:copy --entrypoint /bin/echo launcher:latest set up bin",
+			}},
+		{"success with no image pull", "SUCCESS_SETUP_BIN", true, nil,
+			[]string{
+				"podman volume create --ignore SD_LAUNCH_BIN",
+				"podman volume create --ignore SD_LAUNCH_HAB",
+				"podman container run --rm --pull=never -v SD_LAUNCH_BIN:/opt/sd/:copy -v SD_LAUNCH_HAB:/hab:copy --entrypoint /bin/echo launcher:latest set up bin",
+			}},
+		{"failure container run", "FAIL_CONTAINER_RUN", false, fmt.Errorf("failed to prepare build scripts: exit status 1"), []string{}},
+		{"failure launcher image pull", "FAIL_LAUNCHER_PULL", false, fmt.Errorf("failed to pull launcher image: exit status 1"), []string{}},
+		{"failure volume create", "FAIL_PODMAN_VOLUME_CREATE", false, fmt.Errorf("failed to create volume SD_LAUNCH_BIN: exit status 1"), []string{}},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPodman(func(p *podman) {
+				p.noImagePull = tt.noImagePull
+			})
+			c := newFakeExecCommand(tt.id)
+			execCommand = c.execCmd
+			err := p.setupBin()
+
+			assert.Equal(t, tt.expectedCommands, c.commands[:len(tt.expectedCommands)])
+			assert.Equal(t, tt.expectError, err)
+		})
+	}
+
+	t.Run("success with labels", func(t *testing.T) {
+		p := newTestPodman(func(p *podman) {
+			p.noImagePull = true
+			p.labels = map[string]string{"a": "1"}
+		})
+		c := newFakeExecCommand("SUCCESS_SETUP_BIN")
+		execCommand = c.execCmd
+
+		assert.Nil(t, p.setupBin())
+		assert.Equal(t, []string{
+			"podman volume create --ignore --label a=1 SD_LAUNCH_BIN",
+			"podman volume create --ignore --label a=1 SD_LAUNCH_HAB",
+			"podman container run --rm --pull=never --label a=1 -v SD_LAUNCH_BIN:/opt/sd/:copy -v SD_LAUNCH_HAB:/hab:copy --entrypoint /bin/echo launcher:latest set up bin",
+		}, c.commands)
+	})
+}
+
+func TestPodmanRunBuild(t *testing.T) {
+	defer func() {
+		execCommand = exec.Command
+	}()
+
+	testCase := []struct {
+		name             string
+		id               string
+		podman           *podman
+		expectError      error
+		expectedCommands []string
+		buildEntry       buildEntry
+	}{
+		{"success", "SUCCESS_RUN_BUILD", newTestPodman(), nil,
+			[]string{
+				"podman pull node:12",
+				fmt.Sprintf("podman container run --rm --security-opt label=disable --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v SD_LAUNCH_BIN:/opt/sd -v SD_LAUNCH_HAB:/opt/sd/hab -v %s --pull=never node:12 /opt/sd/local_run.sh ", sshSocket)},
+			newBuildEntry()},
+		{"success with memory limit and build user", "SUCCESS_RUN_BUILD", newTestPodman(func(p *podman) { p.buildUser = "sd-buildbot" }), nil,
+			[]string{
+				"podman pull node:12",
+				fmt.Sprintf("podman container run --rm --security-opt label=disable --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v SD_LAUNCH_BIN:/opt/sd -v SD_LAUNCH_HAB:/opt/sd/hab -v %s -m2GB -usd-buildbot --pull=never node:12 /opt/sd/local_run.sh ", sshSocket)},
+			newBuildEntry(func(b *buildEntry) {
+				b.MemoryLimit = "2GB"
+			})},
+		{"success with token server", "SUCCESS_RUN_BUILD", newTestPodman(), nil,
+			[]string{
+				"podman pull node:12",
+				fmt.Sprintf("podman container run --rm --security-opt label=disable --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v SD_LAUNCH_BIN:/opt/sd -v SD_LAUNCH_HAB:/opt/sd/hab -v %s --add-host sd-local.host:host-gateway --pull=never node:12 /opt/sd/local_run.sh ", sshSocket)},
+			newBuildEntry(func(b *buildEntry) {
+				b.Environment = append(b.Environment, map[string]string{"SD_LOCAL_TOKEN_URL": "http://sd-local.host:8765/token"})
+			})},
+		{"success with no image pull", "SUCCESS_RUN_BUILD", newTestPodman(func(p *podman) { p.noImagePull = true }), nil,
+			[]string{
+				fmt.Sprintf("podman container run --rm --security-opt label=disable --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v SD_LAUNCH_BIN:/opt/sd -v SD_LAUNCH_HAB:/opt/sd/hab -v %s --pull=never node:12 /opt/sd/local_run.sh ", sshSocket)},
+			newBuildEntry()},
+		{"success with dind", "SUCCESS_RUN_BUILD", newTestPodman(func(p *podman) { p.dind.enabled = true }), nil,
+			[]string{
+				"podman pull docker:23.0.1-dind-rootless",
+				"podman network create sd-local-dind-bridge",
+				"podman volume create --ignore SD_DIND_CERT",
+				"podman volume create --ignore SD_DIND_SHARE",
+				"podman container run --rm --privileged --pull=never --name sd-local-dind -d --network sd-local-dind-bridge --network-alias docker -e DOCKER_TLS_CERTDIR=/certs -v SD_DIND_CERT:/certs/client -v SD_DIND_SHARE:/opt/sd_dind_share docker:23.0.1-dind-rootless",
+				"podman pull node:12",
+				fmt.Sprintf("podman container run --network sd-local-dind-bridge -e DOCKER_TLS_CERTDIR=/certs -e DOCKER_HOST=tcp://docker:2376 -e DOCKER_TLS_VERIFY=1 -e DOCKER_CERT_PATH=/certs/client -e SD_DIND_SHARE_PATH=/opt/sd_dind_share -v SD_DIND_CERT:/certs/client:ro -v SD_DIND_SHARE:/opt/sd_dind_share --rm --security-opt label=disable --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v SD_LAUNCH_BIN:/opt/sd -v SD_LAUNCH_HAB:/opt/sd/hab -v %s --pull=never node:12 /opt/sd/local_run.sh ", sshSocket)},
+			newBuildEntry(func(b *buildEntry) {
+				b.Annotations["screwdriver.cd/dockerEnabled"] = true
+			})},
+		{"failure build run", "FAIL_BUILD_CONTAINER_RUN", newTestPodman(), fmt.Errorf("failed to run build container: exit status 1"), []string{}, newBuildEntry()},
+		{"failure build image pull", "FAIL_BUILD_IMAGE_PULL", newTestPodman(), fmt.Errorf("failed to pull user image exit status 1"), []string{}, newBuildEntry()},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeExecCommand(tt.id)
+			execCommand = c.execCmd
+			err := tt.podman.runBuild(tt.buildEntry)
+			for i, expectedCommand := range tt.expectedCommands {
+				assert.True(t, strings.Contains(c.commands[i], expectedCommand), "expect %q \nbut got \n%q", expectedCommand, c.commands[i])
+			}
+			if tt.expectError != nil {
+				assert.Equal(t, tt.expectError.Error(), err.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestPodmanRunBuildWithInteractiveMode(t *testing.T) {
+	defer func() {
+		execCommand = exec.Command
+		osMkdirAll = os.MkdirAll
+		osWriteFile = os.WriteFile
+	}()
+
+	steps := []screwdriver.Step{
+		{Name: "step1", Command: "echo 'This is a test command.'"},
+		{Name: "step2", Command: "cat /foo/bar"},
+	}
+
+	p := newTestPodman(func(p *podman) {
+		p.interactiveMode = true
+		p.sdUtilsPath = ".sd-utils"
+		p.interact = &mockInteract{}
+	})
+
+	testCase := []struct {
+		name             string
+		id               string
+		expectError      error
+		expectedCommands []string
+	}{
+		{"success", "SUCCESS_RUN_BUILD", nil,
+			[]string{
+				"podman pull node:12",
+				fmt.Sprintf("podman container run --rm --security-opt label=disable --entrypoint /bin/sh -e SSH_AUTH_SOCK=/tmp/auth.sock -v /:/sd/workspace/src/screwdriver.cd/sd-local/local-build -v sd-artifacts/:/test/artifacts -v SD_LAUNCH_BIN:/opt/sd -v SD_LAUNCH_HAB:/opt/sd/hab -v %s -itd -v .sd-utils/:/test/sd-utils --pull=never node:12", sshSocket),
+				"podman attach SUCCESS_RUN_BUILD"}},
+		{"failure attach build container", "FAIL_PODMAN_ATTACH", fmt.Errorf("failed to attach build container: exit status 1"), []string{}},
+		{"failure build run", "FAIL_BUILD_CONTAINER_RUN", fmt.Errorf("failed to run build container: exit status 1"), []string{}},
+	}
+
+	for _, tt := range testCase {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeExecCommand(tt.id)
+			fakeOs := newFakeOsMkdir(t)
+
+			execCommand = c.execCmd
+			osMkdirAll = fakeOs.mkdirAll
+			osWriteFile = fakeOs.WriteFile
+
+			err := p.runBuild(newBuildEntry(func(b *buildEntry) { b.Steps = steps }))
+			for i, expectedCommand := range tt.expectedCommands {
+				assert.True(t, strings.Contains(c.commands[i], expectedCommand), "expect %q \nbut got \n%q", expectedCommand, c.commands[i])
+			}
+
+			assert.Contains(t, fakeOs.dirPaths, ".sd-utils/bin")
+			assert.Contains(t, fakeOs.fileNames, "step1")
+			assert.Contains(t, fakeOs.fileNames, "step2")
+
+			if tt.expectError != nil {
+				assert.Equal(t, tt.expectError.Error(), err.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestPodmanKill(t *testing.T) {
+	t.Run("success with no commands", func(t *testing.T) {
+		defer func() {
+			logrus.SetOutput(os.Stderr)
+		}()
+		p := newTestPodman()
+		buf := bytes.NewBuffer(nil)
+		logrus.SetOutput(buf)
+		p.kill(syscall.SIGINT)
+		assert.Equal(t, "", buf.String())
+	})
+
+	t.Run("success", func(t *testing.T) {
+		defer func() {
+			execCommand = exec.Command
+			logrus.SetOutput(os.Stderr)
+		}()
+		c := newFakeExecCommand("SUCCESS_TO_KILL")
+		execCommand = c.execCmd
+		p := newTestPodman(func(p *podman) {
+			p.commands = []*exec.Cmd{execCommand("sleep")}
+		})
+
+		p.commands[0].Start()
+		go func() {
+			time.Sleep(waitForKillTime)
+			p.mutex.Lock()
+			p.commands[0].ProcessState = &os.ProcessState{}
+			p.mutex.Unlock()
+		}()
+
+		buf := bytes.NewBuffer(nil)
+		logrus.SetOutput(buf)
+
+		p.kill(syscall.SIGINT)
+
+		assert.Equal(t, "", buf.String())
+		// podman never uses sudo to send signals
+		assert.Equal(t, 1, len(c.commands))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		defer func() {
+			execCommand = exec.Command
+			logrus.SetOutput(os.Stderr)
+		}()
+		c := newFakeExecCommand("FAIL_TO_KILL")
+		execCommand = c.execCmd
+		p := newTestPodman(func(p *podman) {
+			p.commands = []*exec.Cmd{execCommand("sleep")}
+		})
+
+		p.commands[0].Start()
+		pid := p.commands[0].Process.Pid
+		defer func() {
+			syscall.Kill(pid, syscall.SIGINT)
+			signalFn = defaultSignalFunc
+		}()
+
+		signalFn = func(*os.Process, os.Signal) error {
+			return fmt.Errorf("mocked signal failure")
+		}
+
+		buf := bytes.NewBuffer(nil)
+		logrus.SetOutput(buf)
+
+		p.kill(syscall.SIGINT)
+
+		assert.Contains(t, buf.String(), "failed to stop process:")
+	})
+}
+
+func TestPodmanClean(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		defer func() {
+			execCommand = exec.Command
+		}()
+		c := newFakeExecCommand("SUCCESS_TO_CLEAN")
+		execCommand = c.execCmd
+		p := newTestPodman()
+
+		p.clean()
+		assert.Equal(t, []string{
+			"podman volume rm --force SD_LAUNCH_HAB",
+			"podman volume rm --force SD_LAUNCH_BIN",
+		}, c.commands)
+	})
+
+	t.Run("success with dind", func(t *testing.T) {
+		defer func() {
+			execCommand = exec.Command
+		}()
+		c := newFakeExecCommand("SUCCESS_TO_CLEAN")
+		execCommand = c.execCmd
+		p := newTestPodman(func(p *podman) { p.dind.enabled = true })
+
+		p.clean()
+		assert.Equal(t, []string{
+			"podman volume rm --force SD_LAUNCH_HAB",
+			"podman volume rm --force SD_LAUNCH_BIN",
+			"podman kill sd-local-dind",
+			"podman network rm --force sd-local-dind-bridge",
+			"podman volume rm --force SD_DIND_CERT",
+			"podman volume rm --force SD_DIND_SHARE",
+		}, c.commands)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		defer func() {
+			execCommand = exec.Command
+			logrus.SetOutput(os.Stderr)
+		}()
+		c := newFakeExecCommand("FAIL_TO_CLEAN")
+		execCommand = c.execCmd
+		p := newTestPodman()
+
+		buf := bytes.NewBuffer(nil)
+		logrus.SetOutput(buf)
+
+		p.clean()
+
+		assert.Contains(t, buf.String(), "failed to remove volume:")
+		assert.Contains(t, buf.String(), "failed to remove hab volume:")
+	})
+}
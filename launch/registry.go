@@ -0,0 +1,133 @@
+package launch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	manifestAccept    = "application/vnd.docker.distribution.manifest.v2+json, " +
+		"application/vnd.docker.distribution.manifest.list.v2+json, " +
+		"application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.oci.image.index.v1+json"
+)
+
+var (
+	registryClient = &http.Client{Timeout: 30 * time.Second}
+	challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// imageRegistry returns the registry host and the repository of the image as the runtimes resolve them
+func imageRegistry(image string) (string, string) {
+	host, repo, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, repo = dockerHubRegistry, image
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = dockerHubRegistry
+	}
+	if host == dockerHubRegistry && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+
+	return host, repo
+}
+
+// registryToken gets the anonymous token from the realm of the Bearer challenge
+func registryToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication `%s`", scheme)
+	}
+
+	values := url.Values{}
+	realm := ""
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		if m[1] == "realm" {
+			realm = m[2]
+			continue
+		}
+		values.Set(m[1], m[2])
+	}
+	if realm == "" {
+		return "", fmt.Errorf("no realm in `%s`", challenge)
+	}
+
+	res, err := registryClient.Get(realm + "?" + values.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("StatusCode %d from %s", res.StatusCode, realm)
+	}
+
+	t := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token == "" {
+		return t.AccessToken, nil
+	}
+
+	return t.Token, nil
+}
+
+func requestManifest(manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", manifestAccept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return registryClient.Do(req)
+}
+
+// ResolveImage checks that the tag of the image exists in its registry.
+// Only the registries which allow the anonymous pull are supported.
+func ResolveImage(image, tag string) error {
+	host, repo := imageRegistry(image)
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repo, url.PathEscape(tag))
+
+	res, err := requestManifest(manifestURL, "")
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s:%s: %v", image, tag, err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		token, err := registryToken(res.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s:%s: failed to authenticate to %s: %v", image, tag, host, err)
+		}
+
+		res, err = requestManifest(manifestURL, token)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s:%s: %v", image, tag, err)
+		}
+		res.Body.Close()
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusUnauthorized:
+		// Docker Hub answers 401 for the repositories which do not exist
+		return fmt.Errorf("%s:%s is not found in %s", image, tag, host)
+	default:
+		return fmt.Errorf("failed to resolve %s:%s: StatusCode %d from %s", image, tag, res.StatusCode, host)
+	}
+}
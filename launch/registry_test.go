@@ -0,0 +1,108 @@
+package launch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageRegistry(t *testing.T) {
+	testCases := []struct {
+		image    string
+		wantHost string
+		wantRepo string
+	}{
+		{image: "screwdrivercd/launcher", wantHost: "registry-1.docker.io", wantRepo: "screwdrivercd/launcher"},
+		{image: "node", wantHost: "registry-1.docker.io", wantRepo: "library/node"},
+		{image: "docker.io/node", wantHost: "registry-1.docker.io", wantRepo: "library/node"},
+		{image: "ghcr.io/example/launcher", wantHost: "ghcr.io", wantRepo: "example/launcher"},
+		{image: "localhost:5000/launcher", wantHost: "localhost:5000", wantRepo: "launcher"},
+		{image: "localhost/launcher", wantHost: "localhost", wantRepo: "launcher"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.image, func(t *testing.T) {
+			host, repo := imageRegistry(tt.image)
+			assert.Equal(t, tt.wantHost, host)
+			assert.Equal(t, tt.wantRepo, repo)
+		})
+	}
+}
+
+func TestResolveImage(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal(t, "registry", r.URL.Query().Get("service"))
+			w.Write([]byte(`{"token":"anonymous"}`))
+		case "/v2/screwdrivercd/launcher/manifests/stable":
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:screwdrivercd/launcher:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			w.WriteHeader(http.StatusOK)
+		case "/v2/public/launcher/manifests/stable":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/broken/launcher/manifests/stable":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defClient := registryClient
+	defer func() {
+		registryClient = defClient
+	}()
+	registryClient = server.Client()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	testCases := []struct {
+		name    string
+		image   string
+		tag     string
+		wantErr string
+	}{
+		{
+			name:  "success with the anonymous token",
+			image: host + "/screwdrivercd/launcher",
+			tag:   "stable",
+		},
+		{
+			name:  "success without authentication",
+			image: host + "/public/launcher",
+			tag:   "stable",
+		},
+		{
+			name:    "failure by the tag that does not exist",
+			image:   host + "/screwdrivercd/launcher",
+			tag:     "doesnotexist",
+			wantErr: host + "/screwdrivercd/launcher:doesnotexist is not found in " + host,
+		},
+		{
+			name:    "failure by the registry error",
+			image:   host + "/broken/launcher",
+			tag:     "stable",
+			wantErr: "failed to resolve " + host + "/broken/launcher:stable: StatusCode 500 from " + host,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResolveImage(tt.image, tt.tag)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
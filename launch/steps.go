@@ -0,0 +1,132 @@
+package launch
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+)
+
+// setupStepPrefix is the prefix of the steps injected by the launcher, which are never filtered out
+const setupStepPrefix = "sd-"
+
+// StepSelection selects the steps of the job to run. The names are shell-style globs.
+// The zero value selects all the steps.
+type StepSelection struct {
+	// Only runs the steps matching any of the names
+	Only []string
+	// From runs the steps from the first one matching the name
+	From string
+	// To runs the steps until the first one matching the name after From
+	To string
+	// Skip does not run the steps matching any of the names
+	Skip []string
+}
+
+func matchStep(pattern, name string) bool {
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+func stepNames(steps []screwdriver.Step) []string {
+	names := make([]string, 0, len(steps))
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// validate returns an error when a name is an invalid glob or matches none of the steps
+func (sel StepSelection) validate(steps []screwdriver.Step) error {
+	patterns := append(append([]string{}, sel.Only...), sel.Skip...)
+	for _, p := range []string{sel.From, sel.To} {
+		if p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid step name `%s`: %v", p, err)
+		}
+
+		found := false
+		for _, s := range steps {
+			if matchStep(p, s.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("step `%s` is not found in the job, must be one of [%s]", p, strings.Join(stepNames(steps), " "))
+		}
+	}
+
+	return nil
+}
+
+// SelectSteps returns the steps selected from the steps of the job.
+// The steps injected by the launcher (prefixed with "sd-") are always kept.
+func SelectSteps(steps []screwdriver.Step, sel StepSelection) ([]screwdriver.Step, error) {
+	if len(sel.Only) == 0 && sel.From == "" && sel.To == "" && len(sel.Skip) == 0 {
+		return steps, nil
+	}
+
+	if err := sel.validate(steps); err != nil {
+		return nil, err
+	}
+
+	from, to := 0, len(steps)-1
+	if sel.From != "" {
+		for i, s := range steps {
+			if matchStep(sel.From, s.Name) {
+				from = i
+				break
+			}
+		}
+	}
+	if sel.To != "" {
+		to = -1
+		for i := from; i < len(steps); i++ {
+			if matchStep(sel.To, steps[i].Name) {
+				to = i
+				break
+			}
+		}
+		if to < 0 {
+			return nil, fmt.Errorf("step `%s` of --to-step is before `%s` of --from-step", sel.To, sel.From)
+		}
+	}
+
+	selected := make([]screwdriver.Step, 0, len(steps))
+	userSteps := 0
+	for i, s := range steps {
+		if strings.HasPrefix(s.Name, setupStepPrefix) {
+			selected = append(selected, s)
+			continue
+		}
+
+		if i < from || i > to || (len(sel.Only) > 0 && !matchAny(sel.Only, s.Name)) || matchAny(sel.Skip, s.Name) {
+			continue
+		}
+
+		selected = append(selected, s)
+		userSteps++
+	}
+
+	if userSteps == 0 {
+		return nil, fmt.Errorf("no steps are selected from [%s]", strings.Join(stepNames(steps), " "))
+	}
+
+	return selected, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchStep(p, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package launch
+
+import (
+	"testing"
+
+	"github.com/screwdriver-cd/sd-local/screwdriver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectSteps(t *testing.T) {
+	steps := []screwdriver.Step{
+		{Name: "sd-setup-init", Command: "init"},
+		{Name: "install", Command: "npm install"},
+		{Name: "lint", Command: "npm run lint"},
+		{Name: "test-unit", Command: "npm run test:unit"},
+		{Name: "test-functional", Command: "npm run test:functional"},
+		{Name: "publish", Command: "npm publish"},
+	}
+
+	testCases := []struct {
+		name      string
+		sel       StepSelection
+		want      []string
+		wantError string
+	}{
+		{
+			name: "all steps",
+			want: []string{"sd-setup-init", "install", "lint", "test-unit", "test-functional", "publish"},
+		},
+		{
+			name: "only",
+			sel:  StepSelection{Only: []string{"lint"}},
+			want: []string{"sd-setup-init", "lint"},
+		},
+		{
+			name: "only with glob",
+			sel:  StepSelection{Only: []string{"test-*", "install"}},
+			want: []string{"sd-setup-init", "install", "test-unit", "test-functional"},
+		},
+		{
+			name: "from",
+			sel:  StepSelection{From: "test-unit"},
+			want: []string{"sd-setup-init", "test-unit", "test-functional", "publish"},
+		},
+		{
+			name: "to",
+			sel:  StepSelection{To: "lint"},
+			want: []string{"sd-setup-init", "install", "lint"},
+		},
+		{
+			name: "from and to with glob",
+			sel:  StepSelection{From: "lint", To: "test-*"},
+			want: []string{"sd-setup-init", "lint", "test-unit"},
+		},
+		{
+			name: "skip",
+			sel:  StepSelection{Skip: []string{"install", "publish"}},
+			want: []string{"sd-setup-init", "lint", "test-unit", "test-functional"},
+		},
+		{
+			name: "from and skip",
+			sel:  StepSelection{From: "lint", Skip: []string{"test-functional"}},
+			want: []string{"sd-setup-init", "lint", "test-unit", "publish"},
+		},
+		{
+			name:      "failure by unknown step",
+			sel:       StepSelection{Only: []string{"build"}},
+			wantError: "step `build` is not found in the job, must be one of [sd-setup-init install lint test-unit test-functional publish]",
+		},
+		{
+			name:      "failure by invalid glob",
+			sel:       StepSelection{Skip: []string{"test-["}},
+			wantError: "invalid step name `test-[`: syntax error in pattern",
+		},
+		{
+			name:      "failure by to before from",
+			sel:       StepSelection{From: "lint", To: "install"},
+			wantError: "step `install` of --to-step is before `lint` of --from-step",
+		},
+		{
+			name:      "failure by no steps",
+			sel:       StepSelection{Only: []string{"lint"}, Skip: []string{"lint"}},
+			wantError: "no steps are selected from [sd-setup-init install lint test-unit test-functional publish]",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectSteps(steps, tt.sel)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, stepNames(got))
+		})
+	}
+}
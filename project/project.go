@@ -0,0 +1,170 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-yaml/yaml"
+)
+
+// FileName is the name of the project file in a repository
+const FileName = ".sdlocal.yaml"
+
+// File is the project file which supplies the defaults of the build flags for a repository
+type File struct {
+	// Path is the absolute path of the file
+	Path string `yaml:"-"`
+	// Config is the name of the config entry used for the builds
+	Config string `yaml:"config"`
+	// Flags are the defaults of the flags by their long names
+	Flags map[string]interface{} `yaml:"flags"`
+}
+
+// Find returns the project file in the directory or its nearest parent directory up to the root of the git repository.
+// Only the directory is searched when it is not in a git repository, not to read the file in the home directory or others.
+// It returns nil when there is none.
+func Find(dir string) (*File, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project file: %v", err)
+	}
+
+	root, err := gitRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project file: %v", err)
+	}
+
+	for {
+		p := filepath.Join(dir, FileName)
+		info, err := os.Stat(p)
+		if err == nil && !info.IsDir() {
+			return Read(p)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to find project file: %v", err)
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// gitRoot returns the nearest directory which has .git, which is the root of the git repository or the clone of --src-url.
+// It returns the directory itself when there is none.
+func gitRoot(dir string) (string, error) {
+	for d := dir; ; {
+		_, err := os.Stat(filepath.Join(d, ".git"))
+		if err == nil {
+			return d, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir, nil
+		}
+		d = parent
+	}
+}
+
+// Read parses the project file
+func Read(path string) (*File, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %v", err)
+	}
+
+	f := &File{}
+	if err := yaml.UnmarshalStrict(b, f); err != nil {
+		return nil, fmt.Errorf("failed to parse project file %s: %v", path, err)
+	}
+	f.Path = path
+
+	return f, nil
+}
+
+// Names returns the names of the flags in the file in alphabetical order
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Flags))
+	for name := range f.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Values returns the value of the flag as the strings which the flag parses.
+// A list has a string for each item, and a map has a string of key=value for each pair.
+func (f *File) Values(name string) ([]string, error) {
+	switch v := f.Flags[name].(type) {
+	case nil:
+		return []string{""}, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := scalar(item)
+			if !ok {
+				return nil, fmt.Errorf("invalid value of `%s` in %s: the items must be scalars", name, f.Path)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	case map[interface{}]interface{}:
+		values := make([]string, 0, len(v))
+		for key, value := range v {
+			k, ok := scalar(key)
+			if !ok {
+				return nil, fmt.Errorf("invalid value of `%s` in %s: the keys must be scalars", name, f.Path)
+			}
+			s, ok := scalar(value)
+			if !ok {
+				return nil, fmt.Errorf("invalid value of `%s` in %s: the values must be scalars", name, f.Path)
+			}
+			values = append(values, k+"="+s)
+		}
+		sort.Strings(values)
+		return values, nil
+	default:
+		s, ok := scalar(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid value of `%s` in %s", name, f.Path)
+		}
+		return []string{s}, nil
+	}
+}
+
+// Resolve returns the path relative to the directory of the file as the absolute path
+func (f *File) Resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(f.Path), path)
+}
+
+func scalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v), true
+	}
+
+	return "", false
+}
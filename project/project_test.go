@@ -0,0 +1,175 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFile = `config: work
+flags:
+  memory: 4g
+  no-image-pull: true
+  env-file: .env.local
+  vol:
+    - ./cache:/cache
+    - /tmp:/tmp
+  env:
+    NODE_ENV: test
+    DEBUG: 1
+`
+
+func writeFile(t *testing.T, dir, content string) string {
+	t.Helper()
+
+	p := filepath.Join(dir, FileName)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFind(t *testing.T) {
+	t.Run("success in the directory", func(t *testing.T) {
+		dir := t.TempDir()
+		p := writeFile(t, dir, testFile)
+
+		f, err := Find(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, p, f.Path)
+		assert.Equal(t, "work", f.Config)
+	})
+
+	t.Run("success in the parent directory", func(t *testing.T) {
+		dir := t.TempDir()
+		p := writeFile(t, dir, testFile)
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, ".git"), 0755))
+		sub := filepath.Join(dir, "a", "b")
+		assert.Nil(t, os.MkdirAll(sub, 0755))
+
+		f, err := Find(sub)
+		assert.Nil(t, err)
+		assert.Equal(t, p, f.Path)
+	})
+
+	t.Run("success with the nearest file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, testFile)
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, ".git"), 0755))
+		sub := filepath.Join(dir, "sub")
+		assert.Nil(t, os.MkdirAll(sub, 0755))
+		p := writeFile(t, sub, "config: sub\n")
+
+		f, err := Find(sub)
+		assert.Nil(t, err)
+		assert.Equal(t, p, f.Path)
+		assert.Equal(t, "sub", f.Config)
+	})
+
+	t.Run("success to stop at the root of the git repository", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, testFile)
+		repo := filepath.Join(dir, "repo")
+		sub := filepath.Join(repo, "sub")
+		assert.Nil(t, os.MkdirAll(sub, 0755))
+		// The worktree and the submodule have .git as a file
+		assert.Nil(t, os.WriteFile(filepath.Join(repo, ".git"), []byte("gitdir: ../.git/worktrees/repo\n"), 0644))
+
+		f, err := Find(sub)
+		assert.Nil(t, err)
+		assert.Nil(t, f)
+	})
+
+	t.Run("success to search only the directory out of a git repository", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, testFile)
+		sub := filepath.Join(dir, "sub")
+		assert.Nil(t, os.MkdirAll(sub, 0755))
+
+		f, err := Find(sub)
+		assert.Nil(t, err)
+		assert.Nil(t, f)
+	})
+
+	t.Run("success without file", func(t *testing.T) {
+		dir := t.TempDir()
+		// The directory with the same name is not the project file
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, FileName), 0755))
+
+		f, err := Find(dir)
+		assert.Nil(t, err)
+		assert.Nil(t, f)
+	})
+
+	t.Run("failure by invalid file", func(t *testing.T) {
+		dir := t.TempDir()
+		p := writeFile(t, dir, "flags: [\n")
+
+		_, err := Find(dir)
+		assert.NotNil(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "failed to parse project file "+p+": "), err.Error())
+	})
+
+	t.Run("failure by unknown key", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "memory: 4g\n")
+
+		_, err := Find(dir)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "field memory not found")
+	})
+}
+
+func TestValues(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, testFile+`  meta:
+  bad-list:
+    - [a]
+  bad-map:
+    key: [a]
+`)
+	f, err := Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"bad-list", "bad-map", "env", "env-file", "memory", "meta", "no-image-pull", "vol"}, f.Names())
+
+	testCases := []struct {
+		name    string
+		want    []string
+		wantErr string
+	}{
+		{name: "memory", want: []string{"4g"}},
+		{name: "no-image-pull", want: []string{"true"}},
+		{name: "meta", want: []string{""}},
+		{name: "vol", want: []string{"./cache:/cache", "/tmp:/tmp"}},
+		{name: "env", want: []string{"DEBUG=1", "NODE_ENV=test"}},
+		{name: "bad-list", wantErr: "invalid value of `bad-list` in " + p + ": the items must be scalars"},
+		{name: "bad-map", wantErr: "invalid value of `bad-map` in " + p + ": the values must be scalars"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Values(tt.name)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	f := &File{Path: filepath.Join("/repo", FileName)}
+
+	assert.Equal(t, filepath.Join("/repo", ".env.local"), f.Resolve(".env.local"))
+	assert.Equal(t, filepath.Join("/repo", "cache"), f.Resolve("./cache"))
+	assert.Equal(t, "/etc/ssl/ca.pem", f.Resolve("/etc/ssl/ca.pem"))
+	assert.Equal(t, "", f.Resolve(""))
+}
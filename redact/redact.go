@@ -0,0 +1,224 @@
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Mask replaces the redacted values
+	Mask = "***"
+
+	// JWTPattern matches the JWTs, which are masked even when the value is not known, e.g. the JWT refreshed by another run
+	JWTPattern = `eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`
+
+	// Values shorter than this are not redacted, since masking them would break unrelated output
+	minLength = 4
+)
+
+var secretLikeName = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|PASSPHRASE|CREDENTIAL|PRIVATE|AUTH|(^|_)KEY($|_))`)
+
+// SecretLike returns true when the name of an environment variable looks like it holds a secret
+func SecretLike(name string) bool {
+	return secretLikeName.MatchString(name)
+}
+
+// Redactor masks the registered values and patterns in the output
+type Redactor struct {
+	mutex    sync.RWMutex
+	values   []string
+	patterns []*regexp.Regexp
+}
+
+// New creates a Redactor which masks nothing
+func New() *Redactor {
+	return &Redactor{}
+}
+
+func (r *Redactor) addValue(value string) {
+	if len(value) < minLength {
+		return
+	}
+
+	for _, v := range r.values {
+		if v == value {
+			return
+		}
+	}
+	r.values = append(r.values, value)
+}
+
+// Add registers the values to mask.
+// Each line of a multi-line value and the JSON escaped value are masked as well,
+// since the build log prints a line at a time and the build entry is passed as JSON.
+func (r *Redactor) Add(values ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, value := range values {
+		r.addValue(value)
+
+		if escaped, err := json.Marshal(value); err == nil {
+			r.addValue(string(escaped[1 : len(escaped)-1]))
+		}
+
+		if strings.Contains(value, "\n") {
+			for _, line := range strings.Split(value, "\n") {
+				r.addValue(strings.TrimSpace(line))
+			}
+		}
+	}
+
+	// The longer values are replaced first so that a value containing another one is masked as a whole
+	sort.SliceStable(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+}
+
+// AddPattern registers the regular expression to mask
+func (r *Redactor) AddPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid redact pattern `%s`: %v", pattern, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.patterns = append(r.patterns, re)
+	return nil
+}
+
+// AddPatternFile registers the regular expressions in the file, one per line.
+// Empty lines and lines starting with "#" are ignored. It does nothing when the file does not exist.
+func (r *Redactor) AddPatternFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read redact patterns: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := r.AddPattern(line); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read redact patterns: %v", err)
+	}
+
+	return nil
+}
+
+// Redact returns the string with the registered values and patterns masked
+func (r *Redactor) Redact(s string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+
+	return s
+}
+
+type writer struct {
+	redactor *Redactor
+	writer   io.Writer
+	mutex    sync.Mutex
+	buf      []byte
+}
+
+// Writer returns the writer which masks the output line by line.
+// A line is held until it ends so that a value split across writes is masked.
+// Close writes the rest of the output.
+func (r *Redactor) Writer(w io.Writer) io.WriteCloser {
+	return &writer{redactor: r, writer: w}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := w.buf[:i+1]
+		if _, err := io.WriteString(w.writer, w.redactor.Redact(string(line))); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (w *writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(w.writer, w.redactor.Redact(string(w.buf)))
+	w.buf = nil
+
+	return err
+}
+
+type formatter struct {
+	redactor  *Redactor
+	formatter logrus.Formatter
+}
+
+// Formatter returns the logrus formatter which masks the message and the values of the fields
+// before formatting them with the given formatter
+func (r *Redactor) Formatter(f logrus.Formatter) logrus.Formatter {
+	return &formatter{redactor: r, formatter: f}
+}
+
+func (f *formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Message = f.redactor.Redact(entry.Message)
+
+	// The values which have nothing to mask keep their types, e.g. numbers in JSON
+	redacted.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		s := fmt.Sprint(value)
+		if masked := f.redactor.Redact(s); masked != s {
+			redacted.Data[key] = masked
+		} else {
+			redacted.Data[key] = value
+		}
+	}
+
+	return f.formatter.Format(&redacted)
+}
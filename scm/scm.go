@@ -38,6 +38,15 @@ type SCM interface {
 	LocalPath() string
 }
 
+// Error is returned when the source code cannot be fetched
+type Error struct {
+	Err error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
 type scm struct {
 	baseDir   string
 	remoteURL string
@@ -52,7 +61,7 @@ func New(baseDir, srcURL string, sudo bool) (SCM, error) {
 	results := srcURLRegex.FindStringSubmatch(srcURL)
 
 	if len(results) == 0 {
-		return nil, fmt.Errorf("failed to fetch source code with invalid URL: %s", srcURL)
+		return nil, &Error{Err: fmt.Errorf("failed to fetch source code with invalid URL: %s", srcURL)}
 	}
 
 	remoteURL, branch := results[1], results[2]
@@ -68,7 +77,7 @@ func New(baseDir, srcURL string, sudo bool) (SCM, error) {
 
 	err := osMkdirAll(s.LocalPath(), 0777)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make local source directory: %w", err)
+		return nil, &Error{Err: fmt.Errorf("failed to make local source directory: %w", err)}
 	}
 
 	return s, nil
@@ -85,7 +94,7 @@ func (s *scm) Pull() error {
 	s.commands = append(s.commands, cmd)
 	err := cmd.Run()
 	if err != nil {
-		return fmt.Errorf("failed to clone remote repository: %w", err)
+		return &Error{Err: fmt.Errorf("failed to clone remote repository: %w", err)}
 	}
 
 	return nil
@@ -104,14 +113,40 @@ func (s *scm) Kill(sig os.Signal) {
 }
 
 func (s *scm) Clean() {
-	commands := []string{"rm", "-rf", s.LocalPath()}
-	if s.sudo {
-		commands = append([]string{"sudo"}, commands...)
+	if err := RemoveClone(s.LocalPath(), s.sudo); err != nil {
+		logrus.Warn(fmt.Errorf("failed to remove local source directory: %w", err))
 	}
-	err := execCommand(commands[0], commands[1:]...).Run()
+}
+
+// Clones returns the directories which the source code is cloned into under the base directory
+func Clones(baseDir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(baseDir, "repo"))
 	if err != nil {
-		logrus.Warn(fmt.Errorf("failed to remove local source directory: %w", err))
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read local source directories: %w", err)
 	}
+
+	clones := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			clones = append(clones, filepath.Join(baseDir, "repo", e.Name()))
+		}
+	}
+
+	return clones, nil
+}
+
+// RemoveClone removes the directory which the source code is cloned into.
+// sudo is needed when the build wrote the files as root.
+func RemoveClone(path string, sudo bool) error {
+	commands := []string{"rm", "-rf", path}
+	if sudo {
+		commands = append([]string{"sudo"}, commands...)
+	}
+
+	return execCommand(commands[0], commands[1:]...).Run()
 }
 
 func (s *scm) LocalPath() string {
@@ -242,6 +242,48 @@ func TestClean(t *testing.T) {
 	})
 }
 
+func TestClones(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		baseDir := t.TempDir()
+		for _, d := range []string{"repo/1", "repo/2"} {
+			assert.Nil(t, os.MkdirAll(filepath.Join(baseDir, d), 0777))
+		}
+		assert.Nil(t, os.WriteFile(filepath.Join(baseDir, "repo", "file"), []byte{}, 0600))
+
+		clones, err := Clones(baseDir)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{filepath.Join(baseDir, "repo", "1"), filepath.Join(baseDir, "repo", "2")}, clones)
+	})
+
+	t.Run("success without clones", func(t *testing.T) {
+		clones, err := Clones(t.TempDir())
+		assert.Nil(t, err)
+		assert.Empty(t, clones)
+	})
+}
+
+func TestRemoveClone(t *testing.T) {
+	defer func() {
+		execCommand = exec.Command
+	}()
+
+	t.Run("success with sudo", func(t *testing.T) {
+		c := newFakeExecCommand("SUCCESS_TO_CLEAN")
+		execCommand = c.execCmd
+
+		assert.Nil(t, RemoveClone("/sdlocal/repo/1", true))
+		assert.Equal(t, "sudo rm -rf /sdlocal/repo/1", c.command)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		c := newFakeExecCommand("FAILURE_TO_CLEAN")
+		execCommand = c.execCmd
+
+		assert.EqualError(t, RemoveClone("/sdlocal/repo/1", false), "exit status 1")
+		assert.Equal(t, "rm -rf /sdlocal/repo/1", c.command)
+	})
+}
+
 func TestPull(t *testing.T) {
 	t.Run("success with branch", func(t *testing.T) {
 		baseDir := os.TempDir()
@@ -0,0 +1,171 @@
+package screwdriver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the timeout of a request to the API
+	DefaultTimeout = 60 * time.Second
+	// DefaultRetries is how many times a request is retried on the transient errors
+	DefaultRetries = 3
+	// DefaultRetryBackoff is the wait before the first retry, which is doubled for each retry
+	DefaultRetryBackoff = time.Second
+)
+
+// HTTPOptions configures the requests to the API
+type HTTPOptions struct {
+	// Timeout of each attempt of a request. No timeout with 0.
+	Timeout time.Duration
+	// Retries is how many times a request is retried on a network error or 429, 502, 503 and 504
+	Retries int
+	// RetryBackoff is the wait before the first retry, which is doubled for each retry
+	RetryBackoff time.Duration
+	// Proxy is the URL of the proxy. HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used when it is empty.
+	Proxy string
+	// CACert is the path to the PEM bundle of the CAs trusted in addition to the system ones
+	CACert string
+	// ClientCert and ClientKey are the paths to the PEM client certificate and its key
+	ClientCert string
+	ClientKey  string
+}
+
+// DefaultHTTPOptions returns the options used when they are not configured
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Timeout:      DefaultTimeout,
+		Retries:      DefaultRetries,
+		RetryBackoff: DefaultRetryBackoff,
+	}
+}
+
+// HTTPSettings are the settings of the requests to the API as they are written in the flags and the config.
+// The empty ones are the defaults.
+type HTTPSettings struct {
+	Timeout      string
+	Retries      string
+	RetryBackoff string
+	Proxy        string
+	CACert       string
+	ClientCert   string
+	ClientKey    string
+}
+
+// Options parses the settings
+func (s HTTPSettings) Options() (HTTPOptions, error) {
+	options := DefaultHTTPOptions()
+
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return options, fmt.Errorf("invalid api-timeout `%s`: %v", s.Timeout, err)
+		}
+		options.Timeout = d
+	}
+
+	if s.Retries != "" {
+		n, err := strconv.Atoi(s.Retries)
+		if err != nil || n < 0 {
+			return options, fmt.Errorf("invalid api-retries `%s`: must be 0 or a positive number", s.Retries)
+		}
+		options.Retries = n
+	}
+
+	if s.RetryBackoff != "" {
+		d, err := time.ParseDuration(s.RetryBackoff)
+		if err != nil {
+			return options, fmt.Errorf("invalid api-retry-backoff `%s`: %v", s.RetryBackoff, err)
+		}
+		options.RetryBackoff = d
+	}
+
+	options.Proxy = s.Proxy
+	options.CACert = s.CACert
+	options.ClientCert = s.ClientCert
+	options.ClientKey = s.ClientKey
+
+	return options, nil
+}
+
+// newHTTPClient returns the client configured by the options
+func newHTTPClient(options HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if options.Proxy != "" {
+		proxy, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy `%s`: %v", options.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if options.CACert != "" || options.ClientCert != "" || options.ClientKey != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if options.CACert != "" {
+			pem, err := os.ReadFile(options.CACert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+			}
+
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("failed to read CA bundle: no certificates are found in %s", options.CACert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if options.ClientCert != "" || options.ClientKey != "" {
+			if options.ClientCert == "" || options.ClientKey == "" {
+				return nil, errors.New("both the client certificate and the client key must be specified")
+			}
+
+			cert, err := tls.LoadX509KeyPair(options.ClientCert, options.ClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read client certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   options.Timeout,
+	}, nil
+}
+
+// retryable returns true when the request may succeed by retrying it.
+// The errors of the certificates, the URL and the host which is not found fail again, so that they are not retried.
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
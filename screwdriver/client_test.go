@@ -0,0 +1,417 @@
+package screwdriver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes the self-signed certificate and its key in PEM format, returning their paths
+func writeCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sd-local"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certPath, keyPath
+}
+
+// writeServerCA writes the certificate of the TLS server in PEM format, returning its path
+func writeServerCA(t *testing.T, dir string, server *httptest.Server) string {
+	t.Helper()
+
+	p := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeCert(t, dir)
+	emptyPath := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyPath, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("success with proxy", func(t *testing.T) {
+		client, err := newHTTPClient(HTTPOptions{Timeout: time.Second, Proxy: "http://proxy.example.com:8080"})
+		assert.Nil(t, err)
+		assert.Equal(t, time.Second, client.Timeout)
+
+		req, err := http.NewRequest(http.MethodGet, "https://api.screwdriver.cd/v4/validator", nil)
+		assert.Nil(t, err)
+		proxy, err := client.Transport.(*http.Transport).Proxy(req)
+		assert.Nil(t, err)
+		assert.Equal(t, &url.URL{Scheme: "http", Host: "proxy.example.com:8080"}, proxy)
+	})
+
+	t.Run("success with client certificate", func(t *testing.T) {
+		client, err := newHTTPClient(HTTPOptions{ClientCert: certPath, ClientKey: keyPath})
+		assert.Nil(t, err)
+		assert.Len(t, client.Transport.(*http.Transport).TLSClientConfig.Certificates, 1)
+	})
+
+	testCases := []struct {
+		name    string
+		options HTTPOptions
+		wantErr string
+	}{
+		{
+			name:    "failure by invalid proxy",
+			options: HTTPOptions{Proxy: "http://proxy.example.com:yyy"},
+			wantErr: "invalid proxy `http://proxy.example.com:yyy`: ",
+		},
+		{
+			name:    "failure by missing CA bundle",
+			options: HTTPOptions{CACert: filepath.Join(dir, "missing.pem")},
+			wantErr: "failed to read CA bundle: ",
+		},
+		{
+			name:    "failure by CA bundle without certificates",
+			options: HTTPOptions{CACert: emptyPath},
+			wantErr: fmt.Sprintf("failed to read CA bundle: no certificates are found in %s", emptyPath),
+		},
+		{
+			name:    "failure by client certificate without key",
+			options: HTTPOptions{ClientCert: certPath},
+			wantErr: "both the client certificate and the client key must be specified",
+		},
+		{
+			name:    "failure by client key without certificate",
+			options: HTTPOptions{ClientKey: keyPath},
+			wantErr: "both the client certificate and the client key must be specified",
+		},
+		{
+			name:    "failure by invalid client certificate",
+			options: HTTPOptions{ClientCert: emptyPath, ClientKey: keyPath},
+			wantErr: "failed to read client certificate: ",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newHTTPClient(tt.options)
+			assert.NotNil(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), tt.wantErr), fmt.Sprintf("expected error is `%s...`, actual: `%v`", tt.wantErr, err))
+		})
+	}
+}
+
+// countingTransport counts the requests sent through the base transport
+type countingTransport struct {
+	base     http.RoundTripper
+	attempts atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.attempts.Add(1)
+	return c.base.RoundTrip(r)
+}
+
+// timeoutError is the net.Error of a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	// The errors of the requests are wrapped by url.Error
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://api.screwdriver.cd/v4/jobs/1", Err: err}
+	}
+
+	testCases := []struct {
+		name string
+		res  *http.Response
+		err  error
+		want bool
+	}{
+		{name: "timeout", err: wrap(timeoutError{}), want: true},
+		{name: "connection reset", err: wrap(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), want: true},
+		{name: "connection refused", err: wrap(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), want: true},
+		{name: "unexpected EOF", err: wrap(io.ErrUnexpectedEOF), want: true},
+		{name: "429", res: &http.Response{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "503", res: &http.Response{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "unknown authority", err: wrap(x509.UnknownAuthorityError{}), want: false},
+		{name: "invalid certificate", err: wrap(x509.CertificateInvalidError{Reason: x509.Expired}), want: false},
+		{name: "hostname mismatch", err: wrap(x509.HostnameError{Host: "api.screwdriver.cd"}), want: false},
+		{name: "tls record header", err: wrap(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), want: false},
+		{name: "no such host", err: wrap(&net.DNSError{Err: "no such host", Name: "api.screwdriver.cd", IsNotFound: true}), want: false},
+		{name: "unsupported protocol scheme", err: wrap(errors.New(`unsupported protocol scheme "ftp"`)), want: false},
+		{name: "invalid URL", err: &url.Error{Op: "parse", URL: "://", Err: errors.New("missing protocol scheme")}, want: false},
+		{name: "500", res: &http.Response{StatusCode: http.StatusInternalServerError}, want: false},
+		{name: "404", res: &http.Response{StatusCode: http.StatusNotFound}, want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryable(tt.res, tt.err))
+		})
+	}
+}
+
+func TestRequest(t *testing.T) {
+	testCases := []struct {
+		name         string
+		method       string
+		statuses     []int
+		retries      int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			name:         "success without retry",
+			method:       http.MethodGet,
+			statuses:     []int{200},
+			retries:      3,
+			wantStatus:   200,
+			wantAttempts: 1,
+		},
+		{
+			name:         "success by retrying transient errors",
+			method:       http.MethodGet,
+			statuses:     []int{503, 429, 200},
+			retries:      3,
+			wantStatus:   200,
+			wantAttempts: 3,
+		},
+		{
+			name:         "success by retrying POST with the same body",
+			method:       http.MethodPost,
+			statuses:     []int{502, 504, 200},
+			retries:      3,
+			wantStatus:   200,
+			wantAttempts: 3,
+		},
+		{
+			name:         "failure after retries",
+			method:       http.MethodGet,
+			statuses:     []int{503, 503, 503},
+			retries:      2,
+			wantStatus:   503,
+			wantAttempts: 3,
+		},
+		{
+			name:         "failure without retry by 500",
+			method:       http.MethodGet,
+			statuses:     []int{500, 200},
+			retries:      3,
+			wantStatus:   500,
+			wantAttempts: 1,
+		},
+		{
+			name:         "failure without retry by 400",
+			method:       http.MethodPost,
+			statuses:     []int{400, 200},
+			retries:      3,
+			wantStatus:   400,
+			wantAttempts: 1,
+		},
+		{
+			name:         "failure without retry by no retries",
+			method:       http.MethodGet,
+			statuses:     []int{503, 200},
+			retries:      0,
+			wantStatus:   503,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.method == http.MethodPost {
+					body, err := io.ReadAll(r.Body)
+					assert.Nil(t, err)
+					assert.Equal(t, `{"yaml":"jobs: {}"}`, string(body))
+				}
+				w.WriteHeader(tt.statuses[attempts.Add(1)-1])
+			}))
+			defer server.Close()
+
+			s := &sdAPI{
+				HTTPClient:   http.DefaultClient,
+				APIURL:       server.URL,
+				Retries:      tt.retries,
+				RetryBackoff: time.Millisecond,
+			}
+
+			var body io.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader(`{"yaml":"jobs: {}"}`)
+			}
+
+			res, err := s.request(tt.method, server.URL, body)
+			assert.Nil(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			assert.Equal(t, tt.wantAttempts, int(attempts.Load()))
+		})
+	}
+
+	t.Run("failure by timeout", func(t *testing.T) {
+		// The server never responds until the test finishes, so every attempt times out
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		client, err := newHTTPClient(HTTPOptions{Timeout: 50 * time.Millisecond})
+		assert.Nil(t, err)
+		transport := &countingTransport{base: client.Transport}
+		client.Transport = transport
+		s := &sdAPI{
+			HTTPClient:   client,
+			APIURL:       server.URL,
+			Retries:      1,
+			RetryBackoff: time.Millisecond,
+		}
+
+		_, err = s.request(http.MethodGet, server.URL, nil)
+		var netErr net.Error
+		assert.True(t, errors.As(err, &netErr))
+		assert.True(t, netErr.Timeout())
+		assert.Equal(t, int32(2), transport.attempts.Load())
+	})
+
+	t.Run("failure without retry by unknown certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		client, err := newHTTPClient(HTTPOptions{})
+		assert.Nil(t, err)
+		transport := &countingTransport{base: client.Transport}
+		client.Transport = transport
+		s := &sdAPI{HTTPClient: client, APIURL: server.URL, Retries: 3, RetryBackoff: time.Millisecond}
+
+		_, err = s.request(http.MethodGet, server.URL, nil)
+		var certErr *tls.CertificateVerificationError
+		assert.True(t, errors.As(err, &certErr), err)
+		assert.Equal(t, int32(1), transport.attempts.Load())
+	})
+
+	t.Run("success with CA bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		client, err := newHTTPClient(HTTPOptions{CACert: writeServerCA(t, t.TempDir(), server)})
+		assert.Nil(t, err)
+		s := &sdAPI{HTTPClient: client, APIURL: server.URL}
+
+		res, err := s.request(http.MethodGet, server.URL, nil)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, 200, res.StatusCode)
+	})
+
+	t.Run("success with client certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		certPath, keyPath := writeCert(t, dir)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Len(t, r.TLS.PeerCertificates, 1)
+			w.WriteHeader(200)
+		}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		server.StartTLS()
+		defer server.Close()
+
+		client, err := newHTTPClient(HTTPOptions{CACert: writeServerCA(t, dir, server), ClientCert: certPath, ClientKey: keyPath})
+		assert.Nil(t, err)
+		s := &sdAPI{HTTPClient: client, APIURL: server.URL}
+
+		res, err := s.request(http.MethodGet, server.URL, nil)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, 200, res.StatusCode)
+	})
+
+	t.Run("failure by unknown CA", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		client, err := newHTTPClient(HTTPOptions{})
+		assert.Nil(t, err)
+		s := &sdAPI{HTTPClient: client, APIURL: server.URL}
+
+		_, err = s.request(http.MethodGet, server.URL, nil)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "certificate")
+	})
+}
+
+func TestHTTPSettingsOptions(t *testing.T) {
+	options, err := HTTPSettings{}.Options()
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultHTTPOptions(), options)
+
+	options, err = HTTPSettings{Timeout: "5s", Retries: "1", RetryBackoff: "10ms", Proxy: "http://localhost:3128"}.Options()
+	assert.Nil(t, err)
+	assert.Equal(t, HTTPOptions{Timeout: 5 * time.Second, Retries: 1, RetryBackoff: 10 * time.Millisecond, Proxy: "http://localhost:3128"}, options)
+
+	_, err = HTTPSettings{Retries: "many"}.Options()
+	assert.EqualError(t, err, "invalid api-retries `many`: must be 0 or a positive number")
+}
@@ -0,0 +1,383 @@
+package screwdriver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// offlineAPI parses screwdriver.yaml locally instead of posting it to the validator
+type offlineAPI struct{}
+
+var _ API = (*offlineAPI)(nil)
+
+type offlineJob struct {
+	Image       string                 `yaml:"image"`
+	Steps       []yaml.MapSlice        `yaml:"steps"`
+	Environment yaml.MapSlice          `yaml:"environment"`
+	Annotations map[string]interface{} `yaml:"annotations"`
+	Requires    interface{}            `yaml:"requires"`
+	Template    string                 `yaml:"template"`
+	Cache       *bool                  `yaml:"cache"`
+	Secrets     []string               `yaml:"secrets"`
+}
+
+type offlineCache struct {
+	Pipeline []string            `yaml:"pipeline"`
+	Event    []string            `yaml:"event"`
+	Job      map[string][]string `yaml:"job"`
+}
+
+type offlineConfig struct {
+	Shared offlineJob    `yaml:"shared"`
+	Jobs   yaml.MapSlice `yaml:"jobs"`
+	Cache  offlineCache  `yaml:"cache"`
+}
+
+// NewOffline creates a API which parses screwdriver.yaml without the Screwdriver.cd API.
+// Features which need the validator (e.g. templates) are not supported.
+func NewOffline() API {
+	return &offlineAPI{}
+}
+
+// normalize converts maps decoded by go-yaml so that they can be marshaled to JSON
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalize(value)
+		}
+		return m
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalize(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalize(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func toStringSlice(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		s := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a string", item)
+			}
+			s = append(s, str)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%v is neither a string nor a list of strings", v)
+	}
+}
+
+func parseSteps(jobName string, steps []yaml.MapSlice) ([]Step, error) {
+	parsed := make([]Step, 0, len(steps))
+	for _, step := range steps {
+		if len(step) != 1 {
+			return nil, fmt.Errorf("job `%s` has an invalid step, each step must have exactly one name", jobName)
+		}
+
+		name := fmt.Sprint(step[0].Key)
+		switch command := step[0].Value.(type) {
+		case string:
+			parsed = append(parsed, Step{Name: name, Command: command})
+		case yaml.MapSlice:
+			c, ok := lookup(command, "command").(string)
+			if !ok {
+				return nil, fmt.Errorf("job `%s` has an invalid step `%s`, the command must be a string", jobName, name)
+			}
+			parsed = append(parsed, Step{Name: name, Command: c})
+		default:
+			return nil, fmt.Errorf("job `%s` has an invalid step `%s`, the command must be a string", jobName, name)
+		}
+	}
+
+	return parsed, nil
+}
+
+func lookup(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// mergeEnvironment merges the job environment into the shared one.
+// Keys of the shared environment keep their order and the job values win, like the validator does.
+func mergeEnvironment(shared, job yaml.MapSlice) EnvVars {
+	merged := make(yaml.MapSlice, 0, len(shared)+len(job))
+	merged = append(merged, shared...)
+
+	for _, item := range job {
+		replaced := false
+		for i := range merged {
+			if fmt.Sprint(merged[i].Key) == fmt.Sprint(item.Key) {
+				merged[i].Value = item.Value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, item)
+		}
+	}
+
+	var env EnvVars
+	for _, item := range merged {
+		value := ""
+		if item.Value != nil {
+			value = fmt.Sprint(item.Value)
+		}
+		env = append(env, map[string]string{fmt.Sprint(item.Key): value})
+	}
+
+	return env
+}
+
+func mergeAnnotations(shared, job map[string]interface{}) map[string]interface{} {
+	if shared == nil && job == nil {
+		return nil
+	}
+
+	merged := make(map[string]interface{}, len(shared)+len(job))
+	for key, value := range shared {
+		merged[key] = normalize(value)
+	}
+	for key, value := range job {
+		merged[key] = normalize(value)
+	}
+
+	return merged
+}
+
+// toCache returns the cache of the job, which is nil when nothing is cached or the job disables the cache
+func (c offlineCache) toCache(name string, enabled *bool) *Cache {
+	if enabled != nil && !*enabled {
+		return nil
+	}
+
+	cache := &Cache{
+		Pipeline: c.Pipeline,
+		Event:    c.Event,
+		Job:      c.Job[name],
+	}
+	if cache.Empty() {
+		return nil
+	}
+
+	return cache
+}
+
+// toJob merges the shared settings into the job and converts it to Job
+func (j offlineJob) toJob(name string, shared offlineJob) (Job, error) {
+	template := j.Template
+	if template == "" {
+		template = shared.Template
+	}
+	if template != "" {
+		return Job{}, fmt.Errorf("job `%s` uses template `%s`, templates are not supported in offline mode", name, template)
+	}
+
+	image := j.Image
+	if image == "" {
+		image = shared.Image
+	}
+	if image == "" {
+		return Job{}, fmt.Errorf("job `%s` has no image", name)
+	}
+
+	rawSteps := j.Steps
+	if rawSteps == nil {
+		rawSteps = shared.Steps
+	}
+	if len(rawSteps) == 0 {
+		return Job{}, fmt.Errorf("job `%s` has no steps", name)
+	}
+	steps, err := parseSteps(name, rawSteps)
+	if err != nil {
+		return Job{}, err
+	}
+
+	rawRequires := j.Requires
+	if rawRequires == nil {
+		rawRequires = shared.Requires
+	}
+	requires, err := toStringSlice(rawRequires)
+	if err != nil {
+		return Job{}, fmt.Errorf("job `%s` has invalid requires: %v", name, err)
+	}
+
+	secrets := j.Secrets
+	if secrets == nil {
+		secrets = shared.Secrets
+	}
+
+	return Job{
+		Annotations: mergeAnnotations(shared.Annotations, j.Annotations),
+		Steps:       steps,
+		Environment: mergeEnvironment(shared.Environment, j.Environment),
+		Image:       image,
+		Requires:    requires,
+		Secrets:     secrets,
+	}, nil
+}
+
+func (o *offlineAPI) parse(filePath string) (offlineConfig, error) {
+	sdYAML, err := readScrewdriverYAML(filePath)
+	if err != nil {
+		return offlineConfig{}, err
+	}
+
+	config := offlineConfig{}
+	if err := yaml.Unmarshal([]byte(sdYAML), &config); err != nil {
+		return offlineConfig{}, &ValidationError{Err: fmt.Errorf("failed to parse screwdriver.yaml: %v", err)}
+	}
+
+	return config, nil
+}
+
+// decodeJob decodes a job definition in jobs into offlineJob
+func decodeJob(name string, value interface{}) (offlineJob, error) {
+	// Re-encode the job so that it is decoded with the same rules as the shared settings
+	raw, err := yaml.Marshal(value)
+	if err != nil {
+		return offlineJob{}, &ValidationError{Err: fmt.Errorf("failed to parse screwdriver.yaml: %v", err)}
+	}
+
+	j := offlineJob{}
+	if err := yaml.Unmarshal(raw, &j); err != nil {
+		return offlineJob{}, &ValidationError{Err: fmt.Errorf("failed to parse screwdriver.yaml: job `%s`: %v", name, err)}
+	}
+
+	return j, nil
+}
+
+// Job returns job represented by "jobName"
+func (o *offlineAPI) Job(jobName, filePath string) (Job, error) {
+	config, err := o.parse(filePath)
+	if err != nil {
+		return Job{}, err
+	}
+
+	// Only the requested job is converted so that unsupported features in other jobs do not matter
+	value := lookup(config.Jobs, jobName)
+	if value == nil {
+		return Job{}, &ValidationError{Err: fmt.Errorf("not found '%s' in parsed screwdriver.yaml", jobName)}
+	}
+
+	j, err := decodeJob(jobName, value)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job, err := j.toJob(jobName, config.Shared)
+	if err != nil {
+		return Job{}, &ValidationError{Err: fmt.Errorf("failed to parse screwdriver.yaml: %v", err)}
+	}
+	job.Cache = config.Cache.toCache(jobName, j.Cache)
+
+	return job, nil
+}
+
+// Pipeline returns all jobs and the workflow graph built from "requires"
+func (o *offlineAPI) Pipeline(filePath string) (Pipeline, error) {
+	config, err := o.parse(filePath)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	jobs := make(map[string]Job, len(config.Jobs))
+	names := make([]string, 0, len(config.Jobs))
+	for _, item := range config.Jobs {
+		name := fmt.Sprint(item.Key)
+
+		j, err := decodeJob(name, item.Value)
+		if err != nil {
+			return Pipeline{}, err
+		}
+
+		job, err := j.toJob(name, config.Shared)
+		if err != nil {
+			return Pipeline{}, &ValidationError{Err: fmt.Errorf("failed to parse screwdriver.yaml: %v", err)}
+		}
+		job.Cache = config.Cache.toCache(name, j.Cache)
+
+		jobs[name] = job
+		names = append(names, name)
+	}
+
+	return Pipeline{
+		Jobs:          jobs,
+		WorkflowGraph: workflowGraph(names, jobs),
+	}, nil
+}
+
+// workflowGraph builds the workflow graph in the same shape as the validator.
+// "~job" triggers the job after "job" finishes, and the jobs required together without "~" are joined.
+func workflowGraph(names []string, jobs map[string]Job) WorkflowGraph {
+	graph := WorkflowGraph{
+		Nodes: []WorkflowNode{{Name: "~pr"}, {Name: "~commit"}},
+	}
+	known := map[string]bool{"~pr": true, "~commit": true}
+
+	for _, name := range names {
+		graph.Nodes = append(graph.Nodes, WorkflowNode{Name: name})
+		known[name] = true
+	}
+
+	for _, name := range names {
+		requires := jobs[name].Requires
+		for _, require := range requires {
+			edge := WorkflowEdge{Src: require, Dest: name}
+
+			if trimmed := strings.TrimPrefix(require, "~"); trimmed != require {
+				if _, ok := jobs[trimmed]; ok {
+					edge.Src = trimmed
+				}
+			} else {
+				// A single require triggers the job in the same way as "~job"
+				edge.Join = len(requires) > 1
+			}
+
+			if !known[edge.Src] {
+				// External triggers such as "~sd@123:main" are nodes without a job
+				graph.Nodes = append(graph.Nodes, WorkflowNode{Name: edge.Src})
+				known[edge.Src] = true
+			}
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	return graph
+}
+
+// JWT returns an empty token because no token is exchanged in offline mode
+func (o *offlineAPI) JWT() string {
+	return ""
+}
+
+// InitJWT does nothing in offline mode
+func (o *offlineAPI) InitJWT() error {
+	return nil
+}
+
+// SetJWT does nothing in offline mode
+func (o *offlineAPI) SetJWT(jwt string) {}
@@ -0,0 +1,178 @@
+package screwdriver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOffline(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		gotAPI := NewOffline()
+		_, ok := gotAPI.(*offlineAPI)
+		assert.True(t, ok)
+		assert.Nil(t, gotAPI.InitJWT())
+		gotAPI.SetJWT("jwt")
+		assert.Equal(t, "", gotAPI.JWT())
+	})
+}
+
+func TestOfflineJob(t *testing.T) {
+	testAPI := NewOffline()
+
+	t.Run("success with the same job as the validator", func(t *testing.T) {
+		gotJob, err := testAPI.Job("main", filepath.Join(testDir, "screwdriver.yaml"))
+		assert.Nil(t, err)
+		assert.Equal(t, Job{
+			Steps: []Step{
+				{Name: "install", Command: "echo install"},
+				{Name: "publish", Command: "echo publish"},
+			},
+			Environment: EnvVars{{"TEST_ENV": "hoge"}},
+			Image:       "alpine",
+		}, gotJob)
+	})
+
+	testCases := []struct {
+		name    string
+		jobName string
+		want    Job
+	}{
+		{
+			name:    "success with merging shared settings",
+			jobName: "main",
+			want: Job{
+				Annotations: map[string]interface{}{
+					"screwdriver.cd/cpu": "LOW",
+					"screwdriver.cd/ram": "HIGH",
+					"beta.screwdriver.cd/executor": map[string]interface{}{
+						"name": "k8s",
+					},
+				},
+				Steps: []Step{
+					{Name: "install", Command: "echo install"},
+					{Name: "publish", Command: "echo publish"},
+				},
+				Environment: EnvVars{
+					{"SHARED_ENV": "shared"},
+					{"OVERRIDDEN_ENV": "job"},
+					{"JOB_ENV": "job"},
+					{"NUMBER_ENV": "1"},
+					{"BOOL_ENV": "true"},
+				},
+				Image:    "alpine",
+				Requires: []string{"~pr", "~commit"},
+				Secrets:  []string{"GIT_KEY", "GITHUB_TOKEN"},
+			},
+		},
+		{
+			name:    "success with only shared settings",
+			jobName: "shared",
+			want: Job{
+				Annotations: map[string]interface{}{
+					"screwdriver.cd/cpu": "LOW",
+				},
+				Steps: []Step{
+					{Name: "shared", Command: "echo shared"},
+				},
+				Environment: EnvVars{
+					{"SHARED_ENV": "shared"},
+					{"OVERRIDDEN_ENV": "shared"},
+				},
+				Image:    "node:18",
+				Requires: []string{"main"},
+				Secrets:  []string{"NPM_TOKEN"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			gotJob, err := testAPI.Job(tt.jobName, filepath.Join(testDir, "screwdriverOffline.yaml"))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, gotJob)
+		})
+	}
+
+	t.Run("failure by template", func(t *testing.T) {
+		_, err := testAPI.Job("template", filepath.Join(testDir, "screwdriverOffline.yaml"))
+		assert.EqualError(t, err, "failed to parse screwdriver.yaml: job `template` uses template `node/test@1`, templates are not supported in offline mode")
+	})
+
+	t.Run("failure by job not found", func(t *testing.T) {
+		_, err := testAPI.Job("notfound", filepath.Join(testDir, "screwdriverOffline.yaml"))
+		assert.EqualError(t, err, "not found 'notfound' in parsed screwdriver.yaml")
+	})
+
+	t.Run("failure by invalid yaml", func(t *testing.T) {
+		_, err := testAPI.Job("main", filepath.Join(testDir, "screwdriverInvalid.yaml"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("failure by reading screwdriver.yaml", func(t *testing.T) {
+		_, err := testAPI.Job("main", filepath.Join(testDir, "notExist.yaml"))
+		assert.Contains(t, err.Error(), "failed to read screwdriver.yaml")
+	})
+}
+
+func TestOfflinePipeline(t *testing.T) {
+	testAPI := NewOffline()
+
+	t.Run("success", func(t *testing.T) {
+		gotPipeline, err := testAPI.Pipeline(filepath.Join(testDir, "screwdriverPipeline.yaml"))
+		assert.Nil(t, err)
+		assert.Len(t, gotPipeline.Jobs, 6)
+		assert.Equal(t, WorkflowGraph{
+			Nodes: []WorkflowNode{
+				{Name: "~pr"},
+				{Name: "~commit"},
+				{Name: "test"},
+				{Name: "lint"},
+				{Name: "build"},
+				{Name: "publish"},
+				{Name: "deploy"},
+				{Name: "external"},
+				{Name: "~sd@123:main"},
+			},
+			Edges: []WorkflowEdge{
+				{Src: "~pr", Dest: "test"},
+				{Src: "~commit", Dest: "test"},
+				{Src: "~commit", Dest: "lint"},
+				{Src: "test", Dest: "build"},
+				{Src: "build", Dest: "publish", Join: true},
+				{Src: "lint", Dest: "publish", Join: true},
+				{Src: "publish", Dest: "deploy"},
+				{Src: "~sd@123:main", Dest: "external"},
+			},
+		}, gotPipeline.WorkflowGraph)
+		assert.Equal(t, "alpine", gotPipeline.Jobs["publish"].Image)
+	})
+
+	t.Run("failure by template", func(t *testing.T) {
+		_, err := testAPI.Pipeline(filepath.Join(testDir, "screwdriverOffline.yaml"))
+		assert.EqualError(t, err, "failed to parse screwdriver.yaml: job `template` uses template `node/test@1`, templates are not supported in offline mode")
+	})
+}
+
+func TestOfflineJobCache(t *testing.T) {
+	testAPI := NewOffline()
+
+	testCases := []struct {
+		name    string
+		jobName string
+		want    *Cache
+	}{
+		{"job with its own cache", "test", &Cache{Pipeline: []string{"$SD_SOURCE_DIR/node_modules"}, Event: []string{"/tmp/event"}, Job: []string{"~/.npm"}}},
+		{"job without its own cache", "build", &Cache{Pipeline: []string{"$SD_SOURCE_DIR/node_modules"}, Event: []string{"/tmp/event"}}},
+		{"job disabling cache", "nocache", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			gotJob, err := testAPI.Job(tt.jobName, filepath.Join(testDir, "screwdriverCache.yaml"))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, gotJob.Cache)
+		})
+	}
+}
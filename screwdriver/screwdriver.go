@@ -1,6 +1,7 @@
 package screwdriver
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,7 +11,9 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	ordered "gitlab.com/c0b/go-ordered-json"
 )
 
@@ -23,16 +26,38 @@ const (
 // API has method to get job
 type API interface {
 	Job(jobName, filePath string) (Job, error)
+	Pipeline(filePath string) (Pipeline, error)
 	JWT() string
 	InitJWT() error
+	SetJWT(jwt string)
 }
 
+// APIError is returned when a request to the Screwdriver.cd API fails
+type APIError struct {
+	Err error
+}
+
+func (e *APIError) Error() string { return e.Err.Error() }
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// ValidationError is returned when screwdriver.yaml is invalid or does not have the job
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
 type sdAPI struct {
-	HTTPClient *http.Client
-	UserToken  string
-	APIURL     string
-	SDJWT      string
-	UA         string
+	HTTPClient   *http.Client
+	UserToken    string
+	APIURL       string
+	SDJWT        string
+	UA           string
+	Retries      int
+	RetryBackoff time.Duration
 }
 
 var _ API = (*sdAPI)(nil)
@@ -74,35 +99,99 @@ func (en *EnvVars) AppendAll(en2 map[string]string) {
 	}
 }
 
+// Cache is the directories cached in the scopes of pipeline, event and job
+type Cache struct {
+	Pipeline []string `json:"pipeline"`
+	Event    []string `json:"event"`
+	Job      []string `json:"job"`
+}
+
+// UnmarshalJSON accepts a boolean as well, which is set when the cache is disabled for the job
+func (c *Cache) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*c = Cache{}
+		return nil
+	}
+
+	type cache Cache
+	if err := json.Unmarshal(data, (*cache)(c)); err != nil {
+		return fmt.Errorf("failed to unmarshal cache: %v", err)
+	}
+
+	return nil
+}
+
+// Empty returns true when no directories are cached
+func (c *Cache) Empty() bool {
+	return c == nil || len(c.Pipeline)+len(c.Event)+len(c.Job) == 0
+}
+
 // Job is job entity struct
 type Job struct {
 	Annotations map[string]interface{} `json:"annotations"`
 	Steps       []Step                 `json:"commands"`
 	Environment EnvVars                `json:"environment"`
 	Image       string                 `json:"image"`
+	Requires    []string               `json:"requires"`
+	Cache       *Cache                 `json:"cache"`
+	Secrets     []string               `json:"secrets"`
 }
 
 type jobs map[string][]Job
 
+// WorkflowNode is a node of the workflow graph, which is a job or a trigger such as "~commit"
+type WorkflowNode struct {
+	Name string `json:"name"`
+}
+
+// WorkflowEdge is an edge of the workflow graph
+// Join is true when the destination waits for all of its join sources.
+type WorkflowEdge struct {
+	Src  string `json:"src"`
+	Dest string `json:"dest"`
+	Join bool   `json:"join,omitempty"`
+}
+
+// WorkflowGraph represents the order of jobs built from "requires"
+type WorkflowGraph struct {
+	Nodes []WorkflowNode `json:"nodes"`
+	Edges []WorkflowEdge `json:"edges"`
+}
+
+// Pipeline is the jobs and the workflow graph of a screwdriver.yaml
+type Pipeline struct {
+	Jobs          map[string]Job
+	WorkflowGraph WorkflowGraph
+}
+
 type validatorResponse struct {
-	Jobs   jobs     `json:"jobs"`
-	Errors []string `json:"errors"`
+	Jobs          jobs          `json:"jobs"`
+	WorkflowGraph WorkflowGraph `json:"workflowGraph"`
+	Errors        []string      `json:"errors"`
 }
 
 type tokenResponse struct {
 	JWT string `json:"token"`
 }
 
-// New creates a API
-func New(apiURL, token, ua string) API {
+// New creates a API which sends the requests as configured by the options
+func New(apiURL, token, ua string, options HTTPOptions) (API, error) {
+	client, err := newHTTPClient(options)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &sdAPI{
-		HTTPClient: http.DefaultClient,
-		APIURL:     apiURL,
-		UserToken:  token,
-		UA:         ua,
+		HTTPClient:   client,
+		APIURL:       apiURL,
+		UserToken:    token,
+		UA:           ua,
+		Retries:      options.Retries,
+		RetryBackoff: options.RetryBackoff,
 	}
 
-	return s
+	return s, nil
 }
 
 func (sd *sdAPI) makeURL(endpoint string) (*url.URL, error) {
@@ -115,8 +204,41 @@ func (sd *sdAPI) makeURL(endpoint string) (*url.URL, error) {
 	return u, nil
 }
 
+// request sends the request, retrying it on the transient errors
 func (sd *sdAPI) request(method, path string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, path, body)
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+
+	backoff := sd.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := sd.send(method, path, b)
+		if attempt >= sd.Retries || !retryable(res, err) {
+			return res, err
+		}
+
+		if err != nil {
+			logrus.Warnf("Retrying the request to %s in %s: %v", sd.APIURL, backoff, err)
+		} else {
+			logrus.Warnf("Retrying the request to %s in %s: StatusCode %d", sd.APIURL, backoff, res.StatusCode)
+			res.Body.Close()
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (sd *sdAPI) send(method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, path, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +263,7 @@ func (sd *sdAPI) request(method, path string, body io.Reader) (*http.Response, e
 func (sd *sdAPI) jwt() (string, error) {
 	fullpath, err := sd.makeURL(tokenEndpoint)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request url: %v", err)
+		return "", &APIError{Err: fmt.Errorf("failed to make request url: %v", err)}
 	}
 
 	query := fullpath.Query()
@@ -150,17 +272,17 @@ func (sd *sdAPI) jwt() (string, error) {
 
 	res, err := sd.request(http.MethodGet, fullpath.String(), nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return "", &APIError{Err: fmt.Errorf("failed to send request: %v", err)}
 	}
 	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get JWT: StatusCode %d", res.StatusCode)
+		return "", &APIError{Err: fmt.Errorf("failed to get JWT: StatusCode %d", res.StatusCode)}
 	}
 	defer res.Body.Close()
 
 	tokenResponse := new(tokenResponse)
 	err = json.NewDecoder(res.Body).Decode(tokenResponse)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse JWT response: %v", err)
+		return "", &APIError{Err: fmt.Errorf("failed to parse JWT response: %v", err)}
 	}
 
 	return tokenResponse.JWT, nil
@@ -169,15 +291,15 @@ func (sd *sdAPI) jwt() (string, error) {
 func readScrewdriverYAML(filePath string) (string, error) {
 	yaml, err := os.ReadFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read screwdriver.yaml: %v", err)
+		return "", &ValidationError{Err: fmt.Errorf("failed to read screwdriver.yaml: %v", err)}
 	}
 	return string(yaml), nil
 }
 
-func (sd *sdAPI) validate(filePath string) (jobs, error) {
+func (sd *sdAPI) validate(filePath string) (*validatorResponse, error) {
 	fullpath, err := sd.makeURL(validatorEndpoint)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request url: %v", err)
+		return nil, &APIError{Err: fmt.Errorf("failed to make request url: %v", err)}
 	}
 
 	yaml, err := readScrewdriverYAML(filePath)
@@ -190,42 +312,63 @@ func (sd *sdAPI) validate(filePath string) (jobs, error) {
 
 	res, err := sd.request(http.MethodPost, fullpath.String(), strings.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		return nil, &APIError{Err: fmt.Errorf("failed to send request: %v", err)}
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to post validator: StatusCode %d", res.StatusCode)
+		return nil, &APIError{Err: fmt.Errorf("failed to post validator: StatusCode %d", res.StatusCode)}
 	}
 
 	v := new(validatorResponse)
 	err = json.NewDecoder(res.Body).Decode(v)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse validator response: %v", err)
+		return nil, &APIError{Err: fmt.Errorf("failed to parse validator response: %v", err)}
 	}
 
 	if v.Errors != nil {
-		return nil, fmt.Errorf("failed to parse screwdriver.yaml: %v", v.Errors)
+		return nil, &ValidationError{Err: fmt.Errorf("failed to parse screwdriver.yaml: %v", v.Errors)}
 	}
 
-	return v.Jobs, nil
+	return v, nil
 }
 
 // Job returns job represented by "jobName"
 func (sd *sdAPI) Job(jobName, filepath string) (Job, error) {
-	jobs, err := sd.validate(filepath)
+	v, err := sd.validate(filepath)
 	if err != nil {
 		return Job{}, err
 	}
 
-	job, ok := jobs[jobName]
+	job, ok := v.Jobs[jobName]
 	if !ok {
-		return Job{}, fmt.Errorf("not found '%s' in parsed screwdriver.yaml", jobName)
+		return Job{}, &ValidationError{Err: fmt.Errorf("not found '%s' in parsed screwdriver.yaml", jobName)}
 	}
 
 	return job[0], nil
 }
 
+// Pipeline returns all jobs and the workflow graph
+func (sd *sdAPI) Pipeline(filepath string) (Pipeline, error) {
+	v, err := sd.validate(filepath)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	jobs := make(map[string]Job, len(v.Jobs))
+	for name, job := range v.Jobs {
+		if len(job) == 0 {
+			continue
+		}
+		jobs[name] = job[0]
+	}
+
+	return Pipeline{
+		Jobs:          jobs,
+		WorkflowGraph: v.WorkflowGraph,
+	}, nil
+}
+
 func (sd *sdAPI) InitJWT() error {
 	jwt, err := sd.jwt()
 	if err != nil {
@@ -241,3 +384,8 @@ func (sd *sdAPI) InitJWT() error {
 func (sd *sdAPI) JWT() string {
 	return sd.SDJWT
 }
+
+// SetJWT sets the JWT got before, e.g. the cached one, instead of InitJWT
+func (sd *sdAPI) SetJWT(jwt string) {
+	sd.SDJWT = jwt
+}
@@ -1,6 +1,7 @@
 package screwdriver
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -29,11 +30,14 @@ func TestNew(t *testing.T) {
 		testToken := "token"
 
 		ua := "sd-local/dev (linux; eb004dc1-614c-11eb-bab9-0242ac120002)"
-		gotAPI := New("http://example.com:yyy", testToken, ua)
+		gotAPI, err := New("http://example.com:yyy", testToken, ua, DefaultHTTPOptions())
+		assert.Nil(t, err)
 		api, ok := gotAPI.(*sdAPI)
 		assert.True(t, ok)
 		assert.Equal(t, testToken, api.UserToken)
 		assert.Equal(t, "http://example.com:yyy", api.APIURL)
+		assert.Equal(t, DefaultTimeout, api.HTTPClient.Timeout)
+		assert.Equal(t, DefaultRetries, api.Retries)
 	})
 }
 
@@ -221,6 +225,107 @@ func TestJob(t *testing.T) {
 	})
 }
 
+func TestPipeline(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Header().Set("Content-Type", "application/json")
+
+			testJSON, err := os.ReadFile(filepath.Join(testDir, "validatedPipeline.json"))
+			assert.Nil(t, err)
+			fmt.Fprintln(w, string(testJSON))
+		}))
+
+		testAPI := sdAPI{
+			HTTPClient: http.DefaultClient,
+			UserToken:  "dummy",
+			APIURL:     server.URL,
+			SDJWT:      "jwt",
+		}
+
+		gotPipeline, err := testAPI.Pipeline(filepath.Join(testDir, "screwdriver.yaml"))
+		assert.Nil(t, err)
+		assert.Equal(t, Pipeline{
+			Jobs: map[string]Job{
+				"test": {
+					Steps:    []Step{{Name: "test", Command: "echo test"}},
+					Image:    "alpine",
+					Requires: []string{"~commit"},
+				},
+				"publish": {
+					Steps:    []Step{{Name: "publish", Command: "echo publish"}},
+					Image:    "alpine",
+					Requires: []string{"test"},
+					Secrets:  []string{"GITHUB_TOKEN"},
+				},
+			},
+			WorkflowGraph: WorkflowGraph{
+				Nodes: []WorkflowNode{{Name: "~pr"}, {Name: "~commit"}, {Name: "test"}, {Name: "publish"}},
+				Edges: []WorkflowEdge{
+					{Src: "~commit", Dest: "test"},
+					{Src: "test", Dest: "publish", Join: true},
+				},
+			},
+		}, gotPipeline)
+	})
+
+	t.Run("failure by validator errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Header().Set("Content-Type", "application/json")
+
+			testJSON, err := os.ReadFile(filepath.Join(testDir, "validatedFailed.json"))
+			assert.Nil(t, err)
+			fmt.Fprintln(w, string(testJSON))
+		}))
+
+		testAPI := sdAPI{
+			HTTPClient: http.DefaultClient,
+			UserToken:  "dummy",
+			APIURL:     server.URL,
+			SDJWT:      "jwt",
+		}
+
+		_, err := testAPI.Pipeline(filepath.Join(testDir, "screwdriver.yaml"))
+		assert.NotNil(t, err)
+
+		msg := err.Error()
+		assert.Equal(t, 0, strings.Index(msg, "failed to parse screwdriver.yaml: "), fmt.Sprintf("expected error is `failed to parse screwdriver.yaml: ...`, actual: `%v`", msg))
+	})
+}
+
+func TestCacheUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name string
+		json string
+		want Cache
+	}{
+		{"cache", `{"pipeline": ["/pipeline"], "event": ["/event"], "job": ["/job"]}`, Cache{Pipeline: []string{"/pipeline"}, Event: []string{"/event"}, Job: []string{"/job"}}},
+		{"disabled cache", `false`, Cache{}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Cache{}
+			err := json.Unmarshal([]byte(tt.json), &got)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("invalid cache", func(t *testing.T) {
+		got := Cache{}
+		err := json.Unmarshal([]byte(`"cache"`), &got)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSetJWT(t *testing.T) {
+	s := &sdAPI{}
+	s.SetJWT("jwt")
+	assert.Equal(t, "jwt", s.JWT())
+}
+
 func TestInitJWT(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		testJWT := "jwt"
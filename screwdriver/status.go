@@ -0,0 +1,75 @@
+package screwdriver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+const (
+	storeVersion   = "v1"
+	statusEndpoint = "status"
+)
+
+// ParseURL parses the URL of the API or the store, which must be an absolute http or https URL
+func ParseURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, errors.New("URL is empty")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL `%s`: %v", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL `%s`: must start with http:// or https://", rawURL)
+	}
+
+	return u, nil
+}
+
+// checkStatus requests the status endpoint of the version under the URL
+func checkStatus(baseURL, version, ua string, options HTTPOptions) error {
+	u, err := ParseURL(baseURL)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, version, statusEndpoint)
+
+	client, err := newHTTPClient(options)
+	if err != nil {
+		return err
+	}
+
+	sd := &sdAPI{
+		HTTPClient:   client,
+		APIURL:       baseURL,
+		UA:           ua,
+		Retries:      options.Retries,
+		RetryBackoff: options.RetryBackoff,
+	}
+
+	res, err := sd.request(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %v", u, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to request %s: StatusCode %d", u, res.StatusCode)
+	}
+
+	return nil
+}
+
+// CheckAPIStatus checks that the API responds to its status endpoint
+func CheckAPIStatus(apiURL, ua string, options HTTPOptions) error {
+	return checkStatus(apiURL, apiVersion, ua, options)
+}
+
+// CheckStoreStatus checks that the store responds to its status endpoint
+func CheckStoreStatus(storeURL, ua string, options HTTPOptions) error {
+	return checkStatus(storeURL, storeVersion, ua, options)
+}
@@ -0,0 +1,57 @@
+package screwdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	testCases := []struct {
+		url     string
+		wantErr string
+	}{
+		{url: "https://api.screwdriver.cd"},
+		{url: "http://localhost:8080/path"},
+		{url: "", wantErr: "URL is empty"},
+		{url: "api.screwdriver.cd", wantErr: "invalid URL `api.screwdriver.cd`: must start with http:// or https://"},
+		{url: "ftp://api.screwdriver.cd", wantErr: "invalid URL `ftp://api.screwdriver.cd`: must start with http:// or https://"},
+		{url: "https://api screwdriver", wantErr: "invalid URL `https://api screwdriver`: parse \"https://api screwdriver\": invalid character \" \" in host name"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := ParseURL(tt.url)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		switch r.URL.Path {
+		case "/v4/status", "/store/v1/status":
+			w.Write([]byte("OK"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	options := HTTPOptions{Retries: 0}
+
+	assert.Nil(t, CheckAPIStatus(server.URL, "sd-local", options))
+	assert.Equal(t, "sd-local", gotUA)
+	assert.Nil(t, CheckStoreStatus(server.URL+"/store", "sd-local", options))
+
+	assert.EqualError(t, CheckStoreStatus(server.URL, "sd-local", options), "failed to request "+server.URL+"/v1/status: StatusCode 404")
+	assert.EqualError(t, CheckAPIStatus("api.screwdriver.cd", "sd-local", options), "invalid URL `api.screwdriver.cd`: must start with http:// or https://")
+}
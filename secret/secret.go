@@ -0,0 +1,160 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/screwdriver-cd/sd-local/config"
+)
+
+var validName = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// Passphrase returns the passphrase which the key of the secrets is derived from.
+// confirm is true when the secrets of the config are encrypted for the first time,
+// so that a mistyped passphrase can be caught before it locks the secrets.
+type Passphrase func(confirm bool) (string, error)
+
+// Store is the secrets of a config, which are encrypted in a file with the key derived from a passphrase
+type Store struct {
+	filePath   string
+	passphrase Passphrase
+	// unlocked is the passphrase which decrypted the file, which is reused to encrypt it again
+	unlocked string
+	secrets  map[string]string
+}
+
+// ValidateName returns an error when the name can't be used as a secret name
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name `%s`, it must consist of uppercase letters, digits and underscores and not start with a digit", name)
+	}
+
+	return nil
+}
+
+// New reads the secrets of the config stored in the directory.
+// The passphrase is asked only when the secrets of the config exist.
+func New(dir, configName string, passphrase Passphrase) (*Store, error) {
+	if configName == "" || configName == "." || configName == ".." || strings.ContainsAny(configName, `/\`) {
+		return nil, fmt.Errorf("invalid config name `%s` for secrets", configName)
+	}
+
+	s := &Store{
+		filePath:   filepath.Join(dir, configName),
+		passphrase: passphrase,
+		secrets:    make(map[string]string),
+	}
+
+	encrypted, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read secrets: %v", err)
+	}
+
+	p, err := passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := config.Decrypt(encrypted, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets: %v", err)
+	}
+
+	if err := json.Unmarshal(plain, &s.secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets: %v", err)
+	}
+	s.unlocked = p
+
+	return s, nil
+}
+
+// Set sets the value of the secret
+func (s *Store) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	s.secrets[name] = value
+	return nil
+}
+
+// Remove removes the secret
+func (s *Store) Remove(name string) error {
+	if _, ok := s.secrets[name]; !ok {
+		return fmt.Errorf("secret `%s` does not exist", name)
+	}
+
+	delete(s.secrets, name)
+	return nil
+}
+
+// Names returns the sorted names of the secrets
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Resolve returns the values of the secrets declared in a job.
+// It fails with all the missing names so that they can be set at once.
+func (s *Store) Resolve(names []string) (map[string]string, error) {
+	resolved := make(map[string]string, len(names))
+	missing := make([]string, 0)
+	for _, name := range names {
+		value, ok := s.secrets[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		resolved[name] = value
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("secrets declared in the job are not set: %s, set them with `sd-local secret set <name>`", strings.Join(missing, ", "))
+	}
+
+	return resolved, nil
+}
+
+// Save encrypts and writes the secrets to the file
+func (s *Store) Save() error {
+	if s.unlocked == "" {
+		p, err := s.passphrase(true)
+		if err != nil {
+			return err
+		}
+		s.unlocked = p
+	}
+
+	plain, err := json.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to save secrets: %v", err)
+	}
+
+	encrypted, err := config.Encrypt(plain, s.unlocked)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %v", err)
+	}
+
+	if err := os.WriteFile(s.filePath, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to save secrets: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// passphrase returns the passphrase, recording whether it is confirmed
+func passphrase(p string, confirmed *[]bool) Passphrase {
+	return func(confirm bool) (string, error) {
+		if confirmed != nil {
+			*confirmed = append(*confirmed, confirm)
+		}
+		return p, nil
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("success with no secrets file", func(t *testing.T) {
+		s, err := New(t.TempDir(), "default", passphrase("pass", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, s.Names())
+	})
+
+	t.Run("success reading saved secrets", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := New(dir, "default", passphrase("pass", nil))
+		assert.Nil(t, err)
+		assert.Nil(t, s.Set("GITHUB_TOKEN", "token"))
+		assert.Nil(t, s.Set("GIT_KEY", "key"))
+		assert.Nil(t, s.Save())
+
+		encrypted, err := os.ReadFile(filepath.Join(dir, "default"))
+		assert.Nil(t, err)
+		assert.False(t, strings.Contains(string(encrypted), "token"))
+
+		info, err := os.Stat(filepath.Join(dir, "default"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		s, err = New(dir, "default", passphrase("pass", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"GITHUB_TOKEN", "GIT_KEY"}, s.Names())
+
+		other, err := New(dir, "other", passphrase("pass", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, other.Names())
+	})
+
+	t.Run("failure by invalid config name", func(t *testing.T) {
+		_, err := New(t.TempDir(), "../default", passphrase("pass", nil))
+		assert.EqualError(t, err, "invalid config name `../default` for secrets")
+	})
+
+	t.Run("failure by wrong passphrase", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := New(dir, "default", passphrase("pass", nil))
+		assert.Nil(t, err)
+		assert.Nil(t, s.Set("GIT_KEY", "key"))
+		assert.Nil(t, s.Save())
+
+		_, err = New(dir, "default", passphrase("wrong", nil))
+		assert.EqualError(t, err, "failed to decrypt secrets: the passphrase is wrong or the data is broken")
+	})
+
+	t.Run("failure by passphrase error", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "default"), []byte("encrypted"), 0600)
+		assert.Nil(t, err)
+
+		_, err = New(dir, "default", func(bool) (string, error) { return "", errors.New("no terminal") })
+		assert.EqualError(t, err, "no terminal")
+	})
+}
+
+func TestSave(t *testing.T) {
+	t.Run("success to confirm the passphrase only when the secrets are created", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "secrets")
+		confirmed := []bool{}
+		s, err := New(dir, "default", passphrase("pass", &confirmed))
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{}, confirmed)
+
+		assert.Nil(t, s.Set("GIT_KEY", "key"))
+		assert.Nil(t, s.Save())
+		assert.Nil(t, s.Save())
+		assert.Equal(t, []bool{true}, confirmed)
+
+		_, err = os.Stat(filepath.Join(dir, ".key"))
+		assert.True(t, os.IsNotExist(err))
+
+		s, err = New(dir, "default", passphrase("pass", &confirmed))
+		assert.Nil(t, err)
+		assert.Nil(t, s.Save())
+		assert.Equal(t, []bool{true, false}, confirmed)
+	})
+
+	t.Run("failure by empty passphrase", func(t *testing.T) {
+		s, err := New(t.TempDir(), "default", passphrase("", nil))
+		assert.Nil(t, err)
+		assert.EqualError(t, s.Save(), "failed to encrypt secrets: the passphrase is empty")
+	})
+}
+
+func TestSetAndRemove(t *testing.T) {
+	s, err := New(t.TempDir(), "default", passphrase("pass", nil))
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name      string
+		secret    string
+		wantError string
+	}{
+		{name: "success", secret: "GIT_KEY"},
+		{name: "success with underscore", secret: "_TOKEN_2"},
+		{name: "failure by lowercase", secret: "git_key", wantError: "invalid secret name `git_key`, it must consist of uppercase letters, digits and underscores and not start with a digit"},
+		{name: "failure by leading digit", secret: "1KEY", wantError: "invalid secret name `1KEY`, it must consist of uppercase letters, digits and underscores and not start with a digit"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Set(tt.secret, "value")
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+
+	assert.Nil(t, s.Remove("GIT_KEY"))
+	assert.Equal(t, []string{"_TOKEN_2"}, s.Names())
+	assert.EqualError(t, s.Remove("GIT_KEY"), "secret `GIT_KEY` does not exist")
+}
+
+func TestResolve(t *testing.T) {
+	s, err := New(t.TempDir(), "default", passphrase("pass", nil))
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set("GIT_KEY", "key"))
+	assert.Nil(t, s.Set("GITHUB_TOKEN", "token"))
+	assert.Nil(t, s.Set("NPM_TOKEN", "npm"))
+
+	got, err := s.Resolve([]string{"GIT_KEY", "GITHUB_TOKEN"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"GIT_KEY": "key", "GITHUB_TOKEN": "token"}, got)
+
+	_, err = s.Resolve([]string{"GIT_KEY", "AWS_KEY", "AWS_SECRET"})
+	assert.EqualError(t, err, "secrets declared in the job are not set: AWS_KEY, AWS_SECRET, set them with `sd-local secret set <name>`")
+}
@@ -0,0 +1,237 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SessionLabel is the label of the resources which sd-local creates, whose value is the ID of the session
+	SessionLabel = "cd.screwdriver.sd-local.session"
+	// PIDLabel is the label of the process ID of sd-local which created the resource
+	PIDLabel = "cd.screwdriver.sd-local.pid"
+
+	// VolumeKind is the kind of the volumes
+	VolumeKind = "volume"
+	// ContainerKind is the kind of the containers
+	ContainerKind = "container"
+	// NetworkKind is the kind of the networks
+	NetworkKind = "network"
+	// CloneKind is the kind of the directories which the source code is cloned into
+	CloneKind = "clone"
+
+	fileExt = ".json"
+)
+
+// processRunning returns true if the process exists, even if it is owned by the other user
+var processRunning = func(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// ProcessRunning returns true if the process of the PID is running
+func ProcessRunning(pid int) bool {
+	return processRunning(pid)
+}
+
+// Resource is a volume, a container, a network or a clone which sd-local creates
+type Resource struct {
+	// Runtime is the container runtime which the resource is created with, which is empty for the clones
+	Runtime string `json:"runtime,omitempty"`
+	// Sudo is true when the resource is created with sudo
+	Sudo bool   `json:"sudo,omitempty"`
+	Kind string `json:"kind"`
+	// Name is the name of the resource, or the path of the clone
+	Name string `json:"name"`
+}
+
+func (r Resource) String() string {
+	if r.Runtime == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s %s (%s)", r.Kind, r.Name, r.Runtime)
+}
+
+// Session is the state of a sd-local process, which is saved while it has resources.
+// The methods of the nil Session do nothing, so that the resources are not recorded without the store.
+type Session struct {
+	ID        string     `json:"id"`
+	PID       int        `json:"pid"`
+	StartTime time.Time  `json:"startTime"`
+	Resources []Resource `json:"resources"`
+
+	path  string
+	mutex sync.Mutex
+}
+
+// Store manages the sessions under the base directory. A session is stored in <base>/<id>.json.
+type Store struct {
+	baseDir string
+}
+
+// New returns the store of the sessions in the base directory
+func New(baseDir string) *Store {
+	return &Store{baseDir: baseDir}
+}
+
+// NewSession returns the session of this process, which is saved when the first resource is added
+func (s *Store) NewSession() *Session {
+	id := uuid.NewString()
+
+	return &Session{
+		ID:        id,
+		PID:       os.Getpid(),
+		StartTime: time.Now(),
+		Resources: []Resource{},
+		path:      filepath.Join(s.baseDir, id+fileExt),
+	}
+}
+
+// Sessions returns the saved sessions, the oldest first
+func (s *Store) Sessions() ([]*Session, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Session{}, nil
+		}
+		return nil, fmt.Errorf("failed to read state: %v", err)
+	}
+
+	sessions := make([]*Session, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), fileExt) {
+			continue
+		}
+
+		path := filepath.Join(s.baseDir, e.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state: %v", err)
+		}
+
+		session := &Session{}
+		if err := json.Unmarshal(b, session); err != nil {
+			return nil, fmt.Errorf("failed to read state %s: %v", path, err)
+		}
+		session.path = path
+		sessions = append(sessions, session)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+
+	return sessions, nil
+}
+
+// Labels returns the labels put on the resources of the session
+func (s *Session) Labels() map[string]string {
+	if s == nil {
+		return nil
+	}
+
+	return map[string]string{
+		SessionLabel: s.ID,
+		PIDLabel:     strconv.Itoa(s.PID),
+	}
+}
+
+// Running returns true if the process of the session is running
+func (s *Session) Running() bool {
+	return processRunning(s.PID)
+}
+
+// Add records the resources before they are created
+func (s *Session) Add(resources ...Resource) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Resources = append(s.Resources, resources...)
+	return s.save()
+}
+
+// Remove forgets the resources after they are removed.
+// The state file is removed when the session has no resources.
+func (s *Session) Remove(resources ...Resource) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, r := range resources {
+		for i, v := range s.Resources {
+			if v == r {
+				s.Resources = append(s.Resources[:i], s.Resources[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if len(s.Resources) == 0 {
+		return s.delete()
+	}
+	return s.save()
+}
+
+// Delete removes the state file of the session
+func (s *Session) Delete() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.delete()
+}
+
+func (s *Session) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to save state: %v", err)
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to save state: %v", err)
+	}
+
+	// The file is replaced at once not to be read half-written by `sd-local clean`
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to save state: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save state: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Session) delete() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	bin := Resource{Runtime: "docker", Sudo: true, Kind: VolumeKind, Name: "SD_LAUNCH_BIN"}
+	hab := Resource{Runtime: "docker", Sudo: true, Kind: VolumeKind, Name: "SD_LAUNCH_HAB"}
+	clone := Resource{Kind: CloneKind, Name: "/home/user/.sdlocal/repo/1"}
+
+	t.Run("success", func(t *testing.T) {
+		s := New(filepath.Join(t.TempDir(), "state"))
+		session := s.NewSession()
+		assert.Equal(t, os.Getpid(), session.PID)
+		assert.Equal(t, map[string]string{
+			SessionLabel: session.ID,
+			PIDLabel:     strconv.Itoa(os.Getpid()),
+		}, session.Labels())
+		assert.True(t, session.Running())
+
+		// The session is not saved until it has resources
+		sessions, err := s.Sessions()
+		assert.Nil(t, err)
+		assert.Empty(t, sessions)
+
+		assert.Nil(t, session.Add(clone))
+		assert.Nil(t, session.Add(bin, hab))
+
+		info, err := os.Stat(filepath.Join(s.baseDir, session.ID+".json"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		sessions, err = s.Sessions()
+		assert.Nil(t, err)
+		assert.Len(t, sessions, 1)
+		assert.Equal(t, session.ID, sessions[0].ID)
+		assert.Equal(t, []Resource{clone, bin, hab}, sessions[0].Resources)
+
+		assert.Nil(t, session.Remove(bin, hab))
+		sessions, err = s.Sessions()
+		assert.Nil(t, err)
+		assert.Equal(t, []Resource{clone}, sessions[0].Resources)
+
+		// The state file is removed with the last resource
+		assert.Nil(t, session.Remove(clone))
+		sessions, err = s.Sessions()
+		assert.Nil(t, err)
+		assert.Empty(t, sessions)
+	})
+
+	t.Run("success to delete the state file", func(t *testing.T) {
+		s := New(t.TempDir())
+		session := s.NewSession()
+		assert.Nil(t, session.Add(bin))
+		assert.Nil(t, session.Delete())
+		assert.Nil(t, session.Delete())
+
+		sessions, err := s.Sessions()
+		assert.Nil(t, err)
+		assert.Empty(t, sessions)
+	})
+
+	t.Run("success with nil session", func(t *testing.T) {
+		var session *Session
+		assert.Nil(t, session.Labels())
+		assert.Nil(t, session.Add(bin))
+		assert.Nil(t, session.Remove(bin))
+		assert.Nil(t, session.Delete())
+	})
+}
+
+func TestSessions(t *testing.T) {
+	t.Run("success sorted by the start time", func(t *testing.T) {
+		dir := t.TempDir()
+		s := New(dir)
+
+		older := s.NewSession()
+		older.StartTime = time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+		newer := s.NewSession()
+		newer.StartTime = older.StartTime.Add(time.Hour)
+		assert.Nil(t, newer.Add(Resource{Kind: CloneKind, Name: "/repo/2"}))
+		assert.Nil(t, older.Add(Resource{Kind: CloneKind, Name: "/repo/1"}))
+
+		// The other files are ignored
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "session.json.tmp"), []byte("{"), 0600))
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, "dir.json"), 0700))
+
+		sessions, err := s.Sessions()
+		assert.Nil(t, err)
+		assert.Len(t, sessions, 2)
+		assert.Equal(t, older.ID, sessions[0].ID)
+		assert.Equal(t, newer.ID, sessions[1].ID)
+
+		// The read session can be removed
+		assert.Nil(t, sessions[0].Delete())
+		sessions, err = s.Sessions()
+		assert.Nil(t, err)
+		assert.Len(t, sessions, 1)
+	})
+
+	t.Run("failure by broken state", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600))
+
+		_, err := New(dir).Sessions()
+		assert.EqualError(t, err, "failed to read state "+filepath.Join(dir, "broken.json")+": unexpected end of JSON input")
+	})
+}
+
+func TestProcessRunning(t *testing.T) {
+	assert.True(t, ProcessRunning(os.Getpid()))
+	assert.False(t, ProcessRunning(0))
+
+	defer func(f func(int) bool) { processRunning = f }(processRunning)
+	processRunning = func(pid int) bool { return false }
+
+	assert.False(t, ProcessRunning(os.Getpid()))
+	assert.False(t, (&Session{PID: os.Getpid()}).Running())
+}
+
+func TestResourceString(t *testing.T) {
+	assert.Equal(t, "volume SD_LAUNCH_BIN (docker)", Resource{Runtime: "docker", Kind: VolumeKind, Name: "SD_LAUNCH_BIN"}.String())
+	assert.Equal(t, "clone /repo/1", Resource{Kind: CloneKind, Name: "/repo/1"}.String())
+}
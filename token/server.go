@@ -0,0 +1,173 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Path is the path of the endpoint which returns the JWT
+const Path = "/token"
+
+// bridgeInterfaces are the bridges whose gateway the build containers reach the host through with host-gateway
+var bridgeInterfaces = []string{"docker0", "podman0", "cni-podman0"}
+
+// listenAddresses returns the addresses which the server listens on, instead of every interface not to serve the JWT to the network.
+// The loopback address is reached from the containers of Docker Desktop and rootless podman,
+// and the gateways of the bridges from the containers on Linux.
+var listenAddresses = func() []string {
+	addrs := []string{"127.0.0.1"}
+	for _, name := range bridgeInterfaces {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			continue
+		}
+		ifaddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range ifaddrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				addrs = append(addrs, ipnet.IP.String())
+			}
+		}
+	}
+
+	return addrs
+}
+
+// Server serves the JWT to the build containers, so that long builds can get the refreshed one.
+// The requests must have the key of the build as the bearer token.
+type Server struct {
+	listeners []net.Listener
+	server    *http.Server
+	jwt       func() (string, error)
+
+	mutex sync.Mutex
+	keys  map[string]bool
+}
+
+// NewServer starts the server which returns the JWT by jwt
+func NewServer(jwt func() (string, error)) (*Server, error) {
+	addrs := listenAddresses()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(addrs[0], "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start token server: %v", err)
+	}
+	listeners := []net.Listener{listener}
+
+	// The other addresses are listened on the same port, since the build containers know only one port
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	for _, addr := range addrs[1:] {
+		l, err := net.Listen("tcp", net.JoinHostPort(addr, port))
+		if err != nil {
+			logrus.Warnf("failed to start token server on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, l)
+	}
+
+	s := &Server{
+		listeners: listeners,
+		jwt:       jwt,
+		keys:      make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, s.handle)
+	s.server = &http.Server{Handler: mux}
+
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if err := s.server.Serve(l); err != nil && err != http.ErrServerClosed {
+				logrus.Warnf("failed to serve token server: %v", err)
+			}
+		}(l)
+	}
+
+	return s, nil
+}
+
+// authorized returns true if the request has one of the keys of the running builds
+func (s *Server) authorized(r *http.Request) bool {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	found := false
+	for k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			found = true
+		}
+	}
+
+	return found
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	jwt, err := s.jwt()
+	if err != nil {
+		logrus.Warnf("failed to refresh JWT: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, jwt)
+}
+
+// Port returns the port which the server listens
+func (s *Server) Port() int {
+	return s.listeners[0].Addr().(*net.TCPAddr).Port
+}
+
+// NewKey returns the key shared with a build, which its requests must have until it is revoked
+func (s *Server) NewKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token server key: %v", err)
+	}
+	key := hex.EncodeToString(b)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.keys[key] = true
+	return key, nil
+}
+
+// RevokeKey rejects the requests with the key after the build finishes
+func (s *Server) RevokeKey(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.keys, key)
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.server.Close()
+}
@@ -0,0 +1,166 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	jwtErr := error(nil)
+	s, err := NewServer(func() (string, error) {
+		return "jwt", jwtErr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	key, err := s.NewKey()
+	assert.Nil(t, err)
+	assert.Len(t, key, 64)
+
+	revoked, err := s.NewKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, revoked)
+	s.RevokeKey(revoked)
+
+	testCases := []struct {
+		name       string
+		method     string
+		auth       string
+		jwtErr     error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "success",
+			method:     http.MethodGet,
+			auth:       "Bearer " + key,
+			wantStatus: http.StatusOK,
+			wantBody:   "jwt",
+		},
+		{
+			name:       "failure by no key",
+			method:     http.MethodGet,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "failure by wrong key",
+			method:     http.MethodGet,
+			auth:       "Bearer key",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "failure by revoked key",
+			method:     http.MethodGet,
+			auth:       "Bearer " + revoked,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "failure by key without bearer",
+			method:     http.MethodGet,
+			auth:       key,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "failure by method",
+			method:     http.MethodPost,
+			auth:       "Bearer " + key,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "failure by refresh",
+			method:     http.MethodGet,
+			auth:       "Bearer " + key,
+			jwtErr:     errors.New("failed to get JWT: StatusCode 500"),
+			wantStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			jwtErr = tt.jwtErr
+
+			req, err := http.NewRequest(tt.method, fmt.Sprintf("http://127.0.0.1:%d%s", s.Port(), Path), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantBody != "" {
+				body, err := io.ReadAll(res.Body)
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+		})
+	}
+
+	t.Run("failure by closed server", func(t *testing.T) {
+		port := s.Port()
+		assert.Nil(t, s.Close())
+
+		_, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, Path))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestServerListenAddresses(t *testing.T) {
+	defListenAddresses := listenAddresses
+	defer func() { listenAddresses = defListenAddresses }()
+
+	t.Run("success without listening every interface", func(t *testing.T) {
+		listenAddresses = func() []string { return []string{"127.0.0.1", "127.0.0.2"} }
+
+		s, err := NewServer(func() (string, error) { return "jwt", nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		addrs := []string{}
+		for _, l := range s.listeners {
+			addrs = append(addrs, l.Addr().String())
+		}
+		assert.Equal(t, []string{
+			fmt.Sprintf("127.0.0.1:%d", s.Port()),
+			fmt.Sprintf("127.0.0.2:%d", s.Port()),
+		}, addrs)
+	})
+
+	t.Run("success without the address which can't be listened", func(t *testing.T) {
+		listenAddresses = func() []string { return []string{"127.0.0.1", "192.0.2.1"} }
+
+		s, err := NewServer(func() (string, error) { return "jwt", nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		assert.Len(t, s.listeners, 1)
+	})
+
+	t.Run("failure by the first address", func(t *testing.T) {
+		listenAddresses = func() []string { return []string{"192.0.2.1"} }
+
+		_, err := NewServer(func() (string, error) { return "jwt", nil })
+		assert.Contains(t, fmt.Sprint(err), "failed to start token server: ")
+	})
+
+	t.Run("success with the loopback address by default", func(t *testing.T) {
+		assert.Equal(t, "127.0.0.1", defListenAddresses()[0])
+	})
+}
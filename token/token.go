@@ -0,0 +1,231 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshMargin is how long before the expiry a JWT is refreshed
+const RefreshMargin = 5 * time.Minute
+
+var now = time.Now
+
+// Claims are the claims of the JWT which sd-local shows
+type Claims struct {
+	Username string
+	Scope    []string
+	// ExpiresAt is zero when the JWT does not have the exp claim
+	ExpiresAt time.Time
+}
+
+// ParseClaims returns the claims of the JWT. The signature is not verified.
+func ParseClaims(jwt string) (Claims, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("failed to parse JWT: it must consist of 3 parts")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to parse JWT: %v", err)
+	}
+
+	claims := struct {
+		Username string   `json:"username"`
+		Scope    []string `json:"scope"`
+		Exp      float64  `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, fmt.Errorf("failed to parse JWT: %v", err)
+	}
+
+	c := Claims{Username: claims.Username, Scope: claims.Scope}
+	if claims.Exp > 0 {
+		c.ExpiresAt = time.Unix(int64(claims.Exp), 0)
+	}
+
+	return c, nil
+}
+
+// Expiry returns the time of the exp claim of the JWT. The signature is not verified.
+func Expiry(jwt string) (time.Time, error) {
+	claims, err := ParseClaims(jwt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if claims.ExpiresAt.IsZero() {
+		return time.Time{}, errors.New("failed to parse JWT: it does not have the exp claim")
+	}
+
+	return claims.ExpiresAt, nil
+}
+
+// Key identifies the JWT. The cached JWT is not used when the API URL or the user token of the config is changed.
+type Key struct {
+	Config    string
+	APIURL    string
+	UserToken string
+}
+
+func (k Key) userTokenHash() string {
+	sum := sha256.Sum256([]byte(k.UserToken))
+	return hex.EncodeToString(sum[:])
+}
+
+type cachedJWT struct {
+	APIURL        string    `json:"apiURL"`
+	UserTokenHash string    `json:"userTokenHash"`
+	JWT           string    `json:"token"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+// Store caches the JWT of each config in <base>/<config>.json
+type Store struct {
+	baseDir string
+}
+
+// New returns the store of the JWT cache under the base directory
+func New(baseDir string) *Store {
+	return &Store{baseDir: baseDir}
+}
+
+func (s *Store) path(config string) (string, error) {
+	if config == "" || strings.ContainsAny(config, `/\`) || config == "." || config == ".." {
+		return "", fmt.Errorf("invalid config name `%s` for the JWT cache", config)
+	}
+	return filepath.Join(s.baseDir, config+".json"), nil
+}
+
+// Get returns the cached JWT of the key, which is valid for RefreshMargin at least
+func (s *Store) Get(key Key) (string, bool) {
+	p, err := s.path(key.Config)
+	if err != nil {
+		return "", false
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", false
+	}
+
+	c := cachedJWT{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return "", false
+	}
+
+	if c.APIURL != key.APIURL || c.UserTokenHash != key.userTokenHash() || !now().Add(RefreshMargin).Before(c.ExpiresAt) {
+		return "", false
+	}
+
+	return c.JWT, true
+}
+
+// Put caches the JWT of the key until it expires
+func (s *Store) Put(key Key, jwt string) error {
+	p, err := s.path(key.Config)
+	if err != nil {
+		return err
+	}
+
+	exp, err := Expiry(jwt)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(cachedJWT{
+		APIURL:        key.APIURL,
+		UserTokenHash: key.userTokenHash(),
+		JWT:           jwt,
+		ExpiresAt:     exp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache JWT: %v", err)
+	}
+
+	if err := os.MkdirAll(s.baseDir, 0700); err != nil {
+		return fmt.Errorf("failed to cache JWT: %v", err)
+	}
+
+	if err := os.WriteFile(p, b, 0600); err != nil {
+		return fmt.Errorf("failed to cache JWT: %v", err)
+	}
+
+	return nil
+}
+
+// Delete removes the cached JWT of the config
+func (s *Store) Delete(config string) error {
+	p, err := s.path(config)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove JWT cache: %v", err)
+	}
+
+	return nil
+}
+
+// Source returns the JWT of a config, which is fetched again shortly before it expires
+type Source struct {
+	mutex sync.Mutex
+	store *Store
+	key   Key
+	fetch func() (string, error)
+	// jwt is the JWT cached in memory, which is used without the store
+	jwt string
+}
+
+// NewSource returns the source of the JWT which is cached in the store and fetched from the API by fetch.
+// Without the store, the JWT is cached only in memory.
+func NewSource(store *Store, key Key, fetch func() (string, error)) *Source {
+	return &Source{store: store, key: key, fetch: fetch}
+}
+
+// valid returns true if the JWT is valid for RefreshMargin at least
+func valid(jwt string) bool {
+	exp, err := Expiry(jwt)
+	return err == nil && now().Add(RefreshMargin).Before(exp)
+}
+
+// JWT returns the cached JWT, or the new one fetched when the cached one expires soon
+func (s *Source) JWT() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.store == nil {
+		if valid(s.jwt) {
+			return s.jwt, nil
+		}
+	} else if jwt, ok := s.store.Get(s.key); ok {
+		return jwt, nil
+	}
+
+	jwt, err := s.fetch()
+	if err != nil {
+		return "", err
+	}
+
+	// The JWT without the exp claim can't be cached, which is not a problem of the build
+	if _, err := Expiry(jwt); err != nil {
+		logrus.Debugf("The JWT is not cached: %v", err)
+	} else if s.store == nil {
+		s.jwt = jwt
+	} else if err := s.store.Put(s.key, jwt); err != nil {
+		logrus.Warn(err)
+	}
+
+	return jwt, nil
+}
@@ -0,0 +1,257 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testNow = time.Date(2026, 10, 17, 10, 15, 2, 0, time.UTC)
+
+// testJWT returns the unsigned JWT which expires at exp
+func testJWT(exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"username":"sd-local","exp":%d}`, exp.Unix())))
+	return header + "." + payload + ".signature"
+}
+
+func setupNow(t *testing.T) {
+	n := now
+	t.Cleanup(func() {
+		now = n
+	})
+	now = func() time.Time { return testNow }
+}
+
+func TestExpiry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		jwt       string
+		want      time.Time
+		wantError string
+	}{
+		{
+			name: "success",
+			jwt:  testJWT(testNow.Add(2 * time.Hour)),
+			want: testNow.Add(2 * time.Hour),
+		},
+		{
+			name:      "failure by parts",
+			jwt:       "jwt",
+			wantError: "failed to parse JWT: it must consist of 3 parts",
+		},
+		{
+			name:      "failure by payload",
+			jwt:       "header.!!!.signature",
+			wantError: "failed to parse JWT: illegal base64 data at input byte 0",
+		},
+		{
+			name:      "failure by no exp claim",
+			jwt:       "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"username":"sd-local"}`)) + ".signature",
+			wantError: "failed to parse JWT: it does not have the exp claim",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expiry(tt.jwt)
+			if tt.wantError != "" {
+				assert.EqualError(t, err, tt.wantError)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, tt.want.Equal(got))
+		})
+	}
+}
+
+func TestParseClaims(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"username":"sd-local","scope":["user"],"exp":1792232102}`))
+	claims, err := ParseClaims("header." + payload + ".signature")
+	assert.Nil(t, err)
+	assert.Equal(t, "sd-local", claims.Username)
+	assert.Equal(t, []string{"user"}, claims.Scope)
+	assert.True(t, time.Unix(1792232102, 0).Equal(claims.ExpiresAt))
+
+	claims, err = ParseClaims("header." + base64.RawURLEncoding.EncodeToString([]byte(`{"username":"sd-local"}`)) + ".signature")
+	assert.Nil(t, err)
+	assert.True(t, claims.ExpiresAt.IsZero())
+
+	_, err = ParseClaims("header." + base64.RawURLEncoding.EncodeToString([]byte(`{"scope":"user"}`)) + ".signature")
+	assert.EqualError(t, err, "failed to parse JWT: json: cannot unmarshal string into Go struct field .scope of type []string")
+}
+
+func TestStore(t *testing.T) {
+	key := Key{Config: "default", APIURL: "https://api.screwdriver.cd", UserToken: "user-token"}
+	valid := testJWT(testNow.Add(2 * time.Hour))
+
+	testCases := []struct {
+		name   string
+		jwt    string
+		key    Key
+		wantOK bool
+	}{
+		{
+			name:   "success",
+			jwt:    valid,
+			key:    key,
+			wantOK: true,
+		},
+		{
+			name: "expired soon",
+			jwt:  testJWT(testNow.Add(RefreshMargin)),
+			key:  key,
+		},
+		{
+			name: "other config",
+			jwt:  valid,
+			key:  Key{Config: "local", APIURL: key.APIURL, UserToken: key.UserToken},
+		},
+		{
+			name: "changed API URL",
+			jwt:  valid,
+			key:  Key{Config: key.Config, APIURL: "https://api.example.com", UserToken: key.UserToken},
+		},
+		{
+			name: "changed user token",
+			jwt:  valid,
+			key:  Key{Config: key.Config, APIURL: key.APIURL, UserToken: "new-user-token"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setupNow(t)
+			dir := filepath.Join(t.TempDir(), "tokens")
+			s := New(dir)
+
+			assert.Nil(t, s.Put(key, tt.jwt))
+
+			info, err := os.Stat(filepath.Join(dir, "default.json"))
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+			got, ok := s.Get(tt.key)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.jwt, got)
+			}
+		})
+	}
+
+	t.Run("failure by invalid config name", func(t *testing.T) {
+		s := New(t.TempDir())
+		assert.EqualError(t, s.Put(Key{Config: "../default"}, valid), "invalid config name `../default` for the JWT cache")
+		_, ok := s.Get(Key{Config: "../default"})
+		assert.False(t, ok)
+	})
+
+	t.Run("failure by JWT without exp", func(t *testing.T) {
+		s := New(t.TempDir())
+		assert.EqualError(t, s.Put(key, "jwt"), "failed to parse JWT: it must consist of 3 parts")
+	})
+
+	t.Run("success to delete", func(t *testing.T) {
+		setupNow(t)
+		s := New(t.TempDir())
+		assert.Nil(t, s.Put(key, valid))
+
+		assert.Nil(t, s.Delete("default"))
+		_, ok := s.Get(key)
+		assert.False(t, ok)
+
+		// The cache which does not exist is ignored
+		assert.Nil(t, s.Delete("default"))
+		assert.EqualError(t, s.Delete("../default"), "invalid config name `../default` for the JWT cache")
+	})
+}
+
+func TestSource(t *testing.T) {
+	key := Key{Config: "default", APIURL: "https://api.screwdriver.cd", UserToken: "user-token"}
+
+	t.Run("success with refresh", func(t *testing.T) {
+		setupNow(t)
+		store := New(t.TempDir())
+
+		fetched := 0
+		jwts := []string{testJWT(testNow.Add(time.Hour)), testJWT(testNow.Add(3 * time.Hour))}
+		s := NewSource(store, key, func() (string, error) {
+			fetched++
+			return jwts[fetched-1], nil
+		})
+
+		got, err := s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwts[0], got)
+
+		// The cached JWT is used until shortly before it expires
+		now = func() time.Time { return testNow.Add(50 * time.Minute) }
+		got, err = s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwts[0], got)
+		assert.Equal(t, 1, fetched)
+
+		now = func() time.Time { return testNow.Add(56 * time.Minute) }
+		got, err = s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwts[1], got)
+		assert.Equal(t, 2, fetched)
+	})
+
+	t.Run("success with refresh in memory without store", func(t *testing.T) {
+		setupNow(t)
+
+		fetched := 0
+		jwts := []string{testJWT(testNow.Add(time.Hour)), testJWT(testNow.Add(3 * time.Hour))}
+		s := NewSource(nil, key, func() (string, error) {
+			fetched++
+			return jwts[fetched-1], nil
+		})
+
+		got, err := s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwts[0], got)
+
+		now = func() time.Time { return testNow.Add(50 * time.Minute) }
+		got, err = s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwts[0], got)
+		assert.Equal(t, 1, fetched)
+
+		now = func() time.Time { return testNow.Add(56 * time.Minute) }
+		got, err = s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwts[1], got)
+		assert.Equal(t, 2, fetched)
+	})
+
+	t.Run("success with the JWT cached by another run", func(t *testing.T) {
+		setupNow(t)
+		store := New(t.TempDir())
+		jwt := testJWT(testNow.Add(time.Hour))
+		assert.Nil(t, store.Put(key, jwt))
+
+		s := NewSource(store, key, func() (string, error) {
+			return "", errors.New("must not be fetched")
+		})
+
+		got, err := s.JWT()
+		assert.Nil(t, err)
+		assert.Equal(t, jwt, got)
+	})
+
+	t.Run("failure by fetch", func(t *testing.T) {
+		s := NewSource(New(t.TempDir()), key, func() (string, error) {
+			return "", errors.New("failed to get JWT: StatusCode 500")
+		})
+
+		_, err := s.JWT()
+		assert.EqualError(t, err, "failed to get JWT: StatusCode 500")
+	})
+}
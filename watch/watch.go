@@ -0,0 +1,223 @@
+package watch
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GitignoreFile is the file in the watched directory whose patterns are ignored
+const GitignoreFile = ".gitignore"
+
+// Watcher notifies the changes of the files under a directory recursively.
+// The files matching .gitignore of the directory or the extra patterns are not watched.
+type Watcher struct {
+	root    string
+	ignore  []pattern
+	events  chan string
+	done    chan struct{}
+	close   func() error
+	closing sync.Once
+}
+
+// New starts watching the directory. The extra patterns follow the syntax of .gitignore.
+func New(root string, patterns []string) (*Watcher, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %v", root, err)
+	}
+
+	ignore, err := readGitignore(filepath.Join(root, GitignoreFile))
+	if err != nil {
+		return nil, err
+	}
+	// .git is always ignored since git commands change it without changing the source
+	ignore = append(append([]pattern{parsePattern(".git/")}, ignore...), parsePatterns(patterns)...)
+
+	w := &Watcher{
+		root:   root,
+		ignore: ignore,
+		events: make(chan string, 100),
+		done:   make(chan struct{}),
+	}
+
+	if err := w.start(); err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %v", root, err)
+	}
+
+	return w, nil
+}
+
+// Events returns the channel of the changed paths relative to the watched directory.
+// It is closed when the watcher is closed.
+func (w *Watcher) Events() <-chan string {
+	return w.events
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	var err error
+	w.closing.Do(func() {
+		close(w.done)
+		err = w.close()
+	})
+	return err
+}
+
+// notify sends the change unless the path is ignored
+func (w *Watcher) notify(absPath string, isDir bool) {
+	rel, err := filepath.Rel(w.root, absPath)
+	if err != nil || w.ignored(rel, isDir) {
+		return
+	}
+
+	select {
+	case w.events <- filepath.ToSlash(rel):
+	case <-w.done:
+	}
+}
+
+// walkDirs calls fn with the directories under the directory which are not ignored, including itself
+func (w *Watcher) walkDirs(dir string, fn func(string) error) error {
+	return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			// The directory may be removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		if rel, err := filepath.Rel(w.root, p); err == nil && rel != "." && w.ignored(rel, true) {
+			return filepath.SkipDir
+		}
+
+		return fn(p)
+	})
+}
+
+// ignored returns true when the path or one of its parent directories matches the ignore patterns
+func (w *Watcher) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		if match(w.ignore, prefix, isDir || i < len(parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// pattern is a line of .gitignore
+type pattern struct {
+	// segments are the globs of the path separated by "/", where "**" matches any number of directories
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+func parsePattern(line string) pattern {
+	p := pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// The pattern without "/" matches at any level, and the others are relative to the watched directory
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	p.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+
+	return p
+}
+
+func parsePatterns(lines []string) []pattern {
+	patterns := make([]pattern, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, parsePattern(line))
+	}
+	return patterns
+}
+
+func readGitignore(filePath string) ([]pattern, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %v", GitignoreFile, err)
+	}
+	defer f.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", GitignoreFile, err)
+	}
+
+	return parsePatterns(lines), nil
+}
+
+// match returns true when the path is ignored by the patterns. The last matching pattern wins like git.
+func match(patterns []pattern, rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+
+	ignored := false
+	for _, p := range patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		if matchSegments(p.segments, parts) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments returns true when the parts of the path match the segments of the pattern.
+// The leading and middle "**" match zero or more directories, and the trailing one matches everything inside.
+func matchSegments(segments, parts []string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+
+	if segments[0] == "**" {
+		if len(segments) == 1 {
+			return len(parts) > 0
+		}
+		for i := range parts {
+			if matchSegments(segments[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(segments[0], parts[0]); !matched {
+		return false
+	}
+	return matchSegments(segments[1:], parts[1:])
+}
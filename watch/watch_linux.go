@@ -0,0 +1,105 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+// start watches the directories with inotify
+func (w *Watcher) start() error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	// The non-blocking file is read through the runtime poller, so that Close stops the blocking Read
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	var mutex sync.Mutex
+	dirs := make(map[int]string)
+	addDir := func(dir string) error {
+		wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			// The directory may be removed before it is watched
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		mutex.Lock()
+		dirs[wd] = dir
+		mutex.Unlock()
+		return nil
+	}
+
+	if err := w.walkDirs(w.root, addDir); err != nil {
+		file.Close()
+		return err
+	}
+	w.close = file.Close
+
+	go func() {
+		defer close(w.events)
+
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				select {
+				case <-w.done:
+				default:
+					logrus.Warnf("failed to watch %s: %v", w.root, err)
+				}
+				return
+			}
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				mutex.Lock()
+				dir, ok := dirs[int(event.Wd)]
+				if event.Mask&unix.IN_IGNORED != 0 {
+					delete(dirs, int(event.Wd))
+				}
+				mutex.Unlock()
+				if !ok || event.Len == 0 {
+					continue
+				}
+
+				name := string(nameBytes[:clen(nameBytes)])
+				p := filepath.Join(dir, name)
+				isDir := event.Mask&unix.IN_ISDIR != 0
+
+				// The new directories are watched as well
+				if isDir && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					if err := w.walkDirs(p, addDir); err != nil {
+						logrus.Warnf("failed to watch %s: %v", p, err)
+					}
+				}
+
+				w.notify(p, isDir)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// clen returns the length of the NUL terminated name
+func clen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
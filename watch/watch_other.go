@@ -0,0 +1,86 @@
+//go:build !linux
+
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is the interval to scan the directory where inotify is not available
+var pollInterval = time.Second
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+// start watches the directories by scanning them periodically
+func (w *Watcher) start() error {
+	prev, err := w.scan()
+	if err != nil {
+		return err
+	}
+	w.close = func() error { return nil }
+
+	go func() {
+		defer close(w.events)
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+
+			current, err := w.scan()
+			if err != nil {
+				continue
+			}
+
+			for p, s := range current {
+				if old, ok := prev[p]; !ok || !old.modTime.Equal(s.modTime) || old.size != s.size {
+					w.notify(p, s.isDir)
+				}
+			}
+			for p, s := range prev {
+				if _, ok := current[p]; !ok {
+					w.notify(p, s.isDir)
+				}
+			}
+			prev = current
+		}
+	}()
+
+	return nil
+}
+
+func (w *Watcher) scan() (map[string]fileState, error) {
+	states := make(map[string]fileState)
+	err := w.walkDirs(w.root, func(dir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		for _, e := range entries {
+			var info fs.FileInfo
+			if info, err = e.Info(); err != nil {
+				continue
+			}
+			states[filepath.Join(dir, e.Name())] = fileState{modTime: info.ModTime(), size: info.Size(), isDir: e.IsDir()}
+		}
+		return nil
+	})
+
+	return states, err
+}
@@ -0,0 +1,113 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnored(t *testing.T) {
+	w := &Watcher{ignore: append(
+		[]pattern{parsePattern(".git/")},
+		parsePatterns([]string{
+			"# comment", "node_modules/", "*.log", "!keep.log", "/dist", "docs/**/*.md", "", "build/",
+			"vendor/**", "!vendor/keep/", "**/tmp/cache", "a/**/b", "out/**", "!out/report.html",
+		})...,
+	)}
+
+	testCases := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{rel: "index.js", want: false},
+		{rel: ".git", isDir: true, want: true},
+		{rel: ".git/HEAD", want: true},
+		{rel: "node_modules/lib/index.js", want: true},
+		{rel: "lib/node_modules/index.js", want: true},
+		{rel: "node_modules", want: false},
+		{rel: "debug.log", want: true},
+		{rel: "logs/debug.log", want: true},
+		{rel: "keep.log", want: false},
+		{rel: "dist/app.js", want: true},
+		{rel: "src/dist/app.js", want: false},
+		{rel: "docs/api/index.md", want: true},
+		{rel: "docs/index.md", want: true},
+		{rel: "docs/api/index.js", want: false},
+		{rel: "build", isDir: true, want: true},
+		{rel: "vendor", isDir: true, want: false},
+		{rel: "vendor/lib/index.js", want: true},
+		{rel: "vendor/keep", isDir: true, want: false},
+		{rel: "vendor/keep/index.js", want: true},
+		{rel: "tmp/cache", isDir: true, want: true},
+		{rel: "src/tmp/cache/file", want: true},
+		{rel: "src/tmp/file", want: false},
+		{rel: "a/b", want: true},
+		{rel: "a/x/y/b", want: true},
+		{rel: "x/a/b", want: false},
+		{rel: "out/app.js", want: true},
+		{rel: "out/report.html", want: false},
+		{rel: "out/sub/report.html", want: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.rel, func(t *testing.T) {
+			assert.Equal(t, tt.want, w.ignored(tt.rel, tt.isDir))
+		})
+	}
+}
+
+func waitEvent(t *testing.T, w *Watcher, want string) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("events are closed before %s", want)
+			}
+			if got == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event of %s", want)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(root, GitignoreFile), []byte("*.log\n"), 0666))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "src"), 0777))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "sd-artifacts"), 0777))
+
+	w, err := New(root, []string{"/sd-artifacts/"})
+	assert.Nil(t, err)
+
+	// The ignored files are written first, so the first event must be the watched one
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "build.log"), []byte("log"), 0666))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "sd-artifacts", "builds.log"), []byte("log"), 0666))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "src", "index.js"), []byte("console.log(1)"), 0666))
+
+	select {
+	case got := <-w.Events():
+		assert.Equal(t, "src/index.js", got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event of src/index.js")
+	}
+
+	// The files in the new directories are watched as well
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "src", "lib"), 0777))
+	waitEvent(t, w, "src/lib")
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "src", "lib", "util.js"), []byte("exports = {}"), 0666))
+	waitEvent(t, w, "src/lib/util.js")
+
+	assert.Nil(t, w.Close())
+	for range w.Events() {
+	}
+}